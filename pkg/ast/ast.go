@@ -166,11 +166,14 @@ func (d *Document) ReplaceFragmentSpread(selectionSet int, spreadRef int, replac
 
 // ReplaceFragmentSpreadWithInlineFragment replaces a given fragment spread with a inline fragment
 // attention! the same rules apply as for 'ReplaceFragmentSpread', look above!
+// The directives of the fragment spread, e.g. @defer, are kept on the inline fragment
 func (d *Document) ReplaceFragmentSpreadWithInlineFragment(selectionSet int, spreadRef int, replaceWithSelectionSet int, typeCondition TypeCondition) {
 	d.InlineFragments = append(d.InlineFragments, InlineFragment{
 		TypeCondition: typeCondition,
 		SelectionSet:  replaceWithSelectionSet,
 		HasSelections: len(d.SelectionSets[replaceWithSelectionSet].SelectionRefs) != 0,
+		HasDirectives: d.FragmentSpreads[spreadRef].HasDirectives,
+		Directives:    d.FragmentSpreads[spreadRef].Directives,
 	})
 	ref := len(d.InlineFragments) - 1
 	d.Selections = append(d.Selections, Selection{
@@ -368,14 +371,28 @@ func (d *Document) NodeNameBytes(node Node) ByteSlice {
 	switch node.Kind {
 	case NodeKindObjectTypeDefinition:
 		ref = d.ObjectTypeDefinitions[node.Ref].Name
+	case NodeKindObjectTypeExtension:
+		ref = d.ObjectTypeExtensions[node.Ref].Name
 	case NodeKindInterfaceTypeDefinition:
 		ref = d.InterfaceTypeDefinitions[node.Ref].Name
+	case NodeKindInterfaceTypeExtension:
+		ref = d.InterfaceTypeExtensions[node.Ref].Name
 	case NodeKindInputObjectTypeDefinition:
 		ref = d.InputObjectTypeDefinitions[node.Ref].Name
+	case NodeKindInputObjectTypeExtension:
+		ref = d.InputObjectTypeExtensions[node.Ref].Name
 	case NodeKindUnionTypeDefinition:
 		ref = d.UnionTypeDefinitions[node.Ref].Name
+	case NodeKindUnionTypeExtension:
+		ref = d.UnionTypeExtensions[node.Ref].Name
+	case NodeKindEnumTypeDefinition:
+		ref = d.EnumTypeDefinitions[node.Ref].Name
+	case NodeKindEnumTypeExtension:
+		ref = d.EnumTypeExtensions[node.Ref].Name
 	case NodeKindScalarTypeDefinition:
 		ref = d.ScalarTypeDefinitions[node.Ref].Name
+	case NodeKindScalarTypeExtension:
+		ref = d.ScalarTypeExtensions[node.Ref].Name
 	case NodeKindDirectiveDefinition:
 		ref = d.DirectiveDefinitions[node.Ref].Name
 	case NodeKindField:
@@ -525,7 +542,7 @@ func (d *Document) ExtendEnumTypeDefinitionByEnumTypeExtension(enumTypeDefinitio
 		d.EnumTypeDefinitions[enumTypeDefinitionRef].HasDirectives = true
 	}
 
-	if d.EnumTypeDefinitionHasEnumValueDefinition(enumTypeExtensionRef) {
+	if d.EnumTypeExtensionHasEnumValueDefinition(enumTypeExtensionRef) {
 		d.EnumTypeDefinitions[enumTypeDefinitionRef].EnumValuesDefinition.Refs = append(d.EnumTypeDefinitions[enumTypeDefinitionRef].EnumValuesDefinition.Refs, d.EnumTypeExtensions[enumTypeExtensionRef].EnumValuesDefinition.Refs...)
 		d.EnumTypeDefinitions[enumTypeDefinitionRef].HasEnumValuesDefinition = true
 	}
@@ -1023,6 +1040,10 @@ func (d *Document) TypeIsList(ref int) bool {
 	}
 }
 
+func (d *Document) TypeIsNonNull(ref int) bool {
+	return d.Types[ref].TypeKind == TypeKindNonNull
+}
+
 func (d *Document) TypesAreCompatibleDeep(left int, right int) bool {
 	for {
 		if left == -1 || right == -1 {
@@ -1275,6 +1296,8 @@ func (d *Document) NodeDirectives(node Node) []int {
 		return d.EnumTypeExtensions[node.Ref].Directives.Refs
 	case NodeKindFragmentDefinition:
 		return d.FragmentDefinitions[node.Ref].Directives.Refs
+	case NodeKindFieldDefinition:
+		return d.FieldDefinitions[node.Ref].Directives.Refs
 	case NodeKindInputValueDefinition:
 		return d.InputValueDefinitions[node.Ref].Directives.Refs
 	case NodeKindEnumValueDefinition:
@@ -1569,7 +1592,7 @@ func (d *Document) ValueContentBytes(value Value) ByteSlice {
 	case ValueKindEnum:
 		return d.EnumValueNameBytes(value.Ref)
 	case ValueKindString:
-		d.StringValueContentBytes(value.Ref)
+		return d.StringValueContentBytes(value.Ref)
 	case ValueKindInteger:
 		return d.IntValueRaw(value.Ref)
 	case ValueKindFloat:
@@ -2397,6 +2420,10 @@ func (d *Document) EnumTypeExtensionHasDirectives(ref int) bool {
 	return d.EnumTypeExtensions[ref].HasDirectives
 }
 
+func (d *Document) EnumTypeExtensionHasEnumValueDefinition(ref int) bool {
+	return d.EnumTypeExtensions[ref].HasEnumValuesDefinition
+}
+
 func (d *Document) EnumTypeExtensionNameBytes(ref int) ByteSlice {
 	return d.Input.ByteSlice(d.EnumTypeExtensions[ref].Name)
 }
@@ -2822,6 +2849,7 @@ func (d *Document) SelectionsAfterInlineFragment(inlineFragment int, selectionSe
 }
 
 type Field struct {
+	Position      position.Position  // position of the alias or name, e.g. renamed or id
 	Alias         Alias              // optional, e.g. renamed:
 	Name          ByteSliceReference // field name, e.g. id
 	HasArguments  bool
@@ -43,7 +43,7 @@ func (i *Importer) ImportValue(fromValue ast.Value, from, to *ast.Document) (val
 		value.Ref = len(to.FloatValues) - 1
 		return
 	case ast.ValueKindInteger:
-		to.FloatValues = append(to.FloatValues, ast.FloatValue{
+		to.IntValues = append(to.IntValues, ast.IntValue{
 			Raw:      to.Input.AppendInputBytes(from.IntValueRaw(fromValue.Ref)),
 			Negative: from.IntValueIsNegative(fromValue.Ref),
 		})
@@ -57,6 +57,7 @@ func (i *Importer) ImportValue(fromValue ast.Value, from, to *ast.Document) (val
 			BlockString: from.StringValueIsBlockString(fromValue.Ref),
 			Content:     to.Input.AppendInputBytes(from.StringValueContentBytes(fromValue.Ref)),
 		})
+		value.Ref = len(to.StringValues) - 1
 		return
 	case ast.ValueKindNull:
 		return
@@ -66,6 +67,31 @@ func (i *Importer) ImportValue(fromValue ast.Value, from, to *ast.Document) (val
 		})
 		value.Ref = len(to.EnumValues) - 1
 		return
+	case ast.ValueKindList:
+		list := ast.ListValue{
+			Refs: make([]int, len(from.ListValues[fromValue.Ref].Refs)),
+		}
+		for j, ref := range from.ListValues[fromValue.Ref].Refs {
+			to.Values = append(to.Values, i.ImportValue(from.Value(ref), from, to))
+			list.Refs[j] = len(to.Values) - 1
+		}
+		to.ListValues = append(to.ListValues, list)
+		value.Ref = len(to.ListValues) - 1
+		return
+	case ast.ValueKindObject:
+		object := ast.ObjectValue{
+			Refs: make([]int, len(from.ObjectValues[fromValue.Ref].Refs)),
+		}
+		for j, ref := range from.ObjectValues[fromValue.Ref].Refs {
+			to.ObjectFields = append(to.ObjectFields, ast.ObjectField{
+				Name:  to.Input.AppendInputBytes(from.ObjectFieldNameBytes(ref)),
+				Value: i.ImportValue(from.ObjectFieldValue(ref), from, to),
+			})
+			object.Refs[j] = len(to.ObjectFields) - 1
+		}
+		to.ObjectValues = append(to.ObjectValues, object)
+		value.Ref = len(to.ObjectValues) - 1
+		return
 	case ast.ValueKindVariable:
 		to.VariableValues = append(to.VariableValues, ast.VariableValue{
 			Name: to.Input.AppendInputBytes(from.VariableValueNameBytes(fromValue.Ref)),
@@ -118,7 +144,7 @@ func (i *Importer) ImportVariableDefinition(ref int, from, to *ast.Document) int
 
 func (i *Importer) ImportVariableDefinitions(refs []int, from, to *ast.Document) []int {
 	definitions := make([]int, len(refs))
-	for j, k := range definitions {
+	for j, k := range refs {
 		definitions[j] = i.ImportVariableDefinition(k, from, to)
 	}
 	return definitions
@@ -52,6 +52,61 @@ func TestImporter_ImportType(t *testing.T) {
 	}
 }
 
+func TestImporter_ImportVariableDefinitions(t *testing.T) {
+
+	from, report := astparser.ParseGraphqlDocumentString(`query q($id: ID!, $first: Int = 10, $filter: [Filter!]) {user}`)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	to := &ast.Document{}
+	importer := &Importer{}
+	refs := importer.ImportVariableDefinitions(from.OperationDefinitions[0].VariableDefinitions.Refs, &from, to)
+
+	want := []string{"$id: ID!", "$first: Int = 10", "$filter: [Filter!]"}
+	if len(refs) != len(want) {
+		t.Fatalf("want %d variable definitions, got: %d", len(want), len(refs))
+	}
+	for i, ref := range refs {
+		typeBytes, err := to.PrintTypeBytes(to.VariableDefinitions[ref].Type, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := "$" + string(to.VariableDefinitionNameBytes(ref)) + ": " + string(typeBytes)
+		if to.VariableDefinitions[ref].DefaultValue.IsDefined {
+			valueBytes, err := to.PrintValueBytes(to.VariableDefinitions[ref].DefaultValue.Value, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got += " = " + string(valueBytes)
+		}
+		if got != want[i] {
+			t.Fatalf("want: %s\ngot: %s", want[i], got)
+		}
+	}
+}
+
+func TestImporter_ImportArguments(t *testing.T) {
+
+	from, report := astparser.ParseGraphqlDocumentString(`{users(id: "1", first: 10, filter: {name: {in: ["a", "b"]}, active: true, role: ADMIN}, after: $cursor)}`)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	to := ast.NewDocument()
+	importer := &Importer{}
+	refs := importer.ImportArguments(from.FieldArguments(0), &from, to)
+
+	buf := bytes.Buffer{}
+	if err := to.PrintArguments(refs, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `(id: "1", first: 10, filter: {name: {in: ["a","b"]},active: true,role: ADMIN}, after: $cursor)`
+	if buf.String() != want {
+		t.Fatalf("want: %s\ngot: %s", want, buf.String())
+	}
+}
+
 /*func ExampleImporter_ImportType() {
 	typeBytes := []byte("String!")
 	doc := ast.Document{}
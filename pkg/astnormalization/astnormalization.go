@@ -68,7 +68,10 @@ Output:
 package astnormalization
 
 import (
+	"bytes"
 	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/astparser"
+	"github.com/jensneuse/graphql-go-tools/pkg/astprinter"
 	"github.com/jensneuse/graphql-go-tools/pkg/astvisitor"
 	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
 )
@@ -121,3 +124,59 @@ func (o *OperationNormalizer) NormalizeOperation(operation, definition *ast.Docu
 		o.walkers[i].Walk(operation, definition, report)
 	}
 }
+
+// NormalizeDefinition creates a default DefinitionNormalizer and applies all rules to a given schema definition AST
+// In case you're using DefinitionNormalizer in a hot path you shouldn't be using this function.
+// Create a new DefinitionNormalizer using NewDefinitionNormalizer() instead and re-use it.
+func NormalizeDefinition(definition *ast.Document, report *operationreport.Report) {
+	normalizer := NewDefinitionNormalizer()
+	normalizer.NormalizeDefinition(definition, report)
+}
+
+// DefinitionNormalizer walks a given schema definition AST and merges all type extensions into their type definitions
+type DefinitionNormalizer struct {
+	walker *astvisitor.Walker
+}
+
+// NewDefinitionNormalizer creates a new DefinitionNormalizer and sets up all default rules
+func NewDefinitionNormalizer() *DefinitionNormalizer {
+	walker := astvisitor.NewWalker(48)
+	extendObjectTypeDefinition(&walker)
+	extendInterfaceTypeDefinition(&walker)
+	extendInputObjectTypeDefinition(&walker)
+	extendEnumTypeDefinition(&walker)
+	extendUnionTypeDefinition(&walker)
+	extendScalarTypeDefinition(&walker)
+	removeMergedTypeExtensions(&walker)
+	return &DefinitionNormalizer{
+		walker: &walker,
+	}
+}
+
+// NormalizeDefinition applies all registered rules to the schema definition AST
+func (d *DefinitionNormalizer) NormalizeDefinition(definition *ast.Document, report *operationreport.Report) {
+	d.walker.Walk(definition, nil, report)
+}
+
+// MergeTypeExtensions parses the schema, merges its type extensions into their type definitions and prints it indented
+// It returns nil if the schema is invalid, the errors get added to the report
+func MergeTypeExtensions(schema []byte, report *operationreport.Report) []byte {
+	definition := ast.NewDocument()
+	definition.Input.ResetInputBytes(schema)
+	astparser.NewParser().Parse(definition, report)
+	if report.HasErrors() {
+		return nil
+	}
+
+	NormalizeDefinition(definition, report)
+	if report.HasErrors() {
+		return nil
+	}
+
+	out := bytes.Buffer{}
+	if err := astprinter.PrintIndent(definition, nil, []byte("  "), &out); err != nil {
+		report.AddInternalError(err)
+		return nil
+	}
+	return out.Bytes()
+}
@@ -112,6 +112,80 @@ func TestNormalizeOperation(t *testing.T) {
 	})
 }
 
+func TestNormalizeDefinition(t *testing.T) {
+
+	run := func(definition, expectedOutput string) {
+		definitionDocument := unsafeparser.ParseGraphqlDocumentString(definition)
+		expectedOutputDocument := unsafeparser.ParseGraphqlDocumentString(expectedOutput)
+		report := operationreport.Report{}
+
+		NormalizeDefinition(&definitionDocument, &report)
+
+		if report.HasErrors() {
+			t.Fatal(report.Error())
+		}
+
+		got := mustString(astprinter.PrintString(&definitionDocument, nil))
+		want := mustString(astprinter.PrintString(&expectedOutputDocument, nil))
+
+		if want != got {
+			panic(fmt.Errorf("\nwant:\n%s\ngot:\n%s", want, got))
+		}
+	}
+
+	t.Run("type extensions of all kinds", func(t *testing.T) {
+		run(`
+				type User { id: ID! }
+				extend type User { name: String }
+				interface Node { id: ID! }
+				extend interface Node { name: String }
+				input UserInput { id: ID! }
+				extend input UserInput { name: String }
+				enum Role { ADMIN }
+				extend enum Role { CUSTOMER }
+				union SearchResult = User
+				extend union SearchResult = Review
+				scalar Date
+				extend scalar Date @deprecated(reason: "use String")`, `
+				type User { id: ID! name: String }
+				interface Node { id: ID! name: String }
+				input UserInput { id: ID! name: String }
+				enum Role { ADMIN CUSTOMER }
+				union SearchResult = User | Review
+				scalar Date @deprecated(reason: "use String")`)
+	})
+	t.Run("extension without definition", func(t *testing.T) {
+		run(`
+				extend type Query { me: User }
+				type User { id: ID! }`, `
+				extend type Query { me: User }
+				type User { id: ID! }`)
+	})
+}
+
+func TestMergeTypeExtensions(t *testing.T) {
+	t.Run("merges type extensions", func(t *testing.T) {
+		report := operationreport.Report{}
+		got := MergeTypeExtensions([]byte(`
+			type Query { me: User }
+			extend type Query { users: [User] }
+			type User { id: ID! }`), &report)
+		if report.HasErrors() {
+			t.Fatal(report.Error())
+		}
+		want := "type Query {\n    me: User\n    users: [User]\n}\n\ntype User {\n    id: ID!\n}"
+		if string(got) != want {
+			t.Fatalf("want:\n%s\ngot:\n%s", want, string(got))
+		}
+	})
+	t.Run("invalid schema", func(t *testing.T) {
+		report := operationreport.Report{}
+		if got := MergeTypeExtensions([]byte(`type Query {`), &report); got != nil || !report.HasErrors() {
+			t.Fatalf("want no schema and errors, got: %s", string(got))
+		}
+	})
+}
+
 func BenchmarkAstNormalization(b *testing.B) {
 
 	definition := unsafeparser.ParseGraphqlDocumentString(testDefinition)
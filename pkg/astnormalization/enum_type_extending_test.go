@@ -21,6 +21,17 @@ func TestExtendEnumType(t *testing.T) {
 					extend enum Countries {EN}
 					`)
 	})
+	t.Run("extend enum type by enum values with another enum type without values", func(t *testing.T) {
+		run(extendEnumTypeDefinition, testDefinition, `
+					enum Empty
+					enum Countries {DE ES NL}
+					extend enum Countries {EN}
+					 `, `
+					enum Empty
+					enum Countries {DE ES NL EN}
+					extend enum Countries {EN}
+					`)
+	})
 	t.Run("extend enum type by multiple enum values and directives", func(t *testing.T) {
 		run(extendEnumTypeDefinition, testDefinition, `
 					enum Countries {DE ES NL}
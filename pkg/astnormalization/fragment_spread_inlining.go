@@ -101,9 +101,14 @@ func (f *fragmentSpreadInlineVisitor) EnterFragmentSpread(ref int) {
 	replaceWith := f.operation.FragmentDefinitions[fragmentDefinitionRef].SelectionSet
 	typeCondition := f.operation.FragmentDefinitions[fragmentDefinitionRef].TypeCondition
 
+	// an inline fragment keeps the directives of the spread, e.g. @include(if: $var) or @defer
+	keepDirectives := f.operation.FragmentSpreads[ref].HasDirectives && f.operation.FragmentDefinitions[fragmentDefinitionRef].HasSelections
+
 	switch {
-	case fragmentTypeEqualsParentType || enclosingTypeImplementsFragmentType:
+	case (fragmentTypeEqualsParentType || enclosingTypeImplementsFragmentType) && !keepDirectives:
 		f.transformer.ReplaceFragmentSpread(precedence, selectionSet, ref, replaceWith)
+	case fragmentTypeEqualsParentType || enclosingTypeImplementsFragmentType:
+		f.transformer.ReplaceFragmentSpreadWithInlineFragment(precedence, selectionSet, ref, replaceWith, typeCondition)
 	case fragmentTypeImplementsEnclosingType || fragmentTypeIsMemberOfEnclosingUnionType || enclosingTypeIsMemberOfFragmentUnion || fragmentUnionIntersectsEnclosingInterface:
 		f.transformer.ReplaceFragmentSpreadWithInlineFragment(precedence, selectionSet, ref, replaceWith, typeCondition)
 	}
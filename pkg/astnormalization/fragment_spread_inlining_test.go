@@ -401,4 +401,25 @@ func TestInlineFragments(t *testing.T) {
 					name
 				}`)
 	})
+	t.Run("spread with directives", func(t *testing.T) {
+		run(fragmentSpreadInline, testDefinition, `
+				query conditionalDog($withName: Boolean!) {
+					dog {
+						...dogFragment @include(if: $withName)
+					}
+				}
+				fragment dogFragment on Dog {
+					name
+				}`, `
+				query conditionalDog($withName: Boolean!) {
+					dog {
+						... on Dog @include(if: $withName) {
+							name
+						}
+					}
+				}
+				fragment dogFragment on Dog {
+					name
+				}`)
+	})
 }
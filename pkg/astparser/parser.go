@@ -1547,6 +1547,8 @@ func (p *Parser) parseField() int {
 		p.errUnexpectedToken(p.tokens[firstIdent], keyword.IDENT)
 	}
 
+	field.Position = p.tokens[firstIdent].TextPosition
+
 	if p.peek() == keyword.COLON {
 		field.Alias.IsDefined = true
 		field.Alias.Name = p.tokens[firstIdent].Literal
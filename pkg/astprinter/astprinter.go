@@ -122,6 +122,16 @@ func (p *printVisitor) must(err error) {
 }
 
 func (p *printVisitor) EnterDirective(ref int) {
+	ancestor := p.Ancestors[len(p.Ancestors)-1]
+	if p.document.DirectiveIsFirst(ref, ancestor) {
+		switch ancestor.Kind {
+		case ast.NodeKindFieldDefinition:
+			p.writeFieldDefinitionType(ancestor.Ref)
+			p.write(literal.SPACE)
+		case ast.NodeKindInputValueDefinition, ast.NodeKindEnumValueDefinition:
+			p.write(literal.SPACE)
+		}
+	}
 	p.write(literal.AT)
 	p.write(p.document.DirectiveNameBytes(ref))
 }
@@ -158,7 +168,10 @@ func (p *printVisitor) LeaveDirective(ref int) {
 		ast.NodeKindUnionTypeDefinition,
 		ast.NodeKindUnionTypeExtension,
 		ast.NodeKindEnumTypeDefinition,
-		ast.NodeKindEnumTypeExtension:
+		ast.NodeKindEnumTypeExtension,
+		ast.NodeKindFieldDefinition,
+		ast.NodeKindInputValueDefinition,
+		ast.NodeKindEnumValueDefinition:
 		return
 	default:
 		p.write(literal.SPACE)
@@ -432,9 +445,10 @@ func (p *printVisitor) EnterFieldDefinition(ref int) {
 }
 
 func (p *printVisitor) LeaveFieldDefinition(ref int) {
-	p.write(literal.COLON)
-	p.write(literal.SPACE)
-	p.must(p.document.PrintType(p.document.FieldDefinitionType(ref), p.out))
+	if !p.document.FieldDefinitions[ref].HasDirectives {
+		// the type of fields with directives gets printed in front of the directives
+		p.writeFieldDefinitionType(ref)
+	}
 	if p.document.FieldDefinitionIsLast(ref, p.Ancestors[len(p.Ancestors)-1]) {
 		if p.indent != nil {
 			p.write(literal.LINETERMINATOR)
@@ -449,6 +463,12 @@ func (p *printVisitor) LeaveFieldDefinition(ref int) {
 	}
 }
 
+func (p *printVisitor) writeFieldDefinitionType(ref int) {
+	p.write(literal.COLON)
+	p.write(literal.SPACE)
+	p.must(p.document.PrintType(p.document.FieldDefinitionType(ref), p.out))
+}
+
 func (p *printVisitor) EnterInputValueDefinition(ref int) {
 	if p.document.InputValueDefinitionIsFirst(ref, p.Ancestors[len(p.Ancestors)-1]) {
 		p.write(p.inputValueDefinitionOpener)
@@ -832,6 +852,8 @@ func (p *printVisitor) EnterDirectiveDefinition(ref int) {
 	p.write(literal.AT)
 	p.write(p.document.DirectiveDefinitionNameBytes(ref))
 	p.isFirstDirectiveLocation = true
+	p.inputValueDefinitionOpener = literal.LPAREN
+	p.inputValueDefinitionCloser = literal.RPAREN
 }
 
 func (p *printVisitor) LeaveDirectiveDefinition(ref int) {
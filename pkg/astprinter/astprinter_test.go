@@ -191,6 +191,24 @@ func TestPrint(t *testing.T) {
 					BAZ
 				}`, `extend enum Foo @foo {BAR BAZ}`)
 	})
+	t.Run("directives on field definitions", func(t *testing.T) {
+		run(`
+				type Foo {
+					bar(baz: String @deprecated, qux: Int = 1 @foo @bar): String @deprecated(reason: "no bar") @foo
+					baz: Int! @foo
+					qux: String
+				}
+				enum Bar {
+					BAZ @deprecated
+					QUX
+				}`, `type Foo {bar(baz: String @deprecated qux: Int = 1 @foo @bar): String @deprecated(reason: "no bar") @foo baz: Int! @foo qux: String} enum Bar {BAZ @deprecated QUX}`)
+	})
+	t.Run("directive definition", func(t *testing.T) {
+		run(`
+				directive @cacheControl(maxAge: Int) on FIELD_DEFINITION
+				input Foo {bar: String}
+				directive @deprecated(reason: String) on FIELD_DEFINITION`, `directive @cacheControl(maxAge: Int) on FIELD_DEFINITION input Foo {bar: String} directive @deprecated(reason: String) on FIELD_DEFINITION`)
+	})
 }
 
 func TestPrintSchemaDefinition(t *testing.T) {
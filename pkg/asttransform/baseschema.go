@@ -143,6 +143,22 @@ directive @skip(
     "Skipped when true."
     if: Boolean!
 ) on FIELD | FRAGMENT_SPREAD | INLINE_FRAGMENT
+"Directs the executor to deliver this fragment incrementally after the initial response."
+directive @defer(
+    "Identifies the fragment in the incremental response."
+    label: String
+    "Deferred when true."
+    if: Boolean = true
+) on FRAGMENT_SPREAD | INLINE_FRAGMENT
+"Directs the executor to deliver the items of this list field incrementally after the initial response."
+directive @stream(
+    "Identifies the list in the incremental response."
+    label: String
+    "The number of items to deliver with the initial response."
+    initialCount: Int = 0
+    "Streamed when true."
+    if: Boolean = true
+) on FIELD
 "Marks an element of a GraphQL schema as no longer supported."
 directive @deprecated(
     """
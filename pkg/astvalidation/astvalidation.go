@@ -1338,7 +1338,8 @@ func (a *allVariablesUsedVisitor) EnterDocument(operation, definition *ast.Docum
 }
 
 func (a *allVariablesUsedVisitor) EnterOperationDefinition(ref int) {
-	a.variableDefinitions = a.operation.OperationDefinitions[ref].VariableDefinitions.Refs
+	// copy the refs as used variables get removed from the slice which must not modify the operation
+	a.variableDefinitions = append(a.variableDefinitions[:0], a.operation.OperationDefinitions[ref].VariableDefinitions.Refs...)
 }
 
 func (a *allVariablesUsedVisitor) LeaveOperationDefinition(ref int) {
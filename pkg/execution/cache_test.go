@@ -0,0 +1,411 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	log "github.com/jensneuse/abstractlogger"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCachingDataSource(t *testing.T) {
+
+	resolve := func(source datasource.DataSource, args ResolvedArgs) (string, error) {
+		out := bytes.Buffer{}
+		_, err := source.Resolve(context.Background(), args, &out)
+		return out.String(), err
+	}
+
+	args := func(id string) ResolvedArgs {
+		return ResolvedArgs{
+			{
+				Key:   []byte("id"),
+				Value: []byte(id),
+			},
+		}
+	}
+
+	t.Run("cache hit", func(t *testing.T) {
+		flaky := &flakyDataSource{}
+		source := datasource.NewCachingDataSource(flaky, datasource.NewInMemoryCache(10), time.Minute, "query.foo")
+		for i := 0; i < 2; i++ {
+			out, err := resolve(source, args("1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != `{"foo":"bar"}` {
+				t.Fatalf("unexpected output: %s", out)
+			}
+		}
+		if flaky.calls != 1 {
+			t.Fatalf("want 1 call, got: %d", flaky.calls)
+		}
+		if _, err := resolve(source, args("2")); err != nil {
+			t.Fatal(err)
+		}
+		if flaky.calls != 2 {
+			t.Fatalf("want a call for different args, got: %d calls", flaky.calls)
+		}
+	})
+	t.Run("identity", func(t *testing.T) {
+		flaky := &flakyDataSource{}
+		cache := datasource.NewInMemoryCache(10)
+		_, _ = resolve(datasource.NewCachingDataSource(flaky, cache, time.Minute, "query.foo"), args("1"))
+		_, _ = resolve(datasource.NewCachingDataSource(flaky, cache, time.Minute, "query.bar"), args("1"))
+		if flaky.calls != 2 {
+			t.Fatalf("want a call per identity, got: %d calls", flaky.calls)
+		}
+	})
+	t.Run("errors don't get cached", func(t *testing.T) {
+		flaky := &flakyDataSource{failures: 1}
+		source := datasource.NewCachingDataSource(flaky, datasource.NewInMemoryCache(10), time.Minute, "query.foo")
+		if _, err := resolve(source, args("1")); err == nil {
+			t.Fatal("want err")
+		}
+		for i := 0; i < 2; i++ {
+			out, err := resolve(source, args("1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != `{"foo":"bar"}` {
+				t.Fatalf("want output of successful call only, got: %s", out)
+			}
+		}
+		if flaky.calls != 2 {
+			t.Fatalf("want 2 calls, got: %d", flaky.calls)
+		}
+	})
+	t.Run("batch", func(t *testing.T) {
+		batch := &batchDataSource{}
+		source, ok := datasource.NewCachingDataSource(batch, datasource.NewInMemoryCache(10), time.Minute, "query.user").(datasource.BatchDataSource)
+		if !ok {
+			t.Fatal("want caching data source to support batching")
+		}
+		resolveBatch := func(ids ...string) []string {
+			items := make([]datasource.ResolverArgs, len(ids))
+			bufs := make([]bytes.Buffer, len(ids))
+			outs := make([]io.Writer, len(ids))
+			for i := range ids {
+				items[i] = args(ids[i])
+				outs[i] = &bufs[i]
+			}
+			if _, err := source.ResolveBatch(context.Background(), items, outs); err != nil {
+				t.Fatal(err)
+			}
+			results := make([]string, len(ids))
+			for i := range bufs {
+				results[i] = bufs[i].String()
+			}
+			return results
+		}
+		resolveBatch("1", "2")
+		got := resolveBatch("2", "3")
+		if want := []string{`{"name":"user-2"}`, `{"name":"user-3"}`}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("want: %v, got: %v", want, got)
+		}
+		if want := [][]string{{"1", "2"}, {"3"}}; !reflect.DeepEqual(batch.batches, want) {
+			t.Fatalf("want only items missing in the cache to be resolved: %v, got: %v", want, batch.batches)
+		}
+		if _, ok := datasource.NewCachingDataSource(&flakyDataSource{}, datasource.NewInMemoryCache(10), time.Minute, "query.foo").(datasource.BatchDataSource); ok {
+			t.Fatal("want caching data source not to support batching")
+		}
+	})
+	t.Run("upstream errors get replayed", func(t *testing.T) {
+		partial := &partialDataSource{}
+		source := datasource.NewCachingDataSource(partial, datasource.NewInMemoryCache(10), time.Minute, "query.foo")
+		for i := 0; i < 2; i++ {
+			upstreamErrors := &datasource.UpstreamErrors{}
+			out := bytes.Buffer{}
+			if _, err := source.Resolve(datasource.WithUpstreamErrors(context.Background(), upstreamErrors), args("1"), &out); err != nil {
+				t.Fatal(err)
+			}
+			if got := upstreamErrors.Item(0); len(got) != 1 || got[0].Message != "partial" {
+				t.Fatalf("want upstream error of the cached call, got: %+v", got)
+			}
+		}
+		if partial.calls != 1 {
+			t.Fatalf("want 1 call, got: %d", partial.calls)
+		}
+	})
+	t.Run("lru", func(t *testing.T) {
+		flaky := &flakyDataSource{}
+		source := datasource.NewCachingDataSource(flaky, datasource.NewInMemoryCache(1), time.Minute, "query.foo")
+		_, _ = resolve(source, args("1"))
+		_, _ = resolve(source, args("2"))
+		_, _ = resolve(source, args("1"))
+		if flaky.calls != 3 {
+			t.Fatalf("want evicted entry to be resolved again, got: %d calls", flaky.calls)
+		}
+	})
+}
+
+// partialDataSource reports an UpstreamError next to its data without forbidding to cache the result
+type partialDataSource struct {
+	calls int
+}
+
+func (p *partialDataSource) Resolve(ctx context.Context, args datasource.ResolverArgs, out io.Writer) (n int, err error) {
+	p.calls++
+	datasource.UpstreamErrorsFromContext(ctx).Add(0, datasource.UpstreamError{Message: "partial"})
+	return out.Write([]byte(`{"foo":null}`))
+}
+
+func TestCachingDataSource_HttpJsonDataSource(t *testing.T) {
+
+	test := func(cacheControl string, wantRequests, wantRevalidations int) func(t *testing.T) {
+		return func(t *testing.T) {
+			mux := sync.Mutex{}
+			requests, revalidations := 0, 0
+			fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mux.Lock()
+				defer mux.Unlock()
+				requests++
+				w.Header().Set("ETag", `"v1"`)
+				w.Header().Set("Cache-Control", cacheControl)
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					revalidations++
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				_, _ = w.Write([]byte(`{"foo":"bar"}`))
+			}))
+			defer fakeServer.Close()
+
+			source := datasource.NewCachingDataSource(&datasource.HttpJsonDataSource{
+				Log: log.NoopLogger,
+			}, datasource.NewInMemoryCache(10), time.Minute, "query.foo")
+			args := ResolvedArgs{
+				{
+					Key:   []byte("host"),
+					Value: []byte(fakeServer.URL),
+				},
+				{
+					Key:   []byte("url"),
+					Value: []byte("/"),
+				},
+				{
+					Key:   []byte("method"),
+					Value: []byte("GET"),
+				},
+			}
+			for i := 0; i < 3; i++ {
+				out := bytes.Buffer{}
+				if _, err := source.Resolve(context.Background(), args, &out); err != nil {
+					t.Fatal(err)
+				}
+				if out.String() != `{"foo":"bar"}` {
+					t.Fatalf("unexpected output: %s", out.String())
+				}
+			}
+			if requests != wantRequests || revalidations != wantRevalidations {
+				t.Fatalf("want %d requests and %d revalidations, got: %d requests and %d revalidations", wantRequests, wantRevalidations, requests, revalidations)
+			}
+		}
+	}
+
+	t.Run("max-age", test("max-age=60", 1, 0))
+	t.Run("s-maxage overrides max-age", test("max-age=60, s-maxage=0", 3, 2))
+	t.Run("no-cache revalidates using the etag", test("no-cache", 3, 2))
+	t.Run("no-store", test("no-store", 3, 0))
+	t.Run("private", test("private, max-age=60", 3, 0))
+}
+
+func TestCachingDataSource_ForwardedHeaders(t *testing.T) {
+
+	requests := 0
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"authorization":"` + r.Header.Get("Authorization") + `"}`))
+	}))
+	defer fakeServer.Close()
+
+	source := datasource.NewCachingDataSource(&datasource.HttpJsonDataSource{
+		Log: log.NoopLogger,
+		HeaderForwarding: datasource.HeaderForwarding{
+			Request: datasource.HeaderRules{
+				Allow: append([]string{"Authorization"}, datasource.TracingHeaders...),
+			},
+		},
+	}, datasource.NewInMemoryCache(10), time.Minute, "query.foo")
+
+	resolve := func(request string) string {
+		args := ResolvedArgs{
+			{
+				Key:   []byte("host"),
+				Value: []byte(fakeServer.URL),
+			},
+			{
+				Key:   []byte("url"),
+				Value: []byte("/"),
+			},
+			{
+				Key:   []byte("method"),
+				Value: []byte("GET"),
+			},
+			{
+				Key:   []byte("request"),
+				Value: []byte(request),
+			},
+		}
+		out := bytes.Buffer{}
+		if _, err := source.Resolve(context.Background(), args, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	first := resolve(`{"headers":{"Authorization":"alice","User-Agent":"curl","X-Request-Id":"1"}}`)
+	second := resolve(`{"headers":{"Authorization":"alice","User-Agent":"wget","X-Request-Id":"2"}}`)
+	if first != `{"authorization":"alice"}` || second != first {
+		t.Fatalf("want cached result for the same forwarded headers, got: %s, %s", first, second)
+	}
+	if requests != 1 {
+		t.Fatalf("want headers which don't get forwarded and tracing headers to share the cached result, got: %d requests", requests)
+	}
+	if third := resolve(`{"headers":{"Authorization":"bob"}}`); third != `{"authorization":"bob"}` {
+		t.Fatalf("want result per forwarded Authorization header, got: %s", third)
+	}
+	if requests != 2 {
+		t.Fatalf("want 2 requests, got: %d", requests)
+	}
+}
+
+func TestCachingDataSource_ResponseHeaders(t *testing.T) {
+
+	requests := 0
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("X-Version", "1")
+		w.Header().Add("Set-Cookie", "session=1")
+		_, _ = w.Write([]byte(`{"foo":"bar"}`))
+	}))
+	defer fakeServer.Close()
+
+	source := datasource.NewCachingDataSource(&datasource.HttpJsonDataSource{
+		Log: log.NoopLogger,
+		HeaderForwarding: datasource.HeaderForwarding{
+			Response: datasource.HeaderRules{
+				Allow: []string{"X-Version", "Set-Cookie"},
+			},
+		},
+	}, datasource.NewInMemoryCache(10), time.Minute, "query.foo")
+
+	args := ResolvedArgs{
+		{
+			Key:   []byte("host"),
+			Value: []byte(fakeServer.URL),
+		},
+		{
+			Key:   []byte("url"),
+			Value: []byte("/"),
+		},
+		{
+			Key:   []byte("method"),
+			Value: []byte("GET"),
+		},
+	}
+	for i := 0; i < 2; i++ {
+		responseHeaders := &datasource.ResponseHeaders{}
+		out := bytes.Buffer{}
+		if _, err := source.Resolve(datasource.WithResponseHeaders(context.Background(), responseHeaders), args, &out); err != nil {
+			t.Fatal(err)
+		}
+		header := http.Header{}
+		responseHeaders.CopyTo(header)
+		if header.Get("X-Version") != "1" || header.Get("Set-Cookie") != "session=1" {
+			t.Fatalf("want forwarded response headers of the cached call, got: %v", header)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("want 1 request, got: %d", requests)
+	}
+}
+
+func TestHandler_ResponseCache(t *testing.T) {
+
+	handler := func(schema string) *Handler {
+		base, err := datasource.NewBaseDataSourcePlanner([]byte(schema), datasource.PlannerConfiguration{
+			TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+				{
+					TypeName:  "query",
+					FieldName: "hello",
+					Mapping: &datasource.MappingConfiguration{
+						Disabled: true,
+					},
+					DataSource: datasource.SourceConfig{
+						Name: "StaticDataSource",
+						Config: toJSON(datasource.StaticDataSourceConfig{
+							Data: "world",
+						}),
+					},
+				},
+			},
+		}, log.NoopLogger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+		return NewHandler(base, nil)
+	}
+
+	fetchedDataSource := func(handler *Handler) datasource.DataSource {
+		executor, node, ctx, err := handler.Handle([]byte(`{"query":"{ hello }"}`), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := bytes.Buffer{}
+		if err := executor.Execute(ctx, node, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != `{"data":{"hello":"world"}}` {
+			t.Fatalf("unexpected response: %s", out.String())
+		}
+		return node.(*Object).Fields[0].Value.(*Object).Fetch.(*SingleFetch).Source.DataSource
+	}
+
+	cachedSchema := `
+		schema {
+			query: Query
+		}
+		type Query {
+			hello: String @cacheControl(maxAge: 60)
+		}`
+
+	t.Run("cache control", func(t *testing.T) {
+		source, ok := fetchedDataSource(handler(cachedSchema)).(*datasource.CachingDataSource)
+		if !ok {
+			t.Fatalf("want *datasource.CachingDataSource, got: %T", source)
+		}
+		if source.MaxAge != time.Minute || source.Identity != "query.hello" {
+			t.Fatalf("unexpected caching data source: %+v", source)
+		}
+		if _, ok := source.DataSource.(*datasource.StaticDataSource); !ok {
+			t.Fatalf("want *datasource.StaticDataSource to be wrapped, got: %T", source.DataSource)
+		}
+	})
+	t.Run("no cache control", func(t *testing.T) {
+		source := fetchedDataSource(handler(`
+		schema {
+			query: Query
+		}
+		type Query {
+			hello: String
+		}`))
+		if _, ok := source.(*datasource.CachingDataSource); ok {
+			t.Fatal("want fields without @cacheControl not to be cached")
+		}
+	})
+	t.Run("response cache disabled", func(t *testing.T) {
+		disabled := handler(cachedSchema)
+		disabled.SetResponseCache(nil)
+		if _, ok := fetchedDataSource(disabled).(*datasource.CachingDataSource); ok {
+			t.Fatal("want no caching without a response cache")
+		}
+	})
+}
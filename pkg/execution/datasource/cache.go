@@ -0,0 +1,335 @@
+package datasource
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"github.com/cespare/xxhash"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache is the storage of a CachingDataSource, implement it to keep the cached results in an external store
+// Implementations must be safe to be used from multiple goroutines
+type Cache interface {
+	// Get returns the entry stored for the key, expired entries may be returned to allow revalidation using their ETag
+	Get(key uint64) (CacheEntry, bool)
+	// Set stores the entry for the key, implementations may keep entries with an ETag beyond their expiry
+	Set(key uint64, entry CacheEntry)
+}
+
+// CacheEntry is a cached result of a DataSource
+type CacheEntry struct {
+	Data []byte
+	// ETag identifies the result at the upstream, it's empty if the upstream doesn't support revalidation
+	ETag    string
+	Expires time.Time
+	// Header are the forwarded response headers of the call which produced the result
+	Header http.Header
+	// UpstreamErrors are the UpstreamErrors the DataSource reported for the result
+	UpstreamErrors []UpstreamError
+}
+
+// replay passes the forwarded response headers and the UpstreamErrors of the entry on to the caller as if the DataSource was called
+func (e CacheEntry) replay(ctx context.Context, item int) {
+	ResponseHeadersFromContext(ctx).add(e.Header)
+	UpstreamErrorsFromContext(ctx).Add(item, e.UpstreamErrors...)
+}
+
+// CacheHint lets a DataSource pass the caching rules of its upstream to the CachingDataSource calling it
+// DataSources get the CacheHint of the current call with CacheHintFromContext
+type CacheHint struct {
+	// IfNoneMatch is the ETag of the expired cached result, DataSources supporting revalidation pass it to the upstream
+	IfNoneMatch string
+	// NotModified must be set if the upstream confirmed that the expired result is still valid
+	// Nothing should be written to out in this case
+	NotModified bool
+	// ETag identifies the result returned by the upstream
+	ETag string
+	// MaxAge limits how long the result may be cached, it's only used if HasMaxAge is set
+	MaxAge    time.Duration
+	HasMaxAge bool
+	// NoStore forbids caching the result
+	NoStore bool
+}
+
+type cacheHintKey struct{}
+
+// CacheHintFromContext returns the CacheHint of the call, it's nil if the DataSource isn't called by a CachingDataSource
+func CacheHintFromContext(ctx context.Context) *CacheHint {
+	hint, _ := ctx.Value(cacheHintKey{}).(*CacheHint)
+	return hint
+}
+
+// cacheCall records the side effects of a call to the cached DataSource so that they can be stored with the result
+type cacheCall struct {
+	hint           CacheHint
+	header         ResponseHeaders
+	upstreamErrors UpstreamErrors
+}
+
+// context returns a copy of ctx which makes the DataSource report its CacheHint, forwarded headers and UpstreamErrors to the call
+func (c *cacheCall) context(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, cacheHintKey{}, &c.hint)
+	ctx = WithResponseHeaders(ctx, &c.header)
+	return WithUpstreamErrors(ctx, &c.upstreamErrors)
+}
+
+// entry creates the CacheEntry of the result of the item
+func (c *cacheCall) entry(data []byte, item int) CacheEntry {
+	entry := CacheEntry{
+		Data:           append([]byte(nil), data...),
+		ETag:           c.hint.ETag,
+		UpstreamErrors: c.upstreamErrors.Item(item),
+	}
+	if len(c.header.header) != 0 {
+		entry.Header = http.Header{}
+		c.header.CopyTo(entry.Header)
+	}
+	return entry
+}
+
+// CachingDataSource serves the results of a DataSource from a Cache
+// Results are cached per Identity and resolved args for MaxAge unless the DataSource sets a shorter max age with a CacheHint
+// Results of DataSources forwarding client headers are cached per forwarded headers, other parts of the client request aren't part of the key
+// Only successful calls get cached, expired results with an ETag get revalidated with the upstream
+// The forwarded response headers and UpstreamErrors of a call are cached together with its result and get replayed on each hit
+type CachingDataSource struct {
+	DataSource DataSource
+	Cache      Cache
+	MaxAge     time.Duration
+	// Identity distinguishes the results of DataSources which might get called with the same args, e.g. the type and field name
+	Identity string
+	now      func() time.Time
+}
+
+// NewCachingDataSource wraps the DataSource with a CachingDataSource
+// The returned DataSource implements BatchDataSource if the wrapped DataSource does
+func NewCachingDataSource(source DataSource, cache Cache, maxAge time.Duration, identity string) DataSource {
+	caching := CachingDataSource{
+		DataSource: source,
+		Cache:      cache,
+		MaxAge:     maxAge,
+		Identity:   identity,
+		now:        time.Now,
+	}
+	if _, ok := source.(BatchDataSource); ok {
+		return &CachingBatchDataSource{
+			CachingDataSource: caching,
+		}
+	}
+	return &caching
+}
+
+func (c *CachingDataSource) Resolve(ctx context.Context, args ResolverArgs, out io.Writer) (n int, err error) {
+	key := c.key(args)
+	now := c.now()
+	entry, cached := c.Cache.Get(key)
+	if cached && now.Before(entry.Expires) {
+		entry.replay(ctx, 0)
+		return out.Write(entry.Data)
+	}
+
+	call := &cacheCall{}
+	if cached {
+		call.hint.IfNoneMatch = entry.ETag
+	}
+	buf := bytes.Buffer{}
+	_, err = c.DataSource.Resolve(call.context(ctx), args, &buf)
+	if err != nil {
+		call.entry(nil, 0).replay(ctx, 0)
+		return 0, err
+	}
+
+	if cached && call.hint.NotModified {
+		if revalidated := call.entry(nil, 0); len(revalidated.Header) != 0 {
+			entry.Header = revalidated.Header
+		}
+		entry.Expires = now.Add(c.maxAge(&call.hint))
+		c.Cache.Set(key, entry)
+		entry.replay(ctx, 0)
+		return out.Write(entry.Data)
+	}
+
+	entry = call.entry(buf.Bytes(), 0)
+	maxAge := c.maxAge(&call.hint)
+	if !call.hint.NoStore && (maxAge > 0 || call.hint.ETag != "") {
+		entry.Expires = now.Add(maxAge)
+		c.Cache.Set(key, entry)
+	}
+	entry.replay(ctx, 0)
+	return out.Write(entry.Data)
+}
+
+func (c *CachingDataSource) Deduplicable() bool {
+	return IsDeduplicable(c.DataSource)
+}
+
+func (c *CachingDataSource) Unwrap() DataSource {
+	return c.DataSource
+}
+
+func (c *CachingDataSource) maxAge(hint *CacheHint) time.Duration {
+	if hint.HasMaxAge && hint.MaxAge < c.MaxAge {
+		return hint.MaxAge
+	}
+	return c.MaxAge
+}
+
+// CachingBatchDataSource is the CachingDataSource for DataSources implementing BatchDataSource
+// Cached items are served from the Cache, only the remaining items get resolved with a single call to ResolveBatch
+// Expired items aren't revalidated as an ETag of the whole batch doesn't identify the result of a single item
+type CachingBatchDataSource struct {
+	CachingDataSource
+}
+
+func (c *CachingBatchDataSource) ResolveBatch(ctx context.Context, args []ResolverArgs, outs []io.Writer) (n int, err error) {
+	now := c.now()
+	keys := make([]uint64, len(args))
+	entries := make([]CacheEntry, len(args))
+	var missing []int
+	for i := range args {
+		keys[i] = c.key(args[i])
+		entry, cached := c.Cache.Get(keys[i])
+		if cached && now.Before(entry.Expires) {
+			entries[i] = entry
+			continue
+		}
+		missing = append(missing, i)
+	}
+
+	if len(missing) != 0 {
+		missingArgs := make([]ResolverArgs, len(missing))
+		bufs := make([]bytes.Buffer, len(missing))
+		writers := make([]io.Writer, len(missing))
+		for j, i := range missing {
+			missingArgs[j] = args[i]
+			writers[j] = &bufs[j]
+		}
+		call := &cacheCall{}
+		_, err = c.DataSource.(BatchDataSource).ResolveBatch(call.context(ctx), missingArgs, writers)
+		maxAge := c.maxAge(&call.hint)
+		store := err == nil && !call.hint.NoStore && maxAge > 0
+		for j, i := range missing {
+			entries[i] = call.entry(bufs[j].Bytes(), j)
+			entries[i].ETag = ""
+			if store {
+				entries[i].Expires = now.Add(maxAge)
+				c.Cache.Set(keys[i], entries[i])
+			}
+		}
+		if err != nil {
+			for _, i := range missing {
+				entries[i].replay(ctx, i)
+			}
+			return 0, err
+		}
+	}
+
+	for i := range outs {
+		entries[i].replay(ctx, i)
+		written, err := outs[i].Write(entries[i].Data)
+		n += written
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// key hashes the Identity and all resolved args
+// The client request only reaches the upstream through the forwarded headers, so instead of the "request" arg
+// the headers forwarded by the DataSource get hashed, e.g. results are cached per forwarded Authorization header
+// Tracing headers don't change the result, they're left out so that each traced request doesn't get its own entry
+func (c *CachingDataSource) key(args ResolverArgs) uint64 {
+	digest := xxhash.New()
+	_, _ = digest.Write([]byte(c.Identity))
+	for _, key := range args.Keys() {
+		if bytes.Equal(key, literal.REQUEST) {
+			continue
+		}
+		_, _ = digest.Write([]byte{0})
+		_, _ = digest.Write(key)
+		_, _ = digest.Write([]byte{0})
+		_, _ = digest.Write(args.ByKey(key))
+	}
+	forwarder, ok := Unwrap(c.DataSource).(headerForwarder)
+	if !ok {
+		return digest.Sum64()
+	}
+	header := http.Header{}
+	forwarder.headerForwarding().Request.forwardRequestHeaders(args.ByKey(literal.REQUEST), header)
+	names := make([]string, 0, len(header))
+	for name := range header {
+		if !containsHeader(TracingHeaders, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, _ = digest.Write([]byte{0})
+		_, _ = digest.Write([]byte(name))
+		_, _ = digest.Write([]byte{0})
+		_, _ = digest.Write([]byte(header.Get(name)))
+	}
+	return digest.Sum64()
+}
+
+// InMemoryCache is a bounded LRU Cache
+type InMemoryCache struct {
+	mux     sync.Mutex
+	size    int
+	entries map[uint64]*list.Element
+	order   *list.List
+}
+
+type inMemoryCacheEntry struct {
+	key   uint64
+	entry CacheEntry
+}
+
+// NewInMemoryCache creates an InMemoryCache keeping up to size entries
+func NewInMemoryCache(size int) *InMemoryCache {
+	return &InMemoryCache{
+		size:    size,
+		entries: make(map[uint64]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+func (c *InMemoryCache) Get(key uint64) (CacheEntry, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*inMemoryCacheEntry).entry, true
+}
+
+func (c *InMemoryCache) Set(key uint64, entry CacheEntry) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*inMemoryCacheEntry).entry = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&inMemoryCacheEntry{
+		key:   key,
+		entry: entry,
+	})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*inMemoryCacheEntry).key)
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/jensneuse/graphql-go-tools/pkg/astparser"
 	"github.com/jensneuse/graphql-go-tools/pkg/asttransform"
 	"github.com/jensneuse/graphql-go-tools/pkg/astvisitor"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
 	"io"
+	"time"
 )
 
 type ResolverArgs interface {
@@ -22,6 +24,52 @@ type DataSource interface {
 	Resolve(ctx context.Context, args ResolverArgs, out io.Writer) (n int, err error)
 }
 
+// BatchDataSource is a DataSource which is able to resolve the args of multiple list items with a single upstream call
+// ResolveBatch must write the result for args[i] to outs[i]
+type BatchDataSource interface {
+	DataSource
+	ResolveBatch(ctx context.Context, args []ResolverArgs, outs []io.Writer) (n int, err error)
+}
+
+// DeduplicableDataSource is a DataSource whose results only depend on the args it gets called with
+// Within the execution of a query the Executor calls it only once for identical args and shares the result
+type DeduplicableDataSource interface {
+	DataSource
+	// Deduplicable reports if calls with identical args may share one result
+	Deduplicable() bool
+}
+
+// IsDeduplicable reports if the DataSource implements DeduplicableDataSource and calls with identical args may share one result
+func IsDeduplicable(source DataSource) bool {
+	deduplicable, ok := source.(DeduplicableDataSource)
+	return ok && deduplicable.Deduplicable()
+}
+
+// WrappingDataSource is a DataSource adding behaviour to the DataSource it wraps, e.g. caching or retries
+type WrappingDataSource interface {
+	DataSource
+	// Unwrap returns the wrapped DataSource
+	Unwrap() DataSource
+}
+
+// Unwrap returns the innermost DataSource of the WrappingDataSources wrapping source
+func Unwrap(source DataSource) DataSource {
+	for {
+		wrapping, ok := source.(WrappingDataSource)
+		if !ok {
+			return source
+		}
+		source = wrapping.Unwrap()
+	}
+}
+
+// NamedDataSource is a DataSource which knows the name of the upstream it calls
+type NamedDataSource interface {
+	DataSource
+	// UpstreamName returns the configured name of the upstream, empty if there's none
+	UpstreamName() string
+}
+
 type Planner interface {
 	CorePlanner
 	PlannerVisitors
@@ -50,6 +98,14 @@ type PlannerVisitors interface {
 	astvisitor.LeaveFieldVisitor
 }
 
+// RequiredFieldsPlanner is a Planner which is able to resolve additional fields of the object it's currently planning
+// The RequiredFields of a field get added to the Planner of the enclosing field, e.g. the key fields of a federated entity
+type RequiredFieldsPlanner interface {
+	Planner
+	// AddRequiredFields adds the fields to the selection set currently being planned unless they're selected already
+	AddRequiredFields(fieldNames []string)
+}
+
 type PlannerFactory interface {
 	DataSourcePlanner() Planner
 }
@@ -65,6 +121,8 @@ type BasePlanner struct {
 	Args                  []Argument           // nolint
 	RootField             rootField            // nolint
 	Config                PlannerConfiguration // nolint
+	// circuitBreakers are the CircuitBreakers of the upstreams, see circuitBreaker
+	circuitBreakers map[string]*CircuitBreaker
 }
 
 func NewBaseDataSourcePlanner(schema []byte, config PlannerConfiguration, logger abstractlogger.Logger) (*BasePlanner, error) {
@@ -89,6 +147,8 @@ func (b *BasePlanner) Configure(operation, definition *ast.Document, walker *ast
 	b.Operation, b.Definition, b.Walker = operation, definition, walker
 }
 
+// RegisterDataSourcePlannerFactory initializes the factory for all TypeFieldConfigurations of the DataSource
+// TypeFieldConfigurations with a resilience configuration share one CircuitBreaker per upstream
 func (b *BasePlanner) RegisterDataSourcePlannerFactory(dataSourceName string, factory PlannerFactoryFactory) (err error) {
 	for i := range b.Config.TypeFieldConfigurations {
 		if dataSourceName != b.Config.TypeFieldConfigurations[i].DataSource.Name {
@@ -99,12 +159,42 @@ func (b *BasePlanner) RegisterDataSourcePlannerFactory(dataSourceName string, fa
 		if err != nil {
 			return err
 		}
+		if resilience := b.Config.TypeFieldConfigurations[i].DataSource.Resilience; resilience != nil {
+			circuitBreaker := b.circuitBreaker(b.Config.TypeFieldConfigurations[i], *resilience)
+			b.Config.TypeFieldConfigurations[i].DataSourcePlannerFactory = NewResilientPlannerFactory(b.Config.TypeFieldConfigurations[i].DataSourcePlannerFactory, *resilience, circuitBreaker)
+		}
 	}
 	return nil
 }
 
+// circuitBreaker returns the CircuitBreaker shared by all fields calling the same upstream, nil if circuit breaking is disabled
+// The upstream is identified by the Upstream of the TypeFieldConfiguration,
+// fields without an Upstream call the same upstream if their DataSource kind and config are equal
+// The first configuration of an upstream defines the threshold and the cooldown of its CircuitBreaker
+func (b *BasePlanner) circuitBreaker(config TypeFieldConfiguration, resilience ResilienceConfiguration) *CircuitBreaker {
+	if resilience.CircuitBreakerThreshold <= 0 {
+		return nil
+	}
+	key := "upstream:" + config.Upstream
+	if config.Upstream == "" {
+		key = "source:" + config.DataSource.Name + ":" + string(config.DataSource.Config)
+	}
+	if b.circuitBreakers == nil {
+		b.circuitBreakers = map[string]*CircuitBreaker{}
+	}
+	circuitBreaker, ok := b.circuitBreakers[key]
+	if !ok {
+		circuitBreaker = NewCircuitBreaker(resilience.CircuitBreakerThreshold, time.Duration(resilience.CircuitBreakerCooldownMilliseconds)*time.Millisecond)
+		b.circuitBreakers[key] = circuitBreaker
+	}
+	return circuitBreaker
+}
+
 type PlannerConfiguration struct {
 	TypeFieldConfigurations []TypeFieldConfiguration
+	// TypeResolvers configure how the concrete types of interfaces and unions get resolved
+	// for upstreams which don't return the "__typename" of their objects
+	TypeResolvers []TypeResolverConfiguration
 }
 
 type TypeFieldConfiguration struct {
@@ -113,6 +203,17 @@ type TypeFieldConfiguration struct {
 	Mapping                  *MappingConfiguration
 	DataSource               SourceConfig `json:"data_source"`
 	DataSourcePlannerFactory PlannerFactory
+	// Batch enables resolving the field for all items of a list with a single call to the DataSource
+	// Batch only has an effect if the DataSource implements BatchDataSource
+	Batch bool `json:"batch"`
+	// Upstream identifies the upstream resolving the field (optional)
+	// If the enclosing field gets resolved by the same Upstream the field is resolved by its DataSource instead of an own one
+	// Without a DataSource the field can only be resolved by the DataSource of an enclosing field of the same Upstream,
+	// such a field may be configured once for each Upstream defining it
+	Upstream string `json:"upstream"`
+	// RequiredFields are the fields of the enclosing object the DataSource depends on, e.g. the key fields of a federated entity
+	// They get resolved by the DataSource of the enclosing field even if the client didn't select them (optional)
+	RequiredFields []string `json:"required_fields"`
 }
 
 type SourceConfig struct {
@@ -122,6 +223,9 @@ type SourceConfig struct {
 	// Config is the DataSource specific configuration object
 	// Each Planner needs to make sure to parse their Config Object correctly
 	Config json.RawMessage `json:"dataSourceConfig"`
+	// Resilience configures timeouts, retries and circuit breaking for the DataSource (optional)
+	// Resilience must not be used for stream DataSources as their calls are long lived
+	Resilience *ResilienceConfiguration `json:"resilience"`
 }
 
 type MappingConfiguration struct {
@@ -147,6 +251,53 @@ func (p *PlannerConfiguration) MappingForTypeField(typeName, fieldName string) *
 	return nil
 }
 
+func (p *PlannerConfiguration) BatchForTypeField(typeName, fieldName string) bool {
+	for i := range p.TypeFieldConfigurations {
+		if p.TypeFieldConfigurations[i].TypeName == typeName && p.TypeFieldConfigurations[i].FieldName == fieldName {
+			return p.TypeFieldConfigurations[i].Batch
+		}
+	}
+	return false
+}
+
+func (p *PlannerConfiguration) UpstreamForTypeField(typeName, fieldName string) string {
+	for i := range p.TypeFieldConfigurations {
+		if p.TypeFieldConfigurations[i].TypeName == typeName && p.TypeFieldConfigurations[i].FieldName == fieldName {
+			return p.TypeFieldConfigurations[i].Upstream
+		}
+	}
+	return ""
+}
+
+// UpstreamsForTypeField returns the Upstreams of all TypeFieldConfigurations of the field
+func (p *PlannerConfiguration) UpstreamsForTypeField(typeName, fieldName string) (upstreams []string) {
+	for i := range p.TypeFieldConfigurations {
+		if p.TypeFieldConfigurations[i].TypeName == typeName && p.TypeFieldConfigurations[i].FieldName == fieldName && p.TypeFieldConfigurations[i].Upstream != "" {
+			upstreams = append(upstreams, p.TypeFieldConfigurations[i].Upstream)
+		}
+	}
+	return upstreams
+}
+
+func (p *PlannerConfiguration) RequiredFieldsForTypeField(typeName, fieldName string) []string {
+	for i := range p.TypeFieldConfigurations {
+		if p.TypeFieldConfigurations[i].TypeName == typeName && p.TypeFieldConfigurations[i].FieldName == fieldName {
+			return p.TypeFieldConfigurations[i].RequiredFields
+		}
+	}
+	return nil
+}
+
+// TypeResolverForType returns the TypeResolver of the interface or union typeName, nil if there's none configured
+func (p *PlannerConfiguration) TypeResolverForType(typeName string) TypeResolver {
+	for i := range p.TypeResolvers {
+		if p.TypeResolvers[i].TypeName == typeName {
+			return p.TypeResolvers[i].typeResolver()
+		}
+	}
+	return nil
+}
+
 type rootField struct {
 	isDefined bool
 	ref       int
@@ -194,6 +345,12 @@ func (c *ContextVariableArgument) ArgName() []byte {
 	return c.Name
 }
 
+// JSONVariableName returns the name the JSON value of a variable is stored with in addition to the variable itself
+// Variables hold the contents of string values, values of custom scalars can be of any JSON type so they must be forwarded from their JSON variable
+func JSONVariableName(name []byte) []byte {
+	return append(append([]byte{}, name...), literal.DOT_JSON...)
+}
+
 type PathSelector struct {
 	Path string
 }
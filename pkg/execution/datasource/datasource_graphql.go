@@ -4,22 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/buger/jsonparser"
 	log "github.com/jensneuse/abstractlogger"
 	"github.com/jensneuse/graphql-go-tools/internal/pkg/unsafebytes"
 	"github.com/jensneuse/graphql-go-tools/pkg/ast"
 	"github.com/jensneuse/graphql-go-tools/pkg/astimport"
+	"github.com/jensneuse/graphql-go-tools/pkg/astparser"
 	"github.com/jensneuse/graphql-go-tools/pkg/astprinter"
 	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type GraphqlRequest struct {
-	OperationName string          `json:"operation_name"`
+	OperationName string          `json:"operationName,omitempty"`
 	Variables     json.RawMessage `json:"variables"`
 	Query         string          `json:"query"`
 }
@@ -32,6 +36,13 @@ type GraphQLDataSourceConfig struct {
 	URL string
 	// Method is the http.Method of the upstream, defaults to POST (optional)
 	Method *string
+	// Name identifies the upstream in the "extensions" of the errors it returns (optional)
+	Name string
+	// HeaderForwarding configures the headers forwarded between the client and the upstream (optional)
+	HeaderForwarding HeaderForwarding
+	// Entity resolves the field as a field of a federated entity using the "_entities" root field of the upstream (optional)
+	// The representations of the entities consist of the RequiredFields of the field which must contain the key fields
+	Entity bool
 }
 
 type GraphQLDataSourcePlanner struct {
@@ -40,6 +51,10 @@ type GraphQLDataSourcePlanner struct {
 	nodes                   []ast.Node
 	resolveDocument         *ast.Document
 	dataSourceConfiguration GraphQLDataSourceConfig
+	// entityTypeName is the name of the entity the root field belongs to if the upstream resolves it using "_entities"
+	entityTypeName string
+	// representationFields are the fields of the entity the upstream gets to identify the entity
+	representationFields []string
 }
 
 type GraphQLDataSourcePlannerFactoryFactory struct{}
@@ -62,7 +77,7 @@ func (g *GraphQLDataSourcePlannerFactory) DataSourcePlanner() Planner {
 		BasePlanner:             g.base,
 		importer:                &astimport.Importer{},
 		dataSourceConfiguration: g.config,
-		resolveDocument:         &ast.Document{},
+		resolveDocument:         ast.NewDocument(),
 	}
 }
 
@@ -133,6 +148,10 @@ func (g *GraphQLDataSourcePlanner) EnterField(ref int) {
 		fieldNameStr := g.Operation.FieldNameString(ref)
 		fieldName := g.Operation.FieldNameBytes(ref)
 		mapping := g.Config.MappingForTypeField(typeName, fieldNameStr)
+		if g.dataSourceConfiguration.Entity {
+			g.entityTypeName = typeName
+			g.representationFields = g.Config.RequiredFieldsForTypeField(typeName, fieldNameStr)
+		}
 		if mapping != nil && !mapping.Disabled {
 			fieldName = unsafebytes.StringToBytes(mapping.Path)
 		}
@@ -163,20 +182,15 @@ func (g *GraphQLDataSourcePlanner) EnterField(ref int) {
 		}
 		g.resolveDocument.SelectionSets = append(g.resolveDocument.SelectionSets, set)
 		setRef := len(g.resolveDocument.SelectionSets) - 1
-		hasVariableDefinitions := len(g.Operation.OperationDefinitions[g.Walker.Ancestors[0].Ref].VariableDefinitions.Refs) != 0
-		var variableDefinitionsRefs []int
-		if hasVariableDefinitions {
-			variableDefinitionsRefs = g.importer.ImportVariableDefinitions(g.Operation.OperationDefinitions[g.Walker.Ancestors[0].Ref].VariableDefinitions.Refs, g.Operation, g.resolveDocument)
+		operationSetRef := setRef
+		if g.entityTypeName != "" {
+			operationSetRef = g.entitiesSelectionSet(setRef)
 		}
 		operationDefinition := ast.OperationDefinition{
-			Name:          g.resolveDocument.Input.AppendInputBytes([]byte("o")),
+			Name:          g.resolveDocument.Input.AppendInputBytes(g.operationName()),
 			OperationType: g.Operation.OperationDefinitions[g.Walker.Ancestors[0].Ref].OperationType,
-			SelectionSet:  setRef,
+			SelectionSet:  operationSetRef,
 			HasSelections: true,
-			VariableDefinitions: ast.VariableDefinitionList{
-				Refs: variableDefinitionsRefs,
-			},
-			HasVariableDefinitions: hasVariableDefinitions,
 		}
 		g.resolveDocument.OperationDefinitions = append(g.resolveDocument.OperationDefinitions, operationDefinition)
 		operationDefinitionRef := len(g.resolveDocument.OperationDefinitions) - 1
@@ -197,8 +211,17 @@ func (g *GraphQLDataSourcePlanner) EnterField(ref int) {
 			Ref:  fieldRef,
 		})
 	} else {
+		hasArguments := g.Operation.FieldHasArguments(ref)
+		var argumentRefs []int
+		if hasArguments {
+			argumentRefs = g.importer.ImportArguments(g.Operation.FieldArguments(ref), g.Operation, g.resolveDocument)
+		}
 		field := ast.Field{
 			Name: g.resolveDocument.Input.AppendInputBytes(g.Operation.FieldNameBytes(ref)),
+			Arguments: ast.ArgumentList{
+				Refs: argumentRefs,
+			},
+			HasArguments: hasArguments,
 		}
 		g.resolveDocument.Fields = append(g.resolveDocument.Fields, field)
 		fieldRef := len(g.resolveDocument.Fields) - 1
@@ -217,6 +240,107 @@ func (g *GraphQLDataSourcePlanner) EnterField(ref int) {
 	}
 }
 
+// entitiesSelectionSet wraps the selection set of the root field into the selection set of an operation resolving the entity
+// e.g. "reviews {body}" of the entity User becomes "_entities(representations: $representations){... on User {reviews {body}}}"
+func (g *GraphQLDataSourcePlanner) entitiesSelectionSet(rootFieldSetRef int) int {
+	g.resolveDocument.InlineFragments = append(g.resolveDocument.InlineFragments, ast.InlineFragment{
+		TypeCondition: ast.TypeCondition{
+			Type: g.resolveDocument.AddNamedType([]byte(g.entityTypeName)),
+		},
+		HasSelections: true,
+		SelectionSet:  rootFieldSetRef,
+	})
+	g.resolveDocument.Selections = append(g.resolveDocument.Selections, ast.Selection{
+		Kind: ast.SelectionKindInlineFragment,
+		Ref:  len(g.resolveDocument.InlineFragments) - 1,
+	})
+	g.resolveDocument.SelectionSets = append(g.resolveDocument.SelectionSets, ast.SelectionSet{
+		SelectionRefs: []int{len(g.resolveDocument.Selections) - 1},
+	})
+	g.resolveDocument.VariableValues = append(g.resolveDocument.VariableValues, ast.VariableValue{
+		Name: g.resolveDocument.Input.AppendInputBytes(literal.REPRESENTATIONS),
+	})
+	g.resolveDocument.Arguments = append(g.resolveDocument.Arguments, ast.Argument{
+		Name: g.resolveDocument.Input.AppendInputBytes(literal.REPRESENTATIONS),
+		Value: ast.Value{
+			Kind: ast.ValueKindVariable,
+			Ref:  len(g.resolveDocument.VariableValues) - 1,
+		},
+	})
+	g.resolveDocument.Fields = append(g.resolveDocument.Fields, ast.Field{
+		Name: g.resolveDocument.Input.AppendInputBytes(literal.ENTITIES),
+		Arguments: ast.ArgumentList{
+			Refs: []int{len(g.resolveDocument.Arguments) - 1},
+		},
+		HasArguments:  true,
+		HasSelections: true,
+		SelectionSet:  len(g.resolveDocument.SelectionSets) - 1,
+	})
+	g.resolveDocument.Selections = append(g.resolveDocument.Selections, ast.Selection{
+		Kind: ast.SelectionKindField,
+		Ref:  len(g.resolveDocument.Fields) - 1,
+	})
+	g.resolveDocument.SelectionSets = append(g.resolveDocument.SelectionSets, ast.SelectionSet{
+		SelectionRefs: []int{len(g.resolveDocument.Selections) - 1},
+	})
+	return len(g.resolveDocument.SelectionSets) - 1
+}
+
+// representationsVariableDefinition adds the definition "$representations: [_Any!]!" of the representations of the entities
+func (g *GraphQLDataSourcePlanner) representationsVariableDefinition() int {
+	anyType := g.resolveDocument.AddNonNullNamedType(literal.ANY)
+	g.resolveDocument.Types = append(g.resolveDocument.Types, ast.Type{
+		TypeKind: ast.TypeKindList,
+		OfType:   anyType,
+	})
+	g.resolveDocument.Types = append(g.resolveDocument.Types, ast.Type{
+		TypeKind: ast.TypeKindNonNull,
+		OfType:   len(g.resolveDocument.Types) - 1,
+	})
+	g.resolveDocument.VariableValues = append(g.resolveDocument.VariableValues, ast.VariableValue{
+		Name: g.resolveDocument.Input.AppendInputBytes(literal.REPRESENTATIONS),
+	})
+	g.resolveDocument.VariableDefinitions = append(g.resolveDocument.VariableDefinitions, ast.VariableDefinition{
+		VariableValue: ast.Value{
+			Kind: ast.ValueKindVariable,
+			Ref:  len(g.resolveDocument.VariableValues) - 1,
+		},
+		Type: len(g.resolveDocument.Types) - 1,
+	})
+	return len(g.resolveDocument.VariableDefinitions) - 1
+}
+
+// AddRequiredFields adds the fields to the selection set of the upstream query currently being planned
+func (g *GraphQLDataSourcePlanner) AddRequiredFields(fieldNames []string) {
+	if len(g.nodes) == 0 || g.nodes[len(g.nodes)-1].Kind != ast.NodeKindSelectionSet {
+		return
+	}
+	set := g.nodes[len(g.nodes)-1].Ref
+	for _, fieldName := range fieldNames {
+		if g.selectionSetHasField(set, fieldName) {
+			continue
+		}
+		g.resolveDocument.Fields = append(g.resolveDocument.Fields, ast.Field{
+			Name: g.resolveDocument.Input.AppendInputString(fieldName),
+		})
+		g.resolveDocument.Selections = append(g.resolveDocument.Selections, ast.Selection{
+			Kind: ast.SelectionKindField,
+			Ref:  len(g.resolveDocument.Fields) - 1,
+		})
+		g.resolveDocument.SelectionSets[set].SelectionRefs = append(g.resolveDocument.SelectionSets[set].SelectionRefs, len(g.resolveDocument.Selections)-1)
+	}
+}
+
+func (g *GraphQLDataSourcePlanner) selectionSetHasField(set int, fieldName string) bool {
+	for _, selectionRef := range g.resolveDocument.SelectionSets[set].SelectionRefs {
+		selection := g.resolveDocument.Selections[selectionRef]
+		if selection.Kind == ast.SelectionKindField && g.resolveDocument.FieldNameString(selection.Ref) == fieldName && !g.resolveDocument.Fields[selection.Ref].Alias.IsDefined {
+			return true
+		}
+	}
+	return false
+}
+
 func (g *GraphQLDataSourcePlanner) LeaveField(ref int) {
 	defer func() {
 		g.nodes = g.nodes[:len(g.nodes)-1]
@@ -224,6 +348,16 @@ func (g *GraphQLDataSourcePlanner) LeaveField(ref int) {
 	if g.RootField.ref != ref {
 		return
 	}
+	variableDefinitions := g.usedVariableDefinitions()
+	operation := &g.resolveDocument.OperationDefinitions[g.nodes[0].Ref]
+	if len(variableDefinitions) != 0 {
+		operation.VariableDefinitions.Refs = g.importer.ImportVariableDefinitions(variableDefinitions, g.Operation, g.resolveDocument)
+		operation.HasVariableDefinitions = true
+	}
+	if g.entityTypeName != "" {
+		operation.VariableDefinitions.Refs = append(operation.VariableDefinitions.Refs, g.representationsVariableDefinition())
+		operation.HasVariableDefinitions = true
+	}
 	buff := bytes.Buffer{}
 	err := astprinter.Print(g.resolveDocument, nil, &buff)
 	if err != nil {
@@ -249,28 +383,156 @@ func (g *GraphQLDataSourcePlanner) LeaveField(ref int) {
 		})
 	} else {
 		g.Args = append(g.Args, &StaticVariableArgument{
-			Name:  literal.URL,
+			Name:  literal.METHOD,
 			Value: []byte(*g.dataSourceConfiguration.Method),
 		})
 	}
+	if g.entityTypeName != "" {
+		g.addRepresentationArgs()
+	}
+	var rawVariables []string
+	for _, definition := range variableDefinitions {
+		name := g.Operation.VariableDefinitionNameBytes(definition)
+		typeRef := g.Operation.VariableDefinitions[definition].Type
+		variableName := name
+		if g.isCustomScalar(typeRef) {
+			variableName = JSONVariableName(name)
+		}
+		g.Args = append(g.Args, &ContextVariableArgument{
+			Name:         name,
+			VariableName: variableName,
+		})
+		if g.forwardsRawJSON(typeRef) {
+			rawVariables = append(rawVariables, string(name))
+		}
+	}
+	if len(rawVariables) != 0 {
+		rawVariablesJson, err := json.Marshal(rawVariables)
+		if err != nil {
+			g.Walker.StopWithInternalErr(err)
+			return
+		}
+		g.Args = append(g.Args, &StaticVariableArgument{
+			Name:  literal.RAW_VARIABLES,
+			Value: rawVariablesJson,
+		})
+	}
 }
 
-func (g *GraphQLDataSourcePlanner) Plan(args []Argument) (DataSource, []Argument) {
-	for i := range args {
-		if arg, ok := args[i].(*ContextVariableArgument); ok {
-			if bytes.HasPrefix(arg.Name, literal.DOT_ARGUMENTS_DOT) {
-				arg.Name = bytes.TrimPrefix(arg.Name, literal.DOT_ARGUMENTS_DOT)
-				g.Args = append(g.Args, arg)
+// addRepresentationArgs adds the "__typename" and the representation fields of the entity as args prefixed with "representation."
+// The fields are resolved from the object enclosing the root field
+func (g *GraphQLDataSourcePlanner) addRepresentationArgs() {
+	g.Args = append(g.Args, &StaticVariableArgument{
+		Name:  representationArgName(string(literal.TYPENAME)),
+		Value: []byte(strconv.Quote(g.entityTypeName)),
+	})
+	for _, fieldName := range g.representationFields {
+		g.Args = append(g.Args, &ObjectVariableArgument{
+			Name: representationArgName(fieldName),
+			PathSelector: PathSelector{
+				Path: fieldName,
+			},
+		})
+	}
+}
+
+func representationArgName(fieldName string) []byte {
+	return append(append([]byte{}, literal.REPRESENTATION_DOT...), fieldName...)
+}
+
+// operationName returns the name of the client operation, anonymous operations are named "o"
+// as the upstream query must be named to have variable definitions
+func (g *GraphQLDataSourcePlanner) operationName() []byte {
+	name := g.Operation.OperationDefinitionNameBytes(g.Walker.Ancestors[0].Ref)
+	if len(name) == 0 {
+		return []byte("o")
+	}
+	return name
+}
+
+// usedVariableDefinitions returns the variable definitions of the client operation for all variables used by the upstream query
+func (g *GraphQLDataSourcePlanner) usedVariableDefinitions() (definitions []int) {
+	operationRef := g.Walker.Ancestors[0].Ref
+	for _, definition := range g.Operation.OperationDefinitions[operationRef].VariableDefinitions.Refs {
+		name := g.Operation.VariableDefinitionNameBytes(definition)
+		for i := range g.resolveDocument.VariableValues {
+			if bytes.Equal(name, g.resolveDocument.VariableValueNameBytes(i)) {
+				definitions = append(definitions, definition)
+				break
 			}
 		}
 	}
+	return
+}
+
+// forwardsRawJSON reports if values of the variable type are forwarded as raw JSON
+// Lists, input objects and the Int, Float and Boolean scalars are stored as JSON in the variables of the execution context,
+// custom scalars are forwarded from their JSON variable, all other values are stored as string contents which must be quoted
+func (g *GraphQLDataSourcePlanner) forwardsRawJSON(typeRef int) bool {
+	typeRef = g.unwrapNonNull(typeRef)
+	if g.Operation.Types[typeRef].TypeKind == ast.TypeKindList {
+		return true
+	}
+	typeName := g.Operation.TypeNameBytes(typeRef)
+	switch {
+	case bytes.Equal(typeName, literal.INT), bytes.Equal(typeName, literal.FLOAT), bytes.Equal(typeName, literal.BOOLEAN):
+		return true
+	}
+	node, ok := g.Definition.NodeByName(typeName)
+	return ok && node.Kind == ast.NodeKindInputObjectTypeDefinition || g.isCustomScalar(typeRef)
+}
+
+// isCustomScalar reports if the variable type is a custom scalar, values of custom scalars can be of any JSON type
+func (g *GraphQLDataSourcePlanner) isCustomScalar(typeRef int) bool {
+	typeRef = g.unwrapNonNull(typeRef)
+	if g.Operation.Types[typeRef].TypeKind != ast.TypeKindNamed {
+		return false
+	}
+	typeName := g.Operation.TypeNameBytes(typeRef)
+	switch {
+	case bytes.Equal(typeName, literal.INT), bytes.Equal(typeName, literal.FLOAT), bytes.Equal(typeName, literal.BOOLEAN),
+		bytes.Equal(typeName, literal.STRING), bytes.Equal(typeName, literal.ID):
+		return false
+	}
+	node, ok := g.Definition.NodeByName(typeName)
+	return ok && node.Kind == ast.NodeKindScalarTypeDefinition
+}
+
+func (g *GraphQLDataSourcePlanner) unwrapNonNull(typeRef int) int {
+	for g.Operation.Types[typeRef].TypeKind == ast.TypeKindNonNull {
+		typeRef = g.Operation.Types[typeRef].OfType
+	}
+	return typeRef
+}
+
+// Plan ignores the field arguments as the variables used by the upstream query were added when leaving the root field
+func (g *GraphQLDataSourcePlanner) Plan(args []Argument) (DataSource, []Argument) {
 	return &GraphQLDataSource{
-		Log: g.Log,
+		Log:              g.Log,
+		Name:             g.dataSourceConfiguration.Name,
+		HeaderForwarding: g.dataSourceConfiguration.HeaderForwarding,
 	}, g.Args
 }
 
+// GraphQLDataSource resolves a query against an upstream GraphQL server
+// The "errors" returned by the upstream next to the data get reported as UpstreamErrors
 type GraphQLDataSource struct {
 	Log log.Logger
+	// Name is added as "upstream" to the "extensions" of the UpstreamErrors if set
+	Name             string
+	HeaderForwarding HeaderForwarding
+}
+
+func (g *GraphQLDataSource) Deduplicable() bool {
+	return true
+}
+
+func (g *GraphQLDataSource) headerForwarding() HeaderForwarding {
+	return g.HeaderForwarding
+}
+
+func (g *GraphQLDataSource) UpstreamName() string {
+	return g.Name
 }
 
 func (g *GraphQLDataSource) Resolve(ctx context.Context, args ResolverArgs, out io.Writer) (n int, err error) {
@@ -288,35 +550,317 @@ func (g *GraphQLDataSource) Resolve(ctx context.Context, args ResolverArgs, out
 		return
 	}
 
-	url := string(hostArg) + string(urlArg)
-	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
-		url = "https://" + url
+	if isEntity(args) {
+		return g.resolveEntities(ctx, []ResolverArgs{args}, []io.Writer{out})
 	}
 
-	variables := map[string]interface{}{}
+	variables := map[string]json.RawMessage{}
+	g.collectVariables(args, "", variables)
+
+	data, upstreamErrors, err := g.do(ctx, hostArg, urlArg, queryArg, args.ByKey(literal.REQUEST), variables)
+	if err != nil {
+		return n, err
+	}
+	g.reportUpstreamErrors(ctx, 0, upstreamErrors)
+	return out.Write(data)
+}
+
+// ResolveBatch merges the queries of all items into a single query with one aliased root field per item
+// The variables of each item get suffixed with the index of the item to keep them apart
+func (g *GraphQLDataSource) ResolveBatch(ctx context.Context, args []ResolverArgs, outs []io.Writer) (n int, err error) {
+	if len(args) == 0 {
+		return
+	}
+
+	if isEntity(args[0]) {
+		return g.resolveEntities(ctx, args, outs)
+	}
+
+	hostArg := args[0].ByKey(literal.HOST)
+	urlArg := args[0].ByKey(literal.URL)
+
+	if hostArg == nil || urlArg == nil {
+		g.Log.Error("GraphQLDataSource.Args invalid")
+		return
+	}
+
+	queries := make([][]byte, len(args))
+	variables := map[string]json.RawMessage{}
+	for i := range args {
+		queries[i] = args[i].ByKey(literal.QUERY)
+		if queries[i] == nil {
+			g.Log.Error("GraphQLDataSource.Args invalid")
+			return
+		}
+		g.collectVariables(args[i], batchAlias(i), variables)
+	}
+
+	query, rootFieldNames, err := mergeBatchQueries(queries)
+	if err != nil {
+		g.Log.Error("GraphQLDataSource.mergeBatchQueries",
+			log.Error(err),
+		)
+		return n, err
+	}
+
+	data, upstreamErrors, err := g.do(ctx, hostArg, urlArg, query, args[0].ByKey(literal.REQUEST), variables)
+	if err != nil {
+		return n, err
+	}
+	g.reportBatchUpstreamErrors(ctx, len(args), rootFieldNames, upstreamErrors)
+
+	for i := range outs {
+		result, _, _, err := jsonparser.Get(data, batchAlias(i))
+		if err != nil {
+			g.Log.Error("GraphQLDataSource.jsonparser.Get",
+				log.Error(err),
+			)
+			return n, err
+		}
+		// write the result the same way a single query would have responded
+		item := make([]byte, 0, len(result)+len(rootFieldNames[i])+5)
+		item = append(item, literal.LBRACE...)
+		item = append(item, literal.QUOTE...)
+		item = append(item, rootFieldNames[i]...)
+		item = append(item, literal.QUOTE...)
+		item = append(item, literal.COLON...)
+		item = append(item, result...)
+		item = append(item, literal.RBRACE...)
+		written, err := outs[i].Write(item)
+		n += written
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return
+}
+
+// resolveEntities resolves the entities of all items with a single "_entities" query
+// The representation of each item is built from its args prefixed with "representation.", all items share the query and variables
+// The entity of each item is written the same way a query of the root field would have responded
+func (g *GraphQLDataSource) resolveEntities(ctx context.Context, args []ResolverArgs, outs []io.Writer) (n int, err error) {
+
+	hostArg := args[0].ByKey(literal.HOST)
+	urlArg := args[0].ByKey(literal.URL)
+	queryArg := args[0].ByKey(literal.QUERY)
+
+	if hostArg == nil || urlArg == nil || queryArg == nil {
+		g.Log.Error("GraphQLDataSource.Args invalid")
+		return
+	}
+
+	representations := make([]json.RawMessage, len(args))
+	for i := range args {
+		representations[i] = representation(args[i])
+	}
+
+	variables := map[string]json.RawMessage{}
+	g.collectVariables(args[0], "", variables)
+	variables[string(literal.REPRESENTATIONS)], err = json.Marshal(representations)
+	if err != nil {
+		g.Log.Error("GraphQLDataSource.json.Marshal(representations)",
+			log.Error(err),
+		)
+		return n, err
+	}
+
+	data, upstreamErrors, err := g.do(ctx, hostArg, urlArg, queryArg, args[0].ByKey(literal.REQUEST), variables)
+	if err != nil {
+		return n, err
+	}
+	g.reportEntitiesUpstreamErrors(ctx, len(args), upstreamErrors)
+
+	for i := range outs {
+		entity, _, _, err := jsonparser.Get(data, string(literal.ENTITIES), "["+strconv.Itoa(i)+"]")
+		if err != nil {
+			g.Log.Error("GraphQLDataSource.jsonparser.Get",
+				log.Error(err),
+			)
+			return n, err
+		}
+		written, err := outs[i].Write(entity)
+		n += written
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return
+}
+
+// isEntity reports if the args belong to a field of an entity, i.e. they contain a representation
+func isEntity(args ResolverArgs) bool {
+	for _, key := range args.Keys() {
+		if bytes.HasPrefix(key, literal.REPRESENTATION_DOT) {
+			return true
+		}
+	}
+	return false
+}
+
+// representation builds the representation of an entity from the args prefixed with "representation."
+// e.g. the args "representation.__typename" and "representation.id" become {"__typename":"User","id":"1"}
+func representation(args ResolverArgs) json.RawMessage {
+	out := append([]byte{}, literal.LBRACE...)
+	for _, key := range args.Keys() {
+		if !bytes.HasPrefix(key, literal.REPRESENTATION_DOT) {
+			continue
+		}
+		if len(out) > 1 {
+			out = append(out, literal.COMMA...)
+		}
+		value := args.ByKey(key)
+		if len(value) == 0 {
+			value = literal.NULL
+		}
+		out = append(out, literal.QUOTE...)
+		out = append(out, key[len(literal.REPRESENTATION_DOT):]...)
+		out = append(out, literal.QUOTE...)
+		out = append(out, literal.COLON...)
+		out = append(out, value...)
+	}
+	return append(out, literal.RBRACE...)
+}
+
+// collectVariables adds all args except the configuration of the upstream and the client request to the variables
+// Variables listed in the rawVariables arg are JSON values which get forwarded unchanged, all other variables are string contents
+func (g *GraphQLDataSource) collectVariables(args ResolverArgs, suffix string, variables map[string]json.RawMessage) {
+	var rawVariables []string
+	if rawVariablesArg := args.ByKey(literal.RAW_VARIABLES); rawVariablesArg != nil {
+		if err := json.Unmarshal(rawVariablesArg, &rawVariables); err != nil {
+			g.Log.Error("GraphQLDataSource.collectVariables.json.Unmarshal(rawVariables)",
+				log.Error(err),
+			)
+		}
+	}
 	keys := args.Keys()
 	for i := 0; i < len(keys); i++ {
 		switch {
 		case bytes.Equal(keys[i], literal.HOST):
 		case bytes.Equal(keys[i], literal.URL):
 		case bytes.Equal(keys[i], literal.QUERY):
+		case bytes.Equal(keys[i], literal.METHOD):
+		case bytes.Equal(keys[i], literal.RAW_VARIABLES):
+		case bytes.Equal(keys[i], literal.REQUEST): // the client request must only reach the upstream through the HeaderForwarding
+		case bytes.HasPrefix(keys[i], literal.REPRESENTATION_DOT):
 		default:
-			variables[string(keys[i])] = string(args.ByKey(keys[i]))
+			value := args.ByKey(keys[i])
+			if value == nil {
+				continue
+			}
+			variables[string(keys[i])+suffix] = variableJSON(value, isRawVariable(keys[i], rawVariables))
+		}
+	}
+}
+
+func isRawVariable(name []byte, rawVariables []string) bool {
+	for i := range rawVariables {
+		if rawVariables[i] == string(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// variableJSON turns a resolved variable into JSON, string contents are JSON escaped already so they only need to be quoted
+func variableJSON(value []byte, raw bool) json.RawMessage {
+	if raw || bytes.Equal(value, literal.NULL) {
+		return value
+	}
+	out := make([]byte, 0, len(value)+2)
+	out = append(out, literal.QUOTE...)
+	out = append(out, value...)
+	return append(out, literal.QUOTE...)
+}
+
+// reportUpstreamErrors reports the errors of the upstream for the item to the UpstreamErrors of the call
+// Results with errors must not be cached as they're likely incomplete
+func (g *GraphQLDataSource) reportUpstreamErrors(ctx context.Context, item int, upstreamErrors []UpstreamError) {
+	if len(upstreamErrors) == 0 {
+		return
+	}
+	if hint := CacheHintFromContext(ctx); hint != nil {
+		hint.NoStore = true
+	}
+	if g.Name != "" {
+		for i := range upstreamErrors {
+			if upstreamErrors[i].Extensions == nil {
+				upstreamErrors[i].Extensions = map[string]interface{}{}
+			}
+			upstreamErrors[i].Extensions["upstream"] = g.Name
 		}
 	}
+	UpstreamErrorsFromContext(ctx).Add(item, upstreamErrors...)
+}
+
+// reportBatchUpstreamErrors assigns the errors of a batch to its items using the alias of the root field of each item
+// The alias gets replaced with the name of the root field so that the path is the same as if the item was resolved on its own
+// Errors without a path can't be assigned to an item and get reported for all items
+func (g *GraphQLDataSource) reportBatchUpstreamErrors(ctx context.Context, items int, rootFieldNames [][]byte, upstreamErrors []UpstreamError) {
+	for _, upstreamError := range upstreamErrors {
+		if len(upstreamError.Path) == 0 {
+			for i := 0; i < items; i++ {
+				g.reportUpstreamErrors(ctx, i, []UpstreamError{upstreamError})
+			}
+			continue
+		}
+		for i := 0; i < items; i++ {
+			if upstreamError.Path[0] != batchAlias(i) {
+				continue
+			}
+			path := make([]interface{}, len(upstreamError.Path))
+			copy(path, upstreamError.Path)
+			path[0] = string(rootFieldNames[i])
+			upstreamError.Path = path
+			g.reportUpstreamErrors(ctx, i, []UpstreamError{upstreamError})
+			break
+		}
+	}
+}
+
+// reportEntitiesUpstreamErrors assigns the errors of an "_entities" query to the items using the index of the entity in their path
+// The path gets stripped of "_entities" and the index so that it starts with the root field as if the item was resolved on its own
+// Errors which can't be assigned to an item get reported for all items without a path
+func (g *GraphQLDataSource) reportEntitiesUpstreamErrors(ctx context.Context, items int, upstreamErrors []UpstreamError) {
+	for _, upstreamError := range upstreamErrors {
+		if len(upstreamError.Path) >= 2 && upstreamError.Path[0] == string(literal.ENTITIES) {
+			if index, ok := upstreamError.Path[1].(float64); ok && int(index) >= 0 && int(index) < items {
+				upstreamError.Path = upstreamError.Path[2:]
+				if len(upstreamError.Path) == 0 {
+					upstreamError.Path = nil
+				}
+				g.reportUpstreamErrors(ctx, int(index), []UpstreamError{upstreamError})
+				continue
+			}
+		}
+		upstreamError.Path = nil
+		for i := 0; i < items; i++ {
+			g.reportUpstreamErrors(ctx, i, []UpstreamError{upstreamError})
+		}
+	}
+}
+
+// do sends the query to the upstream and returns the "data" and the "errors" of the response
+// If the response has no "data" the "errors" are returned as err
+func (g *GraphQLDataSource) do(ctx context.Context, hostArg, urlArg, queryArg, requestArg []byte, variables map[string]json.RawMessage) (data []byte, upstreamErrors []UpstreamError, err error) {
+
+	url := string(hostArg) + string(urlArg)
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		url = "https://" + url
+	}
 
 	variablesJson, err := json.Marshal(variables)
 	if err != nil {
 		g.Log.Error("GraphQLDataSource.json.Marshal(variables)",
 			log.Error(err),
 		)
-		return n, err
+		return nil, nil, err
 	}
 
 	gqlRequest := GraphqlRequest{
-		OperationName: "o",
-		Variables:     variablesJson,
-		Query:         string(queryArg),
+		Variables: variablesJson,
+		Query:     string(queryArg),
 	}
 
 	gqlRequestData, err := json.MarshalIndent(gqlRequest, "", "  ")
@@ -324,7 +868,7 @@ func (g *GraphQLDataSource) Resolve(ctx context.Context, args ResolverArgs, out
 		g.Log.Error("GraphQLDataSource.json.MarshalIndent",
 			log.Error(err),
 		)
-		return n, err
+		return nil, nil, err
 	}
 
 	g.Log.Debug("GraphQLDataSource.request",
@@ -345,34 +889,168 @@ func (g *GraphQLDataSource) Resolve(ctx context.Context, args ResolverArgs, out
 		g.Log.Error("GraphQLDataSource.http.NewRequest",
 			log.Error(err),
 		)
-		return n, err
+		return nil, nil, err
 	}
 
+	request = request.WithContext(ctx)
 	request.Header.Add("Content-Type", "application/json")
 	request.Header.Add("Accept", "application/json")
+	g.HeaderForwarding.Request.forwardRequestHeaders(requestArg, request.Header)
 
 	res, err := client.Do(request)
 	if err != nil {
 		g.Log.Error("GraphQLDataSource.client.Do",
 			log.Error(err),
 		)
-		return n, err
+		return nil, nil, err
 	}
-	data, err := ioutil.ReadAll(res.Body)
+	ResponseHeadersFromContext(ctx).forward(g.HeaderForwarding.Response, res.Header)
+	data, err = ioutil.ReadAll(res.Body)
 	if err != nil {
 		g.Log.Error("GraphQLDataSource.ioutil.ReadAll",
 			log.Error(err),
 		)
-		return n, err
+		return nil, nil, err
+	}
+
+	if errorsJson, _, _, _ := jsonparser.Get(data, "errors"); errorsJson != nil {
+		if err := json.Unmarshal(errorsJson, &upstreamErrors); err != nil {
+			g.Log.Error("GraphQLDataSource.json.Unmarshal(errors)",
+				log.Error(err),
+			)
+			return nil, nil, err
+		}
 	}
 
 	data = bytes.ReplaceAll(data, literal.BACKSLASH, nil)
 	data, _, _, err = jsonparser.Get(data, "data")
+	if err == jsonparser.KeyPathNotFoundError && len(upstreamErrors) != 0 {
+		return nil, nil, upstreamErrorsError(upstreamErrors)
+	}
 	if err != nil {
 		g.Log.Error("GraphQLDataSource.jsonparser.Get",
 			log.Error(err),
 		)
-		return n, err
+		return nil, nil, err
+	}
+	return data, upstreamErrors, nil
+}
+
+// upstreamErrorsError turns the errors of a response without "data" into a single error
+func upstreamErrorsError(upstreamErrors []UpstreamError) error {
+	messages := make([]string, len(upstreamErrors))
+	for i := range upstreamErrors {
+		messages[i] = upstreamErrors[i].Message
+	}
+	return errors.New(strings.Join(messages, ", "))
+}
+
+func batchAlias(i int) string {
+	return "_" + strconv.Itoa(i)
+}
+
+// mergeBatchQueries merges multiple single root field queries into one operation
+// e.g. the queries "query o($id: ID){user(id: $id){name}}" and "query o($id: ID){user(id: $id){name}}" get merged into
+// "query o($id_0: ID, $id_1: ID){_0: user(id: $id_0){name} _1: user(id: $id_1){name}}"
+// rootFieldNames contains the name of the root field of each query
+func mergeBatchQueries(queries [][]byte) (query []byte, rootFieldNames [][]byte, err error) {
+	document, report := astparser.ParseGraphqlDocumentBytes(bytes.Join(queries, literal.LINETERMINATOR))
+	if report.HasErrors() {
+		return nil, nil, report
+	}
+	if len(document.OperationDefinitions) != len(queries) {
+		return nil, nil, fmt.Errorf("mergeBatchQueries: want %d operations, got: %d", len(queries), len(document.OperationDefinitions))
+	}
+
+	rootFieldNames = make([][]byte, len(queries))
+	batch := &document.OperationDefinitions[0]
+
+	for i := range document.OperationDefinitions {
+		suffix := batchAlias(i)
+		operation := document.OperationDefinitions[i]
+		for _, ref := range operation.VariableDefinitions.Refs {
+			renameVariables(&document, document.VariableDefinitions[ref].VariableValue, suffix)
+		}
+		selectionRefs := document.SelectionSets[operation.SelectionSet].SelectionRefs
+		if len(selectionRefs) != 1 || document.Selections[selectionRefs[0]].Kind != ast.SelectionKindField {
+			return nil, nil, fmt.Errorf("mergeBatchQueries: want exactly one root field in operation %d", i)
+		}
+		fieldRef := document.Selections[selectionRefs[0]].Ref
+		rootFieldNames[i] = document.FieldNameBytes(fieldRef)
+		document.Fields[fieldRef].Alias = ast.Alias{
+			IsDefined: true,
+			Name:      document.Input.AppendInputString(suffix),
+		}
+		renameFieldVariables(&document, fieldRef, suffix)
+		if i == 0 {
+			continue
+		}
+		batch.VariableDefinitions.Refs = append(batch.VariableDefinitions.Refs, operation.VariableDefinitions.Refs...)
+		batch.HasVariableDefinitions = len(batch.VariableDefinitions.Refs) != 0
+		document.SelectionSets[batch.SelectionSet].SelectionRefs = append(document.SelectionSets[batch.SelectionSet].SelectionRefs, selectionRefs...)
+	}
+
+	for i := range document.RootNodes {
+		if document.RootNodes[i].Kind == ast.NodeKindOperationDefinition && document.RootNodes[i].Ref == 0 {
+			document.RootNodes = []ast.Node{document.RootNodes[i]}
+			break
+		}
+	}
+
+	buf := bytes.Buffer{}
+	err = astprinter.Print(&document, nil, &buf)
+	return buf.Bytes(), rootFieldNames, err
+}
+
+func renameFieldVariables(document *ast.Document, fieldRef int, suffix string) {
+	field := document.Fields[fieldRef]
+	renameArgumentVariables(document, field.Arguments.Refs, suffix)
+	renameDirectiveVariables(document, field.Directives.Refs, suffix)
+	if field.HasSelections {
+		renameSelectionSetVariables(document, field.SelectionSet, suffix)
+	}
+}
+
+func renameSelectionSetVariables(document *ast.Document, setRef int, suffix string) {
+	for _, selectionRef := range document.SelectionSets[setRef].SelectionRefs {
+		selection := document.Selections[selectionRef]
+		switch selection.Kind {
+		case ast.SelectionKindField:
+			renameFieldVariables(document, selection.Ref, suffix)
+		case ast.SelectionKindInlineFragment:
+			inlineFragment := document.InlineFragments[selection.Ref]
+			renameDirectiveVariables(document, inlineFragment.Directives.Refs, suffix)
+			if inlineFragment.HasSelections {
+				renameSelectionSetVariables(document, inlineFragment.SelectionSet, suffix)
+			}
+		}
+	}
+}
+
+func renameDirectiveVariables(document *ast.Document, directiveRefs []int, suffix string) {
+	for _, ref := range directiveRefs {
+		renameArgumentVariables(document, document.Directives[ref].Arguments.Refs, suffix)
+	}
+}
+
+func renameArgumentVariables(document *ast.Document, argumentRefs []int, suffix string) {
+	for _, ref := range argumentRefs {
+		renameVariables(document, document.Arguments[ref].Value, suffix)
+	}
+}
+
+func renameVariables(document *ast.Document, value ast.Value, suffix string) {
+	switch value.Kind {
+	case ast.ValueKindVariable:
+		name := document.Input.ByteSliceString(document.VariableValues[value.Ref].Name)
+		document.VariableValues[value.Ref].Name = document.Input.AppendInputString(name + suffix)
+	case ast.ValueKindList:
+		for _, ref := range document.ListValues[value.Ref].Refs {
+			renameVariables(document, document.Values[ref], suffix)
+		}
+	case ast.ValueKindObject:
+		for _, ref := range document.ObjectValues[value.Ref].Refs {
+			renameVariables(document, document.ObjectFields[ref].Value, suffix)
+		}
 	}
-	return out.Write(data)
 }
@@ -16,6 +16,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -33,12 +34,31 @@ type HttpJsonDataSourceConfig struct {
 	Body *string
 	// Headers defines the header mappings
 	Headers []HttpJsonDataSourceConfigHeader
+	// HeaderForwarding configures the headers forwarded between the client and the upstream (optional)
+	// Headers defined in Headers take precedence over forwarded headers
+	HeaderForwarding HeaderForwarding
 	// DefaultTypeName is the optional variable to define a default type name for the response object
 	// This is useful in case the response might be a Union or Interface type which uses StatusCodeTypeNameMappings
 	DefaultTypeName *string
 	// StatusCodeTypeNameMappings is a slice of mappings from http.StatusCode to GraphQL TypeName
 	// This can be used when the TypeName depends on the http.StatusCode
 	StatusCodeTypeNameMappings []StatusCodeTypeNameMapping
+	// Batch is the optional configuration of a bulk endpoint
+	// If batching is enabled for the field, all items of a list get resolved with a single request to the bulk endpoint
+	Batch *HttpJsonDataSourceConfigBatch
+}
+
+// HttpJsonDataSourceConfigBatch is the configuration of a bulk endpoint
+// The bulk endpoint receives a JSON array with the Input of each item as body
+// and must respond with a JSON array containing the result of each item in the same order
+type HttpJsonDataSourceConfigBatch struct {
+	// URL is the url of the bulk endpoint
+	URL string
+	// Method is the http.Method of the bulk endpoint
+	// default is POST
+	Method *string
+	// Input is the JSON input of a single item, e.g. {"id":{{ .object.id }}}
+	Input string
 }
 
 type StatusCodeTypeNameMapping struct {
@@ -81,7 +101,8 @@ type HttpJsonDataSourcePlanner struct {
 
 func (h *HttpJsonDataSourcePlanner) Plan(args []Argument) (DataSource, []Argument) {
 	return &HttpJsonDataSource{
-		Log: h.Log,
+		Log:              h.Log,
+		HeaderForwarding: h.dataSourceConfig.HeaderForwarding,
 	}, append(h.Args, args...)
 }
 
@@ -152,6 +173,25 @@ func (h *HttpJsonDataSourcePlanner) LeaveField(ref int) {
 		h.Args = append(h.Args, listArg)
 	}
 
+	if h.dataSourceConfig.Batch != nil {
+		h.Args = append(h.Args, &StaticVariableArgument{
+			Name:  literal.BATCHURL,
+			Value: []byte(h.dataSourceConfig.Batch.URL),
+		})
+		batchMethod := literal.HTTP_METHOD_POST
+		if h.dataSourceConfig.Batch.Method != nil {
+			batchMethod = []byte(*h.dataSourceConfig.Batch.Method)
+		}
+		h.Args = append(h.Args, &StaticVariableArgument{
+			Name:  literal.BATCHMETHOD,
+			Value: batchMethod,
+		})
+		h.Args = append(h.Args, &StaticVariableArgument{
+			Name:  literal.BATCHINPUT,
+			Value: []byte(h.dataSourceConfig.Batch.Input),
+		})
+	}
+
 	// __typename
 	var typeNameValue []byte
 	var err error
@@ -191,7 +231,16 @@ func (h *HttpJsonDataSourcePlanner) LeaveField(ref int) {
 }
 
 type HttpJsonDataSource struct {
-	Log log.Logger
+	Log              log.Logger
+	HeaderForwarding HeaderForwarding
+}
+
+func (r *HttpJsonDataSource) Deduplicable() bool {
+	return true
+}
+
+func (r *HttpJsonDataSource) headerForwarding() HeaderForwarding {
+	return r.HeaderForwarding
 }
 
 func (r *HttpJsonDataSource) Resolve(ctx context.Context, args ResolverArgs, out io.Writer) (n int, err error) {
@@ -219,6 +268,119 @@ func (r *HttpJsonDataSource) Resolve(ctx context.Context, args ResolverArgs, out
 		return
 	}
 
+	statusCode, data, err := r.do(ctx, hostArg, urlArg, methodArg, bodyArg, headersArg, args.ByKey(literal.REQUEST))
+	if err != nil {
+		return
+	}
+
+	if hint := CacheHintFromContext(ctx); hint != nil && hint.IfNoneMatch != "" && statusCode == http.StatusNotModified {
+		hint.NotModified = true
+		return
+	}
+
+	data, err = r.setTypeName(data, statusCode, typeNameArg)
+	if err != nil {
+		return
+	}
+
+	return out.Write(data)
+}
+
+// ResolveBatch resolves the args of all items with a single request to the configured bulk endpoint
+// In case there's no bulk endpoint configured each item gets resolved with its own request
+func (r *HttpJsonDataSource) ResolveBatch(ctx context.Context, args []ResolverArgs, outs []io.Writer) (n int, err error) {
+	if len(args) == 0 {
+		return
+	}
+
+	hostArg := args[0].ByKey(literal.HOST)
+	batchUrlArg := args[0].ByKey(literal.BATCHURL)
+	batchMethodArg := args[0].ByKey(literal.BATCHMETHOD)
+	headersArg := args[0].ByKey(literal.HEADERS)
+	typeNameArg := args[0].ByKey(literal.TYPENAME)
+
+	if hostArg == nil || batchUrlArg == nil || batchMethodArg == nil {
+		return r.resolveEach(ctx, args, outs)
+	}
+
+	body := bytes.Buffer{}
+	body.Write(literal.LBRACK)
+	for i := range args {
+		if i != 0 {
+			body.Write(literal.COMMA)
+		}
+		body.Write(args[i].ByKey(literal.BATCHINPUT))
+	}
+	body.Write(literal.RBRACK)
+
+	statusCode, data, err := r.do(ctx, hostArg, batchUrlArg, batchMethodArg, body.Bytes(), headersArg, args[0].ByKey(literal.REQUEST))
+	if err != nil {
+		return
+	}
+
+	// the results of all items are only known if the bulk endpoint succeeded
+	if statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices {
+		err = fmt.Errorf("HttpJsonDataSource.ResolveBatch: bulk endpoint responded with status code: %d", statusCode)
+		r.Log.Error("HttpJsonDataSource.ResolveBatch",
+			log.Error(err),
+		)
+		return
+	}
+
+	results := gjson.ParseBytes(data).Array()
+	if len(results) != len(args) {
+		err = fmt.Errorf("HttpJsonDataSource.ResolveBatch: want %d results from bulk endpoint, got: %d", len(args), len(results))
+		r.Log.Error("HttpJsonDataSource.ResolveBatch",
+			log.Error(err),
+		)
+		return
+	}
+
+	for i := range results {
+		var result []byte
+		var written int
+		result, err = r.setTypeName([]byte(results[i].Raw), statusCode, typeNameArg)
+		if err != nil {
+			return
+		}
+		written, err = outs[i].Write(result)
+		n += written
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// resolveEach resolves all items concurrently, the first error gets returned
+func (r *HttpJsonDataSource) resolveEach(ctx context.Context, args []ResolverArgs, outs []io.Writer) (n int, err error) {
+	wg := sync.WaitGroup{}
+	mux := sync.Mutex{}
+	for i := range args {
+		wg.Add(1)
+		go func(args ResolverArgs, out io.Writer) {
+			defer wg.Done()
+			written, resolveErr := r.Resolve(ctx, args, out)
+			mux.Lock()
+			n += written
+			if err == nil {
+				err = resolveErr
+			}
+			mux.Unlock()
+		}(args[i], outs[i])
+	}
+	wg.Wait()
+	return
+}
+
+// do calls the upstream with the rendered body, the escaping of the quotes of the body template gets removed
+func (r *HttpJsonDataSource) do(ctx context.Context, hostArg, urlArg, methodArg, bodyArg, headersArg, requestArg []byte) (statusCode int, data []byte, err error) {
+
+	if len(bodyArg) != 0 {
+		bodyArg = bytes.ReplaceAll(bodyArg, literal.BACKSLASH, nil)
+	}
+
 	httpMethod := http.MethodGet
 	switch {
 	case bytes.Equal(methodArg, literal.HTTP_METHOD_GET):
@@ -248,6 +410,7 @@ func (r *HttpJsonDataSource) Resolve(ctx context.Context, args ResolverArgs, out
 			r.Log.Error("accessing headers", log.Error(err))
 		}
 	}
+	r.HeaderForwarding.Request.forwardRequestHeaders(requestArg, header)
 
 	r.Log.Debug("HttpJsonDataSource.Resolve",
 		log.String("url", url),
@@ -263,7 +426,6 @@ func (r *HttpJsonDataSource) Resolve(ctx context.Context, args ResolverArgs, out
 
 	var bodyReader io.Reader
 	if len(bodyArg) != 0 {
-		bodyArg = bytes.ReplaceAll(bodyArg, literal.BACKSLASH, nil)
 		bodyReader = bytes.NewReader(bodyArg)
 	}
 
@@ -275,8 +437,14 @@ func (r *HttpJsonDataSource) Resolve(ctx context.Context, args ResolverArgs, out
 		return
 	}
 
+	request = request.WithContext(ctx)
 	request.Header = header
 
+	hint := CacheHintFromContext(ctx)
+	if hint != nil && hint.IfNoneMatch != "" {
+		request.Header.Set("If-None-Match", hint.IfNoneMatch)
+	}
+
 	res, err := client.Do(request)
 	if err != nil {
 		r.Log.Error("HttpJsonDataSource.Resolve.client.Do",
@@ -284,8 +452,9 @@ func (r *HttpJsonDataSource) Resolve(ctx context.Context, args ResolverArgs, out
 		)
 		return
 	}
+	ResponseHeadersFromContext(ctx).forward(r.HeaderForwarding.Response, res.Header)
 
-	data, err := ioutil.ReadAll(res.Body)
+	data, err = ioutil.ReadAll(res.Body)
 	if err != nil {
 		r.Log.Error("HttpJsonDataSource.Resolve.ioutil.ReadAll",
 			log.Error(err),
@@ -293,15 +462,57 @@ func (r *HttpJsonDataSource) Resolve(ctx context.Context, args ResolverArgs, out
 		return
 	}
 
-	statusCode := strconv.Itoa(res.StatusCode)
-	statusCodeTypeName := gjson.GetBytes(typeNameArg, statusCode)
+	if hint != nil {
+		setCacheHint(hint, res)
+	}
+
+	return res.StatusCode, data, nil
+}
+
+// setCacheHint applies the Cache-Control and ETag headers of the response to the CacheHint
+// The response cache is shared between all clients so s-maxage takes precedence over max-age and private responses don't get cached
+func setCacheHint(hint *CacheHint, res *http.Response) {
+	hint.ETag = res.Header.Get("ETag")
+	if res.StatusCode >= http.StatusInternalServerError {
+		hint.NoStore = true
+		return
+	}
+	maxAge, sharedMaxAge := -1, -1
+	for _, directive := range strings.Split(res.Header.Get("Cache-Control"), ",") {
+		name, value := strings.ToLower(strings.TrimSpace(directive)), ""
+		if i := strings.IndexByte(name, '='); i != -1 {
+			name, value = name[:i], strings.Trim(name[i+1:], `"`)
+		}
+		seconds, err := strconv.Atoi(value)
+		switch {
+		case name == "no-store" || name == "private":
+			hint.NoStore = true
+		case name == "no-cache":
+			sharedMaxAge = 0
+		case name == "max-age" && err == nil:
+			maxAge = seconds
+		case name == "s-maxage" && err == nil && sharedMaxAge == -1:
+			sharedMaxAge = seconds
+		}
+	}
+	if sharedMaxAge >= 0 {
+		maxAge = sharedMaxAge
+	}
+	if maxAge >= 0 {
+		hint.MaxAge, hint.HasMaxAge = time.Duration(maxAge)*time.Second, true
+	}
+}
+
+func (r *HttpJsonDataSource) setTypeName(data []byte, statusCode int, typeNameArg []byte) ([]byte, error) {
+	var err error
+	statusCodeTypeName := gjson.GetBytes(typeNameArg, strconv.Itoa(statusCode))
 	if statusCodeTypeName.Exists() {
 		data, err = sjson.SetRawBytes(data, "__typename", []byte(statusCodeTypeName.Raw))
 		if err != nil {
 			r.Log.Error("HttpJsonDataSource.Resolve.setStatusCodeTypeName",
 				log.Error(err),
 			)
-			return
+			return data, err
 		}
 	} else {
 		defaultTypeName := gjson.GetBytes(typeNameArg, "defaultTypeName")
@@ -311,10 +522,9 @@ func (r *HttpJsonDataSource) Resolve(ctx context.Context, args ResolverArgs, out
 				r.Log.Error("HttpJsonDataSource.Resolve.setDefaultTypeName",
 					log.Error(err),
 				)
-				return
+				return data, err
 			}
 		}
 	}
-
-	return out.Write(data)
+	return data, nil
 }
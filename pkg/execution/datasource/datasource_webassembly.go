@@ -103,6 +103,7 @@ type WasmDataSource struct {
 	Log      log.Logger
 	instance wasm.Instance
 	once     sync.Once
+	mux      sync.Mutex
 }
 
 func (s *WasmDataSource) Resolve(ctx context.Context, args ResolverArgs, out io.Writer) (n int, err error) {
@@ -133,6 +134,10 @@ func (s *WasmDataSource) Resolve(ctx context.Context, args ResolverArgs, out io.
 		s.Log.Debug("WasmDataSource.wasm.NewInstance OK")
 	})
 
+	// the wasm instance is not safe for concurrent use, data sources are shared through cached plans
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
 	inputLen := len(input)
 
 	allocateInputResult, err := s.instance.Exports["allocate"](inputLen)
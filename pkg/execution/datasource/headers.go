@@ -0,0 +1,197 @@
+package datasource
+
+import (
+	"context"
+	"github.com/buger/jsonparser"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
+	"net/http"
+	"sync"
+)
+
+// HeaderForwarding configures which headers get forwarded between the client and an upstream
+// The headers of the client request are read from the "request" extra variable, e.g. as set by the http package
+type HeaderForwarding struct {
+	// Request are the rules to forward the headers of the client request to the upstream
+	Request HeaderRules
+	// Response are the rules to forward the headers of the upstream response to the client response
+	Response HeaderRules
+}
+
+// headerForwarder is a DataSource forwarding headers between the client and its upstream
+type headerForwarder interface {
+	headerForwarding() HeaderForwarding
+}
+
+// HeaderRules select and rename the headers to forward, no header gets forwarded by default
+// Header names are case insensitive
+type HeaderRules struct {
+	// Allow is the allow-list of headers to forward, "*" allows all headers
+	Allow []string
+	// Deny is the deny-list of headers which never get forwarded, it takes precedence over Allow
+	Deny []string
+	// Rename maps the names of forwarded headers to the names they get forwarded with, e.g. "X-Auth" to "Authorization"
+	Rename map[string]string
+}
+
+// TracingHeaders are common headers to propagate traces, add them to HeaderRules.Allow to propagate traces to the upstream
+var TracingHeaders = []string{
+	"Traceparent",
+	"Tracestate",
+	"X-Request-Id",
+	"X-B3-Traceid",
+	"X-B3-Spanid",
+	"X-B3-Parentspanid",
+	"X-B3-Sampled",
+	"X-B3-Flags",
+	"B3",
+	"Uber-Trace-Id",
+}
+
+// unforwardableHeaders describe the connection or the body of a single request or response, they never get forwarded
+var unforwardableHeaders = []string{
+	"Accept-Encoding",
+	"Connection",
+	"Content-Encoding",
+	"Content-Length",
+	"Content-Type",
+	"Host",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// forwardedName returns the name the header gets forwarded with, ok is false if the header must not be forwarded
+func (h HeaderRules) forwardedName(name string) (forwardedName string, ok bool) {
+	name = http.CanonicalHeaderKey(name)
+	if containsHeader(unforwardableHeaders, name) || containsHeader(h.Deny, name) {
+		return "", false
+	}
+	if !containsHeader(h.Allow, "*") && !containsHeader(h.Allow, name) {
+		return "", false
+	}
+	for from, to := range h.Rename {
+		if http.CanonicalHeaderKey(from) == name {
+			return http.CanonicalHeaderKey(to), true
+		}
+	}
+	return name, true
+}
+
+func containsHeader(names []string, name string) bool {
+	for i := range names {
+		if http.CanonicalHeaderKey(names[i]) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardRequestHeaders adds the allowed headers of the client request to the header of the upstream request
+// requestArg is the "request" extra variable, headers set already (e.g. statically configured ones) take precedence
+func (h HeaderRules) forwardRequestHeaders(requestArg []byte, header http.Header) {
+	if len(h.Allow) == 0 || len(requestArg) == 0 {
+		return
+	}
+	_ = jsonparser.ObjectEach(requestArg, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
+		name, ok := h.forwardedName(string(key))
+		if !ok || dataType != jsonparser.String || header.Get(name) != "" {
+			return nil
+		}
+		if unescaped, err := jsonparser.ParseString(value); err == nil {
+			header.Set(name, unescaped)
+		}
+		return nil
+	}, string(literal.HEADERS))
+}
+
+// ResponseHeaders collects the headers of upstream responses which get forwarded to the client response
+// It's safe to be used from multiple goroutines
+type ResponseHeaders struct {
+	mux    sync.Mutex
+	header http.Header
+}
+
+type responseHeadersKey struct{}
+
+// WithResponseHeaders returns a copy of ctx which makes DataSources add the forwarded headers of upstream responses to headers
+func WithResponseHeaders(ctx context.Context, headers *ResponseHeaders) context.Context {
+	return context.WithValue(ctx, responseHeadersKey{}, headers)
+}
+
+// ResponseHeadersFromContext returns the ResponseHeaders of the execution, it's nil if the caller doesn't forward headers
+func ResponseHeadersFromContext(ctx context.Context) *ResponseHeaders {
+	headers, _ := ctx.Value(responseHeadersKey{}).(*ResponseHeaders)
+	return headers
+}
+
+// forward adds the allowed headers of the upstream response
+// Set-Cookie headers of all upstreams are kept, for all other headers the last upstream response wins
+// It's safe to be called on nil ResponseHeaders which drops the headers
+func (r *ResponseHeaders) forward(rules HeaderRules, upstream http.Header) {
+	if r == nil || len(rules.Allow) == 0 {
+		return
+	}
+	forwarded := http.Header{}
+	for key, values := range upstream {
+		name, ok := rules.forwardedName(key)
+		if !ok {
+			continue
+		}
+		if name != "Set-Cookie" {
+			forwarded.Del(name)
+		}
+		for i := range values {
+			forwarded.Add(name, values[i])
+		}
+	}
+	r.add(forwarded)
+}
+
+// add adds headers which already passed the forwarding rules, e.g. the headers stored with a cached result
+// Set-Cookie headers are kept unless the same cookie was added before, for all other headers the last one wins
+// It's safe to be called on nil ResponseHeaders which drops the headers
+func (r *ResponseHeaders) add(header http.Header) {
+	if r == nil || len(header) == 0 {
+		return
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.header == nil {
+		r.header = http.Header{}
+	}
+	for name, values := range header {
+		if name != "Set-Cookie" {
+			r.header.Del(name)
+		}
+		for i := range values {
+			if name == "Set-Cookie" && containsValue(r.header[name], values[i]) {
+				continue
+			}
+			r.header.Add(name, values[i])
+		}
+	}
+}
+
+func containsValue(values []string, value string) bool {
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// CopyTo adds the collected headers to header, e.g. the header of the client response
+func (r *ResponseHeaders) CopyTo(header http.Header) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for key, values := range r.header {
+		for i := range values {
+			header.Add(key, values[i])
+		}
+	}
+}
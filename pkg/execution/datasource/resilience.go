@@ -0,0 +1,283 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/astvisitor"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a ResilientDataSource instead of calling the upstream while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ResilienceConfiguration configures how calls to a DataSource get protected against slow or failing upstreams
+// All values are optional, the zero value disables the according feature
+type ResilienceConfiguration struct {
+	// TimeoutMilliseconds is the max duration of a single call to the DataSource
+	TimeoutMilliseconds int `json:"timeoutMilliseconds"`
+	// MaxRetries is the number of retries after a failed call
+	// Retries are only applied to idempotent operations, that is queries
+	MaxRetries int `json:"maxRetries"`
+	// RetryBackoffMilliseconds is the delay before the first retry, the delay doubles with each further retry
+	RetryBackoffMilliseconds int `json:"retryBackoffMilliseconds"`
+	// CircuitBreakerThreshold is the number of consecutive failed calls after which the circuit breaker opens
+	// An open circuit breaker fails all calls immediately
+	CircuitBreakerThreshold int `json:"circuitBreakerThreshold"`
+	// CircuitBreakerCooldownMilliseconds is the duration an open circuit breaker waits before it lets a single trial call through
+	CircuitBreakerCooldownMilliseconds int `json:"circuitBreakerCooldownMilliseconds"`
+}
+
+// ResilientDataSource wraps a DataSource with a timeout per call, retries with exponential backoff and a circuit breaker
+// The result of a call only gets written to out if the call succeeded so that failed attempts don't leave partial data behind
+type ResilientDataSource struct {
+	DataSource DataSource
+	Config     ResilienceConfiguration
+	// Idempotent enables retries, calls with side effects (mutations) must never be retried
+	Idempotent bool
+	// CircuitBreaker is shared by all DataSources calling the same upstream, see BasePlanner.RegisterDataSourcePlannerFactory
+	// It's nil if circuit breaking is disabled
+	CircuitBreaker *CircuitBreaker
+}
+
+func (r *ResilientDataSource) Resolve(ctx context.Context, args ResolverArgs, out io.Writer) (n int, err error) {
+	buf := bytes.Buffer{}
+	err = r.call(ctx, func(ctx context.Context) error {
+		buf.Reset()
+		_, err := r.DataSource.Resolve(ctx, args, &buf)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return out.Write(buf.Bytes())
+}
+
+func (r *ResilientDataSource) Deduplicable() bool {
+	return IsDeduplicable(r.DataSource)
+}
+
+func (r *ResilientDataSource) Unwrap() DataSource {
+	return r.DataSource
+}
+
+func (r *ResilientDataSource) call(ctx context.Context, call func(ctx context.Context) error) (err error) {
+	backoff := time.Duration(r.Config.RetryBackoffMilliseconds) * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		allowed, trial := r.CircuitBreaker.Allow()
+		if !allowed {
+			if attempt > 0 { // a failed attempt opened the circuit, the upstream error is more useful than ErrCircuitOpen
+				return err
+			}
+			return ErrCircuitOpen
+		}
+		err = r.attempt(ctx, call)
+		if ctx.Err() != nil { // the caller gave up, that's not the fault of the upstream
+			r.CircuitBreaker.Abandon(trial)
+			return err
+		}
+		r.CircuitBreaker.Report(trial, err)
+		if err == nil || !r.Idempotent || attempt >= r.Config.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (r *ResilientDataSource) attempt(ctx context.Context, call func(ctx context.Context) error) error {
+	if r.Config.TimeoutMilliseconds <= 0 {
+		return call(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(r.Config.TimeoutMilliseconds)*time.Millisecond)
+	defer cancel()
+	return call(ctx)
+}
+
+// ResilientBatchDataSource is the ResilientDataSource for DataSources implementing BatchDataSource
+type ResilientBatchDataSource struct {
+	ResilientDataSource
+}
+
+func (r *ResilientBatchDataSource) ResolveBatch(ctx context.Context, args []ResolverArgs, outs []io.Writer) (n int, err error) {
+	bufs := make([]bytes.Buffer, len(outs))
+	writers := make([]io.Writer, len(outs))
+	for i := range bufs {
+		writers[i] = &bufs[i]
+	}
+	err = r.call(ctx, func(ctx context.Context) error {
+		for i := range bufs {
+			bufs[i].Reset()
+		}
+		_, err := r.DataSource.(BatchDataSource).ResolveBatch(ctx, args, writers)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i := range outs {
+		written, err := outs[i].Write(bufs[i].Bytes())
+		n += written
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// NewResilientDataSource wraps the DataSource with the resilience configuration
+// The returned DataSource implements BatchDataSource if the wrapped DataSource does
+func NewResilientDataSource(source DataSource, config ResilienceConfiguration, idempotent bool, circuitBreaker *CircuitBreaker) DataSource {
+	resilient := ResilientDataSource{
+		DataSource:     source,
+		Config:         config,
+		Idempotent:     idempotent,
+		CircuitBreaker: circuitBreaker,
+	}
+	if _, ok := source.(BatchDataSource); ok {
+		return &ResilientBatchDataSource{
+			ResilientDataSource: resilient,
+		}
+	}
+	return &resilient
+}
+
+// CircuitBreaker counts consecutive failures of an upstream
+// After Threshold consecutive failures the circuit breaker opens and denies all calls until Cooldown has passed
+// After the Cooldown a single trial call is allowed, a success closes the circuit breaker, a failure opens it again
+// A nil CircuitBreaker allows all calls
+type CircuitBreaker struct {
+	mux       sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	// trial is true while the trial call of the open circuit breaker hasn't reported back
+	trial bool
+	now   func() time.Time
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow reports if a call to the upstream is allowed, trial is true for the trial call of an open circuit breaker
+// No further call is allowed until the trial call got reported
+// Each allowed call must be followed by a call to Report or Abandon with the returned trial
+func (c *CircuitBreaker) Allow() (allowed, trial bool) {
+	if c == nil {
+		return true, false
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.failures < c.threshold {
+		return true, false
+	}
+	if c.trial || c.now().Sub(c.openedAt) < c.cooldown {
+		return false, false
+	}
+	c.trial = true
+	return true, true
+}
+
+// Abandon records an allowed call which ended without a result of the upstream, e.g. because the caller canceled it
+// An abandoned trial call lets the next call through as trial call
+func (c *CircuitBreaker) Abandon(trial bool) {
+	if c == nil || !trial {
+		return
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.trial = false
+}
+
+// Report records the result of an allowed call to the upstream
+func (c *CircuitBreaker) Report(trial bool, err error) {
+	if c == nil {
+		return
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if trial {
+		c.trial = false
+	}
+	if err == nil {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures >= c.threshold {
+		c.openedAt = c.now()
+	}
+}
+
+type resilientPlannerFactory struct {
+	PlannerFactory
+	config         ResilienceConfiguration
+	circuitBreaker *CircuitBreaker
+}
+
+// NewResilientPlannerFactory wraps the DataSources of all Planners created by the factory with the resilience configuration
+// All DataSources created by the factory use the circuitBreaker, it should be shared with all other factories calling the same upstream
+// A nil circuitBreaker disables circuit breaking
+func NewResilientPlannerFactory(factory PlannerFactory, config ResilienceConfiguration, circuitBreaker *CircuitBreaker) PlannerFactory {
+	return &resilientPlannerFactory{
+		PlannerFactory: factory,
+		config:         config,
+		circuitBreaker: circuitBreaker,
+	}
+}
+
+func (r *resilientPlannerFactory) DataSourcePlanner() Planner {
+	return &resilientPlanner{
+		Planner: r.PlannerFactory.DataSourcePlanner(),
+		factory: r,
+	}
+}
+
+type resilientPlanner struct {
+	Planner
+	factory   *resilientPlannerFactory
+	operation *ast.Document
+	walker    *astvisitor.Walker
+}
+
+func (r *resilientPlanner) Configure(operation, definition *ast.Document, walker *astvisitor.Walker) {
+	r.operation, r.walker = operation, walker
+	r.Planner.Configure(operation, definition, walker)
+}
+
+func (r *resilientPlanner) Plan(args []Argument) (DataSource, []Argument) {
+	source, args := r.Planner.Plan(args)
+	return NewResilientDataSource(source, r.factory.config, r.isQuery(), r.factory.circuitBreaker), args
+}
+
+// AddRequiredFields passes the RequiredFields on to the wrapped Planner if it's a RequiredFieldsPlanner
+func (r *resilientPlanner) AddRequiredFields(fieldNames []string) {
+	if planner, ok := r.Planner.(RequiredFieldsPlanner); ok {
+		planner.AddRequiredFields(fieldNames)
+	}
+}
+
+// isQuery reports if the planned field is part of a query operation, only queries are safe to be retried
+func (r *resilientPlanner) isQuery() bool {
+	if r.operation == nil || r.walker == nil || len(r.walker.Ancestors) == 0 {
+		return false
+	}
+	root := r.walker.Ancestors[0]
+	if root.Kind != ast.NodeKindOperationDefinition {
+		return false
+	}
+	return r.operation.OperationDefinitions[root.Ref].OperationType == ast.OperationTypeQuery
+}
@@ -0,0 +1,107 @@
+package datasource
+
+import (
+	"github.com/tidwall/gjson"
+)
+
+// TypeResolver resolves the name of the concrete type of an object returned for an abstract type (interface or union)
+// It's used for upstreams which don't return the "__typename" of their objects
+// ok is false if the type couldn't be resolved
+type TypeResolver interface {
+	ResolveTypeName(data []byte) (typeName string, ok bool)
+}
+
+// TypeResolverFunc is a TypeResolver implemented by a Go func
+type TypeResolverFunc func(data []byte) (typeName string, ok bool)
+
+func (t TypeResolverFunc) ResolveTypeName(data []byte) (typeName string, ok bool) {
+	return t(data)
+}
+
+// TypeResolverConfiguration configures how the concrete types of an interface or union get resolved
+// If multiple strategies are configured they are tried in the order: TypeResolver, Discriminator, FieldPresence, DefaultTypeName
+type TypeResolverConfiguration struct {
+	// TypeName is the name of the interface or union
+	TypeName string
+	// Discriminator resolves the type by the value of a field of the object
+	Discriminator *DiscriminatorConfiguration
+	// FieldPresence resolves the type by the fields present on the object, the first matching entry wins
+	FieldPresence []FieldPresenceConfiguration
+	// DefaultTypeName is used if no other strategy resolved the type
+	DefaultTypeName string
+	// TypeResolver is a custom strategy, e.g. a TypeResolverFunc, it can't be configured from JSON
+	TypeResolver TypeResolver `json:"-"`
+}
+
+// DiscriminatorConfiguration resolves the type by the value of a field of the object
+type DiscriminatorConfiguration struct {
+	// Path is the path of the discriminator field, e.g. "kind" or "meta.kind"
+	Path string
+	// Mapping maps the value of the discriminator field to the type name
+	// If Mapping is empty the value of the discriminator field is the type name
+	Mapping map[string]string
+}
+
+// FieldPresenceConfiguration resolves the type TypeName if all Fields are present on the object
+type FieldPresenceConfiguration struct {
+	TypeName string
+	Fields   []string
+}
+
+func (d *DiscriminatorConfiguration) ResolveTypeName(data []byte) (typeName string, ok bool) {
+	result := gjson.GetBytes(data, d.Path)
+	if !result.Exists() {
+		return "", false
+	}
+	value := result.String()
+	if len(d.Mapping) == 0 {
+		return value, value != ""
+	}
+	typeName, ok = d.Mapping[value]
+	return typeName, ok
+}
+
+func (f *FieldPresenceConfiguration) ResolveTypeName(data []byte) (typeName string, ok bool) {
+	for i := range f.Fields {
+		if !gjson.GetBytes(data, f.Fields[i]).Exists() {
+			return "", false
+		}
+	}
+	return f.TypeName, true
+}
+
+// typeResolverChain returns the type name of the first TypeResolver which is able to resolve the type
+type typeResolverChain []TypeResolver
+
+func (t typeResolverChain) ResolveTypeName(data []byte) (typeName string, ok bool) {
+	for i := range t {
+		typeName, ok = t[i].ResolveTypeName(data)
+		if ok {
+			return typeName, true
+		}
+	}
+	return "", false
+}
+
+func (c *TypeResolverConfiguration) typeResolver() TypeResolver {
+	var chain typeResolverChain
+	if c.TypeResolver != nil {
+		chain = append(chain, c.TypeResolver)
+	}
+	if c.Discriminator != nil {
+		chain = append(chain, c.Discriminator)
+	}
+	for i := range c.FieldPresence {
+		chain = append(chain, &c.FieldPresence[i])
+	}
+	if c.DefaultTypeName != "" {
+		defaultTypeName := c.DefaultTypeName
+		chain = append(chain, TypeResolverFunc(func(data []byte) (string, bool) {
+			return defaultTypeName, true
+		}))
+	}
+	if len(chain) == 1 {
+		return chain[0]
+	}
+	return chain
+}
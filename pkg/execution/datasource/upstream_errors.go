@@ -0,0 +1,59 @@
+package datasource
+
+import (
+	"context"
+	"sync"
+)
+
+// UpstreamError is an error returned by an upstream next to (partial) data, e.g. an entry of the "errors" of a GraphQL response
+// Path is the path of the error within the response of the upstream, its first segment is the root field of the upstream query
+type UpstreamError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// UpstreamErrors collects the UpstreamErrors of a call to a DataSource
+// DataSources get the UpstreamErrors of the current call with UpstreamErrorsFromContext
+// It's safe to be used from multiple goroutines
+type UpstreamErrors struct {
+	mux    sync.Mutex
+	errors map[int][]UpstreamError
+}
+
+type upstreamErrorsKey struct{}
+
+// WithUpstreamErrors returns a copy of ctx which makes DataSources report their UpstreamErrors to errors
+func WithUpstreamErrors(ctx context.Context, errors *UpstreamErrors) context.Context {
+	return context.WithValue(ctx, upstreamErrorsKey{}, errors)
+}
+
+// UpstreamErrorsFromContext returns the UpstreamErrors of the call, it's nil if the caller doesn't collect them
+func UpstreamErrorsFromContext(ctx context.Context) *UpstreamErrors {
+	errors, _ := ctx.Value(upstreamErrorsKey{}).(*UpstreamErrors)
+	return errors
+}
+
+// Add adds errors for the item, item is the index of the args in a call to ResolveBatch and 0 for calls to Resolve
+// It's safe to be called on nil UpstreamErrors which drops the errors
+func (u *UpstreamErrors) Add(item int, errors ...UpstreamError) {
+	if u == nil || len(errors) == 0 {
+		return
+	}
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	if u.errors == nil {
+		u.errors = map[int][]UpstreamError{}
+	}
+	u.errors[item] = append(u.errors[item], errors...)
+}
+
+// Item returns the errors of the item, it's safe to be called on nil UpstreamErrors
+func (u *UpstreamErrors) Item(item int) []UpstreamError {
+	if u == nil {
+		return nil
+	}
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	return u.errors[item]
+}
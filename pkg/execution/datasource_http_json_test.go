@@ -10,6 +10,8 @@ import (
 	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
 	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
 	"github.com/tidwall/gjson"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -119,6 +121,8 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 										Value: []byte(`{"defaultTypeName":"SimpleType"}`),
 									},
 								},
+								ParentType: "Query",
+								ReturnType: "SimpleType",
 							},
 						},
 						Fields: []Field{
@@ -137,6 +141,10 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   4,
+												Column: 5,
+											},
 										},
 										{
 											Name: []byte("scalarField"),
@@ -148,9 +156,17 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   5,
+												Column: 5,
+											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 4,
+								},
 							},
 						},
 					},
@@ -233,6 +249,8 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 										Value: []byte(`{"500":"ErrorType","defaultTypeName":"SuccessType"}`),
 									},
 								},
+								ParentType: "Query",
+								ReturnType: "UnionType",
 							},
 						},
 						Fields: []Field{
@@ -251,6 +269,10 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   4,
+												Column: 5,
+											},
 										},
 										{
 											Name: []byte("result"),
@@ -272,6 +294,10 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   6,
+												Column: 6,
+											},
 										},
 										{
 											Name: []byte("message"),
@@ -293,9 +319,17 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   9,
+												Column: 6,
+											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 4,
+								},
 							},
 						},
 					},
@@ -379,6 +413,8 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 										Value: []byte(`{"500":"ErrorInterface","defaultTypeName":"SuccessInterface"}`),
 									},
 								},
+								ParentType: "Query",
+								ReturnType: "InterfaceType",
 							},
 						},
 						Fields: []Field{
@@ -397,6 +433,10 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   4,
+												Column: 5,
+											},
 										},
 										{
 											Name: []byte("name"),
@@ -407,6 +447,11 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 													},
 												},
 												ValueType: StringValueType,
+												NonNull:   true,
+											},
+											Position: Position{
+												Line:   5,
+												Column: 5,
 											},
 										},
 										{
@@ -428,6 +473,11 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 													},
 												},
 												ValueType: StringValueType,
+												NonNull:   true,
+											},
+											Position: Position{
+												Line:   7,
+												Column: 6,
 											},
 										},
 										{
@@ -449,10 +499,19 @@ func TestHttpJsonDataSourcePlanner_Plan(t *testing.T) {
 													},
 												},
 												ValueType: StringValueType,
+												NonNull:   true,
+											},
+											Position: Position{
+												Line:   10,
+												Column: 6,
 											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 4,
+								},
 							},
 						},
 					},
@@ -521,3 +580,153 @@ func TestHttpJsonDataSource_Resolve(t *testing.T) {
 		`{"500":"ErrorInterface","200":"AnotherSuccess","defaultTypeName":"SuccessInterface"}`,
 		"AnotherSuccess"))
 }
+
+func TestHttpJsonDataSource_ResolveBatch(t *testing.T) {
+
+	args := func(host, id string) ResolvedArgs {
+		return ResolvedArgs{
+			{
+				Key:   []byte("host"),
+				Value: []byte(host),
+			},
+			{
+				Key:   []byte("url"),
+				Value: []byte("/user/" + id),
+			},
+			{
+				Key:   []byte("method"),
+				Value: []byte("GET"),
+			},
+			{
+				Key:   []byte("batchUrl"),
+				Value: []byte("/users"),
+			},
+			{
+				Key:   []byte("batchMethod"),
+				Value: []byte("POST"),
+			},
+			{
+				Key:   []byte("batchInput"),
+				Value: []byte(`{"id":` + id + `}`),
+			},
+			{
+				Key:   []byte("__typename"),
+				Value: []byte(`{"defaultTypeName":"User"}`),
+			},
+		}
+	}
+
+	t.Run("bulk endpoint", func(t *testing.T) {
+		requests := 0
+		fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.Method != http.MethodPost || r.URL.Path != "/users" || string(body) != `[{"id":1},{"id":2}]` {
+				t.Errorf("unexpected request: %s %s %s", r.Method, r.URL.Path, string(body))
+			}
+			_, _ = w.Write([]byte(`[{"name":"one"},{"name":"two"}]`))
+		}))
+		defer fakeServer.Close()
+
+		source := &datasource.HttpJsonDataSource{
+			Log: abstractlogger.Noop{},
+		}
+		first, second := bytes.Buffer{}, bytes.Buffer{}
+		_, err := source.ResolveBatch(context.Background(), []datasource.ResolverArgs{args(fakeServer.URL, "1"), args(fakeServer.URL, "2")}, []io.Writer{&first, &second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if requests != 1 {
+			t.Fatalf("want 1 request, got: %d", requests)
+		}
+		if first.String() != `{"__typename":"User","name":"one"}` {
+			t.Fatalf("unexpected first result: %s", first.String())
+		}
+		if second.String() != `{"__typename":"User","name":"two"}` {
+			t.Fatalf("unexpected second result: %s", second.String())
+		}
+	})
+	t.Run("escaped batch input", func(t *testing.T) {
+		fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			if string(body) != `[{"id":"1"},{"id":"2"}]` {
+				t.Errorf("unexpected body: %s", string(body))
+			}
+			_, _ = w.Write([]byte(`[{"name":"one"},{"name":"two"}]`))
+		}))
+		defer fakeServer.Close()
+
+		escaped := func(id string) ResolvedArgs {
+			resolved := args(fakeServer.URL, id)
+			resolved.Filter(func(i int) bool { return !bytes.Equal(resolved[i].Key, []byte("batchInput")) })
+			return append(resolved, ResolvedArgument{
+				Key:   []byte("batchInput"),
+				Value: []byte(`{\"id\":\"` + id + `\"}`),
+			})
+		}
+		source := &datasource.HttpJsonDataSource{
+			Log: abstractlogger.Noop{},
+		}
+		_, err := source.ResolveBatch(context.Background(), []datasource.ResolverArgs{escaped("1"), escaped("2")}, []io.Writer{&bytes.Buffer{}, &bytes.Buffer{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("error status code", func(t *testing.T) {
+		fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`[{"message":"one"},{"message":"two"}]`))
+		}))
+		defer fakeServer.Close()
+
+		source := &datasource.HttpJsonDataSource{
+			Log: abstractlogger.Noop{},
+		}
+		first, second := bytes.Buffer{}, bytes.Buffer{}
+		_, err := source.ResolveBatch(context.Background(), []datasource.ResolverArgs{args(fakeServer.URL, "1"), args(fakeServer.URL, "2")}, []io.Writer{&first, &second})
+		if err == nil {
+			t.Fatal("want err")
+		}
+		if first.Len() != 0 || second.Len() != 0 {
+			t.Fatalf("want no results, got: %s, %s", first.String(), second.String())
+		}
+	})
+	t.Run("result count mismatch", func(t *testing.T) {
+		fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[{"name":"one"}]`))
+		}))
+		defer fakeServer.Close()
+
+		source := &datasource.HttpJsonDataSource{
+			Log: abstractlogger.Noop{},
+		}
+		_, err := source.ResolveBatch(context.Background(), []datasource.ResolverArgs{args(fakeServer.URL, "1"), args(fakeServer.URL, "2")}, []io.Writer{&bytes.Buffer{}, &bytes.Buffer{}})
+		if err == nil {
+			t.Fatal("want err")
+		}
+	})
+	t.Run("without bulk endpoint", func(t *testing.T) {
+		fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+		}))
+		defer fakeServer.Close()
+
+		source := &datasource.HttpJsonDataSource{
+			Log: abstractlogger.Noop{},
+		}
+		first, second := args(fakeServer.URL, "1"), args(fakeServer.URL, "2")
+		first.Filter(func(i int) bool { return !bytes.HasPrefix(first[i].Key, []byte("batch")) })
+		second.Filter(func(i int) bool { return !bytes.HasPrefix(second[i].Key, []byte("batch")) })
+		firstOut, secondOut := bytes.Buffer{}, bytes.Buffer{}
+		_, err := source.ResolveBatch(context.Background(), []datasource.ResolverArgs{first, second}, []io.Writer{&firstOut, &secondOut})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if firstOut.String() != `{"__typename":"User","path":"/user/1"}` {
+			t.Fatalf("unexpected first result: %s", firstOut.String())
+		}
+		if secondOut.String() != `{"__typename":"User","path":"/user/2"}` {
+			t.Fatalf("unexpected second result: %s", secondOut.String())
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package execution
+
+import (
+	"bytes"
+	"github.com/cespare/xxhash"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"io"
+	"sync"
+)
+
+// fetchGroup deduplicates the calls to DataSources within the execution of a query
+// Calls of a datasource.DeduplicableDataSource with identical args share one call and its result
+// Mutations aren't deduplicated as each of their fields must have its side effects
+type fetchGroup struct {
+	mux   sync.Mutex
+	calls map[fetchKey]*fetchCall
+}
+
+// fetchKey identifies a call by the DataSource instance and the hash of the args
+// The DataSource is the unwrapped DataSource so that its configuration, e.g. the upstream or forwarded headers, is part of the identity
+// DataSources implementing datasource.DeduplicableDataSource are pointers which makes them comparable
+type fetchKey struct {
+	source datasource.DataSource
+	args   uint64
+}
+
+// fetchCall is a call to a DataSource, done gets closed once data, upstreamErrors and err are set
+type fetchCall struct {
+	done           chan struct{}
+	data           []byte
+	upstreamErrors []datasource.UpstreamError
+	err            error
+}
+
+func newFetchGroup() *fetchGroup {
+	return &fetchGroup{
+		calls: map[fetchKey]*fetchCall{},
+	}
+}
+
+// resolve calls the DataSource unless an identical call was started before, shared reports if the result of another call got used
+// It's safe to be called on a nil fetchGroup which disables deduplication
+func (f *fetchGroup) resolve(ctx Context, source datasource.DataSource, args ResolvedArgs, out io.Writer) (n int, shared bool, err error) {
+	if f == nil || !datasource.IsDeduplicable(source) {
+		n, err = source.Resolve(ctx, args, out)
+		return n, false, err
+	}
+
+	key := fetchKey{
+		source: datasource.Unwrap(source),
+		args:   argsKey(args),
+	}
+	f.mux.Lock()
+	call, shared := f.calls[key]
+	if !shared {
+		call = &fetchCall{
+			done: make(chan struct{}),
+		}
+		f.calls[key] = call
+	}
+	f.mux.Unlock()
+
+	if shared {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return 0, true, ctx.Err()
+		}
+	} else {
+		buf := bytes.Buffer{}
+		_, call.err = source.Resolve(ctx, args, &buf)
+		call.data = buf.Bytes()
+		call.upstreamErrors = datasource.UpstreamErrorsFromContext(ctx).Item(0)
+		close(call.done)
+	}
+
+	if call.err != nil {
+		return 0, shared, call.err
+	}
+	if shared {
+		datasource.UpstreamErrorsFromContext(ctx).Add(0, call.upstreamErrors...)
+	}
+	n, err = out.Write(call.data)
+	return n, shared, err
+}
+
+// argsKey hashes the args
+func argsKey(args ResolvedArgs) uint64 {
+	digest := xxhash.New()
+	for i := range args {
+		_, _ = digest.Write(args[i].Key)
+		_, _ = digest.Write([]byte{0})
+		_, _ = digest.Write(args[i].Value)
+		_, _ = digest.Write([]byte{0})
+	}
+	return digest.Sum64()
+}
@@ -0,0 +1,120 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingDataSource counts its calls and returns the "id" arg after a short delay so that concurrent calls overlap
+type countingDataSource struct {
+	mux          sync.Mutex
+	calls        int
+	deduplicable bool
+}
+
+func (c *countingDataSource) Resolve(ctx context.Context, args datasource.ResolverArgs, out io.Writer) (n int, err error) {
+	c.mux.Lock()
+	c.calls++
+	c.mux.Unlock()
+	time.Sleep(time.Millisecond * 10)
+	return out.Write(args.ByKey([]byte("id")))
+}
+
+func (c *countingDataSource) Deduplicable() bool {
+	return c.deduplicable
+}
+
+func TestExecutor_FetchDeduplication(t *testing.T) {
+
+	plan := func(operationType ast.OperationType, source datasource.DataSource, ids ...string) RootNode {
+		data := &Object{
+			Fetch: &ParallelFetch{},
+		}
+		for i, id := range ids {
+			name := string(rune('a' + i))
+			data.Fetch.(*ParallelFetch).Fetches = append(data.Fetch.(*ParallelFetch).Fetches, &SingleFetch{
+				Source: &DataSourceInvocation{
+					Args: []datasource.Argument{
+						&datasource.StaticVariableArgument{
+							Name:  []byte("id"),
+							Value: []byte(id),
+						},
+					},
+					DataSource: source,
+				},
+				BufferName: name,
+			})
+			data.Fields = append(data.Fields, Field{
+				Name:            []byte(name),
+				HasResolvedData: true,
+				Value: &Value{
+					ValueType: StringValueType,
+				},
+			})
+		}
+		return &Object{
+			operationType: operationType,
+			Fields: []Field{
+				{
+					Name:  []byte("data"),
+					Value: data,
+				},
+			},
+		}
+	}
+
+	test := func(operationType ast.OperationType, deduplicable bool, ids []string, wantCalls int, want string) func(t *testing.T) {
+		return func(t *testing.T) {
+			source := &countingDataSource{deduplicable: deduplicable}
+			node := plan(operationType, source, ids...)
+			executor := NewExecutor(nil)
+			for i := 0; i < 2; i++ { // the results must not be shared between executions
+				out := bytes.Buffer{}
+				if err := executor.Execute(Context{Context: context.Background()}, node, &out); err != nil {
+					t.Fatal(err)
+				}
+				if out.String() != want {
+					t.Fatalf("want: %s\ngot: %s\n", want, out.String())
+				}
+			}
+			if source.calls != wantCalls*2 {
+				t.Fatalf("want %d calls per execution, got: %d calls in 2 executions", wantCalls, source.calls)
+			}
+		}
+	}
+
+	t.Run("identical args", test(ast.OperationTypeQuery, true, []string{"1", "1", "1"}, 1, `{"data":{"a":"1","b":"1","c":"1"}}`))
+	t.Run("different args", test(ast.OperationTypeQuery, true, []string{"1", "2", "1"}, 2, `{"data":{"a":"1","b":"2","c":"1"}}`))
+	t.Run("data source not deduplicable", test(ast.OperationTypeQuery, false, []string{"1", "1"}, 2, `{"data":{"a":"1","b":"1"}}`))
+	t.Run("mutation", test(ast.OperationTypeMutation, true, []string{"1", "1"}, 2, `{"data":{"a":"1","b":"1"}}`))
+	t.Run("different data sources of the same type", func(t *testing.T) {
+		first, second := &countingDataSource{deduplicable: true}, &countingDataSource{deduplicable: true}
+		node := plan(ast.OperationTypeQuery, first, "1", "1")
+		node.(*Object).Fields[0].Value.(*Object).Fetch.(*ParallelFetch).Fetches[1].(*SingleFetch).Source.DataSource = second
+		out := bytes.Buffer{}
+		if err := NewExecutor(nil).Execute(Context{Context: context.Background()}, node, &out); err != nil {
+			t.Fatal(err)
+		}
+		if first.calls != 1 || second.calls != 1 {
+			t.Fatalf("want one call per data source, got: %d and %d calls", first.calls, second.calls)
+		}
+	})
+	t.Run("wrapped data source", func(t *testing.T) {
+		source := &countingDataSource{deduplicable: true}
+		node := plan(ast.OperationTypeQuery, source, "1", "1")
+		node.(*Object).Fields[0].Value.(*Object).Fetch.(*ParallelFetch).Fetches[1].(*SingleFetch).Source.DataSource = datasource.NewResilientDataSource(source, datasource.ResilienceConfiguration{}, true, nil)
+		out := bytes.Buffer{}
+		if err := NewExecutor(nil).Execute(Context{Context: context.Background()}, node, &out); err != nil {
+			t.Fatal(err)
+		}
+		if source.calls != 1 {
+			t.Fatalf("want calls of the wrapped data source to be shared, got: %d calls", source.calls)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package execution
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jensneuse/graphql-go-tools/internal/pkg/unsafebytes"
+)
+
+// Enum is the enum type of a Value
+// Values returned by the upstream get mapped to enum values and validated against the enum values of the schema
+type Enum struct {
+	// Name is the name of the enum in the schema
+	Name string
+	// Values are the names of all enum values of the enum
+	Values [][]byte
+	// Mapping maps values returned by the upstream to enum values, e.g. "in_progress" to "IN_PROGRESS"
+	// Upstream values which are no key of the mapping are used as they are
+	Mapping map[string]string
+}
+
+// ErrEnumValueInvalid is the field error for values which are no enum value of the Enum
+type ErrEnumValueInvalid struct {
+	value []byte
+	enum  string
+}
+
+func (e ErrEnumValueInvalid) Error() string {
+	return fmt.Sprintf("Enum %s cannot represent value: %s", e.enum, unsafebytes.BytesToString(e.value))
+}
+
+// resolveValue returns the enum value for the value returned by the upstream
+// ok is false if the (mapped) value is no enum value of the Enum
+func (e *Enum) resolveValue(value []byte) (enumValue []byte, ok bool) {
+	if mapped, exists := e.Mapping[unsafebytes.BytesToString(value)]; exists {
+		value = unsafebytes.StringToBytes(mapped)
+	}
+	for i := range e.Values {
+		if bytes.Equal(value, e.Values[i]) {
+			return e.Values[i], true
+		}
+	}
+	return nil, false
+}
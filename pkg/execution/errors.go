@@ -0,0 +1,62 @@
+package execution
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errNonNullFieldResolvedNull = errors.New("cannot return null for non-nullable field")
+
+// Position is the location of a field in the operation document
+type Position struct {
+	Line   uint32 `json:"line"`
+	Column uint32 `json:"column"`
+}
+
+// ResolveError is a field error as defined in https://graphql.github.io/graphql-spec/June2018/#sec-Errors
+type ResolveError struct {
+	Message    string                 `json:"message"`
+	Locations  []Position             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type ResolveErrors []ResolveError
+
+// responsePath turns the dot separated path of the executor, e.g. "query.data.users.0.name",
+// into the response path of an error, e.g. ["users",0,"name"]
+// The first two segments (operation type and data) are omitted as they're not part of the response path
+func responsePath(path string) []interface{} {
+	segments := strings.Split(path, ".")
+	if len(segments) <= 2 {
+		return nil
+	}
+	segments = segments[2:]
+	out := make([]interface{}, len(segments))
+	for i := range segments {
+		if index, err := strconv.Atoi(segments[i]); err == nil {
+			out[i] = index
+			continue
+		}
+		out[i] = segments[i]
+	}
+	return out
+}
+
+// upstreamPath rewrites the path of an upstream error relative to the response path of the field the upstream got resolved for
+// The first segment of the upstream path is the root field of the upstream query which is replaced by the field, e.g.
+// the upstream path ["user","friends",0] of the field at "query.data.posts.1.author" becomes ["posts",1,"author","friends",0]
+func upstreamPath(fieldPath string, path []interface{}) []interface{} {
+	out := responsePath(fieldPath)
+	if len(path) < 2 {
+		return out
+	}
+	for _, segment := range path[1:] {
+		if index, ok := segment.(float64); ok { // indices of decoded JSON paths are floats
+			segment = int(index)
+		}
+		out = append(out, segment)
+	}
+	return out
+}
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/buger/jsonparser"
 	"github.com/cespare/xxhash"
 	"github.com/jensneuse/byte-template"
@@ -15,6 +16,7 @@ import (
 	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
 	"github.com/jensneuse/graphql-go-tools/pkg/lexer/runes"
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 	"io"
 	"strconv"
 	"sync"
@@ -22,45 +24,213 @@ import (
 
 type Executor struct {
 	context            Context
-	out                io.Writer
+	out                *bytes.Buffer
 	err                error
+	errors             ResolveErrors
+	errorsMux          sync.Mutex
+	position           Position // position of the field currently being resolved, used to locate errors
 	buffers            LockableBufferMap
 	escapeBuf          [48]byte
 	templateDirectives []byte_template.DirectiveDefinition
+	// prefetchSlots limits the number of concurrent list item prefetches, it's nil if unlimited
+	prefetchSlots chan struct{}
+	// incremental is true during ExecuteIncremental, otherwise deferred fragments and streamed lists get resolved in place
+	incremental bool
+	// patches are the deferred fragments and streamed lists which get resolved after the current payload
+	patches []patch
+	// extensions are written to the "extensions" field of the response
+	extensions []responseExtension
+	// instrumentation receives the events of the execution, it's nil if instrumentation is disabled
+	instrumentation Instrumentation
 }
 
+type responseExtension struct {
+	key   string
+	value []byte
+}
+
+// DefaultMaxConcurrency is the default number of list items an Executor prefetches concurrently
+const DefaultMaxConcurrency = 32
+
 type LockableBufferMap struct {
 	sync.Mutex
 	Buffers map[uint64]*bytes.Buffer
+	// Errors holds the errors of failed fetches using the same keys as Buffers
+	Errors map[uint64]error
+	// UpstreamErrors holds the errors the upstreams returned next to the data of successful fetches using the same keys as Buffers
+	UpstreamErrors map[uint64][]datasource.UpstreamError
 }
 
 func NewExecutor(templateDirectives []byte_template.DirectiveDefinition) *Executor {
 	return &Executor{
 		buffers: LockableBufferMap{
-			Buffers: map[uint64]*bytes.Buffer{},
+			Buffers:        map[uint64]*bytes.Buffer{},
+			Errors:         map[uint64]error{},
+			UpstreamErrors: map[uint64][]datasource.UpstreamError{},
 		},
+		out:                bytes.NewBuffer(make([]byte, 0, 1024)),
 		templateDirectives: templateDirectives,
+		prefetchSlots:      make(chan struct{}, DefaultMaxConcurrency),
+	}
+}
+
+// ChangeMaxConcurrency changes the number of list items which get prefetched concurrently, a value <= 0 removes the limit
+// ChangeMaxConcurrency must not be called during execution
+func (e *Executor) ChangeMaxConcurrency(maxConcurrency int) {
+	if maxConcurrency <= 0 {
+		e.prefetchSlots = nil
+		return
 	}
+	e.prefetchSlots = make(chan struct{}, maxConcurrency)
 }
 
+// Execute resolves the RootNode and writes the response to w
+// Field errors don't abort the execution, they get collected and are written to the "errors" array of the response
+// The returned error is only non nil if the response could not be written at all
 func (e *Executor) Execute(ctx Context, node RootNode, w io.Writer) error {
+	e.reset(ctx, node)
+	e.executionStart()
+	path := rootPath(node)
+	switch root := node.(type) {
+	case *Object:
+		e.write(literal.LBRACE)
+		e.resolveObjectFields(root, nil, path)
+		e.executionEnd()
+		if len(e.errors) != 0 {
+			e.write(literal.COMMA)
+			e.writeErrors()
+		}
+		e.writeExtensions()
+		e.write(literal.RBRACE)
+	default:
+		e.resolveNode(node, nil, path, nil, true)
+		e.executionEnd()
+	}
+	if e.err != nil {
+		return e.err
+	}
+	_, err := e.out.WriteTo(w)
+	return err
+}
+
+func (e *Executor) reset(ctx Context, node RootNode) {
+	if ctx.Context == nil {
+		ctx.Context = context.Background()
+	}
+	ctx.instrumentation = e.instrumentation
+	ctx.fetches = nil
+	if node.OperationType() == ast.OperationTypeQuery {
+		ctx.fetches = newFetchGroup()
+	}
 	e.context = ctx
-	e.out = w
+	e.out.Reset()
 	e.err = nil
-	var path string
+	e.errors = e.errors[:0]
+	e.position = Position{}
+	e.patches = e.patches[:0]
+	for key := range e.buffers.Errors {
+		delete(e.buffers.Errors, key)
+	}
+	for key := range e.buffers.UpstreamErrors {
+		delete(e.buffers.UpstreamErrors, key)
+	}
+}
+
+// rootPath returns the path of the root node which is the prefix of all paths
+func rootPath(node RootNode) string {
 	switch node.OperationType() {
 	case ast.OperationTypeQuery:
-		path = "query"
+		return "query"
 	case ast.OperationTypeMutation:
-		path = "mutation"
+		return "mutation"
 	case ast.OperationTypeSubscription:
-		path = "subscription"
+		return "subscription"
+	default:
+		return ""
 	}
-	e.resolveNode(node, nil, path, nil, true)
-	return e.err
 }
 
-// write writes the data to the out io.Writer if there is no error previously captured
+// SetExtension sets the value of key in the "extensions" field of the response, value must be valid JSON
+// Extensions are kept across executions, an already set key gets replaced
+func (e *Executor) SetExtension(key string, value []byte) {
+	for i := range e.extensions {
+		if e.extensions[i].key == key {
+			e.extensions[i].value = value
+			return
+		}
+	}
+	e.extensions = append(e.extensions, responseExtension{
+		key:   key,
+		value: value,
+	})
+}
+
+// writeExtensions writes the "extensions" field of the response including the leading comma if there are any extensions
+func (e *Executor) writeExtensions() {
+	if len(e.extensions) == 0 {
+		return
+	}
+	e.write(literal.COMMA)
+	e.writeQuoted(literal.EXTENSIONS)
+	e.write(literal.COLON)
+	e.write(literal.LBRACE)
+	for i := range e.extensions {
+		if i != 0 {
+			e.write(literal.COMMA)
+		}
+		e.writeJSON(e.extensions[i].key)
+		e.write(literal.COLON)
+		e.write(e.extensions[i].value)
+	}
+	e.write(literal.RBRACE)
+}
+
+// writeErrors writes all collected errors as the "errors" field of the response
+func (e *Executor) writeErrors() {
+	e.writeQuoted(literal.ERRORS)
+	e.write(literal.COLON)
+	data, err := json.Marshal(e.errors)
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.write(data)
+}
+
+// addError records a field error for the response path using the position of the field currently being resolved
+// addError is safe to be called from multiple goroutines
+func (e *Executor) addError(path string, err error) {
+	resolveError := ResolveError{
+		Message: err.Error(),
+		Path:    responsePath(path),
+	}
+	if e.position.Line != 0 {
+		resolveError.Locations = []Position{e.position}
+	}
+	e.errorsMux.Lock()
+	e.errors = append(e.errors, resolveError)
+	e.errorsMux.Unlock()
+}
+
+// addUpstreamErrors records the errors an upstream returned for the field at path
+// The errors get located at the field as their locations refer to the query sent to the upstream
+func (e *Executor) addUpstreamErrors(path string, upstreamErrors []datasource.UpstreamError) {
+	for i := range upstreamErrors {
+		resolveError := ResolveError{
+			Message:    upstreamErrors[i].Message,
+			Path:       upstreamPath(path, upstreamErrors[i].Path),
+			Extensions: upstreamErrors[i].Extensions,
+		}
+		if e.position.Line != 0 {
+			resolveError.Locations = []Position{e.position}
+		}
+		e.errorsMux.Lock()
+		e.errors = append(e.errors, resolveError)
+		e.errorsMux.Unlock()
+	}
+}
+
+// write writes the data to the out buffer if there is no error previously captured
 func (e *Executor) write(data []byte) {
 	if e.err != nil {
 		return
@@ -68,125 +238,353 @@ func (e *Executor) write(data []byte) {
 	_, e.err = e.out.Write(data)
 }
 
-// writeQuoted quotes and writes the data to the out io.Writer if there is no error previously captured
+// writeQuoted quotes and writes the data to the out buffer if there is no error previously captured
 func (e *Executor) writeQuoted(data []byte) {
 	e.write(literal.QUOTE)
 	e.write(data)
 	e.write(literal.QUOTE)
 }
 
-func (e *Executor) resolveNode(node Node, data []byte, path string, prefetch *sync.WaitGroup, shouldFetch bool) {
+// resolveNode writes the node to the out buffer and reports if the node resolved to null
+// In case a nullable Object or List contains a null value for a non-null child the already written output gets truncated
+// and the Object or List itself resolves to null which propagates the null to the nearest nullable parent
+func (e *Executor) resolveNode(node Node, data []byte, path string, prefetch *sync.WaitGroup, shouldFetch bool) (isNull bool) {
 
 	switch node := node.(type) {
 	case *Object:
 		if data != nil { // in case data is not nil apply any path selection/transformation and return early if there is no data
-			data = e.resolveData(node.DataResolvingConfig, data)
+			data = e.resolveObjectData(node, data, path)
 			if data == nil || bytes.Equal(data, literal.NULL) {
+				if prefetch != nil { // there's nothing to prefetch for a null object
+					prefetch.Done()
+					return true
+				}
 				e.write(literal.NULL)
-				return
+				return true
 			}
 		}
-		if shouldFetch && node.Fetch != nil { // execute the fetch on the object
-			_,err := node.Fetch.Fetch(e.context, data, e, path, &e.buffers)
-			if err != nil {
-				e.err = err
-			}
+		if shouldFetch && node.hasFetch() { // execute the fetch on the object
+			e.fetch(node, data, path)
 			if prefetch != nil { // in case this was a prefetch we can immediately return
 				prefetch.Done()
-				return
+				return false
 			}
 		}
+		start, patches := e.out.Len(), len(e.patches)
 		e.write(literal.LBRACE) // start writing the object
-		hasPreviousValue := false
-		for i := 0; i < len(node.Fields); i++ {
-			if node.Fields[i].Skip != nil {
-				if node.Fields[i].Skip.Evaluate(e.context, data) {
-					continue
-				}
-			}
-			if hasPreviousValue { // separate all values with a comma in case we have at least one previous (unskipped field)
-				e.write(literal.COMMA)
-			}
-			hasPreviousValue = true
-			e.resolveNode(&node.Fields[i], data, path, nil, true) // recursively evaluate all fields
+		if e.resolveObjectFields(node, data, path) {
+			e.truncate(start, patches) // a non-null field resolved to null so the whole object becomes null
+			e.write(literal.NULL)
+			return true
 		}
 		e.write(literal.RBRACE) // end writing the object
+		return false
 	case *Field:
 		path = path + "." + unsafebytes.BytesToString(node.Name) // add the node name to the path using a "." as separator
-		if node.HasResolvedData {                                // in case this field has associated resolved data we have to fetch it from the buffer
-			if buf := e.buffers.Buffers[xxhash.Sum64String(path)]; buf != nil {
+		e.writeQuoted(node.Name)
+		e.write(literal.COLON)
+		parentPosition := e.position
+		e.position = node.Position
+		defer func() {
+			e.position = parentPosition
+		}()
+		var upstreamErrors []datasource.UpstreamError
+		if node.HasResolvedData { // in case this field has associated resolved data we have to fetch it from the buffer
+			hash := xxhash.Sum64String(path)
+			if err := e.buffers.Errors[hash]; err != nil {
+				e.addError(path, err)
+				e.write(literal.NULL)
+				return true
+			}
+			if buf := e.buffers.Buffers[hash]; buf != nil {
 				data = buf.Bytes()
 			}
+			upstreamErrors = e.buffers.UpstreamErrors[hash]
 		}
-		e.writeQuoted(node.Name)
-		e.write(literal.COLON)
+		errorCount := len(e.errors)
+		e.addUpstreamErrors(path, upstreamErrors) // errors of the upstream explain a null of the field
 		if data == nil && !node.Value.HasResolversRecursively() {
+			isNull = true
 			e.write(literal.NULL)
-			return
+		} else {
+			isNull = e.resolveNode(node.Value, data, path, nil, true)
+		}
+		if isNull && isNonNull(node.Value) && len(e.errors) == errorCount { // only report the null if there's no error explaining it already
+			e.addError(path, errNonNullFieldResolvedNull)
 		}
-		e.resolveNode(node.Value, data, path, nil, true)
+		return isNull
 	case *Value:
-		data = e.resolveData(node.DataResolvingConfig, data)
-		_, e.err = node.ValueType.writeValue(data, e.escapeBuf[:], e.out)
-		return
+		if node.Scalar != nil {
+			return e.resolveScalar(node, data, path)
+		}
+		data = e.resolveData(node.DataResolvingConfig, data, path)
+		if node.Enum != nil && len(data) != 0 && !bytes.Equal(data, literal.NULL) {
+			enumValue, ok := node.Enum.resolveValue(data)
+			if !ok {
+				e.addError(path, ErrEnumValueInvalid{
+					value: data,
+					enum:  node.Enum.Name,
+				})
+				e.write(literal.NULL)
+				return true
+			}
+			data = enumValue
+		}
+		_, err := node.ValueType.writeValue(data, e.escapeBuf[:], e.out)
+		if err == nil {
+			return len(data) == 0 || bytes.Equal(data, literal.NULL)
+		}
+		if _, ok := err.(ErrJSONValueTypeValueIncompatible); !ok {
+			e.err = err
+			return true
+		}
+		e.addError(path, err)
+		e.write(literal.NULL)
+		return true
 	case *List:
-		data = e.resolveData(node.DataResolvingConfig, data)
+		data = e.resolveData(node.DataResolvingConfig, data, path)
 		if len(data) == 0 {
 			e.write(literal.NULL)
-			return
-		}
-		shouldPrefetch := false
-		switch object := node.Value.(type) {
-		case *Object:
-			if object.Fetch != nil {
-				shouldPrefetch = true
-			}
+			return true
 		}
 		result := gjson.ParseBytes(data).Array()
+		if node.Filter != nil {
+			result = e.filterListItems(node.Filter, result)
+		}
 		listItems := make([][]byte, len(result))
+		raw := isScalar(node.Value) // custom scalars get the raw JSON value
 		for i := range result {
-			if result[i].Type == gjson.String {
+			if result[i].Type == gjson.String && !raw {
 				listItems[i] = unsafebytes.StringToBytes(result[i].Str)
 			} else {
 				listItems[i] = unsafebytes.StringToBytes(result[i].Raw)
 			}
 		}
-		path = path + "."
-		maxItems := len(listItems)
-		if node.Filter != nil {
-			switch filter := node.Filter.(type) {
-			case *ListFilterFirstN:
-				if maxItems > filter.FirstN {
-					maxItems = filter.FirstN
-				}
+		if node.Stream != nil && e.isStreamed(node.Stream, data) && len(listItems) > node.Stream.InitialCount {
+			for i := node.Stream.InitialCount; i < len(listItems); i++ {
+				e.patches = append(e.patches, patch{
+					label: node.Stream.Label,
+					path:  path,
+					list:  node,
+					items: listItems[i : i+1],
+					index: i,
+				})
 			}
+			listItems = listItems[:node.Stream.InitialCount]
 		}
-		if shouldPrefetch {
-			wg := &sync.WaitGroup{}
-			for i := 0; i < maxItems; i++ {
-				wg.Add(1)
-				go e.resolveNode(node.Value, listItems[i], path+strconv.Itoa(i), wg, true)
+		return e.resolveListItems(node, listItems, path, 0)
+	}
+	return false
+}
+
+// resolveListItems writes the items of the list, index is the index of the first item within the list
+// It returns true in case a non-null item resolved to null which means the list has to become null
+func (e *Executor) resolveListItems(node *List, listItems [][]byte, path string, index int) (isNull bool) {
+	path = path + "."
+	if object, ok := node.Value.(*Object); ok && object.hasFetch() {
+		if fetches := batchFetches(object); len(fetches) != 0 {
+			for _, fetch := range fetches {
+				e.batchPrefetch(object, fetch, listItems, path, index)
 			}
-			wg.Wait()
+		} else {
+			e.prefetch(node.Value, listItems, path, index)
 		}
-		i := 0
-		for i = 0; i < maxItems; i++ {
-			if i == 0 {
-				e.write(literal.LBRACK)
-			} else {
-				e.write(literal.COMMA)
+	}
+	start, patches := e.out.Len(), len(e.patches)
+	itemIsNonNull := isNonNull(node.Value)
+	i := 0
+	for i = 0; i < len(listItems); i++ {
+		if i == 0 {
+			e.write(literal.LBRACK)
+		} else {
+			e.write(literal.COMMA)
+		}
+		errorCount := len(e.errors)
+		itemPath := path + strconv.Itoa(index+i)
+		if e.resolveNode(node.Value, listItems[i], itemPath, nil, false) && itemIsNonNull {
+			if len(e.errors) == errorCount {
+				e.addError(itemPath, errNonNullFieldResolvedNull)
 			}
-			e.resolveNode(node.Value, listItems[i], path+strconv.Itoa(i), nil, false)
+			e.truncate(start, patches) // a non-null item resolved to null so the whole list becomes null
+			e.write(literal.NULL)
+			return true
 		}
-		if i == 0 || e.err == jsonparser.KeyPathNotFoundError {
-			e.err = nil
-			e.write(literal.LBRACK)
+	}
+	if i == 0 || e.err == jsonparser.KeyPathNotFoundError {
+		e.err = nil
+		e.write(literal.LBRACK)
+	}
+	e.write(literal.RBRACK)
+	return false
+}
+
+// prefetch resolves the fetches of all list items concurrently while respecting the concurrency limit
+// Once the context is done no more goroutines get started, the remaining fetches fail immediately with the context error
+func (e *Executor) prefetch(node Node, listItems [][]byte, path string, index int) {
+	wg := &sync.WaitGroup{}
+	for i := range listItems {
+		wg.Add(1)
+		itemPath := path + strconv.Itoa(index+i)
+		if !e.acquirePrefetchSlot() {
+			e.resolveNode(node, listItems[i], itemPath, wg, true)
+			continue
+		}
+		go func(item []byte, itemPath string) {
+			defer e.releasePrefetchSlot()
+			e.resolveNode(node, item, itemPath, wg, true)
+		}(listItems[i], itemPath)
+	}
+	wg.Wait()
+}
+
+// acquirePrefetchSlot blocks until a prefetch slot is available, it returns false if the context is done
+func (e *Executor) acquirePrefetchSlot() bool {
+	if e.context.Err() != nil {
+		return false
+	}
+	if e.prefetchSlots == nil {
+		return true
+	}
+	select {
+	case e.prefetchSlots <- struct{}{}:
+		return true
+	case <-e.context.Done():
+		return false
+	}
+}
+
+func (e *Executor) releasePrefetchSlot() {
+	if e.prefetchSlots != nil {
+		<-e.prefetchSlots
+	}
+}
+
+// batchFetches returns the fetches of the object if all of them resolve list items in batches
+// A ParallelFetch of batch SingleFetches, e.g. of fields of an entity owned by several subgraphs, results in one batch per SingleFetch
+func batchFetches(object *Object) []*SingleFetch {
+	if len(object.Deferred) != 0 {
+		return nil
+	}
+	switch fetch := object.Fetch.(type) {
+	case *SingleFetch:
+		if fetch.Batch {
+			return []*SingleFetch{fetch}
+		}
+	case *ParallelFetch:
+		fetches := make([]*SingleFetch, 0, len(fetch.Fetches))
+		for i := range fetch.Fetches {
+			single, ok := fetch.Fetches[i].(*SingleFetch)
+			if !ok || !single.Batch {
+				return nil
+			}
+			fetches = append(fetches, single)
 		}
-		e.write(literal.RBRACK)
+		return fetches
 	}
+	return nil
 }
 
-func (e *Executor) resolveData(config DataResolvingConfig, data []byte) []byte {
+// batchPrefetch resolves the fetch of all list items with a single call to the DataSource
+// Items which resolve to null or skip the fetched field are left out as there's nothing to fetch for them
+func (e *Executor) batchPrefetch(object *Object, fetch *SingleFetch, listItems [][]byte, path string, index int) {
+	items := make([][]byte, 0, len(listItems))
+	paths := make([]string, 0, len(listItems))
+	for i := range listItems {
+		itemPath := path + strconv.Itoa(index+i)
+		data := e.resolveObjectData(object, listItems[i], itemPath)
+		if data == nil || bytes.Equal(data, literal.NULL) {
+			continue
+		}
+		if fetch.Skip != nil && fetch.Skip.Evaluate(e.context, data) {
+			continue
+		}
+		items = append(items, data)
+		paths = append(paths, itemPath)
+	}
+	if len(items) == 0 {
+		return
+	}
+	// fetch errors are stored next to the buffers and get reported by the fields reading from them
+	_, _ = fetch.FetchBatch(e.context, items, e, paths, &e.buffers)
+}
+
+// resolveObjectFields writes all fields of the object
+// It returns true in case a non-null field resolved to null which means the object has to become null
+func (e *Executor) resolveObjectFields(node *Object, data []byte, path string) (nonNullFieldIsNull bool) {
+	hasPreviousValue := false
+	return e.resolveFields(node, data, path, &hasPreviousValue)
+}
+
+// resolveFields writes the fields of the object followed by the fields of all deferred fragments which don't get deferred
+// The SerialFetch of an object gets executed field by field so that each field is resolved completely before the next one gets fetched
+func (e *Executor) resolveFields(node *Object, data []byte, path string, hasPreviousValue *bool) (nonNullFieldIsNull bool) {
+	serial, _ := node.Fetch.(*SerialFetch)
+	fetched := 0
+	for i := 0; i < len(node.Fields); i++ {
+		if node.Fields[i].Skip != nil {
+			if node.Fields[i].Skip.Evaluate(e.context, data) {
+				continue
+			}
+		}
+		if serial != nil && node.Fields[i].HasResolvedData {
+			fetched = e.fetchSerially(serial, fetched, string(node.Fields[i].Name), data, path)
+		}
+		if *hasPreviousValue { // separate all values with a comma in case we have at least one previous (unskipped field)
+			e.write(literal.COMMA)
+		}
+		*hasPreviousValue = true
+		if e.resolveNode(&node.Fields[i], data, path, nil, true) && isNonNull(node.Fields[i].Value) { // recursively evaluate all fields
+			return true
+		}
+	}
+	for _, fragment := range node.Deferred {
+		if e.isDeferred(fragment, data) {
+			e.patches = append(e.patches, patch{
+				label:    fragment.Label,
+				path:     path,
+				data:     data,
+				fragment: fragment,
+			})
+			continue
+		}
+		if e.resolveFields(fragment.Object, data, path, hasPreviousValue) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchSerially executes the Fetches of the SerialFetch starting at next up to the SingleFetch with the bufferName
+// It returns the index of the next Fetch to execute, nothing gets executed if no remaining SingleFetch has the bufferName
+func (e *Executor) fetchSerially(serial *SerialFetch, next int, bufferName string, data []byte, path string) int {
+	last := -1
+	for i := next; i < len(serial.Fetches); i++ {
+		if fetch, ok := serial.Fetches[i].(*SingleFetch); ok && fetch.BufferName == bufferName {
+			last = i
+			break
+		}
+	}
+	for ; next <= last; next++ {
+		_, _ = serial.Fetches[next].Fetch(e.context, data, e, path, &e.buffers)
+	}
+	return next
+}
+
+// isNonNull returns true if the node must not resolve to null
+func isNonNull(node Node) bool {
+	switch node := node.(type) {
+	case *Object:
+		return node.NonNull
+	case *List:
+		return node.NonNull
+	case *Value:
+		return node.NonNull
+	default:
+		return false
+	}
+}
+
+func (e *Executor) resolveData(config DataResolvingConfig, data []byte, path string) []byte {
 	if len(data) == 0 {
 		return nil
 	}
@@ -202,10 +600,37 @@ func (e *Executor) resolveData(config DataResolvingConfig, data []byte) []byte {
 	if config.Transformation == nil {
 		return data
 	}
-	data, e.err = config.Transformation.Transform(data)
+	data, err := config.Transformation.Transform(data)
+	if err != nil {
+		e.addError(path, err)
+		return nil
+	}
 	return data
 }
 
+// resolveObjectData resolves the data of the object and sets the "__typename" of the object if it's
+// missing and the object has a TypeResolver
+func (e *Executor) resolveObjectData(node *Object, data []byte, path string) []byte {
+	data = e.resolveData(node.DataResolvingConfig, data, path)
+	if node.TypeResolver == nil || data == nil || bytes.Equal(data, literal.NULL) {
+		return data
+	}
+	if gjson.GetBytes(data, "__typename").Exists() {
+		return data
+	}
+	typeName, ok := node.TypeResolver.ResolveTypeName(data)
+	if !ok {
+		return data
+	}
+	quotedTypeName, _ := json.Marshal(typeName)
+	withTypeName, err := sjson.SetRawBytes(append([]byte(nil), data...), "__typename", quotedTypeName)
+	if err != nil {
+		e.addError(path, err)
+		return data
+	}
+	return withTypeName
+}
+
 func (e *Executor) ResolveArgs(args []datasource.Argument, data []byte) ResolvedArgs {
 
 	args = append(args, e.context.ExtraArguments...)
@@ -322,12 +747,18 @@ type Node interface {
 type RootNode interface {
 	Node
 	OperationType() ast.OperationType
+	// Incremental returns true if the plan contains fragments annotated with @defer or lists annotated with @stream
+	Incremental() bool
 }
 
 type Context struct {
 	context.Context
 	Variables      Variables
 	ExtraArguments []datasource.Argument
+	// instrumentation is the Instrumentation of the Executor, it's passed with the Context so that Fetches can emit events
+	instrumentation Instrumentation
+	// fetches deduplicates the calls to DataSources within one execution, it's nil if calls must not be deduplicated
+	fetches *fetchGroup
 }
 
 type Variables map[uint64][]byte
@@ -384,13 +815,23 @@ type Object struct {
 	DataResolvingConfig DataResolvingConfig
 	Fields              []Field
 	Fetch               Fetch
-	operationType       ast.OperationType
+	NonNull             bool
+	// TypeResolver resolves the "__typename" of objects of an interface or union if the upstream doesn't return it
+	TypeResolver datasource.TypeResolver
+	// Deferred are the fragments of the Object annotated with @defer
+	Deferred      []*DeferredFragment
+	operationType ast.OperationType
+	incremental   bool
 }
 
 func (o *Object) OperationType() ast.OperationType {
 	return o.operationType
 }
 
+func (o *Object) Incremental() bool {
+	return o.incremental
+}
+
 type ArgsResolver interface {
 	ResolveArgs(args []datasource.Argument, data []byte) ResolvedArgs
 }
@@ -399,28 +840,119 @@ type Fetch interface {
 	Fetch(ctx Context, data []byte, argsResolver ArgsResolver, suffix string, buffers *LockableBufferMap) (n int, err error)
 }
 
+// SingleFetch resolves a DataSource into the buffer of the field named BufferName
+// In case the DataSource returns an error it gets stored next to the buffer
+// If Batch is true and the SingleFetch is nested inside a list the Executor resolves all items with a single call to FetchBatch
+// If the field resolved by the SingleFetch gets skipped at runtime there's nothing to fetch, Skip is the skip condition of that field
 type SingleFetch struct {
 	Source     *DataSourceInvocation
 	BufferName string
+	Batch      bool
+	Skip       BooleanCondition
 }
 
-func (s *SingleFetch) Fetch(ctx Context, data []byte, argsResolver ArgsResolver, path string, buffers *LockableBufferMap) (int, error) {
+func (s *SingleFetch) Fetch(ctx Context, data []byte, argsResolver ArgsResolver, path string, buffers *LockableBufferMap) (n int, err error) {
+	if s.Skip != nil && s.Skip.Evaluate(ctx, data) {
+		return 0, nil
+	}
+	hash, buffer := s.buffer(path, buffers)
+	upstreamErrors := &datasource.UpstreamErrors{}
+	if err = ctx.Err(); err == nil { // there's no point in calling the DataSource if the context is already done
+		args := argsResolver.ResolveArgs(s.Source.Args, data)
+		end := s.startFetch(ctx, path, args, false)
+		var shared bool
+		ctx.Context = datasource.WithUpstreamErrors(ctx.Context, upstreamErrors)
+		n, shared, err = ctx.fetches.resolve(ctx, s.Source.DataSource, args, buffer)
+		end(n, shared, err)
+	}
+	s.setError(buffers, err, hash)
+	s.setUpstreamErrors(buffers, upstreamErrors, hash)
+	return n, err
+}
+
+// FetchBatch resolves the args for all data items with a single call to the DataSource
+// The result for data[i] gets written into the buffer of paths[i]
+// An error fails the fetch for all items
+// FetchBatch requires the DataSource to implement datasource.BatchDataSource
+func (s *SingleFetch) FetchBatch(ctx Context, data [][]byte, argsResolver ArgsResolver, paths []string, buffers *LockableBufferMap) (n int, err error) {
+	args := make([]datasource.ResolverArgs, len(data))
+	outs := make([]io.Writer, len(data))
+	hashes := make([]uint64, len(data))
+	for i := range data {
+		args[i] = argsResolver.ResolveArgs(s.Source.Args, data[i])
+		hashes[i], outs[i] = s.buffer(paths[i], buffers)
+	}
+	source, isBatchDataSource := s.Source.DataSource.(datasource.BatchDataSource)
+	switch {
+	case ctx.Err() != nil:
+		err = ctx.Err()
+	case !isBatchDataSource:
+		err = fmt.Errorf("SingleFetch.FetchBatch: DataSource %T doesn't implement datasource.BatchDataSource", s.Source.DataSource)
+	default:
+		ends := make([]func(bytes int, shared bool, err error), len(data))
+		for i := range data {
+			ends[i] = s.startFetch(ctx, paths[i], args[i].(ResolvedArgs), true)
+		}
+		upstreamErrors := &datasource.UpstreamErrors{}
+		n, err = source.ResolveBatch(datasource.WithUpstreamErrors(ctx, upstreamErrors), args, outs)
+		for i := range ends {
+			ends[i](outs[i].(*bytes.Buffer).Len(), false, err)
+		}
+		s.setUpstreamErrors(buffers, upstreamErrors, hashes...)
+	}
+	s.setError(buffers, err, hashes...)
+	return n, err
+}
+
+// buffer returns the reset buffer for the path and its key
+func (s *SingleFetch) buffer(path string, buffers *LockableBufferMap) (uint64, *bytes.Buffer) {
 	bufferName := path + "." + s.BufferName
 	hash := xxhash.Sum64String(bufferName)
 	buffers.Lock()
+	defer buffers.Unlock()
 	buffer, exists := buffers.Buffers[hash]
-	buffers.Unlock()
 	if !exists {
 		buffer = bytes.NewBuffer(make([]byte, 0, 1024))
-		buffers.Lock()
 		buffers.Buffers[hash] = buffer
-		buffers.Unlock()
 	} else {
 		buffer.Reset()
 	}
-	return s.Source.DataSource.Resolve(ctx, argsResolver.ResolveArgs(s.Source.Args, data), buffer)
+	return hash, buffer
+}
+
+func (s *SingleFetch) setError(buffers *LockableBufferMap, err error, hashes ...uint64) {
+	buffers.Lock()
+	defer buffers.Unlock()
+	for _, hash := range hashes {
+		if err != nil {
+			buffers.Errors[hash] = err
+		} else {
+			delete(buffers.Errors, hash)
+		}
+	}
 }
 
+// setUpstreamErrors stores the UpstreamErrors of each item next to the buffer of the item
+func (s *SingleFetch) setUpstreamErrors(buffers *LockableBufferMap, upstreamErrors *datasource.UpstreamErrors, hashes ...uint64) {
+	buffers.Lock()
+	defer buffers.Unlock()
+	for i, hash := range hashes {
+		if itemErrors := upstreamErrors.Item(i); len(itemErrors) != 0 {
+			if buffers.UpstreamErrors == nil {
+				buffers.UpstreamErrors = map[uint64][]datasource.UpstreamError{}
+			}
+			buffers.UpstreamErrors[hash] = itemErrors
+		} else {
+			delete(buffers.UpstreamErrors, hash)
+		}
+	}
+}
+
+// SerialFetch executes all Fetches one after another, each Fetch only starts after the previous one finished
+// A failed Fetch doesn't stop the following Fetches as its error is stored next to its buffer
+// It returns the sum of all written bytes and the error of the first failed Fetch
+// The Executor resolves an Object with a SerialFetch field by field, the Fetch of a field only starts once all previous fields
+// including their nested fetches got resolved, e.g. the fields of a mutation see the results of all previous mutations
 type SerialFetch struct {
 	Fetches []Fetch
 }
@@ -428,28 +960,38 @@ type SerialFetch struct {
 func (s *SerialFetch) Fetch(ctx Context, data []byte, argsResolver ArgsResolver, suffix string, buffers *LockableBufferMap) (n int, err error) {
 	for i := 0; i < len(s.Fetches); i++ {
 		nextN, nextErr := s.Fetches[i].Fetch(ctx, data, argsResolver, suffix, buffers)
-		if nextErr != nil {
-			return n, nextErr
+		if err == nil {
+			err = nextErr
 		}
 		n = n + nextN
 	}
 	return
 }
 
+// ParallelFetch executes all Fetches concurrently
+// It returns the sum of all written bytes and the error of the first failed Fetch (in order of Fetches)
 type ParallelFetch struct {
-	wg      sync.WaitGroup
 	Fetches []Fetch
 }
 
 func (p *ParallelFetch) Fetch(ctx Context, data []byte, argsResolver ArgsResolver, suffix string, buffers *LockableBufferMap) (n int, err error) {
+	wg := sync.WaitGroup{} // plans are shared between concurrent executions so the WaitGroup must not be part of the plan
+	written := make([]int, len(p.Fetches))
+	errs := make([]error, len(p.Fetches))
 	for i := 0; i < len(p.Fetches); i++ {
-		p.wg.Add(1)
-		go func(fetch Fetch, ctx Context, data []byte, argsResolver ArgsResolver) {
-			_,_ = fetch.Fetch(ctx, data, argsResolver, suffix, buffers) // TODO: handle results
-			p.wg.Done()
-		}(p.Fetches[i], ctx, data, argsResolver)
+		wg.Add(1)
+		go func(i int, fetch Fetch, ctx Context, data []byte, argsResolver ArgsResolver) {
+			written[i], errs[i] = fetch.Fetch(ctx, data, argsResolver, suffix, buffers)
+			wg.Done()
+		}(i, p.Fetches[i], ctx, data, argsResolver)
+	}
+	wg.Wait()
+	for i := range p.Fetches {
+		n += written[i]
+		if err == nil {
+			err = errs[i]
+		}
 	}
-	p.wg.Wait()
 	return
 }
 
@@ -459,6 +1001,11 @@ func (o *Object) HasResolversRecursively() bool {
 			return true
 		}
 	}
+	for i := range o.Deferred {
+		if o.Deferred[i].Object.HasResolversRecursively() {
+			return true
+		}
+	}
 	return false
 }
 
@@ -475,6 +1022,8 @@ type Field struct {
 	Value           Node
 	Skip            BooleanCondition
 	HasResolvedData bool
+	// Position is the position of the field in the operation, it's used to locate errors
+	Position Position
 }
 
 func (f *Field) HasResolversRecursively() bool {
@@ -532,6 +1081,20 @@ func (i *IfNotEqual) Evaluate(ctx Context, data []byte) bool {
 	return !equal.Evaluate(ctx, data)
 }
 
+// IfAny is true if any of the Conditions is true
+type IfAny struct {
+	Conditions []BooleanCondition
+}
+
+func (i *IfAny) Evaluate(ctx Context, data []byte) bool {
+	for j := range i.Conditions {
+		if i.Conditions[j].Evaluate(ctx, data) {
+			return true
+		}
+	}
+	return false
+}
+
 func (*Field) Kind() NodeKind {
 	return FieldKind
 }
@@ -539,6 +1102,11 @@ func (*Field) Kind() NodeKind {
 type Value struct {
 	DataResolvingConfig DataResolvingConfig
 	ValueType           JSONValueType
+	NonNull             bool
+	// Scalar is the registered custom scalar of the value, nil for built-in scalars, enums and unregistered custom scalars
+	Scalar *Scalar
+	// Enum is the enum type of the value, nil if the value is no enum
+	Enum *Enum
 }
 
 func (value *Value) HasResolversRecursively() bool {
@@ -553,6 +1121,9 @@ type List struct {
 	DataResolvingConfig DataResolvingConfig
 	Value               Node
 	Filter              ListFilter
+	NonNull             bool
+	// Stream is the configuration of a list annotated with @stream, nil if the list isn't streamed
+	Stream *Stream
 }
 
 func (l *List) HasResolversRecursively() bool {
@@ -571,10 +1142,19 @@ type ListFilterKind int
 
 const (
 	ListFilterKindFirstN ListFilterKind = iota + 1
+	ListFilterKindLastN
+	ListFilterKindOffset
+	ListFilterKindEquals
+	ListFilterKindSortBy
+	ListFilterKindChain
 )
 
 type ListFilterFirstN struct {
+	// FirstN is the maximum number of items, a negative FirstN doesn't limit the list
 	FirstN int
+	// Variable is the name of the variable passed to the field argument of the filter
+	// If the variable is provided its value replaces FirstN
+	Variable []byte
 }
 
 func (_ ListFilterFirstN) Kind() ListFilterKind {
@@ -584,4 +1164,21 @@ func (_ ListFilterFirstN) Kind() ListFilterKind {
 type DataSourceInvocation struct {
 	Args       []datasource.Argument
 	DataSource datasource.DataSource
+	// Upstream is the Upstream configured for the field, empty if there's none
+	Upstream string
+	// ParentType is the name of the type enclosing the field the DataSource resolves the data for, e.g. "Query"
+	ParentType string
+	// ReturnType is the printed type of the field the DataSource resolves the data for, e.g. "[Droid!]"
+	ReturnType string
+}
+
+// UpstreamName returns the Upstream of the invocation or else the configured name of the upstream of the unwrapped DataSource
+func (d *DataSourceInvocation) UpstreamName() string {
+	if d.Upstream != "" {
+		return d.Upstream
+	}
+	if named, ok := datasource.Unwrap(d.DataSource).(datasource.NamedDataSource); ok {
+		return named.UpstreamName()
+	}
+	return ""
 }
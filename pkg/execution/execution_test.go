@@ -10,6 +10,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -1262,33 +1266,97 @@ func TestExecutor_ListFilterFirstN(t *testing.T) {
 	}
 }
 
-func TestExecutor_ObjectVariables(t *testing.T) {
+func TestExecutor_ListFilters(t *testing.T) {
 
-	REST1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	run := func(filter ListFilter, variables map[uint64][]byte, want string) func(t *testing.T) {
+		return func(t *testing.T) {
+			plan := &Object{
+				operationType: ast.OperationTypeQuery,
+				Fields: []Field{
+					{
+						Name: []byte("data"),
+						Value: &Object{
+							Fetch: &SingleFetch{
+								Source: &DataSourceInvocation{
+									DataSource: &datasource.StaticDataSource{
+										Data: []byte(`[{"id":1,"status":"open","rank":3},{"id":2,"status":"closed","rank":1},{"id":3,"status":"open","rank":2},{"id":4,"status":"open","rank":1}]`),
+									},
+								},
+								BufferName: "tasks",
+							},
+							Fields: []Field{
+								{
+									Name:            []byte("tasks"),
+									HasResolvedData: true,
+									Value: &List{
+										Filter: filter,
+										Value: &Value{
+											DataResolvingConfig: DataResolvingConfig{
+												PathSelector: datasource.PathSelector{
+													Path: "id",
+												},
+											},
+											ValueType: IntegerValueType,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
 
-		if r.Method != http.MethodPost {
-			t.Fatalf("want method POST, got: %s", r.Method)
-			return
-		}
+			out := &bytes.Buffer{}
+			ex := NewExecutor(nil)
+			ctx := Context{
+				Context:   context.Background(),
+				Variables: variables,
+			}
 
-		got, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			t.Fatal(err)
+			err := ex.Execute(ctx, plan, out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := out.String()
+			if got != want {
+				t.Fatalf("want: %s\ngot: %s\n", want, got)
+			}
 		}
+	}
 
-		want := []byte(`{"id":1}`)
+	t.Run("last n", run(&ListFilterLastN{LastN: 2}, nil, `{"data":{"tasks":[3,4]}}`))
+	t.Run("offset", run(&ListFilterOffset{Offset: 1}, nil, `{"data":{"tasks":[2,3,4]}}`))
+	t.Run("offset beyond length", run(&ListFilterOffset{Offset: 5}, nil, `{"data":{"tasks":[]}}`))
+	t.Run("first n from variable", run(&ListFilterFirstN{FirstN: 3, Variable: []byte("first")}, map[uint64][]byte{
+		xxhash.Sum64String("first"): []byte("1"),
+	}, `{"data":{"tasks":[1]}}`))
+	t.Run("first n without variable", run(&ListFilterFirstN{FirstN: -1, Variable: []byte("first")}, nil, `{"data":{"tasks":[1,2,3,4]}}`))
+	t.Run("equals", run(&ListFilterEquals{Path: "status", Value: []byte("open")}, nil, `{"data":{"tasks":[1,3,4]}}`))
+	t.Run("equals from variable", run(&ListFilterEquals{Path: "status", Variable: []byte("status")}, map[uint64][]byte{
+		xxhash.Sum64String("status"): []byte("closed"),
+	}, `{"data":{"tasks":[2]}}`))
+	t.Run("sort by", run(&ListFilterSortBy{Path: "rank"}, nil, `{"data":{"tasks":[2,4,3,1]}}`))
+	t.Run("sort by descending", run(&ListFilterSortBy{Path: "rank", Descending: true}, nil, `{"data":{"tasks":[1,3,2,4]}}`))
+	t.Run("chain", run(&ListFilterChain{
+		Filters: []ListFilter{
+			&ListFilterEquals{Path: "status", Value: []byte("open")},
+			&ListFilterSortBy{Path: "rank"},
+			&ListFilterOffset{Offset: 1},
+			&ListFilterFirstN{FirstN: 1},
+		},
+	}, nil, `{"data":{"tasks":[3]}}`))
+}
 
-		if !bytes.Equal(want, got) {
-			t.Fatalf("want: '%s', got: '%s'", string(want), string(got))
-		}
+type errorDataSource struct {
+	err error
+}
 
-		response := []byte(`{"name":"Woof","age":3}`)
+func (e errorDataSource) Resolve(ctx context.Context, args datasource.ResolverArgs, out io.Writer) (n int, err error) {
+	return 0, e.err
+}
 
-		_, err = w.Write(response)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}))
+func TestExecutor_ResolveErrors(t *testing.T) {
 
 	plan := &Object{
 		operationType: ast.OperationTypeQuery,
@@ -1296,45 +1364,31 @@ func TestExecutor_ObjectVariables(t *testing.T) {
 			{
 				Name: []byte("data"),
 				Value: &Object{
-					Fetch: &SingleFetch{
-						Source: &DataSourceInvocation{
-							DataSource: &datasource.StaticDataSource{
-								Data: []byte(`{"name": "Jens","id":1}`),
+					Fetch: &ParallelFetch{
+						Fetches: []Fetch{
+							&SingleFetch{
+								Source: &DataSourceInvocation{
+									DataSource: &datasource.StaticDataSource{
+										Data: []byte(`{"name":"Jens","age":"abc"}`),
+									},
+								},
+								BufferName: "user",
+							},
+							&SingleFetch{
+								Source: &DataSourceInvocation{
+									DataSource: errorDataSource{
+										err: fmt.Errorf("upstream unavailable"),
+									},
+								},
+								BufferName: "pets",
 							},
 						},
-						BufferName: "user",
 					},
 					Fields: []Field{
 						{
 							Name:            []byte("user"),
 							HasResolvedData: true,
 							Value: &Object{
-								Fetch: &SingleFetch{
-									BufferName: "pet",
-									Source: &DataSourceInvocation{
-										Args: []datasource.Argument{
-											&datasource.StaticVariableArgument{
-												Name:  literal.HOST,
-												Value: []byte(REST1.URL),
-											},
-											&datasource.StaticVariableArgument{
-												Name:  literal.URL,
-												Value: []byte("/"),
-											},
-											&datasource.StaticVariableArgument{
-												Name:  literal.METHOD,
-												Value: []byte("POST"),
-											},
-											&datasource.StaticVariableArgument{
-												Name:  literal.BODY,
-												Value: []byte(`{"id":{{ .object.id }}}`),
-											},
-										},
-										DataSource: &datasource.HttpJsonDataSource{
-											Log: log.NoopLogger,
-										},
-									},
-								},
 								Fields: []Field{
 									{
 										Name: []byte("name"),
@@ -1346,50 +1400,57 @@ func TestExecutor_ObjectVariables(t *testing.T) {
 											},
 											ValueType: StringValueType,
 										},
+										Position: Position{
+											Line:   3,
+											Column: 5,
+										},
 									},
 									{
-										Name: []byte("id"),
+										Name: []byte("age"),
 										Value: &Value{
 											DataResolvingConfig: DataResolvingConfig{
 												PathSelector: datasource.PathSelector{
-													Path: "id",
+													Path: "age",
 												},
 											},
 											ValueType: IntegerValueType,
 										},
+										Position: Position{
+											Line:   4,
+											Column: 5,
+										},
 									},
-									{
-										Name:            []byte("pet"),
-										HasResolvedData: true,
-										Value: &Object{
-											Fields: []Field{
-												{
-													Name: []byte("name"),
-													Value: &Value{
-														DataResolvingConfig: DataResolvingConfig{
-															PathSelector: datasource.PathSelector{
-																Path: "name",
-															},
-														},
-														ValueType: StringValueType,
-													},
-												},
-												{
-													Name: []byte("age"),
-													Value: &Value{
-														DataResolvingConfig: DataResolvingConfig{
-															PathSelector: datasource.PathSelector{
-																Path: "age",
-															},
-														},
-														ValueType: IntegerValueType,
+								},
+							},
+							Position: Position{
+								Line:   2,
+								Column: 3,
+							},
+						},
+						{
+							Name:            []byte("pets"),
+							HasResolvedData: true,
+							Value: &List{
+								Value: &Object{
+									Fields: []Field{
+										{
+											Name: []byte("name"),
+											Value: &Value{
+												DataResolvingConfig: DataResolvingConfig{
+													PathSelector: datasource.PathSelector{
+														Path: "name",
 													},
 												},
+												ValueType: StringValueType,
 											},
 										},
 									},
 								},
 							},
+							Position: Position{
+								Line:   6,
+								Column: 3,
+							},
 						},
 					},
 				},
@@ -1408,7 +1469,7 @@ func TestExecutor_ObjectVariables(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	want := `{"data":{"user":{"name":"Jens","id":1,"pet":{"name":"Woof","age":3}}}}`
+	want := `{"data":{"user":{"name":"Jens","age":null},"pets":null},"errors":[{"message":"JSONValueType.writeValue: cannot write abc as IntegerValueType","locations":[{"line":4,"column":5}],"path":["user","age"]},{"message":"upstream unavailable","locations":[{"line":6,"column":3}],"path":["pets"]}]}`
 	got := out.String()
 
 	if got != want {
@@ -1416,23 +1477,7 @@ func TestExecutor_ObjectVariables(t *testing.T) {
 	}
 }
 
-func TestExecutor_NestedObjectVariables(t *testing.T) {
-
-	previewService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write([]byte(`{"data":{"id":1}}`))
-	}))
-
-	defer previewService.Close()
-
-	additionalDataService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		want := "/api/additional_data?data_ids=1"
-		if r.RequestURI != want {
-			t.Fatalf("want uri: %s, got: %s", want, r.RequestURI)
-		}
-		_, _ = w.Write([]byte(`{"name":"foo"}`))
-	}))
-
-	defer additionalDataService.Close()
+func TestExecutor_NonNullPropagation(t *testing.T) {
 
 	plan := &Object{
 		operationType: ast.OperationTypeQuery,
@@ -1441,61 +1486,25 @@ func TestExecutor_NestedObjectVariables(t *testing.T) {
 				Name: []byte("data"),
 				Value: &Object{
 					Fetch: &SingleFetch{
-						BufferName: "preview",
 						Source: &DataSourceInvocation{
-							Args: []datasource.Argument{
-								&datasource.StaticVariableArgument{
-									Name:  literal.HOST,
-									Value: []byte(previewService.URL),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  literal.URL,
-									Value: []byte("/"),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  literal.METHOD,
-									Value: []byte("GET"),
-								},
-							},
-							DataSource: &datasource.HttpJsonDataSource{
-								Log: log.NoopLogger,
+							DataSource: &datasource.StaticDataSource{
+								Data: []byte(`{"user":{"id":"1","name":null},"friends":[{"name":"Yaara"},{"name":null}],"pet":{"name":"Woofie"}}`),
 							},
 						},
+						BufferName: "root",
 					},
 					Fields: []Field{
 						{
-							Name:            []byte("preview"),
+							Name:            []byte("root"),
 							HasResolvedData: true,
 							Value: &Object{
 								Fields: []Field{
 									{
-										Name: []byte("data"),
+										Name: []byte("user"),
 										Value: &Object{
 											DataResolvingConfig: DataResolvingConfig{
 												PathSelector: datasource.PathSelector{
-													Path: "data",
-												},
-											},
-											Fetch: &SingleFetch{
-												BufferName: "additional_data_for_id",
-												Source: &DataSourceInvocation{
-													Args: []datasource.Argument{
-														&datasource.StaticVariableArgument{
-															Name:  literal.HOST,
-															Value: []byte(additionalDataService.URL),
-														},
-														&datasource.StaticVariableArgument{
-															Name:  literal.URL,
-															Value: []byte("/api/additional_data?data_ids={{ .object.id }}"),
-														},
-														&datasource.StaticVariableArgument{
-															Name:  literal.METHOD,
-															Value: []byte("GET"),
-														},
-													},
-													DataSource: &datasource.HttpJsonDataSource{
-														Log: log.NoopLogger,
-													},
+													Path: "user",
 												},
 											},
 											Fields: []Field{
@@ -1507,96 +1516,81 @@ func TestExecutor_NestedObjectVariables(t *testing.T) {
 																Path: "id",
 															},
 														},
-														ValueType: IntegerValueType,
+														ValueType: StringValueType,
+														NonNull:   true,
 													},
 												},
 												{
-													Name:            []byte("additional_data_for_id"),
-													HasResolvedData: true,
-													Value: &Object{
-														Fields: []Field{
-															{
-																Name: []byte("name"),
-																Value: &Value{
-																	ValueType: StringValueType,
-																	DataResolvingConfig: DataResolvingConfig{
-																		PathSelector: datasource.PathSelector{
-																			Path: "name",
-																		},
-																	},
+													Name: []byte("name"),
+													Value: &Value{
+														DataResolvingConfig: DataResolvingConfig{
+															PathSelector: datasource.PathSelector{
+																Path: "name",
+															},
+														},
+														ValueType: StringValueType,
+														NonNull:   true,
+													},
+													Position: Position{
+														Line:   4,
+														Column: 5,
+													},
+												},
+											},
+										},
+									},
+									{
+										Name: []byte("friends"),
+										Value: &List{
+											DataResolvingConfig: DataResolvingConfig{
+												PathSelector: datasource.PathSelector{
+													Path: "friends",
+												},
+											},
+											Value: &Object{
+												Fields: []Field{
+													{
+														Name: []byte("name"),
+														Value: &Value{
+															DataResolvingConfig: DataResolvingConfig{
+																PathSelector: datasource.PathSelector{
+																	Path: "name",
 																},
 															},
+															ValueType: StringValueType,
+															NonNull:   true,
+														},
+														Position: Position{
+															Line:   7,
+															Column: 5,
 														},
 													},
 												},
+												NonNull: true,
 											},
 										},
 									},
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	out := &bytes.Buffer{}
-	ex := NewExecutor(nil)
-	ctx := Context{
-		Context: context.Background(),
-	}
-
-	err := ex.Execute(ctx, plan, out)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	want := `{"data":{"preview":{"data":{"id":1,"additional_data_for_id":{"name":"foo"}}}}}`
-	got := out.String()
-
-	if got != want {
-		t.Fatalf("want: %s\ngot: %s\n", want, got)
-	}
-}
-
-func TestExecutor_ListWithPath(t *testing.T) {
-
-	plan := &Object{
-		operationType: ast.OperationTypeQuery,
-		Fields: []Field{
-			{
-				Name: []byte("data"),
-				Value: &Object{
-					Fetch: &SingleFetch{
-						Source: &DataSourceInvocation{
-							DataSource: &datasource.StaticDataSource{
-								Data: []byte(`{"apis": [{"id": 1},{"id":2}]}`),
-							},
-						},
-						BufferName: "apis",
-					},
-					Fields: []Field{
-						{
-							Name:            []byte("apis"),
-							HasResolvedData: true,
-							Value: &List{
-								DataResolvingConfig: DataResolvingConfig{
-									PathSelector: datasource.PathSelector{
-										Path: "apis",
-									},
-								},
-								Value: &Object{
-									Fields: []Field{
-										{
-											Name: []byte("id"),
-											Value: &Value{
-												DataResolvingConfig: DataResolvingConfig{
-													PathSelector: datasource.PathSelector{
-														Path: "id",
+									{
+										Name: []byte("pet"),
+										Value: &Object{
+											DataResolvingConfig: DataResolvingConfig{
+												PathSelector: datasource.PathSelector{
+													Path: "pet",
+												},
+											},
+											Fields: []Field{
+												{
+													Name: []byte("name"),
+													Value: &Value{
+														DataResolvingConfig: DataResolvingConfig{
+															PathSelector: datasource.PathSelector{
+																Path: "name",
+															},
+														},
+														ValueType: StringValueType,
+														NonNull:   true,
 													},
 												},
-												ValueType: IntegerValueType,
 											},
 										},
 									},
@@ -1620,7 +1614,7 @@ func TestExecutor_ListWithPath(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	want := `{"data":{"apis":[{"id":1},{"id":2}]}}`
+	want := `{"data":{"root":{"user":null,"friends":null,"pet":{"name":"Woofie"}}},"errors":[{"message":"cannot return null for non-nullable field","locations":[{"line":4,"column":5}],"path":["root","user","name"]},{"message":"cannot return null for non-nullable field","locations":[{"line":7,"column":5}],"path":["root","friends",1,"name"]}]}`
 	got := out.String()
 
 	if got != want {
@@ -1628,21 +1622,31 @@ func TestExecutor_ListWithPath(t *testing.T) {
 	}
 }
 
-func TestExecutor_GraphqlDataSourceWithParams(t *testing.T) {
+type batchDataSource struct {
+	batches [][]string
+}
 
-	graphQL1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+func (b *batchDataSource) Resolve(ctx context.Context, args datasource.ResolverArgs, out io.Writer) (n int, err error) {
+	return 0, fmt.Errorf("batchDataSource must not be resolved without batching")
+}
 
-		_, err := w.Write([]byte(`{
-			"data": {
-				"countries": [
-					{"id":1},{"id":2}
-				]
-			}
-		}`))
+func (b *batchDataSource) ResolveBatch(ctx context.Context, args []datasource.ResolverArgs, outs []io.Writer) (n int, err error) {
+	batch := make([]string, len(args))
+	for i := range args {
+		batch[i] = string(args[i].ByKey([]byte("id")))
+		written, err := fmt.Fprintf(outs[i], `{"name":"user-%s"}`, batch[i])
+		n += written
 		if err != nil {
-			t.Fatal(err)
+			return n, err
 		}
-	}))
+	}
+	b.batches = append(b.batches, batch)
+	return
+}
+
+func TestExecutor_BatchFetch(t *testing.T) {
+
+	source := &batchDataSource{}
 
 	plan := &Object{
 		operationType: ast.OperationTypeQuery,
@@ -1652,55 +1656,58 @@ func TestExecutor_GraphqlDataSourceWithParams(t *testing.T) {
 				Value: &Object{
 					Fetch: &SingleFetch{
 						Source: &DataSourceInvocation{
-							Args: []datasource.Argument{
-								&datasource.StaticVariableArgument{
-									Name:  literal.HOST,
-									Value: []byte(graphQL1.URL),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  literal.URL,
-									Value: []byte("/graphql"),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  literal.QUERY,
-									Value: []byte("query q1($code: String!){countries(code: $code){id}}"),
-								},
-								&datasource.ContextVariableArgument{
-									Name:         []byte("code"),
-									VariableName: []byte("code"),
-								},
-							},
-							DataSource: &datasource.GraphQLDataSource{
-								Log: log.NoopLogger,
+							DataSource: &FakeDataSource{
+								data: []byte(`[{"id":1},null,{"id":2},{"id":3}]`),
 							},
 						},
-						BufferName: "countries",
+						BufferName: "users",
 					},
 					Fields: []Field{
 						{
-							Name:            []byte("countries"),
+							Name:            []byte("users"),
 							HasResolvedData: true,
 							Value: &List{
-								DataResolvingConfig: DataResolvingConfig{
-									PathSelector: datasource.PathSelector{
-										Path: "countries",
-									},
-								},
 								Value: &Object{
-									Fields: []Field{
-										{
-											Name: []byte("id"),
-											Value: &Value{
-												DataResolvingConfig: DataResolvingConfig{
+									Fetch: &SingleFetch{
+										Source: &DataSourceInvocation{
+											Args: []datasource.Argument{
+												&datasource.ObjectVariableArgument{
+													Name: []byte("id"),
 													PathSelector: datasource.PathSelector{
 														Path: "id",
 													},
 												},
-												ValueType: IntegerValueType,
+											},
+											DataSource: source,
+										},
+										BufferName: "user",
+										Batch:      true,
+									},
+									Fields: []Field{
+										{
+											Name:            []byte("user"),
+											HasResolvedData: true,
+											Value: &Object{
+												Fields: []Field{
+													{
+														Name: []byte("name"),
+														Value: &Value{
+															DataResolvingConfig: DataResolvingConfig{
+																PathSelector: datasource.PathSelector{
+																	Path: "name",
+																},
+															},
+															ValueType: StringValueType,
+														},
+													},
+												},
 											},
 										},
 									},
 								},
+								Filter: &ListFilterFirstN{
+									FirstN: 3,
+								},
 							},
 						},
 					},
@@ -1709,358 +1716,406 @@ func TestExecutor_GraphqlDataSourceWithParams(t *testing.T) {
 		},
 	}
 
-	out := &bytes.Buffer{}
+	out := bytes.Buffer{}
 	ex := NewExecutor(nil)
-	ctx := Context{
-		Context: context.Background(),
-		Variables: map[uint64][]byte{
-			xxhash.Sum64String("code"): []byte("DE"),
-		},
-	}
-
-	err := ex.Execute(ctx, plan, out)
+	err := ex.Execute(Context{Context: context.Background()}, plan, &out)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	want := `{"data":{"countries":[{"id":1},{"id":2}]}}`
+	want := `{"data":{"users":[{"user":{"name":"user-1"}},null,{"user":{"name":"user-2"}}]}}`
 	got := out.String()
-
 	if got != want {
 		t.Fatalf("want: %s\ngot: %s\n", want, got)
 	}
+
+	if len(source.batches) != 1 {
+		t.Fatalf("want 1 batch, got: %d", len(source.batches))
+	}
+	if got := fmt.Sprint(source.batches[0]); got != "[1 2]" {
+		t.Fatalf("want batch [1 2], got: %s", got)
+	}
 }
 
-func TestExecutor_ObjectWithPath(t *testing.T) {
+type concurrencyDataSource struct {
+	mux         sync.Mutex
+	calls       int
+	inFlight    int
+	maxInFlight int
+	release     chan struct{}
+}
 
-	plan := &Object{
-		operationType: ast.OperationTypeQuery,
-		Fields: []Field{
-			{
-				Name: []byte("data"),
-				Value: &Object{
-					Fetch: &SingleFetch{
-						Source: &DataSourceInvocation{
-							DataSource: &datasource.StaticDataSource{
-								Data: []byte(`{"api": {"id": 1}`),
+func (c *concurrencyDataSource) Resolve(ctx context.Context, args datasource.ResolverArgs, out io.Writer) (n int, err error) {
+	c.mux.Lock()
+	c.calls++
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.mux.Unlock()
+	defer func() {
+		c.mux.Lock()
+		c.inFlight--
+		c.mux.Unlock()
+	}()
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-time.After(time.Millisecond):
+	}
+	return out.Write([]byte(`{"name":"user"}`))
+}
+
+func TestExecutor_PrefetchConcurrency(t *testing.T) {
+
+	plan := func(source datasource.DataSource, items int) RootNode {
+		list := make([]string, items)
+		for i := range list {
+			list[i] = fmt.Sprintf(`{"id":%d}`, i)
+		}
+		return &Object{
+			operationType: ast.OperationTypeQuery,
+			Fields: []Field{
+				{
+					Name: []byte("data"),
+					Value: &Object{
+						Fetch: &SingleFetch{
+							Source: &DataSourceInvocation{
+								DataSource: &FakeDataSource{
+									data: []byte("[" + strings.Join(list, ",") + "]"),
+								},
 							},
+							BufferName: "users",
 						},
-						BufferName: "id",
-					},
-					Fields: []Field{
-						{
-							Name:            []byte("id"),
-							HasResolvedData: true,
-							Value: &Value{
-								DataResolvingConfig: DataResolvingConfig{
-									PathSelector: datasource.PathSelector{
-										Path: "api.id",
+						Fields: []Field{
+							{
+								Name:            []byte("users"),
+								HasResolvedData: true,
+								Value: &List{
+									Value: &Object{
+										Fetch: &SingleFetch{
+											Source: &DataSourceInvocation{
+												DataSource: source,
+											},
+											BufferName: "user",
+										},
+										Fields: []Field{
+											{
+												Name:            []byte("user"),
+												HasResolvedData: true,
+												Value: &Object{
+													Fields: []Field{
+														{
+															Name: []byte("name"),
+															Value: &Value{
+																DataResolvingConfig: DataResolvingConfig{
+																	PathSelector: datasource.PathSelector{
+																		Path: "name",
+																	},
+																},
+																ValueType: StringValueType,
+															},
+														},
+													},
+												},
+											},
+										},
 									},
 								},
-								ValueType: IntegerValueType,
 							},
 						},
 					},
 				},
 			},
-		},
-	}
-
-	out := &bytes.Buffer{}
-	ex := NewExecutor(nil)
-	ctx := Context{
-		Context: context.Background(),
-	}
-
-	err := ex.Execute(ctx, plan, out)
-	if err != nil {
-		t.Fatal(err)
+		}
 	}
 
-	want := `{"data":{"id":1}}`
-	got := out.String()
+	t.Run("limited", func(t *testing.T) {
+		source := &concurrencyDataSource{}
+		ex := NewExecutor(nil)
+		ex.ChangeMaxConcurrency(3)
+		out := bytes.Buffer{}
+		err := ex.Execute(Context{Context: context.Background()}, plan(source, 20), &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if source.calls != 20 {
+			t.Fatalf("want 20 calls, got: %d", source.calls)
+		}
+		if source.maxInFlight > 3 {
+			t.Fatalf("want at most 3 concurrent calls, got: %d", source.maxInFlight)
+		}
+		if strings.Count(out.String(), `{"user":{"name":"user"}}`) != 20 {
+			t.Fatalf("unexpected output: %s", out.String())
+		}
+	})
+	t.Run("canceled", func(t *testing.T) {
+		source := &concurrencyDataSource{}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		out := bytes.Buffer{}
+		err := NewExecutor(nil).Execute(Context{Context: ctx}, plan(source, 2), &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if source.calls != 0 {
+			t.Fatalf("want no calls, got: %d", source.calls)
+		}
+		want := `{"data":{"users":null},"errors":[{"message":"context canceled","path":["users"]}]}`
+		if out.String() != want {
+			t.Fatalf("want: %s\ngot: %s\n", want, out.String())
+		}
+	})
+	t.Run("canceled during prefetch", func(t *testing.T) {
+		source := &concurrencyDataSource{
+			release: make(chan struct{}),
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(time.Millisecond * 10)
+			cancel()
+		}()
+		ex := NewExecutor(nil)
+		ex.ChangeMaxConcurrency(1)
+		out := bytes.Buffer{}
+		done := make(chan error)
+		go func() {
+			done <- ex.Execute(Context{Context: ctx}, plan(source, 100), &out)
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("want execution to stop after cancellation")
+		}
+		if source.calls >= 100 {
+			t.Fatalf("want remaining fetches to be skipped, got: %d calls", source.calls)
+		}
+		if !strings.Contains(out.String(), `"message":"context canceled"`) {
+			t.Fatalf("want context canceled errors, got: %s", out.String())
+		}
+	})
+}
 
-	if got != want {
-		t.Fatalf("want: %s\ngot: %s\n", want, got)
-	}
-}
-
-func TestExecutor_ResolveArgs(t *testing.T) {
-	e := NewExecutor(nil)
-	e.context = Context{
-		Context: context.Background(),
-		Variables: map[uint64][]byte{
-			xxhash.Sum64String("input"): []byte(`{"foo": "fooValue"}`),
+func TestParallelFetch_Fetch(t *testing.T) {
+	fetch := &ParallelFetch{
+		Fetches: []Fetch{
+			&SingleFetch{
+				Source: &DataSourceInvocation{
+					DataSource: &FakeDataSource{
+						data: []byte(`{"foo":"bar"}`),
+					},
+				},
+				BufferName: "foo",
+			},
+			&SingleFetch{
+				Source: &DataSourceInvocation{
+					DataSource: errorDataSource{
+						err: fmt.Errorf("bar failed"),
+					},
+				},
+				BufferName: "bar",
+			},
 		},
 	}
-
-	args := []datasource.Argument{
-		&datasource.StaticVariableArgument{
-			Name:  []byte("body"),
-			Value: []byte("{\\\"key\\\":\\\"{{ .arguments.input.foo }}\\\"}"),
-		},
-		&datasource.ContextVariableArgument{
-			Name:         []byte(".arguments.input"),
-			VariableName: []byte("input"),
-		},
+	buffers := &LockableBufferMap{
+		Buffers: map[uint64]*bytes.Buffer{},
+		Errors:  map[uint64]error{},
 	}
-
-	resolved := e.ResolveArgs(args, nil)
-	if len(resolved) != 1 {
-		t.Fatalf("want 1, got: %d\n", len(resolved))
-		return
+	n, err := fetch.Fetch(Context{Context: context.Background()}, nil, NewExecutor(nil), "query.data", buffers)
+	if err == nil || err.Error() != "bar failed" {
+		t.Fatalf("want err 'bar failed', got: %v", err)
 	}
-	want := []byte("{\\\"key\\\":\\\"fooValue\\\"}")
-	if !bytes.Equal(resolved.ByKey([]byte("body")), want) {
-		t.Fatalf("want key 'body' with value: '%s'\ndump: %s", string(want), resolved.Dump())
+	if n != len(`{"foo":"bar"}`) {
+		t.Fatalf("want n to be the sum of all fetches, got: %d", n)
 	}
-}
-
-func TestExecutor_ResolveArgsString(t *testing.T) {
-	e := NewExecutor(nil)
-	e.context = Context{
-		Context: context.Background(),
-		Variables: map[uint64][]byte{
-			xxhash.Sum64String("id"): []byte("foo123"),
-		},
+	if got := buffers.Buffers[xxhash.Sum64String("query.data.foo")].String(); got != `{"foo":"bar"}` {
+		t.Fatalf("unexpected buffer: %s", got)
 	}
+}
 
-	args := []datasource.Argument{
-		&datasource.StaticVariableArgument{
-			Name:  []byte("url"),
-			Value: []byte("/apis/{{ .arguments.id }}"),
-		},
-		&datasource.ContextVariableArgument{
-			Name:         []byte(".arguments.id"),
-			VariableName: []byte("id"),
-		},
-	}
+type recordingDataSource struct {
+	name  string
+	mux   *sync.Mutex
+	calls *[]string
+	err   error
+}
 
-	resolved := e.ResolveArgs(args, nil)
-	if len(resolved) != 1 {
-		t.Fatalf("want 1, got: %d\n", len(resolved))
-		return
-	}
-	want := []byte("/apis/foo123")
-	if !bytes.Equal(resolved.ByKey([]byte("url")), want) {
-		t.Fatalf("want key 'body' with value: '%s'\ndump: %s", string(want), resolved.Dump())
+func (r recordingDataSource) Resolve(ctx context.Context, args datasource.ResolverArgs, out io.Writer) (n int, err error) {
+	r.mux.Lock()
+	*r.calls = append(*r.calls, "start "+r.name)
+	r.mux.Unlock()
+	time.Sleep(time.Millisecond)
+	r.mux.Lock()
+	*r.calls = append(*r.calls, "end "+r.name)
+	r.mux.Unlock()
+	if r.err != nil {
+		return 0, r.err
 	}
+	return out.Write([]byte(`{"name":"` + r.name + `"}`))
 }
 
-func TestExecutor_ResolveArgs_MultipleNested(t *testing.T) {
-	e := NewExecutor(nil)
-	e.context = Context{
-		Context: context.Background(),
-		Variables: map[uint64][]byte{
-			xxhash.Sum64String("from"):  []byte(`{"year":2019,"month":11,"day":1}`),
-			xxhash.Sum64String("until"): []byte(`{"year":2019,"month":12,"day":31}`),
-			xxhash.Sum64String("page"):  []byte(`0`),
+func TestSerialFetch_Fetch(t *testing.T) {
+	mux := &sync.Mutex{}
+	var calls []string
+	fetch := &SerialFetch{
+		Fetches: []Fetch{
+			&SingleFetch{
+				Source: &DataSourceInvocation{
+					DataSource: recordingDataSource{name: "first", mux: mux, calls: &calls, err: fmt.Errorf("first failed")},
+				},
+				BufferName: "first",
+			},
+			&SingleFetch{
+				Source: &DataSourceInvocation{
+					DataSource: recordingDataSource{name: "second", mux: mux, calls: &calls},
+				},
+				BufferName: "second",
+			},
 		},
 	}
-
-	args := []datasource.Argument{
-		&datasource.StaticVariableArgument{
-			Name:  []byte("url"),
-			Value: []byte("/api/usage/apis/{{ .id }}/{{ .arguments.from.day }}/{{ .arguments.from.month }}/{{ .arguments.from.year }}/{{ .arguments.until.day }}/{{ .arguments.until.month }}/{{ .arguments.until.year }}?by=Hits&sort=1&p={{ .arguments.page }}"),
-		},
-		&datasource.StaticVariableArgument{
-			Name:  []byte("id"),
-			Value: []byte("1"),
-		},
-		&datasource.ContextVariableArgument{
-			Name:         []byte(".arguments.from"),
-			VariableName: []byte("from"),
-		},
-		&datasource.ContextVariableArgument{
-			Name:         []byte(".arguments.until"),
-			VariableName: []byte("until"),
-		},
-		&datasource.ContextVariableArgument{
-			Name:         []byte(".arguments.page"),
-			VariableName: []byte("page"),
-		},
+	buffers := &LockableBufferMap{
+		Buffers: map[uint64]*bytes.Buffer{},
+		Errors:  map[uint64]error{},
 	}
-
-	resolved := e.ResolveArgs(args, nil)
-	if len(resolved) != 2 {
-		t.Fatalf("want 1, got: %d\n", len(resolved))
-		return
+	_, err := fetch.Fetch(Context{Context: context.Background()}, nil, NewExecutor(nil), "mutation.data", buffers)
+	if err == nil || err.Error() != "first failed" {
+		t.Fatalf("want err 'first failed', got: %v", err)
 	}
-	want := []byte("/api/usage/apis/1/1/11/2019/31/12/2019?by=Hits&sort=1&p=0")
-	got := resolved.ByKey([]byte("url"))
-	if !bytes.Equal(got, want) {
-		t.Fatalf("want key 'body' with value: '%s'\ngot: '%s'\ndump: %s", string(want), string(got), resolved.Dump())
+	want := []string{"start first", "end first", "start second", "end second"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("want fetches to run one after another: %v, got: %v", want, calls)
+	}
+	if got := buffers.Buffers[xxhash.Sum64String("mutation.data.second")].String(); got != `{"name":"second"}` {
+		t.Fatalf("want fetches after a failed fetch to be executed, got: %s", got)
 	}
 }
 
-func TestExecutor_ResolveArgsComplexPayload(t *testing.T) {
-	e := NewExecutor(nil)
-	e.context = Context{
-		Context: context.Background(),
-		Variables: map[uint64][]byte{
-			xxhash.Sum64String("input"): []byte(`{"foo": "fooValue", "bar": {"bal": "baz"}}`),
+func TestExecutor_SerialFetch(t *testing.T) {
+	mux := &sync.Mutex{}
+	var calls []string
+	name := &Object{
+		Fields: []Field{
+			{
+				Name: []byte("name"),
+				Value: &Value{
+					DataResolvingConfig: DataResolvingConfig{
+						PathSelector: datasource.PathSelector{
+							Path: "name",
+						},
+					},
+					ValueType: StringValueType,
+				},
+			},
 		},
 	}
-
-	args := []datasource.Argument{
-		&datasource.StaticVariableArgument{
-			Name:  []byte("body"),
-			Value: []byte("{{ .arguments.input }}"),
-		},
-		&datasource.ContextVariableArgument{
-			Name:         []byte(".arguments.input"),
-			VariableName: []byte("input"),
+	plan := &Object{
+		operationType: ast.OperationTypeMutation,
+		Fields: []Field{
+			{
+				Name: []byte("data"),
+				Value: &Object{
+					Fetch: &SerialFetch{
+						Fetches: []Fetch{
+							&SingleFetch{
+								Source: &DataSourceInvocation{
+									DataSource: recordingDataSource{name: "first", mux: mux, calls: &calls},
+								},
+								BufferName: "first",
+							},
+							&SingleFetch{
+								Source: &DataSourceInvocation{
+									DataSource: recordingDataSource{name: "second", mux: mux, calls: &calls},
+								},
+								BufferName: "second",
+							},
+						},
+					},
+					Fields: []Field{
+						{
+							Name: []byte("__typename"),
+							Value: &Value{
+								DataResolvingConfig: DataResolvingConfig{
+									PathSelector: datasource.PathSelector{
+										Path: "__typename",
+									},
+								},
+								ValueType: StringValueType,
+							},
+						},
+						{
+							Name:            []byte("first"),
+							HasResolvedData: true,
+							Value: &Object{
+								Fetch: &SingleFetch{
+									Source: &DataSourceInvocation{
+										DataSource: recordingDataSource{name: "nested", mux: mux, calls: &calls},
+									},
+									BufferName: "nested",
+								},
+								Fields: []Field{
+									{
+										Name:            []byte("nested"),
+										HasResolvedData: true,
+										Value:           name,
+									},
+								},
+							},
+						},
+						{
+							Name:            []byte("second"),
+							HasResolvedData: true,
+							Value:           name,
+						},
+					},
+				},
+			},
 		},
 	}
 
-	resolved := e.ResolveArgs(args, nil)
-	if len(resolved) != 1 {
-		t.Fatalf("want 1, got: %d\n", len(resolved))
-		return
+	out := bytes.Buffer{}
+	if err := NewExecutor(nil).Execute(Context{Context: context.Background()}, plan, &out); err != nil {
+		t.Fatal(err)
 	}
-	want := `{"foo": "fooValue", "bar": {"bal": "baz"}}`
-	got := resolved.ByKey([]byte("body"))
-	if !bytes.Equal(got, []byte(want)) {
-		t.Fatalf("want key 'body' with value:\n%s\ngot:\n%s\n", want, string(got))
+	want := `{"data":{"__typename":null,"first":{"nested":{"name":"nested"}},"second":{"name":"second"}}}`
+	if got := out.String(); got != want {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+	}
+	wantCalls := []string{"start first", "end first", "start nested", "end nested", "start second", "end second"}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Fatalf("want each field to be resolved before the next one gets fetched: %v, got: %v", wantCalls, calls)
 	}
 }
 
-func TestExecutor_ResolveArgsComplexPayloadWithSelector(t *testing.T) {
-	e := NewExecutor(nil)
-	e.context = Context{
-		Context: context.Background(),
-		Variables: map[uint64][]byte{
-			xxhash.Sum64String("input"): []byte(`{"foo": "fooValue", "bar": {"bal": "baz"}}`),
-		},
-	}
+func TestExecutor_ObjectVariables(t *testing.T) {
 
-	args := []datasource.Argument{
-		&datasource.StaticVariableArgument{
-			Name:  []byte("body"),
-			Value: []byte("{{ .arguments.input.bar }}"),
-		},
-		&datasource.ContextVariableArgument{
-			Name:         []byte(".arguments.input"),
-			VariableName: []byte("input"),
-		},
-	}
+	REST1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-	resolved := e.ResolveArgs(args, nil)
-	if len(resolved) != 1 {
-		t.Fatalf("want 1, got: %d\n", len(resolved))
-		return
-	}
-	want := `{"bal": "baz"}`
-	if !bytes.Equal(resolved.ByKey([]byte("body")), []byte(want)) {
-		t.Fatalf("want key 'body' with value: '%s'", want)
-	}
-}
+		if r.Method != http.MethodPost {
+			t.Fatalf("want method POST, got: %s", r.Method)
+			return
+		}
 
-func TestExecutor_ResolveArgsFlatObjectContainingJSON(t *testing.T) {
-	e := NewExecutor(nil)
-	e.context = Context{
-		Context: context.Background(),
-		Variables: map[uint64][]byte{
-			xxhash.Sum64String("request"): []byte(`{"headers":{"Authorization":"foo"}}`),
-		},
-	}
+		got, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	args := []datasource.Argument{
-		&datasource.StaticVariableArgument{
-			Name:  []byte("header"),
-			Value: []byte("{{ .request.headers.Authorization }}"),
-		},
-		&datasource.ContextVariableArgument{
-			Name:         []byte("request"),
-			VariableName: []byte("request"),
-		},
-	}
+		want := []byte(`{"id":1}`)
 
-	resolved := e.ResolveArgs(args, nil)
-	if len(resolved) != 2 {
-		t.Fatalf("want 2, got: %d\n", len(resolved))
-		return
-	}
-	want := "foo"
-	got := resolved.ByKey([]byte("header"))
-	if !bytes.Equal(got, []byte(want)) {
-		t.Fatalf("want key 'request' with value: '%s', got: '%s'", want, string(got))
-	}
-}
-
-func TestExecutor_ResolveArgsWithListArguments(t *testing.T) {
-	e := NewExecutor(nil)
-	e.context = Context{
-		Context: context.Background(),
-	}
-
-	args := []datasource.Argument{
-		&datasource.ListArgument{
-			Name: []byte("headers"),
-			Arguments: []datasource.Argument{
-				&datasource.StaticVariableArgument{
-					Name:  []byte("foo"),
-					Value: []byte("fooVal"),
-				},
-				&datasource.StaticVariableArgument{
-					Name:  []byte("bar"),
-					Value: []byte("barVal"),
-				},
-			},
-		},
-	}
-
-	resolved := e.ResolveArgs(args, nil)
-	if len(resolved) != 1 {
-		t.Fatalf("want 1, got: %d\n", len(resolved))
-		return
-	}
-	want := "{\"bar\":\"barVal\",\"foo\":\"fooVal\"}"
-	got := string(resolved.ByKey([]byte("headers")))
-	if want != got {
-		t.Fatalf("want key 'headers' with value:\n%s, got:\n%s\ndump:\n%s\n", want, got, resolved.Dump())
-	}
-}
-
-func TestExecutor_HTTPJSONDataSourceWithBody(t *testing.T) {
-
-	wantUpstream := map[string]interface{}{
-		"key": "fooValue",
-	}
-	wantBytes, err := json.MarshalIndent(wantUpstream, "", "  ")
-	if err != nil {
-		t.Fatal(err)
-		return
-	}
-
-	wantString := string(wantBytes)
-
-	REST1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
-		if r.Method != http.MethodPost {
-			t.Fatalf("wantUpstream: %s, got: %s\n", http.MethodPost, r.Method)
-			return
+		if !bytes.Equal(want, got) {
+			t.Fatalf("want: '%s', got: '%s'", string(want), string(got))
 		}
 
-		data, err := ioutil.ReadAll(r.Body)
+		response := []byte(`{"name":"Woof","age":3}`)
+
+		_, err = w.Write(response)
 		if err != nil {
 			t.Fatal(err)
-			return
-		}
-		defer r.Body.Close()
-
-		strData := string(data)
-		_ = strData
-
-		gotString := prettyJSON(bytes.NewReader(data))
-
-		if wantString != gotString {
-			t.Fatalf("wantUpstream: %s\ngot: %s\n", wantString, gotString)
-			return
 		}
-
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("bar"))
 	}))
 
 	plan := &Object{
@@ -2070,41 +2125,98 @@ func TestExecutor_HTTPJSONDataSourceWithBody(t *testing.T) {
 				Name: []byte("data"),
 				Value: &Object{
 					Fetch: &SingleFetch{
-						BufferName: "withBody",
 						Source: &DataSourceInvocation{
-							DataSource: &datasource.HttpJsonDataSource{
-								Log: log.NoopLogger,
-							},
-							Args: []datasource.Argument{
-								&datasource.StaticVariableArgument{
-									Name:  []byte("host"),
-									Value: []byte(REST1.URL),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  []byte("url"),
-									Value: []byte("/"),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  []byte("method"),
-									Value: []byte("POST"),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  []byte("body"),
-									Value: []byte("{\\\"key\\\":\\\"{{ .arguments.input.foo }}\\\"}"),
-								},
-								&datasource.ContextVariableArgument{
-									Name:         []byte(".arguments.input"),
-									VariableName: []byte("input"),
-								},
+							DataSource: &datasource.StaticDataSource{
+								Data: []byte(`{"name": "Jens","id":1}`),
 							},
 						},
+						BufferName: "user",
 					},
 					Fields: []Field{
 						{
-							Name:            []byte("withBody"),
+							Name:            []byte("user"),
 							HasResolvedData: true,
-							Value: &Value{
-								ValueType: StringValueType,
+							Value: &Object{
+								Fetch: &SingleFetch{
+									BufferName: "pet",
+									Source: &DataSourceInvocation{
+										Args: []datasource.Argument{
+											&datasource.StaticVariableArgument{
+												Name:  literal.HOST,
+												Value: []byte(REST1.URL),
+											},
+											&datasource.StaticVariableArgument{
+												Name:  literal.URL,
+												Value: []byte("/"),
+											},
+											&datasource.StaticVariableArgument{
+												Name:  literal.METHOD,
+												Value: []byte("POST"),
+											},
+											&datasource.StaticVariableArgument{
+												Name:  literal.BODY,
+												Value: []byte(`{"id":{{ .object.id }}}`),
+											},
+										},
+										DataSource: &datasource.HttpJsonDataSource{
+											Log: log.NoopLogger,
+										},
+									},
+								},
+								Fields: []Field{
+									{
+										Name: []byte("name"),
+										Value: &Value{
+											DataResolvingConfig: DataResolvingConfig{
+												PathSelector: datasource.PathSelector{
+													Path: "name",
+												},
+											},
+											ValueType: StringValueType,
+										},
+									},
+									{
+										Name: []byte("id"),
+										Value: &Value{
+											DataResolvingConfig: DataResolvingConfig{
+												PathSelector: datasource.PathSelector{
+													Path: "id",
+												},
+											},
+											ValueType: IntegerValueType,
+										},
+									},
+									{
+										Name:            []byte("pet"),
+										HasResolvedData: true,
+										Value: &Object{
+											Fields: []Field{
+												{
+													Name: []byte("name"),
+													Value: &Value{
+														DataResolvingConfig: DataResolvingConfig{
+															PathSelector: datasource.PathSelector{
+																Path: "name",
+															},
+														},
+														ValueType: StringValueType,
+													},
+												},
+												{
+													Name: []byte("age"),
+													Value: &Value{
+														DataResolvingConfig: DataResolvingConfig{
+															PathSelector: datasource.PathSelector{
+																Path: "age",
+															},
+														},
+														ValueType: IntegerValueType,
+													},
+												},
+											},
+										},
+									},
+								},
 							},
 						},
 					},
@@ -2117,64 +2229,1164 @@ func TestExecutor_HTTPJSONDataSourceWithBody(t *testing.T) {
 	ex := NewExecutor(nil)
 	ctx := Context{
 		Context: context.Background(),
-		Variables: map[uint64][]byte{
-			xxhash.Sum64String("input"): []byte(`{"foo": "fooValue"}`),
-		},
-	}
-
-	err = ex.Execute(ctx, plan, out)
-	if err != nil {
-		t.Fatal(err)
 	}
 
-	expected := map[string]interface{}{
-		"data": map[string]interface{}{
-			"withBody": "bar",
-		},
-	}
-
-	wantResult, err := json.MarshalIndent(expected, "", "  ")
+	err := ex.Execute(ctx, plan, out)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	want := string(wantResult)
-	got := prettyJSON(out)
+	want := `{"data":{"user":{"name":"Jens","id":1,"pet":{"name":"Woof","age":3}}}}`
+	got := out.String()
 
-	if want != got {
+	if got != want {
 		t.Fatalf("want: %s\ngot: %s\n", want, got)
-		return
 	}
 }
 
-func TestExecutor_Execute_WithUnions(t *testing.T) {
+func TestExecutor_NestedObjectVariables(t *testing.T) {
 
-	planApi := func(apiResponse string, succesFirst bool) *Object {
+	previewService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"id":1}}`))
+	}))
 
-		successFields := []Field{
+	defer previewService.Close()
+
+	additionalDataService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := "/api/additional_data?data_ids=1"
+		if r.RequestURI != want {
+			t.Fatalf("want uri: %s, got: %s", want, r.RequestURI)
+		}
+		_, _ = w.Write([]byte(`{"name":"foo"}`))
+	}))
+
+	defer additionalDataService.Close()
+
+	plan := &Object{
+		operationType: ast.OperationTypeQuery,
+		Fields: []Field{
 			{
-				Name: []byte("id"),
-				Skip: &IfNotEqual{
-					Left: &datasource.ObjectVariableArgument{
-						PathSelector: datasource.PathSelector{
-							Path: "__typename",
-						},
-					},
-					Right: &datasource.StaticVariableArgument{
-						Value: []byte("Api"),
-					},
-				},
-				Value: &Value{
-					ValueType: StringValueType,
-					DataResolvingConfig: DataResolvingConfig{
-						PathSelector: datasource.PathSelector{
-							Path: "id",
-						},
-					},
-				},
+				Name: []byte("data"),
+				Value: &Object{
+					Fetch: &SingleFetch{
+						BufferName: "preview",
+						Source: &DataSourceInvocation{
+							Args: []datasource.Argument{
+								&datasource.StaticVariableArgument{
+									Name:  literal.HOST,
+									Value: []byte(previewService.URL),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  literal.URL,
+									Value: []byte("/"),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  literal.METHOD,
+									Value: []byte("GET"),
+								},
+							},
+							DataSource: &datasource.HttpJsonDataSource{
+								Log: log.NoopLogger,
+							},
+						},
+					},
+					Fields: []Field{
+						{
+							Name:            []byte("preview"),
+							HasResolvedData: true,
+							Value: &Object{
+								Fields: []Field{
+									{
+										Name: []byte("data"),
+										Value: &Object{
+											DataResolvingConfig: DataResolvingConfig{
+												PathSelector: datasource.PathSelector{
+													Path: "data",
+												},
+											},
+											Fetch: &SingleFetch{
+												BufferName: "additional_data_for_id",
+												Source: &DataSourceInvocation{
+													Args: []datasource.Argument{
+														&datasource.StaticVariableArgument{
+															Name:  literal.HOST,
+															Value: []byte(additionalDataService.URL),
+														},
+														&datasource.StaticVariableArgument{
+															Name:  literal.URL,
+															Value: []byte("/api/additional_data?data_ids={{ .object.id }}"),
+														},
+														&datasource.StaticVariableArgument{
+															Name:  literal.METHOD,
+															Value: []byte("GET"),
+														},
+													},
+													DataSource: &datasource.HttpJsonDataSource{
+														Log: log.NoopLogger,
+													},
+												},
+											},
+											Fields: []Field{
+												{
+													Name: []byte("id"),
+													Value: &Value{
+														DataResolvingConfig: DataResolvingConfig{
+															PathSelector: datasource.PathSelector{
+																Path: "id",
+															},
+														},
+														ValueType: IntegerValueType,
+													},
+												},
+												{
+													Name:            []byte("additional_data_for_id"),
+													HasResolvedData: true,
+													Value: &Object{
+														Fields: []Field{
+															{
+																Name: []byte("name"),
+																Value: &Value{
+																	ValueType: StringValueType,
+																	DataResolvingConfig: DataResolvingConfig{
+																		PathSelector: datasource.PathSelector{
+																			Path: "name",
+																		},
+																	},
+																},
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	ex := NewExecutor(nil)
+	ctx := Context{
+		Context: context.Background(),
+	}
+
+	err := ex.Execute(ctx, plan, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"data":{"preview":{"data":{"id":1,"additional_data_for_id":{"name":"foo"}}}}}`
+	got := out.String()
+
+	if got != want {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+	}
+}
+
+func TestExecutor_ListWithPath(t *testing.T) {
+
+	plan := &Object{
+		operationType: ast.OperationTypeQuery,
+		Fields: []Field{
+			{
+				Name: []byte("data"),
+				Value: &Object{
+					Fetch: &SingleFetch{
+						Source: &DataSourceInvocation{
+							DataSource: &datasource.StaticDataSource{
+								Data: []byte(`{"apis": [{"id": 1},{"id":2}]}`),
+							},
+						},
+						BufferName: "apis",
+					},
+					Fields: []Field{
+						{
+							Name:            []byte("apis"),
+							HasResolvedData: true,
+							Value: &List{
+								DataResolvingConfig: DataResolvingConfig{
+									PathSelector: datasource.PathSelector{
+										Path: "apis",
+									},
+								},
+								Value: &Object{
+									Fields: []Field{
+										{
+											Name: []byte("id"),
+											Value: &Value{
+												DataResolvingConfig: DataResolvingConfig{
+													PathSelector: datasource.PathSelector{
+														Path: "id",
+													},
+												},
+												ValueType: IntegerValueType,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	ex := NewExecutor(nil)
+	ctx := Context{
+		Context: context.Background(),
+	}
+
+	err := ex.Execute(ctx, plan, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"data":{"apis":[{"id":1},{"id":2}]}}`
+	got := out.String()
+
+	if got != want {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+	}
+}
+
+func TestExecutor_GraphqlDataSourceWithParams(t *testing.T) {
+
+	graphQL1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		_, err := w.Write([]byte(`{
+			"data": {
+				"countries": [
+					{"id":1},{"id":2}
+				]
+			}
+		}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+
+	plan := &Object{
+		operationType: ast.OperationTypeQuery,
+		Fields: []Field{
+			{
+				Name: []byte("data"),
+				Value: &Object{
+					Fetch: &SingleFetch{
+						Source: &DataSourceInvocation{
+							Args: []datasource.Argument{
+								&datasource.StaticVariableArgument{
+									Name:  literal.HOST,
+									Value: []byte(graphQL1.URL),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  literal.URL,
+									Value: []byte("/graphql"),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  literal.QUERY,
+									Value: []byte("query q1($code: String!){countries(code: $code){id}}"),
+								},
+								&datasource.ContextVariableArgument{
+									Name:         []byte("code"),
+									VariableName: []byte("code"),
+								},
+							},
+							DataSource: &datasource.GraphQLDataSource{
+								Log: log.NoopLogger,
+							},
+						},
+						BufferName: "countries",
+					},
+					Fields: []Field{
+						{
+							Name:            []byte("countries"),
+							HasResolvedData: true,
+							Value: &List{
+								DataResolvingConfig: DataResolvingConfig{
+									PathSelector: datasource.PathSelector{
+										Path: "countries",
+									},
+								},
+								Value: &Object{
+									Fields: []Field{
+										{
+											Name: []byte("id"),
+											Value: &Value{
+												DataResolvingConfig: DataResolvingConfig{
+													PathSelector: datasource.PathSelector{
+														Path: "id",
+													},
+												},
+												ValueType: IntegerValueType,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	ex := NewExecutor(nil)
+	ctx := Context{
+		Context: context.Background(),
+		Variables: map[uint64][]byte{
+			xxhash.Sum64String("code"): []byte("DE"),
+		},
+	}
+
+	err := ex.Execute(ctx, plan, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"data":{"countries":[{"id":1},{"id":2}]}}`
+	got := out.String()
+
+	if got != want {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+	}
+}
+
+func TestGraphQLDataSource_ResolveBatch(t *testing.T) {
+
+	var request datasource.GraphqlRequest
+	requests := 0
+	graphQL := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(`{"data":{"_0":{"name":"one"},"_1":{"name":"two"}}}`))
+	}))
+	defer graphQL.Close()
+
+	args := func(id string) ResolvedArgs {
+		return ResolvedArgs{
+			{
+				Key:   literal.HOST,
+				Value: []byte(graphQL.URL),
+			},
+			{
+				Key:   literal.URL,
+				Value: []byte("/graphql"),
+			},
+			{
+				Key:   literal.QUERY,
+				Value: []byte("query o($id: ID!){user(id: $id){name friends(first: 1) @include(if: $id){name}}}"),
+			},
+			{
+				Key:   []byte("id"),
+				Value: []byte(id),
+			},
+		}
+	}
+
+	source := &datasource.GraphQLDataSource{
+		Log: log.NoopLogger,
+	}
+	first, second := bytes.Buffer{}, bytes.Buffer{}
+	_, err := source.ResolveBatch(context.Background(), []datasource.ResolverArgs{args("1"), args("2")}, []io.Writer{&first, &second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requests != 1 {
+		t.Fatalf("want 1 request, got: %d", requests)
+	}
+	wantQuery := "query o($id_0: ID!, $id_1: ID!){_0: user(id: $id_0){name friends(first: 1)@include(if: $id_0) {name}} _1: user(id: $id_1){name friends(first: 1)@include(if: $id_1) {name}}}"
+	if request.Query != wantQuery {
+		t.Fatalf("want query: %s\ngot: %s\n", wantQuery, request.Query)
+	}
+	var variables map[string]string
+	if err := json.Unmarshal(request.Variables, &variables); err != nil {
+		t.Fatal(err)
+	}
+	if variables["id_0"] != "1" || variables["id_1"] != "2" || len(variables) != 2 {
+		t.Fatalf("unexpected variables: %s", string(request.Variables))
+	}
+	if first.String() != `{"user":{"name":"one"}}` {
+		t.Fatalf("unexpected first result: %s", first.String())
+	}
+	if second.String() != `{"user":{"name":"two"}}` {
+		t.Fatalf("unexpected second result: %s", second.String())
+	}
+}
+
+func TestGraphQLDataSource_TypedVariables(t *testing.T) {
+
+	var request datasource.GraphqlRequest
+	graphQL := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(`{"data":{"users":[{"name":"Jens","friends":[{"name":"Stefan"}]}]}}`))
+	}))
+	defer graphQL.Close()
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+		schema {
+			query: Query
+		}
+		enum Role {
+			ADMIN
+			USER
+		}
+		input UserFilter {
+			name: String
+			roles: [Role!]
+		}
+		type User {
+			name: String
+			friends(first: Int, active: Boolean): [User]
+		}
+		type Query {
+			users(filter: UserFilter, first: Int, after: ID, role: Role): [User]
+		}`), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "users",
+				DataSource: datasource.SourceConfig{
+					Name: "GraphQLDataSource",
+					Config: toJSON(datasource.GraphQLDataSourceConfig{
+						Host: graphQL.URL,
+						URL:  "/",
+					}),
+				},
+			},
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicOnErr(base.RegisterDataSourcePlannerFactory("GraphQLDataSource", datasource.GraphQLDataSourcePlannerFactoryFactory{}))
+	handler := NewHandler(base, nil)
+
+	executor, node, ctx, err := handler.Handle([]byte(`{
+		"query":"query Users($filter: UserFilter, $first: Int, $after: ID, $role: Role, $active: Boolean, $withFriends: Boolean!) { users(filter: $filter, first: $first, after: $after, role: $role) { name friends(first: 1, active: $active) @include(if: $withFriends) { name } } }",
+		"variables":{"filter":{"name":"Jens","roles":["ADMIN"]},"first":10,"after":"abc","role":"USER","active":true,"withFriends":true}
+	}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.Buffer{}
+	if err := executor.Execute(ctx, node, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	wantQuery := "query Users($filter: UserFilter, $first: Int, $after: ID, $role: Role, $active: Boolean){users(filter: $filter, first: $first, after: $after, role: $role){name friends(first: 1, active: $active){name}}}"
+	if request.Query != wantQuery {
+		t.Fatalf("want query: %s\ngot: %s\n", wantQuery, request.Query)
+	}
+	wantVariables := `{"active":true,"after":"abc","filter":{"name":"Jens","roles":["ADMIN"]},"first":10,"role":"USER"}`
+	variables := bytes.Buffer{}
+	if err := json.Compact(&variables, request.Variables); err != nil {
+		t.Fatal(err)
+	}
+	if variables.String() != wantVariables {
+		t.Fatalf("want variables: %s\ngot: %s\n", wantVariables, variables.String())
+	}
+	wantResponse := `{"data":{"users":[{"name":"Jens","friends":[{"name":"Stefan"}]}]}}`
+	if out.String() != wantResponse {
+		t.Fatalf("want response: %s\ngot: %s\n", wantResponse, out.String())
+	}
+}
+
+func TestGraphQLDataSource_CustomScalarVariables(t *testing.T) {
+
+	var request datasource.GraphqlRequest
+	graphQL := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(`{"data":{"users":[{"name":"Jens"}]}}`))
+	}))
+	defer graphQL.Close()
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+		schema {
+			query: Query
+		}
+		scalar JSON
+		scalar Timestamp
+		type User {
+			name: String
+		}
+		type Query {
+			users(filter: JSON, since: Timestamp!, until: Timestamp, cursor: JSON): [User]
+		}`), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "users",
+				DataSource: datasource.SourceConfig{
+					Name: "GraphQLDataSource",
+					Config: toJSON(datasource.GraphQLDataSourceConfig{
+						Host: graphQL.URL,
+						URL:  "/",
+					}),
+				},
+			},
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicOnErr(base.RegisterDataSourcePlannerFactory("GraphQLDataSource", datasource.GraphQLDataSourcePlannerFactoryFactory{}))
+	handler := NewHandler(base, nil)
+
+	executor, node, ctx, err := handler.Handle([]byte(`{
+		"query":"query Users($filter: JSON, $since: Timestamp!, $until: Timestamp, $cursor: JSON) { users(filter: $filter, since: $since, until: $until, cursor: $cursor) { name } }",
+		"variables":{"filter":{"name":"Jens","roles":["ADMIN"]},"since":1577836800,"until":null,"cursor":"abc"}
+	}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.Buffer{}
+	if err := executor.Execute(ctx, node, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	wantVariables := `{"cursor":"abc","filter":{"name":"Jens","roles":["ADMIN"]},"since":1577836800,"until":null}`
+	variables := bytes.Buffer{}
+	if err := json.Compact(&variables, request.Variables); err != nil {
+		t.Fatal(err)
+	}
+	if variables.String() != wantVariables {
+		t.Fatalf("want variables: %s\ngot: %s\n", wantVariables, variables.String())
+	}
+	wantResponse := `{"data":{"users":[{"name":"Jens"}]}}`
+	if out.String() != wantResponse {
+		t.Fatalf("want response: %s\ngot: %s\n", wantResponse, out.String())
+	}
+}
+
+func TestGraphQLDataSource_UpstreamErrors(t *testing.T) {
+
+	run := func(upstreamResponse, query, want string) func(t *testing.T) {
+		return func(t *testing.T) {
+			graphQL := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(upstreamResponse))
+			}))
+			defer graphQL.Close()
+
+			base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+				schema {
+					query: Query
+				}
+				type User {
+					name: String
+					friends: [User]
+				}
+				type Query {
+					user: User
+				}`), datasource.PlannerConfiguration{
+				TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+					{
+						TypeName:  "query",
+						FieldName: "user",
+						DataSource: datasource.SourceConfig{
+							Name: "GraphQLDataSource",
+							Config: toJSON(datasource.GraphQLDataSourceConfig{
+								Host: graphQL.URL,
+								URL:  "/",
+								Name: "users",
+							}),
+						},
+					},
+				},
+			}, log.NoopLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			panicOnErr(base.RegisterDataSourcePlannerFactory("GraphQLDataSource", datasource.GraphQLDataSourcePlannerFactoryFactory{}))
+			handler := NewHandler(base, nil)
+
+			executor, node, ctx, err := handler.Handle([]byte(`{"query":"`+query+`"}`), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := bytes.Buffer{}
+			if err := executor.Execute(ctx, node, &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != want {
+				t.Fatalf("want: %s\ngot: %s\n", want, out.String())
+			}
+		}
+	}
+
+	t.Run("partial data", run(
+		`{"data":{"user":{"name":"Jens","friends":[{"name":"Stefan"},{"name":null}]}},"errors":[{"message":"friend not found","path":["user","friends",1,"name"],"locations":[{"line":1,"column":30}]}]}`,
+		`{ me: user { name friends { name } } }`,
+		`{"data":{"me":{"name":"Jens","friends":[{"name":"Stefan"},{"name":null}]}},"errors":[{"message":"friend not found","locations":[{"line":1,"column":3}],"path":["me","friends",1,"name"],"extensions":{"upstream":"users"}}]}`,
+	))
+	t.Run("error without path", run(
+		`{"data":{"user":null},"errors":[{"message":"internal server error"}]}`,
+		`{ user { name } }`,
+		`{"data":{"user":null},"errors":[{"message":"internal server error","locations":[{"line":1,"column":3}],"path":["user"],"extensions":{"upstream":"users"}}]}`,
+	))
+	t.Run("no data", run(
+		`{"errors":[{"message":"unauthorized"},{"message":"token expired"}]}`,
+		`{ user { name } }`,
+		`{"data":{"user":null},"errors":[{"message":"unauthorized, token expired","locations":[{"line":1,"column":3}],"path":["user"]}]}`,
+	))
+}
+
+func TestGraphQLDataSource_ResolveBatch_UpstreamErrors(t *testing.T) {
+
+	graphQL := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"_0":{"name":"one"},"_1":null},"errors":[{"message":"user not found","path":["_1"]},{"message":"rate limited"}]}`))
+	}))
+	defer graphQL.Close()
+
+	args := func(id string) ResolvedArgs {
+		return ResolvedArgs{
+			{
+				Key:   literal.HOST,
+				Value: []byte(graphQL.URL),
+			},
+			{
+				Key:   literal.URL,
+				Value: []byte("/graphql"),
+			},
+			{
+				Key:   literal.QUERY,
+				Value: []byte("query o($id: ID!){user(id: $id){name}}"),
+			},
+			{
+				Key:   []byte("id"),
+				Value: []byte(id),
+			},
+		}
+	}
+
+	source := &datasource.GraphQLDataSource{
+		Log: log.NoopLogger,
+	}
+	upstreamErrors := &datasource.UpstreamErrors{}
+	first, second := bytes.Buffer{}, bytes.Buffer{}
+	_, err := source.ResolveBatch(datasource.WithUpstreamErrors(context.Background(), upstreamErrors), []datasource.ResolverArgs{args("1"), args("2")}, []io.Writer{&first, &second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []datasource.UpstreamError{
+		{Message: "rate limited"},
+	}
+	if got := upstreamErrors.Item(0); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want errors of first item: %+v\ngot: %+v", want, got)
+	}
+	want = []datasource.UpstreamError{
+		{Message: "user not found", Path: []interface{}{"user"}},
+		{Message: "rate limited"},
+	}
+	if got := upstreamErrors.Item(1); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want errors of second item: %+v\ngot: %+v", want, got)
+	}
+}
+
+func TestExecutor_ObjectWithPath(t *testing.T) {
+
+	plan := &Object{
+		operationType: ast.OperationTypeQuery,
+		Fields: []Field{
+			{
+				Name: []byte("data"),
+				Value: &Object{
+					Fetch: &SingleFetch{
+						Source: &DataSourceInvocation{
+							DataSource: &datasource.StaticDataSource{
+								Data: []byte(`{"api": {"id": 1}`),
+							},
+						},
+						BufferName: "id",
+					},
+					Fields: []Field{
+						{
+							Name:            []byte("id"),
+							HasResolvedData: true,
+							Value: &Value{
+								DataResolvingConfig: DataResolvingConfig{
+									PathSelector: datasource.PathSelector{
+										Path: "api.id",
+									},
+								},
+								ValueType: IntegerValueType,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	ex := NewExecutor(nil)
+	ctx := Context{
+		Context: context.Background(),
+	}
+
+	err := ex.Execute(ctx, plan, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"data":{"id":1}}`
+	got := out.String()
+
+	if got != want {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+	}
+}
+
+func TestExecutor_ResolveArgs(t *testing.T) {
+	e := NewExecutor(nil)
+	e.context = Context{
+		Context: context.Background(),
+		Variables: map[uint64][]byte{
+			xxhash.Sum64String("input"): []byte(`{"foo": "fooValue"}`),
+		},
+	}
+
+	args := []datasource.Argument{
+		&datasource.StaticVariableArgument{
+			Name:  []byte("body"),
+			Value: []byte("{\\\"key\\\":\\\"{{ .arguments.input.foo }}\\\"}"),
+		},
+		&datasource.ContextVariableArgument{
+			Name:         []byte(".arguments.input"),
+			VariableName: []byte("input"),
+		},
+	}
+
+	resolved := e.ResolveArgs(args, nil)
+	if len(resolved) != 1 {
+		t.Fatalf("want 1, got: %d\n", len(resolved))
+		return
+	}
+	want := []byte("{\\\"key\\\":\\\"fooValue\\\"}")
+	if !bytes.Equal(resolved.ByKey([]byte("body")), want) {
+		t.Fatalf("want key 'body' with value: '%s'\ndump: %s", string(want), resolved.Dump())
+	}
+}
+
+func TestExecutor_ResolveArgsString(t *testing.T) {
+	e := NewExecutor(nil)
+	e.context = Context{
+		Context: context.Background(),
+		Variables: map[uint64][]byte{
+			xxhash.Sum64String("id"): []byte("foo123"),
+		},
+	}
+
+	args := []datasource.Argument{
+		&datasource.StaticVariableArgument{
+			Name:  []byte("url"),
+			Value: []byte("/apis/{{ .arguments.id }}"),
+		},
+		&datasource.ContextVariableArgument{
+			Name:         []byte(".arguments.id"),
+			VariableName: []byte("id"),
+		},
+	}
+
+	resolved := e.ResolveArgs(args, nil)
+	if len(resolved) != 1 {
+		t.Fatalf("want 1, got: %d\n", len(resolved))
+		return
+	}
+	want := []byte("/apis/foo123")
+	if !bytes.Equal(resolved.ByKey([]byte("url")), want) {
+		t.Fatalf("want key 'body' with value: '%s'\ndump: %s", string(want), resolved.Dump())
+	}
+}
+
+func TestExecutor_ResolveArgs_MultipleNested(t *testing.T) {
+	e := NewExecutor(nil)
+	e.context = Context{
+		Context: context.Background(),
+		Variables: map[uint64][]byte{
+			xxhash.Sum64String("from"):  []byte(`{"year":2019,"month":11,"day":1}`),
+			xxhash.Sum64String("until"): []byte(`{"year":2019,"month":12,"day":31}`),
+			xxhash.Sum64String("page"):  []byte(`0`),
+		},
+	}
+
+	args := []datasource.Argument{
+		&datasource.StaticVariableArgument{
+			Name:  []byte("url"),
+			Value: []byte("/api/usage/apis/{{ .id }}/{{ .arguments.from.day }}/{{ .arguments.from.month }}/{{ .arguments.from.year }}/{{ .arguments.until.day }}/{{ .arguments.until.month }}/{{ .arguments.until.year }}?by=Hits&sort=1&p={{ .arguments.page }}"),
+		},
+		&datasource.StaticVariableArgument{
+			Name:  []byte("id"),
+			Value: []byte("1"),
+		},
+		&datasource.ContextVariableArgument{
+			Name:         []byte(".arguments.from"),
+			VariableName: []byte("from"),
+		},
+		&datasource.ContextVariableArgument{
+			Name:         []byte(".arguments.until"),
+			VariableName: []byte("until"),
+		},
+		&datasource.ContextVariableArgument{
+			Name:         []byte(".arguments.page"),
+			VariableName: []byte("page"),
+		},
+	}
+
+	resolved := e.ResolveArgs(args, nil)
+	if len(resolved) != 2 {
+		t.Fatalf("want 1, got: %d\n", len(resolved))
+		return
+	}
+	want := []byte("/api/usage/apis/1/1/11/2019/31/12/2019?by=Hits&sort=1&p=0")
+	got := resolved.ByKey([]byte("url"))
+	if !bytes.Equal(got, want) {
+		t.Fatalf("want key 'body' with value: '%s'\ngot: '%s'\ndump: %s", string(want), string(got), resolved.Dump())
+	}
+}
+
+func TestExecutor_ResolveArgsComplexPayload(t *testing.T) {
+	e := NewExecutor(nil)
+	e.context = Context{
+		Context: context.Background(),
+		Variables: map[uint64][]byte{
+			xxhash.Sum64String("input"): []byte(`{"foo": "fooValue", "bar": {"bal": "baz"}}`),
+		},
+	}
+
+	args := []datasource.Argument{
+		&datasource.StaticVariableArgument{
+			Name:  []byte("body"),
+			Value: []byte("{{ .arguments.input }}"),
+		},
+		&datasource.ContextVariableArgument{
+			Name:         []byte(".arguments.input"),
+			VariableName: []byte("input"),
+		},
+	}
+
+	resolved := e.ResolveArgs(args, nil)
+	if len(resolved) != 1 {
+		t.Fatalf("want 1, got: %d\n", len(resolved))
+		return
+	}
+	want := `{"foo": "fooValue", "bar": {"bal": "baz"}}`
+	got := resolved.ByKey([]byte("body"))
+	if !bytes.Equal(got, []byte(want)) {
+		t.Fatalf("want key 'body' with value:\n%s\ngot:\n%s\n", want, string(got))
+	}
+}
+
+func TestExecutor_ResolveArgsComplexPayloadWithSelector(t *testing.T) {
+	e := NewExecutor(nil)
+	e.context = Context{
+		Context: context.Background(),
+		Variables: map[uint64][]byte{
+			xxhash.Sum64String("input"): []byte(`{"foo": "fooValue", "bar": {"bal": "baz"}}`),
+		},
+	}
+
+	args := []datasource.Argument{
+		&datasource.StaticVariableArgument{
+			Name:  []byte("body"),
+			Value: []byte("{{ .arguments.input.bar }}"),
+		},
+		&datasource.ContextVariableArgument{
+			Name:         []byte(".arguments.input"),
+			VariableName: []byte("input"),
+		},
+	}
+
+	resolved := e.ResolveArgs(args, nil)
+	if len(resolved) != 1 {
+		t.Fatalf("want 1, got: %d\n", len(resolved))
+		return
+	}
+	want := `{"bal": "baz"}`
+	if !bytes.Equal(resolved.ByKey([]byte("body")), []byte(want)) {
+		t.Fatalf("want key 'body' with value: '%s'", want)
+	}
+}
+
+func TestExecutor_ResolveArgsFlatObjectContainingJSON(t *testing.T) {
+	e := NewExecutor(nil)
+	e.context = Context{
+		Context: context.Background(),
+		Variables: map[uint64][]byte{
+			xxhash.Sum64String("request"): []byte(`{"headers":{"Authorization":"foo"}}`),
+		},
+	}
+
+	args := []datasource.Argument{
+		&datasource.StaticVariableArgument{
+			Name:  []byte("header"),
+			Value: []byte("{{ .request.headers.Authorization }}"),
+		},
+		&datasource.ContextVariableArgument{
+			Name:         []byte("request"),
+			VariableName: []byte("request"),
+		},
+	}
+
+	resolved := e.ResolveArgs(args, nil)
+	if len(resolved) != 2 {
+		t.Fatalf("want 2, got: %d\n", len(resolved))
+		return
+	}
+	want := "foo"
+	got := resolved.ByKey([]byte("header"))
+	if !bytes.Equal(got, []byte(want)) {
+		t.Fatalf("want key 'request' with value: '%s', got: '%s'", want, string(got))
+	}
+}
+
+func TestExecutor_ResolveArgsWithListArguments(t *testing.T) {
+	e := NewExecutor(nil)
+	e.context = Context{
+		Context: context.Background(),
+	}
+
+	args := []datasource.Argument{
+		&datasource.ListArgument{
+			Name: []byte("headers"),
+			Arguments: []datasource.Argument{
+				&datasource.StaticVariableArgument{
+					Name:  []byte("foo"),
+					Value: []byte("fooVal"),
+				},
+				&datasource.StaticVariableArgument{
+					Name:  []byte("bar"),
+					Value: []byte("barVal"),
+				},
+			},
+		},
+	}
+
+	resolved := e.ResolveArgs(args, nil)
+	if len(resolved) != 1 {
+		t.Fatalf("want 1, got: %d\n", len(resolved))
+		return
+	}
+	want := "{\"bar\":\"barVal\",\"foo\":\"fooVal\"}"
+	got := string(resolved.ByKey([]byte("headers")))
+	if want != got {
+		t.Fatalf("want key 'headers' with value:\n%s, got:\n%s\ndump:\n%s\n", want, got, resolved.Dump())
+	}
+}
+
+func TestExecutor_HTTPJSONDataSourceWithBody(t *testing.T) {
+
+	wantUpstream := map[string]interface{}{
+		"key": "fooValue",
+	}
+	wantBytes, err := json.MarshalIndent(wantUpstream, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	wantString := string(wantBytes)
+
+	REST1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost {
+			t.Fatalf("wantUpstream: %s, got: %s\n", http.MethodPost, r.Method)
+			return
+		}
+
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		defer r.Body.Close()
+
+		strData := string(data)
+		_ = strData
+
+		gotString := prettyJSON(bytes.NewReader(data))
+
+		if wantString != gotString {
+			t.Fatalf("wantUpstream: %s\ngot: %s\n", wantString, gotString)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("bar"))
+	}))
+
+	plan := &Object{
+		operationType: ast.OperationTypeQuery,
+		Fields: []Field{
+			{
+				Name: []byte("data"),
+				Value: &Object{
+					Fetch: &SingleFetch{
+						BufferName: "withBody",
+						Source: &DataSourceInvocation{
+							DataSource: &datasource.HttpJsonDataSource{
+								Log: log.NoopLogger,
+							},
+							Args: []datasource.Argument{
+								&datasource.StaticVariableArgument{
+									Name:  []byte("host"),
+									Value: []byte(REST1.URL),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  []byte("url"),
+									Value: []byte("/"),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  []byte("method"),
+									Value: []byte("POST"),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  []byte("body"),
+									Value: []byte("{\\\"key\\\":\\\"{{ .arguments.input.foo }}\\\"}"),
+								},
+								&datasource.ContextVariableArgument{
+									Name:         []byte(".arguments.input"),
+									VariableName: []byte("input"),
+								},
+							},
+						},
+					},
+					Fields: []Field{
+						{
+							Name:            []byte("withBody"),
+							HasResolvedData: true,
+							Value: &Value{
+								ValueType: StringValueType,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	ex := NewExecutor(nil)
+	ctx := Context{
+		Context: context.Background(),
+		Variables: map[uint64][]byte{
+			xxhash.Sum64String("input"): []byte(`{"foo": "fooValue"}`),
+		},
+	}
+
+	err = ex.Execute(ctx, plan, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"data": map[string]interface{}{
+			"withBody": "bar",
+		},
+	}
+
+	wantResult, err := json.MarshalIndent(expected, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := string(wantResult)
+	got := prettyJSON(out)
+
+	if want != got {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+		return
+	}
+}
+
+func TestExecutor_Execute_WithUnions(t *testing.T) {
+
+	planApi := func(apiResponse string, succesFirst bool) *Object {
+
+		successFields := []Field{
+			{
+				Name: []byte("id"),
+				Skip: &IfNotEqual{
+					Left: &datasource.ObjectVariableArgument{
+						PathSelector: datasource.PathSelector{
+							Path: "__typename",
+						},
+					},
+					Right: &datasource.StaticVariableArgument{
+						Value: []byte("Api"),
+					},
+				},
+				Value: &Value{
+					ValueType: StringValueType,
+					DataResolvingConfig: DataResolvingConfig{
+						PathSelector: datasource.PathSelector{
+							Path: "id",
+						},
+					},
+				},
+			},
+			{
+				Name: []byte("name"),
+				Skip: &IfNotEqual{
+					Left: &datasource.ObjectVariableArgument{
+						PathSelector: datasource.PathSelector{
+							Path: "__typename",
+						},
+					},
+					Right: &datasource.StaticVariableArgument{
+						Value: []byte("Api"),
+					},
+				},
+				Value: &Value{
+					ValueType: StringValueType,
+					DataResolvingConfig: DataResolvingConfig{
+						PathSelector: datasource.PathSelector{
+							Path: "name",
+						},
+					},
+				},
+			},
+		}
+
+		errorFields := []Field{
+			{
+				Name: []byte("status"),
+				Skip: &IfNotEqual{
+					Left: &datasource.ObjectVariableArgument{
+						PathSelector: datasource.PathSelector{
+							Path: "__typename",
+						},
+					},
+					Right: &datasource.StaticVariableArgument{
+						Value: []byte("RequestResult"),
+					},
+				},
+				Value: &Value{
+					ValueType: StringValueType,
+					DataResolvingConfig: DataResolvingConfig{
+						PathSelector: datasource.PathSelector{
+							Path: "Status",
+						},
+					},
+				},
 			},
 			{
-				Name: []byte("name"),
+				Name: []byte("message"),
 				Skip: &IfNotEqual{
 					Left: &datasource.ObjectVariableArgument{
 						PathSelector: datasource.PathSelector{
@@ -2182,306 +3394,648 @@ func TestExecutor_Execute_WithUnions(t *testing.T) {
 						},
 					},
 					Right: &datasource.StaticVariableArgument{
-						Value: []byte("Api"),
+						Value: []byte("RequestResult"),
 					},
 				},
 				Value: &Value{
 					ValueType: StringValueType,
 					DataResolvingConfig: DataResolvingConfig{
 						PathSelector: datasource.PathSelector{
-							Path: "name",
+							Path: "Message",
+						},
+					},
+				},
+			},
+		}
+
+		var fields []Field
+		if succesFirst {
+			fields = append(successFields, errorFields...)
+		} else {
+			fields = append(errorFields, successFields...)
+		}
+
+		return &Object{
+			operationType: ast.OperationTypeQuery,
+			Fields: []Field{
+				{
+					Name: []byte("data"),
+					Value: &Object{
+						Fetch: &SingleFetch{
+							Source: &DataSourceInvocation{
+								DataSource: FakeDataSource{
+									data: []byte(apiResponse),
+								},
+							},
+							BufferName: "api",
+						},
+						Fields: []Field{
+							{
+								Name:            []byte("api"),
+								HasResolvedData: true,
+								Value: &Object{
+									Fields: fields,
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	planApis := func(apiResponse string, succesFirst bool) *Object {
+		successField := Field{
+			Name: []byte("apis"),
+			Skip: &IfNotEqual{
+				Left: &datasource.ObjectVariableArgument{
+					PathSelector: datasource.PathSelector{
+						Path: "__typename",
+					},
+				},
+				Right: &datasource.StaticVariableArgument{
+					Value: []byte("ApisResultSuccess"),
+				},
+			},
+			Value: &List{
+				DataResolvingConfig: DataResolvingConfig{
+					PathSelector: datasource.PathSelector{
+						Path: "apis",
+					},
+				},
+				Value: &Object{
+					Fields: []Field{
+						{
+							Name: []byte("name"),
+							Value: &Value{
+								DataResolvingConfig: DataResolvingConfig{
+									PathSelector: datasource.PathSelector{
+										Path: "name",
+									},
+								},
+								ValueType: StringValueType,
+							},
+						},
+					},
+				},
+			},
+		}
+
+		errorFields := []Field{
+			{
+				Name: []byte("status"),
+				Skip: &IfNotEqual{
+					Left: &datasource.ObjectVariableArgument{
+						PathSelector: datasource.PathSelector{
+							Path: "__typename",
+						},
+					},
+					Right: &datasource.StaticVariableArgument{
+						Value: []byte("RequestResult"),
+					},
+				},
+				Value: &Value{
+					ValueType: StringValueType,
+					DataResolvingConfig: DataResolvingConfig{
+						PathSelector: datasource.PathSelector{
+							Path: "Status",
+						},
+					},
+				},
+			},
+			{
+				Name: []byte("message"),
+				Skip: &IfNotEqual{
+					Left: &datasource.ObjectVariableArgument{
+						PathSelector: datasource.PathSelector{
+							Path: "__typename",
+						},
+					},
+					Right: &datasource.StaticVariableArgument{
+						Value: []byte("RequestResult"),
+					},
+				},
+				Value: &Value{
+					ValueType: StringValueType,
+					DataResolvingConfig: DataResolvingConfig{
+						PathSelector: datasource.PathSelector{
+							Path: "Message",
+						},
+					},
+				},
+			},
+		}
+
+		var fields []Field
+		if succesFirst {
+			fields = append(fields, successField, errorFields[0], errorFields[1])
+		} else {
+			fields = append(fields, errorFields[0], errorFields[1], successField)
+		}
+
+		return &Object{
+			operationType: ast.OperationTypeQuery,
+			Fields: []Field{
+				{
+					Name: []byte("data"),
+					Value: &Object{
+						Fetch: &SingleFetch{
+							Source: &DataSourceInvocation{
+								DataSource: FakeDataSource{
+									data: []byte(apiResponse),
+								},
+							},
+							BufferName: "apis",
+						},
+						Fields: []Field{
+							{
+								Name:            []byte("apis"),
+								HasResolvedData: true,
+								Value: &Object{
+									Fields: fields,
+								},
+							},
 						},
 					},
 				},
 			},
 		}
+	}
+
+	makeTest := func(apiResponse, wantResult string, planner func(apiResponse string, successFirst bool) *Object) func(t *testing.T) {
+		return func(t *testing.T) {
+			out := &bytes.Buffer{}
+			ex := NewExecutor(nil)
+			ctx := Context{
+				Context: context.Background(),
+			}
+			err := ex.Execute(ctx, planner(apiResponse, true), out)
+			if err != nil {
+				panic(err)
+			}
+
+			successFirst := out.String()
+			out.Reset()
+			err = ex.Execute(ctx, planner(apiResponse, false), out)
+			if err != nil {
+				panic(err)
+			}
+			errorFirst := out.String()
+			if successFirst != errorFirst {
+				panic(fmt.Errorf("successFirst: %s\nerrorFirst: %s\n", successFirst, errorFirst))
+			}
+			if wantResult != successFirst {
+				panic(fmt.Errorf("want: %s\n got: %s\n", wantResult, successFirst))
+			}
+		}
+	}
+
+	t.Run("list response error case", makeTest(
+		`{"__typename":"RequestResult","Status":"Error","Message":"Could not retrieve Apis details"}`,
+		`{"data":{"apis":{"status":"Error","message":"Could not retrieve Apis details"}}}`, planApis),
+	)
+	t.Run("list response valid response", makeTest(
+		`{"__typename":"ApisResultSuccess","apis":[{"id":"1","name":"a", "__typename":"Api"},{"id":"2","name":"b", "__typename":"Api"}]}`,
+		`{"data":{"apis":{"apis":[{"name":"a"},{"name":"b"}]}}}`, planApis),
+	)
+	t.Run("object response valid response", makeTest(
+		`{"id":"1","name":"a", "__typename":"Api"}}`,
+		`{"data":{"api":{"id":"1","name":"a"}}}`, planApi),
+	)
+	t.Run("object response error response", makeTest(
+		`{"__typename":"RequestResult","Status":"Error","Message":"Could not retrieve Api detail","Meta":null}`,
+		`{"data":{"api":{"status":"Error","message":"Could not retrieve Api detail"}}}`, planApi))
+}
+
+func TestExecutor_HTTPJSONDataSourceWithBodyComplexPlayload(t *testing.T) {
+
+	wantUpstream := map[string]interface{}{
+		"foo": "fooValue",
+		"bar": map[string]interface{}{
+			"bal": "baz",
+		},
+	}
+
+	wantBytes, err := json.MarshalIndent(wantUpstream, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	wantString := string(wantBytes)
+
+	REST1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost {
+			t.Fatalf("wantUpstream: %s, got: %s\n", http.MethodPost, r.Method)
+			return
+		}
+
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		defer r.Body.Close()
+
+		gotString := prettyJSON(bytes.NewReader(data))
+
+		if wantString != gotString {
+			t.Fatalf("wantUpstream: %s\ngot: %s\n", wantString, gotString)
+			return
+		}
 
-		errorFields := []Field{
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("bar"))
+	}))
+
+	plan := &Object{
+		operationType: ast.OperationTypeQuery,
+		Fields: []Field{
 			{
-				Name: []byte("status"),
-				Skip: &IfNotEqual{
-					Left: &datasource.ObjectVariableArgument{
-						PathSelector: datasource.PathSelector{
-							Path: "__typename",
+				Name: []byte("data"),
+				Value: &Object{
+					Fetch: &SingleFetch{
+						BufferName: "withBody",
+						Source: &DataSourceInvocation{
+							DataSource: &datasource.HttpJsonDataSource{
+								Log: log.NoopLogger,
+							},
+							Args: []datasource.Argument{
+								&datasource.StaticVariableArgument{
+									Name:  []byte("host"),
+									Value: []byte(REST1.URL),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  []byte("url"),
+									Value: []byte("/"),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  []byte("method"),
+									Value: []byte("POST"),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  []byte("body"),
+									Value: []byte("{{ .arguments.input }}"),
+								},
+								&datasource.ContextVariableArgument{
+									Name:         []byte(".arguments.input"),
+									VariableName: []byte("input"),
+								},
+							},
 						},
 					},
-					Right: &datasource.StaticVariableArgument{
-						Value: []byte("RequestResult"),
-					},
-				},
-				Value: &Value{
-					ValueType: StringValueType,
-					DataResolvingConfig: DataResolvingConfig{
-						PathSelector: datasource.PathSelector{
-							Path: "Status",
+					Fields: []Field{
+						{
+							Name:            []byte("withBody"),
+							HasResolvedData: true,
+							Value: &Value{
+								ValueType: StringValueType,
+							},
 						},
 					},
 				},
 			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	ex := NewExecutor(nil)
+	ctx := Context{
+		Context: context.Background(),
+		Variables: map[uint64][]byte{
+			xxhash.Sum64String("input"): []byte(`{"foo": "fooValue", "bar": {"bal": "baz"}}`),
+		},
+	}
+
+	err = ex.Execute(ctx, plan, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"data": map[string]interface{}{
+			"withBody": "bar",
+		},
+	}
+
+	wantResult, err := json.MarshalIndent(expected, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := string(wantResult)
+	got := prettyJSON(out)
+
+	if want != got {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+		return
+	}
+}
+
+func TestExecutor_HTTPJSONDataSourceWithHeaders(t *testing.T) {
+
+	REST1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		for k, v := range map[string]string{
+			"foo": "fooVal",
+			"bar": "barVal",
+		} {
+			got := r.Header.Get(k)
+			if got != v {
+				t.Fatalf("want header with key '%s' and value '%s', got: '%s'", k, v, got)
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("bar"))
+	}))
+
+	plan := &Object{
+		operationType: ast.OperationTypeQuery,
+		Fields: []Field{
 			{
-				Name: []byte("message"),
-				Skip: &IfNotEqual{
-					Left: &datasource.ObjectVariableArgument{
-						PathSelector: datasource.PathSelector{
-							Path: "__typename",
+				Name: []byte("data"),
+				Value: &Object{
+					Fetch: &SingleFetch{
+						BufferName: "withHeaders",
+						Source: &DataSourceInvocation{
+							DataSource: &datasource.HttpJsonDataSource{
+								Log: log.NoopLogger,
+							},
+							Args: []datasource.Argument{
+								&datasource.StaticVariableArgument{
+									Name:  []byte("host"),
+									Value: []byte(REST1.URL),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  []byte("url"),
+									Value: []byte("/"),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  []byte("method"),
+									Value: []byte("GET"),
+								},
+								&datasource.ListArgument{
+									Name: []byte("headers"),
+									Arguments: []datasource.Argument{
+										&datasource.StaticVariableArgument{
+											Name:  []byte("foo"),
+											Value: []byte("fooVal"),
+										},
+										&datasource.StaticVariableArgument{
+											Name:  []byte("bar"),
+											Value: []byte("barVal"),
+										},
+									},
+								},
+							},
 						},
 					},
-					Right: &datasource.StaticVariableArgument{
-						Value: []byte("RequestResult"),
-					},
-				},
-				Value: &Value{
-					ValueType: StringValueType,
-					DataResolvingConfig: DataResolvingConfig{
-						PathSelector: datasource.PathSelector{
-							Path: "Message",
+					Fields: []Field{
+						{
+							Name:            []byte("withHeaders"),
+							HasResolvedData: true,
+							Value: &Value{
+								ValueType: StringValueType,
+							},
 						},
 					},
 				},
 			},
-		}
+		},
+	}
 
-		var fields []Field
-		if succesFirst {
-			fields = append(successFields, errorFields...)
-		} else {
-			fields = append(errorFields, successFields...)
-		}
+	out := &bytes.Buffer{}
+	ex := NewExecutor(nil)
+	ctx := Context{
+		Context: context.Background(),
+	}
 
-		return &Object{
-			operationType: ast.OperationTypeQuery,
-			Fields: []Field{
-				{
-					Name: []byte("data"),
-					Value: &Object{
-						Fetch: &SingleFetch{
-							Source: &DataSourceInvocation{
-								DataSource: FakeDataSource{
-									data: []byte(apiResponse),
-								},
+	err := ex.Execute(ctx, plan, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"data": map[string]interface{}{
+			"withHeaders": "bar",
+		},
+	}
+
+	wantResult, err := json.MarshalIndent(expected, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := string(wantResult)
+	got := prettyJSON(out)
+
+	if want != got {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+		return
+	}
+}
+
+func TestExecutor_HTTPJSONDataSourceWithPathSelector(t *testing.T) {
+
+	response := []byte(`
+{
+	"name": {"first": "Tom", "last": "Anderson"},
+	"age":37,
+	"children": ["Sara","Alex","Jack"],
+	"fav.movie": "Deer Hunter",
+	"friends": [
+		{"first": "Dale", "last": "Murphy", "age": 44, "nets": ["ig", "fb", "tw"]},
+		{"first": "Roger", "last": "Craig", "age": 68, "nets": ["fb", "tw"]},
+		{"first": "Jane", "last": "Murphy", "age": 47, "nets": ["ig", "tw"]}
+	]
+}`)
+
+	REST1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(response)
+	}))
+
+	plan := &Object{
+		operationType: ast.OperationTypeQuery,
+		Fields: []Field{
+			{
+				Name: []byte("data"),
+				Value: &Object{
+					Fetch: &SingleFetch{
+						BufferName: "friends",
+						Source: &DataSourceInvocation{
+							DataSource: &datasource.HttpJsonDataSource{
+								Log: log.NoopLogger,
 							},
-							BufferName: "api",
-						},
-						Fields: []Field{
-							{
-								Name:            []byte("api"),
-								HasResolvedData: true,
-								Value: &Object{
-									Fields: fields,
+							Args: []datasource.Argument{
+								&datasource.StaticVariableArgument{
+									Name:  []byte("host"),
+									Value: []byte(REST1.URL),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  []byte("url"),
+									Value: []byte("/"),
+								},
+								&datasource.StaticVariableArgument{
+									Name:  []byte("method"),
+									Value: []byte("GET"),
 								},
 							},
 						},
 					},
-				},
-			},
-		}
-	}
-
-	planApis := func(apiResponse string, succesFirst bool) *Object {
-		successField := Field{
-			Name: []byte("apis"),
-			Skip: &IfNotEqual{
-				Left: &datasource.ObjectVariableArgument{
-					PathSelector: datasource.PathSelector{
-						Path: "__typename",
-					},
-				},
-				Right: &datasource.StaticVariableArgument{
-					Value: []byte("ApisResultSuccess"),
-				},
-			},
-			Value: &List{
-				DataResolvingConfig: DataResolvingConfig{
-					PathSelector: datasource.PathSelector{
-						Path: "apis",
-					},
-				},
-				Value: &Object{
 					Fields: []Field{
 						{
-							Name: []byte("name"),
-							Value: &Value{
-								DataResolvingConfig: DataResolvingConfig{
-									PathSelector: datasource.PathSelector{
-										Path: "name",
+							Name:            []byte("friends"),
+							HasResolvedData: true,
+							Value: &Object{
+								Fields: []Field{
+									{
+										Name: []byte("firstNames"),
+										Value: &List{
+											DataResolvingConfig: DataResolvingConfig{
+												PathSelector: datasource.PathSelector{
+													Path: "friends.#.first",
+												},
+											},
+											Value: &Value{
+												ValueType: StringValueType,
+											},
+										},
 									},
 								},
-								ValueType: StringValueType,
 							},
 						},
 					},
 				},
 			},
-		}
+		},
+	}
 
-		errorFields := []Field{
-			{
-				Name: []byte("status"),
-				Skip: &IfNotEqual{
-					Left: &datasource.ObjectVariableArgument{
-						PathSelector: datasource.PathSelector{
-							Path: "__typename",
-						},
-					},
-					Right: &datasource.StaticVariableArgument{
-						Value: []byte("RequestResult"),
-					},
-				},
-				Value: &Value{
-					ValueType: StringValueType,
-					DataResolvingConfig: DataResolvingConfig{
-						PathSelector: datasource.PathSelector{
-							Path: "Status",
-						},
-					},
-				},
-			},
-			{
-				Name: []byte("message"),
-				Skip: &IfNotEqual{
-					Left: &datasource.ObjectVariableArgument{
-						PathSelector: datasource.PathSelector{
-							Path: "__typename",
-						},
-					},
-					Right: &datasource.StaticVariableArgument{
-						Value: []byte("RequestResult"),
-					},
-				},
-				Value: &Value{
-					ValueType: StringValueType,
-					DataResolvingConfig: DataResolvingConfig{
-						PathSelector: datasource.PathSelector{
-							Path: "Message",
-						},
-					},
-				},
-			},
-		}
+	out := &bytes.Buffer{}
+	ex := NewExecutor(nil)
+	ctx := Context{
+		Context: context.Background(),
+	}
 
-		var fields []Field
-		if succesFirst {
-			fields = append(fields, successField, errorFields[0], errorFields[1])
-		} else {
-			fields = append(fields, errorFields[0], errorFields[1], successField)
-		}
+	err := ex.Execute(ctx, plan, out)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		return &Object{
-			operationType: ast.OperationTypeQuery,
-			Fields: []Field{
-				{
-					Name: []byte("data"),
-					Value: &Object{
-						Fetch: &SingleFetch{
-							Source: &DataSourceInvocation{
-								DataSource: FakeDataSource{
-									data: []byte(apiResponse),
-								},
-							},
-							BufferName: "apis",
-						},
-						Fields: []Field{
-							{
-								Name:            []byte("apis"),
-								HasResolvedData: true,
-								Value: &Object{
-									Fields: fields,
-								},
-							},
-						},
-					},
-				},
+	expected := map[string]interface{}{
+		"data": map[string]interface{}{
+			"friends": map[string]interface{}{
+				"firstNames": []string{"Dale", "Roger", "Jane"},
 			},
-		}
+		},
 	}
 
-	makeTest := func(apiResponse, wantResult string, planner func(apiResponse string, successFirst bool) *Object) func(t *testing.T) {
-		return func(t *testing.T) {
-			out := &bytes.Buffer{}
-			ex := NewExecutor(nil)
-			ctx := Context{
-				Context: context.Background(),
-			}
-			err := ex.Execute(ctx, planner(apiResponse, true), out)
-			if err != nil {
-				panic(err)
-			}
+	wantResult, err := json.MarshalIndent(expected, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-			successFirst := out.String()
-			out.Reset()
-			err = ex.Execute(ctx, planner(apiResponse, false), out)
-			if err != nil {
-				panic(err)
-			}
-			errorFirst := out.String()
-			if successFirst != errorFirst {
-				panic(fmt.Errorf("successFirst: %s\nerrorFirst: %s\n", successFirst, errorFirst))
-			}
-			if wantResult != successFirst {
-				panic(fmt.Errorf("want: %s\n got: %s\n", wantResult, successFirst))
-			}
-		}
+	want := string(wantResult)
+	got := prettyJSON(out)
+
+	if want != got {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+		return
 	}
+}
 
-	t.Run("list response error case", makeTest(
-		`{"__typename":"RequestResult","Status":"Error","Message":"Could not retrieve Apis details"}`,
-		`{"data":{"apis":{"status":"Error","message":"Could not retrieve Apis details"}}}`, planApis),
-	)
-	t.Run("list response valid response", makeTest(
-		`{"__typename":"ApisResultSuccess","apis":[{"id":"1","name":"a", "__typename":"Api"},{"id":"2","name":"b", "__typename":"Api"}]}`,
-		`{"data":{"apis":{"apis":[{"name":"a"},{"name":"b"}]}}}`, planApis),
-	)
-	t.Run("object response valid response", makeTest(
-		`{"id":"1","name":"a", "__typename":"Api"}}`,
-		`{"data":{"api":{"id":"1","name":"a"}}}`, planApi),
-	)
-	t.Run("object response error response", makeTest(
-		`{"__typename":"RequestResult","Status":"Error","Message":"Could not retrieve Api detail","Meta":null}`,
-		`{"data":{"api":{"status":"Error","message":"Could not retrieve Api detail"}}}`, planApi))
+func prettyJSON(r io.Reader) string {
+	data := map[string]interface{}{}
+	err := json.NewDecoder(r).Decode(&data)
+	if err != nil {
+		panic(err)
+	}
+	out, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return string(out)
 }
 
-func TestExecutor_HTTPJSONDataSourceWithBodyComplexPlayload(t *testing.T) {
+func TestExecutor_Introspection(t *testing.T) {
+	executor := NewExecutor(nil)
+	ctx := Context{
+		Context: context.Background(),
+	}
+
+	schema := []byte(`
+		schema {
+			query: Query
+		}
+		type Query {
+			"""
+			multiline
+			description
+			"""
+			foo: String
+			__schema: __Schema!
+		}
+	`)
+
+	config := datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "__schema",
+				DataSource: datasource.SourceConfig{
+					Name:   "SchemaDataSource",
+					Config: toJSON(datasource.SchemaDataSourcePlannerConfig{}),
+				},
+			},
+		},
+	}
+
+	base, err := datasource.NewBaseDataSourcePlanner(schema, config, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	wantUpstream := map[string]interface{}{
-		"foo": "fooValue",
-		"bar": map[string]interface{}{
-			"bal": "baz",
-		},
+	err = base.RegisterDataSourcePlannerFactory("SchemaDataSource", datasource.SchemaDataSourcePlannerFactoryFactory{})
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	wantBytes, err := json.MarshalIndent(wantUpstream, "", "  ")
+	handler := NewHandler(base, nil)
+
+	gen := introspection.NewGenerator()
+	report := operationreport.Report{}
+	data := introspection.Data{}
+	gen.Generate(handler.base.Definition, &report, &data)
+
+	introspectionData, err := json.Marshal(data)
 	if err != nil {
 		t.Fatal(err)
-		return
 	}
 
-	wantString := string(wantBytes)
+	out := bytes.Buffer{}
+	err = executor.Execute(ctx, introspectionQuery(introspectionData), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	REST1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	response := out.Bytes()
 
-		if r.Method != http.MethodPost {
-			t.Fatalf("wantUpstream: %s, got: %s\n", http.MethodPost, r.Method)
-			return
-		}
+	goldie.Assert(t, "introspection_execution", response)
+	if t.Failed() {
 
-		data, err := ioutil.ReadAll(r.Body)
+		fixture, err := ioutil.ReadFile("./fixtures/introspection_execution.golden")
 		if err != nil {
 			t.Fatal(err)
-			return
 		}
-		defer r.Body.Close()
 
-		gotString := prettyJSON(bytes.NewReader(data))
-
-		if wantString != gotString {
-			t.Fatalf("wantUpstream: %s\ngot: %s\n", wantString, gotString)
-			return
-		}
+		diffview.NewGoland().DiffViewBytes("execution", fixture, response)
+	}
+}
 
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("bar"))
-	}))
+func TestExecutor_SkipInclude(t *testing.T) {
 
+	source := &flakyDataSource{}
 	plan := &Object{
 		operationType: ast.OperationTypeQuery,
 		Fields: []Field{
@@ -2489,40 +4043,37 @@ func TestExecutor_HTTPJSONDataSourceWithBodyComplexPlayload(t *testing.T) {
 				Name: []byte("data"),
 				Value: &Object{
 					Fetch: &SingleFetch{
-						BufferName: "withBody",
 						Source: &DataSourceInvocation{
-							DataSource: &datasource.HttpJsonDataSource{
-								Log: log.NoopLogger,
+							DataSource: source,
+						},
+						BufferName: "foo",
+						Skip: &IfNotEqual{
+							Left: &datasource.ContextVariableArgument{
+								VariableName: []byte("withFoo"),
 							},
-							Args: []datasource.Argument{
-								&datasource.StaticVariableArgument{
-									Name:  []byte("host"),
-									Value: []byte(REST1.URL),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  []byte("url"),
-									Value: []byte("/"),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  []byte("method"),
-									Value: []byte("POST"),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  []byte("body"),
-									Value: []byte("{{ .arguments.input }}"),
-								},
-								&datasource.ContextVariableArgument{
-									Name:         []byte(".arguments.input"),
-									VariableName: []byte("input"),
-								},
+							Right: &datasource.StaticVariableArgument{
+								Value: literal.TRUE,
 							},
 						},
 					},
 					Fields: []Field{
 						{
-							Name:            []byte("withBody"),
+							Name:            []byte("foo"),
 							HasResolvedData: true,
+							Skip: &IfNotEqual{
+								Left: &datasource.ContextVariableArgument{
+									VariableName: []byte("withFoo"),
+								},
+								Right: &datasource.StaticVariableArgument{
+									Value: literal.TRUE,
+								},
+							},
 							Value: &Value{
+								DataResolvingConfig: DataResolvingConfig{
+									PathSelector: datasource.PathSelector{
+										Path: "foo",
+									},
+								},
 								ValueType: StringValueType,
 							},
 						},
@@ -2532,162 +4083,354 @@ func TestExecutor_HTTPJSONDataSourceWithBodyComplexPlayload(t *testing.T) {
 		},
 	}
 
-	out := &bytes.Buffer{}
-	ex := NewExecutor(nil)
-	ctx := Context{
-		Context: context.Background(),
-		Variables: map[uint64][]byte{
-			xxhash.Sum64String("input"): []byte(`{"foo": "fooValue", "bar": {"bal": "baz"}}`),
-		},
+	execute := func(withFoo string) string {
+		out := bytes.Buffer{}
+		ctx := Context{
+			Context: context.Background(),
+			Variables: map[uint64][]byte{
+				xxhash.Sum64String("withFoo"): []byte(withFoo),
+			},
+		}
+		err := NewExecutor(nil).Execute(ctx, plan, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
 	}
 
-	err = ex.Execute(ctx, plan, out)
-	if err != nil {
-		t.Fatal(err)
+	if got, want := execute("false"), `{"data":{}}`; got != want {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
 	}
-
-	expected := map[string]interface{}{
-		"data": map[string]interface{}{
-			"withBody": "bar",
-		},
+	if source.calls != 0 {
+		t.Fatalf("want skipped field not to be fetched, got: %d calls", source.calls)
 	}
-
-	wantResult, err := json.MarshalIndent(expected, "", "  ")
-	if err != nil {
-		t.Fatal(err)
+	if got, want := execute("true"), `{"data":{"foo":"bar"}}`; got != want {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+	}
+	if source.calls != 1 {
+		t.Fatalf("want 1 call, got: %d", source.calls)
 	}
+}
 
-	want := string(wantResult)
-	got := prettyJSON(out)
+type payloadRecorder struct {
+	payloads []string
+	hasNext  []bool
+}
 
-	if want != got {
-		t.Fatalf("want: %s\ngot: %s\n", want, got)
-		return
-	}
+func (p *payloadRecorder) WritePayload(payload []byte, hasNext bool) error {
+	p.payloads = append(p.payloads, string(payload))
+	p.hasNext = append(p.hasNext, hasNext)
+	return nil
 }
 
-func TestExecutor_HTTPJSONDataSourceWithHeaders(t *testing.T) {
+func TestExecutor_ExecuteIncremental(t *testing.T) {
 
-	REST1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	plan := func() *Object {
+		return &Object{
+			operationType: ast.OperationTypeQuery,
+			incremental:   true,
+			Fields: []Field{
+				{
+					Name: []byte("data"),
+					Value: &Object{
+						Fetch: &SingleFetch{
+							Source: &DataSourceInvocation{
+								DataSource: &datasource.StaticDataSource{
+									Data: []byte(`{"name":"Luke"}`),
+								},
+							},
+							BufferName: "hero",
+						},
+						Fields: []Field{
+							{
+								Name:            []byte("hero"),
+								HasResolvedData: true,
+								Value: &Object{
+									Fields: []Field{
+										{
+											Name: []byte("name"),
+											Value: &Value{
+												DataResolvingConfig: DataResolvingConfig{
+													PathSelector: datasource.PathSelector{
+														Path: "name",
+													},
+												},
+												ValueType: StringValueType,
+											},
+										},
+									},
+									Deferred: []*DeferredFragment{
+										{
+											Label: "friends",
+											Disabled: &IfEqual{
+												Left: &datasource.ContextVariableArgument{
+													VariableName: []byte("deferFriends"),
+												},
+												Right: &datasource.StaticVariableArgument{
+													Value: literal.FALSE,
+												},
+											},
+											Object: &Object{
+												Fetch: &SingleFetch{
+													Source: &DataSourceInvocation{
+														DataSource: &datasource.StaticDataSource{
+															Data: []byte(`[{"name":"Leia"},{"name":"Han"},{"name":"Chewie"}]`),
+														},
+													},
+													BufferName: "friends",
+												},
+												Fields: []Field{
+													{
+														Name:            []byte("friends"),
+														HasResolvedData: true,
+														Value: &List{
+															Stream: &Stream{
+																Label:        "items",
+																InitialCount: 1,
+															},
+															Value: &Object{
+																Fields: []Field{
+																	{
+																		Name: []byte("name"),
+																		Value: &Value{
+																			DataResolvingConfig: DataResolvingConfig{
+																				PathSelector: datasource.PathSelector{
+																					Path: "name",
+																				},
+																			},
+																			ValueType: StringValueType,
+																		},
+																	},
+																},
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
 
-		for k, v := range map[string]string{
-			"foo": "fooVal",
-			"bar": "barVal",
-		} {
-			got := r.Header.Get(k)
-			if got != v {
-				t.Fatalf("want header with key '%s' and value '%s', got: '%s'", k, v, got)
-			}
+	executionContext := func(deferFriends string) Context {
+		return Context{
+			Context: context.Background(),
+			Variables: map[uint64][]byte{
+				xxhash.Sum64String("deferFriends"): []byte(deferFriends),
+			},
 		}
+	}
 
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("bar"))
-	}))
+	t.Run("payloads", func(t *testing.T) {
+		recorder := &payloadRecorder{}
+		err := NewExecutor(nil).ExecuteIncremental(executionContext("true"), plan(), recorder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			`{"data":{"hero":{"name":"Luke"}},"hasNext":true}`,
+			`{"data":{"friends":[{"name":"Leia"}]},"path":["hero"],"label":"friends","hasNext":true}`,
+			`{"items":[{"name":"Han"}],"path":["hero","friends",1],"label":"items","hasNext":true}`,
+			`{"items":[{"name":"Chewie"}],"path":["hero","friends",2],"label":"items","hasNext":false}`,
+		}
+		if !reflect.DeepEqual(recorder.payloads, want) {
+			t.Fatalf("want:\n%s\ngot:\n%s\n", strings.Join(want, "\n"), strings.Join(recorder.payloads, "\n"))
+		}
+		if !reflect.DeepEqual(recorder.hasNext, []bool{true, true, true, false}) {
+			t.Fatalf("want hasNext: true, true, true, false, got: %v", recorder.hasNext)
+		}
+	})
+	t.Run("omitted variable", func(t *testing.T) {
+		recorder := &payloadRecorder{}
+		err := NewExecutor(nil).ExecuteIncremental(Context{Context: context.Background()}, plan(), recorder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			`{"data":{"hero":{"name":"Luke"}},"hasNext":true}`,
+			`{"data":{"friends":[{"name":"Leia"}]},"path":["hero"],"label":"friends","hasNext":true}`,
+			`{"items":[{"name":"Han"}],"path":["hero","friends",1],"label":"items","hasNext":true}`,
+			`{"items":[{"name":"Chewie"}],"path":["hero","friends",2],"label":"items","hasNext":false}`,
+		}
+		if !reflect.DeepEqual(recorder.payloads, want) {
+			t.Fatalf("want:\n%s\ngot:\n%s\n", strings.Join(want, "\n"), strings.Join(recorder.payloads, "\n"))
+		}
+	})
+	t.Run("disabled defer", func(t *testing.T) {
+		recorder := &payloadRecorder{}
+		err := NewExecutor(nil).ExecuteIncremental(executionContext("false"), plan(), recorder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			`{"data":{"hero":{"name":"Luke","friends":[{"name":"Leia"}]}},"hasNext":true}`,
+			`{"items":[{"name":"Han"}],"path":["hero","friends",1],"label":"items","hasNext":true}`,
+			`{"items":[{"name":"Chewie"}],"path":["hero","friends",2],"label":"items","hasNext":false}`,
+		}
+		if !reflect.DeepEqual(recorder.payloads, want) {
+			t.Fatalf("want:\n%s\ngot:\n%s\n", strings.Join(want, "\n"), strings.Join(recorder.payloads, "\n"))
+		}
+	})
+	t.Run("execute resolves in place", func(t *testing.T) {
+		out := bytes.Buffer{}
+		err := NewExecutor(nil).Execute(executionContext("true"), plan(), &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"data":{"hero":{"name":"Luke","friends":[{"name":"Leia"},{"name":"Han"},{"name":"Chewie"}]}}}`
+		if got := out.String(); got != want {
+			t.Fatalf("want: %s\ngot: %s\n", want, got)
+		}
+	})
+}
 
-	plan := &Object{
-		operationType: ast.OperationTypeQuery,
-		Fields: []Field{
-			{
-				Name: []byte("data"),
-				Value: &Object{
-					Fetch: &SingleFetch{
-						BufferName: "withHeaders",
-						Source: &DataSourceInvocation{
-							DataSource: &datasource.HttpJsonDataSource{
-								Log: log.NoopLogger,
-							},
-							Args: []datasource.Argument{
-								&datasource.StaticVariableArgument{
-									Name:  []byte("host"),
-									Value: []byte(REST1.URL),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  []byte("url"),
-									Value: []byte("/"),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  []byte("method"),
-									Value: []byte("GET"),
+func TestExecutor_TypeResolver(t *testing.T) {
+
+	typeNameIsNot := func(typeName string) BooleanCondition {
+		return &IfNotEqual{
+			Left: &datasource.ObjectVariableArgument{
+				PathSelector: datasource.PathSelector{
+					Path: "__typename",
+				},
+			},
+			Right: &datasource.StaticVariableArgument{
+				Value: []byte(typeName),
+			},
+		}
+	}
+
+	stringField := func(name string, skip BooleanCondition) Field {
+		return Field{
+			Name: []byte(name),
+			Skip: skip,
+			Value: &Value{
+				DataResolvingConfig: DataResolvingConfig{
+					PathSelector: datasource.PathSelector{
+						Path: name,
+					},
+				},
+				ValueType: StringValueType,
+			},
+		}
+	}
+
+	run := func(resolver datasource.TypeResolver, want string) func(t *testing.T) {
+		return func(t *testing.T) {
+			plan := &Object{
+				operationType: ast.OperationTypeQuery,
+				Fields: []Field{
+					{
+						Name: []byte("data"),
+						Value: &Object{
+							Fetch: &SingleFetch{
+								Source: &DataSourceInvocation{
+									DataSource: &datasource.StaticDataSource{
+										Data: []byte(`[{"kind":"cat","name":"Tom","lives":"9"},{"kind":"dog","name":"Rex","bark":"woof"},{"__typename":"Dog","name":"Lassie","bark":"wuff"}]`),
+									},
 								},
-								&datasource.ListArgument{
-									Name: []byte("headers"),
-									Arguments: []datasource.Argument{
-										&datasource.StaticVariableArgument{
-											Name:  []byte("foo"),
-											Value: []byte("fooVal"),
-										},
-										&datasource.StaticVariableArgument{
-											Name:  []byte("bar"),
-											Value: []byte("barVal"),
+								BufferName: "pets",
+							},
+							Fields: []Field{
+								{
+									Name:            []byte("pets"),
+									HasResolvedData: true,
+									Value: &List{
+										Value: &Object{
+											TypeResolver: resolver,
+											Fields: []Field{
+												stringField("__typename", nil),
+												stringField("name", nil),
+												stringField("lives", typeNameIsNot("Cat")),
+												stringField("bark", typeNameIsNot("Dog")),
+											},
 										},
 									},
 								},
 							},
 						},
 					},
-					Fields: []Field{
-						{
-							Name:            []byte("withHeaders"),
-							HasResolvedData: true,
-							Value: &Value{
-								ValueType: StringValueType,
-							},
-						},
-					},
 				},
-			},
-		},
-	}
+			}
 
-	out := &bytes.Buffer{}
-	ex := NewExecutor(nil)
-	ctx := Context{
-		Context: context.Background(),
+			out := bytes.Buffer{}
+			err := NewExecutor(nil).Execute(Context{Context: context.Background()}, plan, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != want {
+				t.Fatalf("want: %s\ngot: %s\n", want, got)
+			}
+		}
 	}
 
-	err := ex.Execute(ctx, plan, out)
-	if err != nil {
-		t.Fatal(err)
-	}
+	resolved := `{"data":{"pets":[{"__typename":"Cat","name":"Tom","lives":"9"},{"__typename":"Dog","name":"Rex","bark":"woof"},{"__typename":"Dog","name":"Lassie","bark":"wuff"}]}}`
 
-	expected := map[string]interface{}{
-		"data": map[string]interface{}{
-			"withHeaders": "bar",
+	t.Run("discriminator", run(&datasource.DiscriminatorConfiguration{
+		Path: "kind",
+		Mapping: map[string]string{
+			"cat": "Cat",
+			"dog": "Dog",
 		},
-	}
+	}, resolved))
+	t.Run("field presence", run((&datasource.PlannerConfiguration{
+		TypeResolvers: []datasource.TypeResolverConfiguration{
+			{
+				TypeName: "Pet",
+				FieldPresence: []datasource.FieldPresenceConfiguration{
+					{
+						TypeName: "Cat",
+						Fields:   []string{"lives"},
+					},
+					{
+						TypeName: "Dog",
+						Fields:   []string{"bark"},
+					},
+				},
+			},
+		},
+	}).TypeResolverForType("Pet"), resolved))
+	t.Run("func", run(datasource.TypeResolverFunc(func(data []byte) (string, bool) {
+		if strings.Contains(string(data), `"kind":"cat"`) {
+			return "Cat", true
+		}
+		return "", false
+	}), `{"data":{"pets":[{"__typename":"Cat","name":"Tom","lives":"9"},{"__typename":null,"name":"Rex"},{"__typename":"Dog","name":"Lassie","bark":"wuff"}]}}`))
+}
 
-	wantResult, err := json.MarshalIndent(expected, "", "  ")
-	if err != nil {
-		t.Fatal(err)
-	}
+type instrumentationRecorder struct {
+	mux                                sync.Mutex
+	executionsStarted, executionsEnded int
+	started                            []FetchEvent
+	ended                              []FetchEndEvent
+}
 
-	want := string(wantResult)
-	got := prettyJSON(out)
+func (i *instrumentationRecorder) ExecutionStart(ctx Context) {
+	i.executionsStarted++
+}
 
-	if want != got {
-		t.Fatalf("want: %s\ngot: %s\n", want, got)
-		return
-	}
+func (i *instrumentationRecorder) ExecutionEnd(ctx Context) {
+	i.executionsEnded++
 }
 
-func TestExecutor_HTTPJSONDataSourceWithPathSelector(t *testing.T) {
+func (i *instrumentationRecorder) FetchStart(ctx Context, event FetchEvent) {
+	i.mux.Lock()
+	i.started = append(i.started, event)
+	i.mux.Unlock()
+}
 
-	response := []byte(`
-{
-	"name": {"first": "Tom", "last": "Anderson"},
-	"age":37,
-	"children": ["Sara","Alex","Jack"],
-	"fav.movie": "Deer Hunter",
-	"friends": [
-		{"first": "Dale", "last": "Murphy", "age": 44, "nets": ["ig", "fb", "tw"]},
-		{"first": "Roger", "last": "Craig", "age": 68, "nets": ["fb", "tw"]},
-		{"first": "Jane", "last": "Murphy", "age": 47, "nets": ["ig", "tw"]}
-	]
-}`)
+func (i *instrumentationRecorder) FetchEnd(ctx Context, event FetchEndEvent) {
+	i.mux.Lock()
+	i.ended = append(i.ended, event)
+	i.mux.Unlock()
+}
 
-	REST1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write(response)
-	}))
+func TestExecutor_Instrumentation(t *testing.T) {
 
 	plan := &Object{
 		operationType: ast.OperationTypeQuery,
@@ -2695,173 +4438,95 @@ func TestExecutor_HTTPJSONDataSourceWithPathSelector(t *testing.T) {
 			{
 				Name: []byte("data"),
 				Value: &Object{
-					Fetch: &SingleFetch{
-						BufferName: "friends",
-						Source: &DataSourceInvocation{
-							DataSource: &datasource.HttpJsonDataSource{
-								Log: log.NoopLogger,
-							},
-							Args: []datasource.Argument{
-								&datasource.StaticVariableArgument{
-									Name:  []byte("host"),
-									Value: []byte(REST1.URL),
-								},
-								&datasource.StaticVariableArgument{
-									Name:  []byte("url"),
-									Value: []byte("/"),
+					Fetch: &ParallelFetch{
+						Fetches: []Fetch{
+							&SingleFetch{
+								Source: &DataSourceInvocation{
+									Args: []datasource.Argument{
+										&datasource.StaticVariableArgument{
+											Name:  []byte("id"),
+											Value: []byte("123"),
+										},
+									},
+									DataSource: &datasource.StaticDataSource{
+										Data: []byte(`{"name":"Jens"}`),
+									},
 								},
-								&datasource.StaticVariableArgument{
-									Name:  []byte("method"),
-									Value: []byte("GET"),
+								BufferName: "user",
+							},
+							&SingleFetch{
+								Source: &DataSourceInvocation{
+									DataSource: errorDataSource{
+										err: fmt.Errorf("upstream unavailable"),
+									},
 								},
+								BufferName: "pets",
 							},
 						},
 					},
 					Fields: []Field{
 						{
-							Name:            []byte("friends"),
+							Name:            []byte("user"),
 							HasResolvedData: true,
 							Value: &Object{
 								Fields: []Field{
 									{
-										Name: []byte("firstNames"),
-										Value: &List{
+										Name: []byte("name"),
+										Value: &Value{
 											DataResolvingConfig: DataResolvingConfig{
 												PathSelector: datasource.PathSelector{
-													Path: "friends.#.first",
+													Path: "name",
 												},
 											},
-											Value: &Value{
-												ValueType: StringValueType,
-											},
+											ValueType: StringValueType,
 										},
 									},
 								},
 							},
 						},
+						{
+							Name:            []byte("pets"),
+							HasResolvedData: true,
+							Value: &List{
+								Value: &Value{
+									ValueType: StringValueType,
+								},
+							},
+						},
 					},
 				},
 			},
 		},
 	}
 
-	out := &bytes.Buffer{}
+	recorder := &instrumentationRecorder{}
 	ex := NewExecutor(nil)
-	ctx := Context{
-		Context: context.Background(),
-	}
-
-	err := ex.Execute(ctx, plan, out)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	expected := map[string]interface{}{
-		"data": map[string]interface{}{
-			"friends": map[string]interface{}{
-				"firstNames": []string{"Dale", "Roger", "Jane"},
-			},
-		},
-	}
-
-	wantResult, err := json.MarshalIndent(expected, "", "  ")
+	ex.SetInstrumentation(recorder)
+	out := &bytes.Buffer{}
+	err := ex.Execute(Context{Context: context.Background()}, plan, out)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	want := string(wantResult)
-	got := prettyJSON(out)
-
-	if want != got {
-		t.Fatalf("want: %s\ngot: %s\n", want, got)
-		return
-	}
-}
-
-func prettyJSON(r io.Reader) string {
-	data := map[string]interface{}{}
-	err := json.NewDecoder(r).Decode(&data)
-	if err != nil {
-		panic(err)
-	}
-	out, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		panic(err)
-	}
-	return string(out)
-}
-
-func TestExecutor_Introspection(t *testing.T) {
-	executor := NewExecutor(nil)
-	ctx := Context{
-		Context: context.Background(),
-	}
-
-	schema := []byte(`
-		schema {
-			query: Query
-		}
-		type Query {
-			"""
-			multiline
-			description
-			"""
-			foo: String
-			__schema: __Schema!
-		}
-	`)
-
-	config := datasource.PlannerConfiguration{
-		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
-			{
-				TypeName:  "query",
-				FieldName: "__schema",
-				DataSource: datasource.SourceConfig{
-					Name:   "SchemaDataSource",
-					Config: toJSON(datasource.SchemaDataSourcePlannerConfig{}),
-				},
-			},
-		},
-	}
-
-	base, err := datasource.NewBaseDataSourcePlanner(schema, config, log.NoopLogger)
-	if err != nil {
-		t.Fatal(err)
+	if recorder.executionsStarted != 1 || recorder.executionsEnded != 1 {
+		t.Fatalf("want 1 execution start and end, got: %d, %d", recorder.executionsStarted, recorder.executionsEnded)
 	}
-
-	err = base.RegisterDataSourcePlannerFactory("SchemaDataSource", datasource.SchemaDataSourcePlannerFactoryFactory{})
-	if err != nil {
-		t.Fatal(err)
+	if len(recorder.started) != 2 || len(recorder.ended) != 2 {
+		t.Fatalf("want 2 fetch start and end events, got: %d, %d", len(recorder.started), len(recorder.ended))
 	}
+	sort.Slice(recorder.ended, func(i, j int) bool {
+		return recorder.ended[i].Path[0].(string) > recorder.ended[j].Path[0].(string)
+	})
 
-	handler := NewHandler(base, nil)
-
-	gen := introspection.NewGenerator()
-	report := operationreport.Report{}
-	data := introspection.Data{}
-	gen.Generate(handler.base.Definition, &report, &data)
-
-	introspectionData, err := json.Marshal(data)
-	if err != nil {
-		t.Fatal(err)
+	user := recorder.ended[0]
+	if !reflect.DeepEqual(user.Path, []interface{}{"user"}) || user.DataSource != "StaticDataSource" || user.ArgsSize != len("id123") || user.Bytes != len(`{"name":"Jens"}`) || user.Err != nil {
+		t.Fatalf("unexpected fetch end event for user: %+v", user)
 	}
-
-	out := bytes.Buffer{}
-	err = executor.Execute(ctx, introspectionQuery(introspectionData), &out)
-	if err != nil {
-		t.Fatal(err)
+	pets := recorder.ended[1]
+	if !reflect.DeepEqual(pets.Path, []interface{}{"pets"}) || pets.DataSource != "errorDataSource" || pets.Bytes != 0 || pets.Err == nil {
+		t.Fatalf("unexpected fetch end event for pets: %+v", pets)
 	}
-
-	response := out.Bytes()
-
-	goldie.Assert(t, "introspection_execution", response)
-	if t.Failed() {
-
-		fixture, err := ioutil.ReadFile("./fixtures/introspection_execution.golden")
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		diffview.NewGoland().DiffViewBytes("execution", fixture, response)
+	if user.Start.IsZero() || user.Duration < 0 {
+		t.Fatalf("want start and duration, got: %+v", user)
 	}
 }
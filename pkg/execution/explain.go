@@ -0,0 +1,426 @@
+package execution
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PlanDescription describes a plan in a stable form which can be marshalled into JSON
+// Paths are response paths joined by dots, list items are marked with "[]", e.g. "data.users[].name"
+type PlanDescription struct {
+	OperationType string           `json:"operationType"`
+	Incremental   bool             `json:"incremental,omitempty"`
+	Root          *NodeDescription `json:"root"`
+}
+
+// NodeDescription describes an Object, List or Value of a plan
+type NodeDescription struct {
+	Kind           string `json:"kind"`
+	Path           string `json:"path"`
+	NonNull        bool   `json:"nonNull,omitempty"`
+	PathSelector   string `json:"pathSelector,omitempty"`
+	Transformation string `json:"transformation,omitempty"`
+	// Fetch, Fields, TypeResolver and Deferred describe an Object
+	Fetch        *FetchDescription     `json:"fetch,omitempty"`
+	Fields       []FieldDescription    `json:"fields,omitempty"`
+	TypeResolver string                `json:"typeResolver,omitempty"`
+	Deferred     []DeferredDescription `json:"deferred,omitempty"`
+	// Filters, Stream and Item describe a List
+	Filters []FilterDescription `json:"filters,omitempty"`
+	Stream  *StreamDescription  `json:"stream,omitempty"`
+	Item    *NodeDescription    `json:"item,omitempty"`
+	// ValueType, Scalar and Enum describe a Value
+	ValueType string `json:"valueType,omitempty"`
+	Scalar    string `json:"scalar,omitempty"`
+	Enum      string `json:"enum,omitempty"`
+}
+
+// FieldDescription describes a Field of an Object, Conditional is true if the field has a skip condition
+type FieldDescription struct {
+	Name        string           `json:"name"`
+	Conditional bool             `json:"conditional,omitempty"`
+	Value       *NodeDescription `json:"value"`
+}
+
+// FetchDescription describes a SingleFetch, SerialFetch or ParallelFetch
+// DataSource is the kind of the unwrapped DataSource of a SingleFetch, Wrappers are the kinds of the DataSources wrapping it from the outside in
+type FetchDescription struct {
+	Kind       string                `json:"kind"`
+	DataSource string                `json:"dataSource,omitempty"`
+	Upstream   string                `json:"upstream,omitempty"`
+	Wrappers   []string              `json:"wrappers,omitempty"`
+	BufferName string                `json:"bufferName,omitempty"`
+	Batch      bool                  `json:"batch,omitempty"`
+	Arguments  []ArgumentDescription `json:"arguments,omitempty"`
+	Fetches    []FetchDescription    `json:"fetches,omitempty"`
+}
+
+// ArgumentDescription describes an argument of a DataSource
+// Value is the template of a static argument, the variable name of a context variable or the path of an object variable
+type ArgumentDescription struct {
+	Name      string                `json:"name"`
+	Kind      string                `json:"kind"`
+	Value     string                `json:"value,omitempty"`
+	Arguments []ArgumentDescription `json:"arguments,omitempty"`
+}
+
+// FilterDescription describes a ListFilter, a ListFilterChain is described by the list of its filters
+type FilterDescription struct {
+	Kind       string `json:"kind"`
+	Count      *int   `json:"count,omitempty"`
+	Variable   string `json:"variable,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Value      string `json:"value,omitempty"`
+	Descending bool   `json:"descending,omitempty"`
+}
+
+// StreamDescription describes a list annotated with @stream
+type StreamDescription struct {
+	Label        string `json:"label,omitempty"`
+	InitialCount int    `json:"initialCount"`
+	Conditional  bool   `json:"conditional,omitempty"`
+}
+
+// DeferredDescription describes a fragment annotated with @defer
+type DeferredDescription struct {
+	Label       string           `json:"label,omitempty"`
+	Conditional bool             `json:"conditional,omitempty"`
+	Object      *NodeDescription `json:"object"`
+}
+
+// DescribePlan returns the description of the plan
+func DescribePlan(node RootNode) *PlanDescription {
+	return &PlanDescription{
+		OperationType: rootPath(node),
+		Incremental:   node.Incremental(),
+		Root:          describeNode(node, ""),
+	}
+}
+
+// PrintPlanJSON writes the description of the plan as indented JSON
+func PrintPlanJSON(node RootNode, w io.Writer) error {
+	data, err := json.MarshalIndent(DescribePlan(node), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// PrintPlanDOT writes the plan as Graphviz DOT graph
+// Fields are nodes connected to their parent field, fetches are boxes connected to the field they resolve the data for
+func PrintPlanDOT(node RootNode, w io.Writer) error {
+	description := DescribePlan(node)
+	printer := dotPrinter{
+		lines: []string{"digraph plan {", "\tnode [shape=ellipse];"},
+	}
+	root := description.OperationType
+	printer.node(root, root, "shape=doubleoctagon")
+	printer.object(root, description.Root)
+	printer.lines = append(printer.lines, "}")
+	_, err := io.WriteString(w, strings.Join(printer.lines, "\n")+"\n")
+	return err
+}
+
+func describeNode(node Node, path string) *NodeDescription {
+	switch node := node.(type) {
+	case *Object:
+		description := &NodeDescription{
+			Kind:    "Object",
+			Path:    path,
+			NonNull: node.NonNull,
+			Fetch:   describeFetch(node.Fetch),
+		}
+		describeDataResolvingConfig(description, node.DataResolvingConfig)
+		if node.TypeResolver != nil {
+			description.TypeResolver = typeName(node.TypeResolver)
+		}
+		for _, field := range node.Fields {
+			description.Fields = append(description.Fields, FieldDescription{
+				Name:        string(field.Name),
+				Conditional: field.Skip != nil,
+				Value:       describeNode(field.Value, joinPath(path, string(field.Name))),
+			})
+		}
+		for _, fragment := range node.Deferred {
+			description.Deferred = append(description.Deferred, DeferredDescription{
+				Label:       fragment.Label,
+				Conditional: fragment.Disabled != nil,
+				Object:      describeNode(fragment.Object, path),
+			})
+		}
+		return description
+	case *List:
+		description := &NodeDescription{
+			Kind:    "List",
+			Path:    path,
+			NonNull: node.NonNull,
+			Filters: describeFilter(nil, node.Filter),
+			Item:    describeNode(node.Value, path+"[]"),
+		}
+		describeDataResolvingConfig(description, node.DataResolvingConfig)
+		if node.Stream != nil {
+			description.Stream = &StreamDescription{
+				Label:        node.Stream.Label,
+				InitialCount: node.Stream.InitialCount,
+				Conditional:  node.Stream.Disabled != nil,
+			}
+		}
+		return description
+	case *Value:
+		description := &NodeDescription{
+			Kind:      "Value",
+			Path:      path,
+			NonNull:   node.NonNull,
+			ValueType: node.ValueType.String(),
+		}
+		describeDataResolvingConfig(description, node.DataResolvingConfig)
+		if node.Scalar != nil {
+			description.Scalar = node.Scalar.Name
+		}
+		if node.Enum != nil {
+			description.Enum = node.Enum.Name
+		}
+		return description
+	default:
+		return &NodeDescription{
+			Kind: typeName(node),
+			Path: path,
+		}
+	}
+}
+
+func describeDataResolvingConfig(description *NodeDescription, config DataResolvingConfig) {
+	description.PathSelector = config.PathSelector.Path
+	if config.Transformation != nil {
+		description.Transformation = typeName(config.Transformation)
+	}
+}
+
+func describeFetch(fetch Fetch) *FetchDescription {
+	switch fetch := fetch.(type) {
+	case nil:
+		return nil
+	case *SingleFetch:
+		description := &FetchDescription{
+			Kind:       "SingleFetch",
+			BufferName: fetch.BufferName,
+			Batch:      fetch.Batch,
+		}
+		if fetch.Source != nil {
+			description.DataSource, description.Wrappers = describeDataSource(fetch.Source.DataSource)
+			description.Upstream = fetch.Source.UpstreamName()
+			description.Arguments = describeArguments(fetch.Source.Args)
+		}
+		return description
+	case *SerialFetch:
+		return describeFetches("SerialFetch", fetch.Fetches)
+	case *ParallelFetch:
+		return describeFetches("ParallelFetch", fetch.Fetches)
+	default:
+		return &FetchDescription{
+			Kind: typeName(fetch),
+		}
+	}
+}
+
+func describeDataSource(source datasource.DataSource) (kind string, wrappers []string) {
+	for {
+		wrapping, ok := source.(datasource.WrappingDataSource)
+		if !ok {
+			return typeName(source), wrappers
+		}
+		wrappers = append(wrappers, typeName(source))
+		source = wrapping.Unwrap()
+	}
+}
+
+func describeFetches(kind string, fetches []Fetch) *FetchDescription {
+	description := &FetchDescription{
+		Kind:    kind,
+		Fetches: make([]FetchDescription, 0, len(fetches)),
+	}
+	for _, fetch := range fetches {
+		description.Fetches = append(description.Fetches, *describeFetch(fetch))
+	}
+	return description
+}
+
+func describeArguments(args []datasource.Argument) []ArgumentDescription {
+	var descriptions []ArgumentDescription
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case nil:
+			continue
+		case *datasource.StaticVariableArgument:
+			descriptions = append(descriptions, ArgumentDescription{
+				Name:  string(arg.Name),
+				Kind:  "static",
+				Value: string(arg.Value),
+			})
+		case *datasource.ContextVariableArgument:
+			descriptions = append(descriptions, ArgumentDescription{
+				Name:  string(arg.Name),
+				Kind:  "contextVariable",
+				Value: string(arg.VariableName),
+			})
+		case *datasource.ObjectVariableArgument:
+			descriptions = append(descriptions, ArgumentDescription{
+				Name:  string(arg.Name),
+				Kind:  "objectVariable",
+				Value: arg.PathSelector.Path,
+			})
+		case *datasource.ListArgument:
+			descriptions = append(descriptions, ArgumentDescription{
+				Name:      string(arg.Name),
+				Kind:      "list",
+				Arguments: describeArguments(arg.Arguments),
+			})
+		default:
+			descriptions = append(descriptions, ArgumentDescription{
+				Name: string(arg.ArgName()),
+				Kind: typeName(arg),
+			})
+		}
+	}
+	return descriptions
+}
+
+func describeFilter(descriptions []FilterDescription, filter ListFilter) []FilterDescription {
+	count := func(n int) *int {
+		if n < 0 {
+			return nil
+		}
+		return &n
+	}
+	switch filter := filter.(type) {
+	case nil:
+		return descriptions
+	case *ListFilterFirstN:
+		return append(descriptions, FilterDescription{Kind: "FirstN", Count: count(filter.FirstN), Variable: string(filter.Variable)})
+	case *ListFilterLastN:
+		return append(descriptions, FilterDescription{Kind: "LastN", Count: count(filter.LastN), Variable: string(filter.Variable)})
+	case *ListFilterOffset:
+		return append(descriptions, FilterDescription{Kind: "Offset", Count: count(filter.Offset), Variable: string(filter.Variable)})
+	case *ListFilterEquals:
+		return append(descriptions, FilterDescription{Kind: "Equals", Path: filter.Path, Value: string(filter.Value), Variable: string(filter.Variable)})
+	case *ListFilterSortBy:
+		return append(descriptions, FilterDescription{Kind: "SortBy", Path: filter.Path, Descending: filter.Descending})
+	case *ListFilterChain:
+		for i := range filter.Filters {
+			descriptions = describeFilter(descriptions, filter.Filters[i])
+		}
+		return descriptions
+	default:
+		return append(descriptions, FilterDescription{Kind: typeName(filter)})
+	}
+}
+
+// typeName returns the name of the type of value without package and pointer, e.g. "GraphQLDataSource"
+func typeName(value interface{}) string {
+	name := fmt.Sprintf("%T", value)
+	name = strings.TrimPrefix(name, "*")
+	if i := strings.LastIndexByte(name, '.'); i != -1 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// dotPrinter collects the lines of a DOT graph
+type dotPrinter struct {
+	lines   []string
+	fetches int
+}
+
+func (d *dotPrinter) node(id, label, attributes string) {
+	line := "\t" + strconv.Quote(id) + " [label=" + strconv.Quote(label)
+	if attributes != "" {
+		line += ", " + attributes
+	}
+	d.lines = append(d.lines, line+"];")
+}
+
+func (d *dotPrinter) edge(from, to, attributes string) {
+	line := "\t" + strconv.Quote(from) + " -> " + strconv.Quote(to)
+	if attributes != "" {
+		line += " [" + attributes + "]"
+	}
+	d.lines = append(d.lines, line+";")
+}
+
+// object prints the fetch and the fields of the Object described by description, id is the id of the DOT node of the object
+func (d *dotPrinter) object(id string, description *NodeDescription) {
+	if description.Fetch != nil {
+		d.fetch(id, description.Fetch)
+	}
+	for _, field := range description.Fields {
+		d.field(id, field.Value, field.Name, field.Conditional)
+	}
+	for _, fragment := range description.Deferred {
+		d.object(id, fragment.Object)
+	}
+}
+
+func (d *dotPrinter) field(parent string, description *NodeDescription, name string, conditional bool) {
+	label := name
+	item := description
+	for item.Kind == "List" {
+		label += "[]"
+		item = item.Item
+	}
+	switch {
+	case item.Scalar != "":
+		label += ": " + item.Scalar
+	case item.Enum != "":
+		label += ": " + item.Enum
+	case item.ValueType != "":
+		label += ": " + strings.TrimSuffix(item.ValueType, "ValueType")
+	}
+	attributes := ""
+	if conditional {
+		attributes = "style=dashed"
+	}
+	d.node(description.Path, label, attributes)
+	d.edge(parent, description.Path, attributes)
+	if item.Kind == "Object" {
+		d.object(description.Path, item)
+	}
+}
+
+// fetch prints the fetch as box connected to the node it resolves the data for
+func (d *dotPrinter) fetch(target string, description *FetchDescription) {
+	d.fetches++
+	id := "fetch" + strconv.Itoa(d.fetches)
+	label := description.Kind
+	if description.DataSource != "" {
+		label += "\n" + description.DataSource
+	}
+	if description.Upstream != "" {
+		label += "\nupstream: " + description.Upstream
+	}
+	if len(description.Wrappers) != 0 {
+		label += "\nwrappers: " + strings.Join(description.Wrappers, ", ")
+	}
+	if description.BufferName != "" {
+		label += "\nbuffer: " + description.BufferName
+	}
+	if description.Batch {
+		label += "\nbatch"
+	}
+	d.node(id, label, "shape=box")
+	d.edge(id, target, "style=dotted")
+	for i := range description.Fetches {
+		d.fetch(id, &description.Fetches[i])
+	}
+}
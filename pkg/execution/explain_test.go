@@ -0,0 +1,145 @@
+package execution
+
+import (
+	"bytes"
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/sebdah/goldie"
+	"testing"
+)
+
+func explainPlan() RootNode {
+	return &Object{
+		operationType: ast.OperationTypeQuery,
+		Fields: []Field{
+			{
+				Name: []byte("data"),
+				Value: &Object{
+					Fetch: &SingleFetch{
+						Source: &DataSourceInvocation{
+							Args: []datasource.Argument{
+								&datasource.StaticVariableArgument{
+									Name:  []byte("url"),
+									Value: []byte("example.com/users"),
+								},
+								&datasource.ContextVariableArgument{
+									Name:         []byte(".arguments.id"),
+									VariableName: []byte("id"),
+								},
+							},
+							DataSource: &datasource.HttpJsonDataSource{},
+							Upstream:   "users",
+						},
+						BufferName: "user",
+					},
+					Fields: []Field{
+						{
+							Name:            []byte("user"),
+							HasResolvedData: true,
+							Value: &Object{
+								Fetch: &ParallelFetch{
+									Fetches: []Fetch{
+										&SingleFetch{
+											Source: &DataSourceInvocation{
+												Args: []datasource.Argument{
+													&datasource.ObjectVariableArgument{
+														Name: []byte(".object.id"),
+														PathSelector: datasource.PathSelector{
+															Path: "id",
+														},
+													},
+												},
+												DataSource: &datasource.CachingDataSource{
+													DataSource: &datasource.ResilientDataSource{
+														DataSource: &datasource.GraphQLDataSource{
+															Name: "friends",
+														},
+													},
+												},
+											},
+											BufferName: "friends",
+										},
+										&SingleFetch{
+											Source: &DataSourceInvocation{
+												DataSource: &datasource.StaticDataSource{},
+											},
+											BufferName: "status",
+										},
+									},
+								},
+								Fields: []Field{
+									{
+										Name: []byte("name"),
+										Value: &Value{
+											DataResolvingConfig: DataResolvingConfig{
+												PathSelector: datasource.PathSelector{
+													Path: "name",
+												},
+											},
+											ValueType: StringValueType,
+											NonNull:   true,
+										},
+									},
+									{
+										Name:            []byte("friends"),
+										HasResolvedData: true,
+										Skip:            &IfEqual{},
+										Value: &List{
+											Filter: &ListFilterChain{
+												Filters: []ListFilter{
+													&ListFilterSortBy{Path: "name"},
+													&ListFilterFirstN{FirstN: -1, Variable: []byte("first")},
+												},
+											},
+											Value: &Object{
+												Fields: []Field{
+													{
+														Name: []byte("name"),
+														Value: &Value{
+															DataResolvingConfig: DataResolvingConfig{
+																PathSelector: datasource.PathSelector{
+																	Path: "name",
+																},
+															},
+															ValueType: StringValueType,
+														},
+													},
+												},
+											},
+										},
+									},
+									{
+										Name:            []byte("status"),
+										HasResolvedData: true,
+										Value: &Value{
+											ValueType: StringValueType,
+											Enum: &Enum{
+												Name: "Status",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestPrintPlanJSON(t *testing.T) {
+	out := bytes.Buffer{}
+	if err := PrintPlanJSON(explainPlan(), &out); err != nil {
+		t.Fatal(err)
+	}
+	goldie.Assert(t, "explain_plan_json", out.Bytes())
+}
+
+func TestPrintPlanDOT(t *testing.T) {
+	out := bytes.Buffer{}
+	if err := PrintPlanDOT(explainPlan(), &out); err != nil {
+		t.Fatal(err)
+	}
+	goldie.Assert(t, "explain_plan_dot", out.Bytes())
+}
@@ -0,0 +1,158 @@
+package execution
+
+import (
+	"bytes"
+	"encoding/json"
+	log "github.com/jensneuse/abstractlogger"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/federation"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// subgraphServer responds to the GraphQL requests of the gateway with the response of the query and variables of the request
+// Requests without a response fail with the query and variables so that unexpected upstream queries show up in the test output
+func subgraphServer(responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query     string
+			Variables json.RawMessage
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		variables := bytes.Buffer{}
+		_ = json.Compact(&variables, request.Variables)
+		key := request.Query + " " + variables.String()
+		response, ok := responses[key]
+		if !ok {
+			_, _ = w.Write(toJSON(map[string]interface{}{
+				"errors": []map[string]string{{"message": "unexpected request: " + key}},
+			}))
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+}
+
+func TestFederation(t *testing.T) {
+
+	accounts := subgraphServer(map[string]string{
+		`query o {me {name id}} {}`: `{"data":{"me":{"name":"Jens","id":"1"}}}`,
+	})
+	defer accounts.Close()
+	reviews := subgraphServer(map[string]string{
+		`query o($representations: [_Any!]!){_entities(representations: $representations){... on User {reviews {body product {upc}}}}} {"representations":[{"__typename":"User","id":"1"}]}`: `{"data":{"_entities":[{"reviews":[{"body":"great","product":{"upc":"top-1"}},{"body":"bad","product":{"upc":"top-2"}}]}]}}`,
+	})
+	defer reviews.Close()
+	products := subgraphServer(map[string]string{
+		`query o($representations: [_Any!]!){_entities(representations: $representations){... on Product {name}}} {"representations":[{"__typename":"Product","upc":"top-1"}]}`: `{"data":{"_entities":[{"name":"Table"}]}}`,
+		`query o($representations: [_Any!]!){_entities(representations: $representations){... on Product {name}}} {"representations":[{"__typename":"Product","upc":"top-2"}]}`: `{"data":{"_entities":[null]},"errors":[{"message":"product not found","path":["_entities",0]}]}`,
+		`query o {topProducts {name upc price weight}} {}`: `{"data":{"topProducts":[{"name":"Table","upc":"top-1","price":100,"weight":10},{"name":"Chair","upc":"top-2","price":50,"weight":null}]}}`,
+	})
+	defer products.Close()
+	inventory := subgraphServer(map[string]string{
+		`query o($representations: [_Any!]!){_entities(representations: $representations){... on Product {shippingEstimate}}} {"representations":[{"__typename":"Product","upc":"top-1","price":100,"weight":10},{"__typename":"Product","upc":"top-2","price":50,"weight":null}]}`: `{"data":{"_entities":[{"shippingEstimate":5},{"shippingEstimate":0}]}}`,
+		`query o($representations: [_Any!]!){_entities(representations: $representations){... on Product {inStock}}} {"representations":[{"__typename":"Product","upc":"top-1"},{"__typename":"Product","upc":"top-2"}]}`:                                                           `{"data":{"_entities":[{"inStock":true},{"inStock":false}]},"errors":[{"message":"stock unknown","path":["_entities",1,"inStock"]}]}`,
+	})
+	defer inventory.Close()
+
+	subgraph := func(name string, server *httptest.Server, sdl string) federation.Subgraph {
+		return federation.Subgraph{
+			Name: name,
+			SDL:  []byte(sdl),
+			DataSource: datasource.GraphQLDataSourceConfig{
+				Host: server.URL,
+				URL:  "/",
+			},
+		}
+	}
+
+	report := operationreport.Report{}
+	schema, config := federation.Compose([]federation.Subgraph{
+		subgraph("accounts", accounts, `
+			extend type Query {
+				me: User
+			}
+			type User @key(fields: "id") {
+				id: ID!
+				name: String
+			}`),
+		subgraph("reviews", reviews, `
+			type Review {
+				body: String
+				product: Product
+			}
+			extend type User @key(fields: "id") {
+				id: ID! @external
+				reviews: [Review]
+			}
+			extend type Product @key(fields: "upc") {
+				upc: String! @external
+			}`),
+		subgraph("products", products, `
+			extend type Query {
+				topProducts: [Product]
+			}
+			type Product @key(fields: "upc") {
+				upc: String!
+				name: String
+				price: Int
+				weight: Int
+			}`),
+		subgraph("inventory", inventory, `
+			extend type Product @key(fields: "upc") {
+				upc: String! @external
+				price: Int @external
+				weight: Int @external
+				inStock: Boolean
+				shippingEstimate: Int @requires(fields: "price weight")
+			}`),
+	}, &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	run := func(query, want string) func(t *testing.T) {
+		return func(t *testing.T) {
+			base, err := datasource.NewBaseDataSourcePlanner(schema, config, log.NoopLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			panicOnErr(base.RegisterDataSourcePlannerFactory("GraphQLDataSource", datasource.GraphQLDataSourcePlannerFactoryFactory{}))
+			handler := NewHandler(base, nil)
+
+			executor, node, ctx, err := handler.Handle([]byte(`{"query":"`+query+`"}`), nil)
+			if err != nil {
+				if !strings.Contains(err.Error(), want) {
+					t.Fatalf("want error: %s\ngot: %s\n", want, err)
+				}
+				return
+			}
+			out := bytes.Buffer{}
+			if err := executor.Execute(ctx, node, &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != want {
+				t.Fatalf("want: %s\ngot: %s\n", want, out.String())
+			}
+		}
+	}
+
+	t.Run("entity fields of several subgraphs", run(
+		`{ me { name reviews { body product { name } } } }`,
+		`{"data":{"me":{"name":"Jens","reviews":[{"body":"great","product":{"name":"Table"}},{"body":"bad","product":{"name":null}}]}},"errors":[{"message":"product not found","locations":[{"line":1,"column":38}],"path":["me","reviews",1,"product","name"],"extensions":{"upstream":"products"}}]}`,
+	))
+	t.Run("requires", run(
+		`{ topProducts { name shippingEstimate inStock } }`,
+		`{"data":{"topProducts":[{"name":"Table","shippingEstimate":5,"inStock":true},{"name":"Chair","shippingEstimate":0,"inStock":false}]},"errors":[{"message":"stock unknown","locations":[{"line":1,"column":39}],"path":["topProducts",1,"inStock"],"extensions":{"upstream":"inventory"}}]}`,
+	))
+	t.Run("requires fields of another subgraph", run(
+		`{ me { reviews { product { shippingEstimate } } } }`,
+		`field: price required by field: shippingEstimate on type: Product can't be resolved by the upstream of the enclosing field`,
+	))
+}
@@ -3,56 +3,215 @@
 package execution
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/buger/jsonparser"
 	"github.com/cespare/xxhash"
 	"github.com/jensneuse/byte-template"
+	"github.com/jensneuse/graphql-go-tools/internal/pkg/unsafebytes"
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
 	"github.com/jensneuse/graphql-go-tools/pkg/astnormalization"
 	"github.com/jensneuse/graphql-go-tools/pkg/astparser"
+	"github.com/jensneuse/graphql-go-tools/pkg/astprinter"
 	"github.com/jensneuse/graphql-go-tools/pkg/astvalidation"
 	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
+	"github.com/jensneuse/graphql-go-tools/pkg/metrics"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
 )
 
+// DefaultResponseCacheSize is the number of data source results a Handler keeps by default
+const DefaultResponseCacheSize = 4096
+
 type Handler struct {
 	templateDirectives []byte_template.DirectiveDefinition
 	base               *datasource.BasePlanner
+	planCache          *planCache
+	maxConcurrency     int
+	scalars            Scalars
+	// responseCache stores the results of fields with a @cacheControl directive
+	responseCache datasource.Cache
+	// queryPlanExtension enables the "queryPlan" response extension
+	queryPlanExtension bool
+	// tracingExtension enables the "tracing" response extension
+	tracingExtension bool
+	metrics          metrics.Metrics
+	// operationNameLabel enables the operation name label of the metrics
+	operationNameLabel bool
 }
 
 func NewHandler(base *datasource.BasePlanner, templateDirectives []byte_template.DirectiveDefinition) *Handler {
 	return &Handler{
 		templateDirectives: templateDirectives,
 		base:               base,
+		planCache:          newPlanCache(DefaultPlanCacheSize),
+		maxConcurrency:     DefaultMaxConcurrency,
+		scalars:            Scalars{},
+		responseCache:      datasource.NewInMemoryCache(DefaultResponseCacheSize),
+		metrics:            metrics.Noop{},
+	}
+}
+
+// ChangePlanCacheSize changes the number of plans the Handler keeps, a size of 0 disables plan caching
+// ChangePlanCacheSize drops all cached plans and must not be called concurrently to Handle
+func (h *Handler) ChangePlanCacheSize(size int) {
+	h.planCache = newPlanCache(size)
+}
+
+// ChangeMaxConcurrency changes the number of list items the Executors returned by Handle prefetch concurrently, a value <= 0 removes the limit
+func (h *Handler) ChangeMaxConcurrency(maxConcurrency int) {
+	h.maxConcurrency = maxConcurrency
+}
+
+// RegisterScalar registers a custom scalar which is used to parse variables and to serialize values of the scalar
+// Scalars must be registered before the first call to Handle as they are part of the cached plans
+func (h *Handler) RegisterScalar(scalar Scalar) {
+	h.scalars.Register(scalar)
+}
+
+// SetResponseCache replaces the in-memory cache storing the results of fields with a @cacheControl directive, nil disables response caching
+// The cache must be set before the first call to Handle as the cached plans refer to it
+func (h *Handler) SetResponseCache(cache datasource.Cache) {
+	h.responseCache = cache
+}
+
+// EnableQueryPlanExtension allows clients to request the plan of their operation with the request extension "queryPlan"
+// "queryPlan": true adds the JSON description of the plan, "queryPlan": "dot" adds the plan as Graphviz DOT graph to the response extensions
+// Plans reveal the configuration of all data sources, e.g. static headers, so the extension should only be enabled for trusted clients
+func (h *Handler) EnableQueryPlanExtension(enabled bool) {
+	h.queryPlanExtension = enabled
+}
+
+// EnableTracingExtension adds the timings of parsing, validation, planning and all fetches in the Apollo tracing format
+// to the "tracing" field of the response extensions of all requests
+func (h *Handler) EnableTracingExtension(enabled bool) {
+	h.tracingExtension = enabled
+}
+
+// SetMetrics sets the Metrics receiving the request counts, the durations of all phases and the data source calls of all requests
+// nil restores the default which discards all metrics
+func (h *Handler) SetMetrics(m metrics.Metrics) {
+	if m == nil {
+		m = metrics.Noop{}
 	}
+	h.metrics = m
+}
+
+// EnableOperationNameLabel labels the request and phase metrics with the operation name of the request, the label is empty otherwise
+// Operation names are chosen by the clients, so every new name creates new time series in the metrics backend
+// The label should only be enabled if the clients are trusted or the operations are restricted, e.g. to persisted queries
+func (h *Handler) EnableOperationNameLabel(enabled bool) {
+	h.operationNameLabel = enabled
+}
+
+// Metrics returns the Metrics of the Handler
+func (h *Handler) Metrics() metrics.Metrics {
+	return h.metrics
 }
 
 type GraphqlRequest struct {
-	OperationName string          `json:"operation_name"`
+	OperationName string          `json:"operationName"`
 	Variables     json.RawMessage `json:"variables"`
 	Query         string          `json:"query"`
+	Extensions    json.RawMessage `json:"extensions"`
+}
+
+// UnmarshalJSON accepts the operation name using the standard "operationName" key as well as the legacy "operation_name" key
+func (g *GraphqlRequest) UnmarshalJSON(data []byte) error {
+	type graphqlRequest GraphqlRequest // prevents the recursion into UnmarshalJSON
+	request := struct {
+		graphqlRequest
+		LegacyOperationName string `json:"operation_name"`
+	}{}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return err
+	}
+	*g = GraphqlRequest(request.graphqlRequest)
+	if g.OperationName == "" {
+		g.OperationName = request.LegacyOperationName
+	}
+	return nil
 }
 
 func (h *Handler) Handle(requestData, extraVariables []byte) (executor *Executor, node RootNode, ctx Context, err error) {
 
+	executor = NewExecutor(h.templateDirectives)
+	executor.ChangeMaxConcurrency(h.maxConcurrency)
+	var tracing *TracingCollector
+	if h.tracingExtension {
+		tracing = NewTracingCollector(executor)
+	}
+
 	var graphqlRequest GraphqlRequest
 	err = json.Unmarshal(requestData, &graphqlRequest)
+	var labelOperationName string
+	if h.operationNameLabel {
+		labelOperationName = graphqlRequest.OperationName
+	}
+	h.metrics.IncCounter(metrics.RequestsTotal, operationNameLabel(labelOperationName))
+	defer func() {
+		if err != nil {
+			h.metrics.IncCounter(metrics.RequestErrorsTotal, operationNameLabel(labelOperationName))
+		}
+	}()
 	if err != nil {
 		return
 	}
+	executor.AddInstrumentation(&metricsInstrumentation{
+		metrics:       h.metrics,
+		operationName: labelOperationName,
+	})
+	phases := handlerPhases{
+		metrics:       h.metrics,
+		tracing:       tracing,
+		operationName: labelOperationName,
+	}
+
+	variables, extraArguments := h.VariablesFromJson(graphqlRequest.Variables, extraVariables)
+	if h.queryPlanExtension {
+		defer func() {
+			if err == nil {
+				err = setQueryPlanExtension(executor, node, graphqlRequest.Extensions)
+			}
+		}()
+	}
+	ctx = Context{
+		Variables:      variables,
+		ExtraArguments: extraArguments,
+	}
 
+	// in case the exact same query was planned before we can skip parsing, normalization, validation and planning
+	requestKey := planCacheKey([]byte(graphqlRequest.Query), graphqlRequest.OperationName)
+	if cached, ok := h.planCache.get(requestKey); ok {
+		report := operationreport.Report{}
+		validation := phases.start()
+		coerceVariables(cached.variableDefinitions, h.base.Definition, h.scalars, graphqlRequest.Variables, variables, &report)
+		phases.end(tracingPhaseValidation, validation)
+		if report.HasErrors() {
+			err = report
+			return
+		}
+		return executor, cached.plan, ctx, nil
+	}
+
+	parsing := phases.start()
 	operationDocument, report := astparser.ParseGraphqlDocumentString(graphqlRequest.Query)
+	phases.end(tracingPhaseParsing, parsing)
 	if report.HasErrors() {
 		err = report
 		return
 	}
 
-	variables, extraArguments := h.VariablesFromJson(graphqlRequest.Variables, extraVariables)
-
 	planner := NewPlanner(h.base)
+	planner.visitor.scalars = h.scalars
+	planner.visitor.cache = h.responseCache
 	if report.HasErrors() {
 		err = report
 		return
 	}
 
+	validation := phases.start()
 	astnormalization.NormalizeOperation(&operationDocument, h.base.Definition, &report)
 	if report.HasErrors() {
 		err = report
@@ -69,27 +228,104 @@ func (h *Handler) Handle(requestData, extraVariables []byte) (executor *Executor
 		err = report
 		return
 	}
+
+	// variables get checked before planning so that invalid input never reaches a data source
+	operationRef, operationErr := operationDefinitionRef(&operationDocument, graphqlRequest.OperationName)
+	if operationErr != nil {
+		report.AddExternalError(*operationErr)
+		err = report
+		return
+	}
+	variableDefinitions := newVariableDefinitions(&operationDocument, operationRef)
+	coerceVariables(variableDefinitions, h.base.Definition, h.scalars, graphqlRequest.Variables, variables, &report)
+	if report.HasErrors() {
+		err = report
+		return
+	}
 	normalizer := astnormalization.NewNormalizer(true)
 	normalizer.NormalizeOperation(&operationDocument, h.base.Definition, &report)
 	if report.HasErrors() {
 		err = report
 		return
 	}
-	plan := planner.Plan(&operationDocument, h.base.Definition, &report)
+	phases.end(tracingPhaseValidation, validation)
+
+	planning := phases.start()
+	defer phases.end(tracingPhasePlanning, planning)
+
+	// queries which only differ in formatting or fragment usage share the same normalized operation and therefore the same plan
+	normalizedOperation := bytes.Buffer{}
+	err = astprinter.Print(&operationDocument, h.base.Definition, &normalizedOperation)
+	if err != nil {
+		return
+	}
+	operationKey := planCacheKey(normalizedOperation.Bytes(), graphqlRequest.OperationName)
+	if cached, ok := h.planCache.get(operationKey); ok {
+		h.planCache.add(requestKey, cached)
+		return executor, cached.plan, ctx, nil
+	}
+
+	plan := planner.Plan(&operationDocument, h.base.Definition, graphqlRequest.OperationName, &report)
 	if report.HasErrors() {
 		err = report
 		return
 	}
 
-	executor = NewExecutor(h.templateDirectives)
-	ctx = Context{
-		Variables:      variables,
-		ExtraArguments: extraArguments,
+	// subscription plans can't be shared because stream data sources keep state per subscription
+	if plan.OperationType() != ast.OperationTypeSubscription {
+		cached := cachedPlan{
+			plan:                plan,
+			variableDefinitions: variableDefinitions,
+		}
+		h.planCache.add(operationKey, cached)
+		h.planCache.add(requestKey, cached)
 	}
 
 	return executor, plan, ctx, err
 }
 
+// setQueryPlanExtension adds the plan to the response extensions if the request extensions ask for it
+func setQueryPlanExtension(executor *Executor, node RootNode, requestExtensions []byte) error {
+	var format string
+	value, dataType, _, err := jsonparser.Get(requestExtensions, unsafebytes.BytesToString(literal.QUERY_PLAN))
+	switch {
+	case err != nil:
+		return nil
+	case dataType == jsonparser.Boolean && bytes.Equal(value, literal.TRUE):
+		format = "json"
+	case dataType == jsonparser.String:
+		format = string(value)
+	default:
+		return nil
+	}
+	var plan []byte
+	switch format {
+	case "json":
+		plan, err = json.Marshal(DescribePlan(node))
+	case "dot":
+		dot := bytes.Buffer{}
+		err = PrintPlanDOT(node, &dot)
+		if err == nil {
+			plan, err = json.Marshal(dot.String())
+		}
+	default:
+		return fmt.Errorf("unsupported queryPlan format: %s, supported formats are: json, dot", format)
+	}
+	if err != nil {
+		return err
+	}
+	executor.SetExtension(unsafebytes.BytesToString(literal.QUERY_PLAN), plan)
+	return nil
+}
+
+func planCacheKey(operation []byte, operationName string) uint64 {
+	digest := xxhash.New()
+	_, _ = digest.Write(operation)
+	_, _ = digest.Write([]byte{0})
+	_, _ = digest.Write([]byte(operationName))
+	return digest.Sum64()
+}
+
 func (h *Handler) VariablesFromJson(requestVariables, extraVariables []byte) (variables Variables, extraArguments []datasource.Argument) {
 	variables = map[uint64][]byte{}
 	_ = jsonparser.ObjectEach(requestVariables, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
@@ -2,9 +2,14 @@ package execution
 
 import (
 	"bytes"
+	"encoding/json"
 	"github.com/cespare/xxhash"
 	log "github.com/jensneuse/abstractlogger"
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
 	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/metrics"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -47,3 +52,681 @@ func TestHandler_VariablesFromRequest(t *testing.T) {
 		t.Fatalf("unexpected")
 	}
 }
+
+func TestHandler_PlanCache(t *testing.T) {
+
+	schema := []byte(`
+		schema {
+			query: Query
+		}
+		type Query {
+			hello(name: String): String
+		}`)
+
+	newHandler := func(t *testing.T) *Handler {
+		base, err := datasource.NewBaseDataSourcePlanner(schema, datasource.PlannerConfiguration{}, log.NoopLogger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewHandler(base, nil)
+	}
+
+	handle := func(t *testing.T, handler *Handler, request string) (RootNode, Context) {
+		_, plan, ctx, err := handler.Handle([]byte(request), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return plan, ctx
+	}
+
+	t.Run("same query", func(t *testing.T) {
+		handler := newHandler(t)
+		first, _ := handle(t, handler, `{"query":"query Hello($name: String) { hello(name: $name) }","variables":{"name":"foo"}}`)
+		second, ctx := handle(t, handler, `{"query":"query Hello($name: String) { hello(name: $name) }","variables":{"name":"bar"}}`)
+		if first != second {
+			t.Fatal("want plan to be reused")
+		}
+		if got := string(ctx.Variables[xxhash.Sum64String("name")]); got != "bar" {
+			t.Fatalf("want variables to be resolved per request, got: %s", got)
+		}
+	})
+	t.Run("same normalized query", func(t *testing.T) {
+		handler := newHandler(t)
+		first, _ := handle(t, handler, `{"query":"query Hello($name: String) { hello(name: $name) }"}`)
+		second, _ := handle(t, handler, `{"query":"query Hello($name: String) {\n\thello(name: $name)\n}"}`)
+		if first != second {
+			t.Fatal("want plan to be reused")
+		}
+		if handler.planCache.len() != 3 {
+			t.Fatalf("want 3 cache entries, got: %d", handler.planCache.len())
+		}
+	})
+	t.Run("different operation name", func(t *testing.T) {
+		handler := newHandler(t)
+		first, _ := handle(t, handler, `{"query":"query Hello { hello }","operation_name":"Hello"}`)
+		second, _ := handle(t, handler, `{"query":"query Hello { hello }"}`)
+		if first == second {
+			t.Fatal("want plans to be keyed by operation name")
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		handler := newHandler(t)
+		handler.ChangePlanCacheSize(0)
+		first, _ := handle(t, handler, `{"query":"query Hello { hello }"}`)
+		second, _ := handle(t, handler, `{"query":"query Hello { hello }"}`)
+		if first == second {
+			t.Fatal("want plans not to be reused")
+		}
+		if handler.planCache.len() != 0 {
+			t.Fatalf("want empty cache, got: %d", handler.planCache.len())
+		}
+	})
+}
+
+func TestPlanCache(t *testing.T) {
+	cache := newPlanCache(2)
+	first, second, third := &Object{}, &Object{}, &Object{}
+
+	cache.add(1, cachedPlan{plan: first})
+	cache.add(2, cachedPlan{plan: second})
+	if _, ok := cache.get(1); !ok { // 1 is now the most recently used entry
+		t.Fatal("want entry 1")
+	}
+	cache.add(3, cachedPlan{plan: third})
+
+	if _, ok := cache.get(2); ok {
+		t.Fatal("want least recently used entry 2 to be evicted")
+	}
+	if cached, ok := cache.get(1); !ok || cached.plan != first {
+		t.Fatal("want entry 1")
+	}
+	if cached, ok := cache.get(3); !ok || cached.plan != third {
+		t.Fatal("want entry 3")
+	}
+	if cache.len() != 2 {
+		t.Fatalf("want 2 entries, got: %d", cache.len())
+	}
+}
+
+func TestHandler_OperationName(t *testing.T) {
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+		schema {
+			query: Query
+			mutation: Mutation
+		}
+		type Query {
+			hello: String
+		}
+		type Mutation {
+			setHello(hello: String): String
+		}`), datasource.PlannerConfiguration{}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(base, nil)
+
+	query := `query Hello { hello } mutation SetHello { setHello(hello: \"foo\") }`
+
+	t.Run("operationName", func(t *testing.T) {
+		_, plan, _, err := handler.Handle([]byte(`{"query":"`+query+`","operationName":"SetHello"}`), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.OperationType() != ast.OperationTypeMutation {
+			t.Fatalf("want mutation, got: %v", plan.OperationType())
+		}
+		fields := plan.(*Object).Fields[0].Value.(*Object).Fields
+		if len(fields) != 1 || string(fields[0].Name) != "setHello" {
+			t.Fatalf("want only the fields of the selected operation, got: %d fields", len(fields))
+		}
+	})
+	t.Run("legacy operation_name", func(t *testing.T) {
+		_, plan, _, err := handler.Handle([]byte(`{"query":"`+query+`","operation_name":"Hello"}`), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.OperationType() != ast.OperationTypeQuery {
+			t.Fatalf("want query, got: %v", plan.OperationType())
+		}
+	})
+	t.Run("missing operationName", func(t *testing.T) {
+		_, _, _, err := handler.Handle([]byte(`{"query":"`+query+`"}`), nil)
+		if err == nil || !strings.Contains(err.Error(), "operation name is required") {
+			t.Fatalf("want operation name required error, got: %v", err)
+		}
+	})
+	t.Run("unknown operationName", func(t *testing.T) {
+		_, _, _, err := handler.Handle([]byte(`{"query":"`+query+`","operationName":"Unknown"}`), nil)
+		if err == nil || !strings.Contains(err.Error(), "operation with name: Unknown not found") {
+			t.Fatalf("want operation not found error, got: %v", err)
+		}
+	})
+	t.Run("single operation without operationName", func(t *testing.T) {
+		_, plan, _, err := handler.Handle([]byte(`{"query":"query Hello { hello }"}`), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.OperationType() != ast.OperationTypeQuery {
+			t.Fatalf("want query, got: %v", plan.OperationType())
+		}
+	})
+}
+
+func TestHandler_Variables(t *testing.T) {
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+		schema {
+			query: Query
+		}
+		type Query {
+			droid(id: ID!): String
+			hero(episode: Episode): String
+			search(limit: Int, names: [String!]): String
+			review(review: ReviewInput!): String
+		}
+		enum Episode {
+			NEWHOPE
+			EMPIRE
+		}
+		input ReviewInput {
+			stars: Int!
+			commentary: String = "none"
+			episode: Episode
+		}`), datasource.PlannerConfiguration{}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(base, nil)
+
+	run := func(query, variables string, wantVariables map[string]string, wantErr string) func(t *testing.T) {
+		return func(t *testing.T) {
+			// the second request hits the plan cache which must not skip the coercion
+			for i := 0; i < 2; i++ {
+				_, _, ctx, err := handler.Handle([]byte(`{"query":"`+query+`","variables":`+variables+`}`), nil)
+				if wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), wantErr) {
+						t.Fatalf("want error: %s, got: %v", wantErr, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				for name, want := range wantVariables {
+					if got := string(ctx.Variables[xxhash.Sum64String(name)]); got != want {
+						t.Fatalf("want variable %s: %s, got: %s", name, want, got)
+					}
+				}
+			}
+		}
+	}
+
+	t.Run("valid", run(`query Droid($id: ID!) { droid(id: $id) }`, `{"id":"2001"}`, map[string]string{"id": "2001"}, ""))
+	t.Run("int as id", run(`query Droid($id: ID!) { droid(id: $id) }`, `{"id":2001}`, map[string]string{"id": "2001"}, ""))
+	t.Run("missing non-null", run(`query Droid($id: ID!) { droid(id: $id) }`, `{}`, nil, "variable: id of required type: ID! was not provided"))
+	t.Run("null for non-null", run(`query Droid($id: ID!) { droid(id: $id) }`, `{"id":null}`, nil, "variable: id got invalid value: null; expected type: ID!"))
+	t.Run("default value", run(`query Hero($episode: Episode = EMPIRE) { hero(episode: $episode) }`, `{}`, map[string]string{"episode": "EMPIRE"}, ""))
+	t.Run("enum", run(`query Hero($episode: Episode) { hero(episode: $episode) }`, `{"episode":"NEWHOPE"}`, map[string]string{"episode": "NEWHOPE"}, ""))
+	t.Run("unknown enum value", run(`query Hero($episode: Episode) { hero(episode: $episode) }`, `{"episode":"JEDI"}`, nil, `variable: episode got invalid value: "JEDI"; expected type: Episode`))
+	t.Run("wrong scalar", run(`query Search($limit: Int) { search(limit: $limit) }`, `{"limit":"ten"}`, nil, `variable: limit got invalid value: "ten"; expected type: Int`))
+	t.Run("int out of range", run(`query Search($limit: Int) { search(limit: $limit) }`, `{"limit":2147483648}`, nil, "expected type: Int"))
+	t.Run("integral float as int", run(`query Search($limit: Int) { search(limit: $limit) }`, `{"limit":1.0}`, map[string]string{"limit": "1"}, ""))
+	t.Run("exponent as int", run(`query Search($limit: Int) { search(limit: $limit) }`, `{"limit":1e3}`, map[string]string{"limit": "1000"}, ""))
+	t.Run("fraction as int", run(`query Search($limit: Int) { search(limit: $limit) }`, `{"limit":1.5}`, nil, "expected type: Int"))
+	t.Run("exponent out of range", run(`query Search($limit: Int) { search(limit: $limit) }`, `{"limit":1e10}`, nil, "expected type: Int"))
+	t.Run("single value for list", run(`query Search($names: [String!]) { search(names: $names) }`, `{"names":"foo"}`, map[string]string{"names": `["foo"]`}, ""))
+	t.Run("invalid list item", run(`query Search($names: [String!]) { search(names: $names) }`, `{"names":["foo",null]}`, nil, "expected type: String! at: 1"))
+	t.Run("input object", run(`query Review($review: ReviewInput!) { review(review: $review) }`, `{"review":{"episode":"EMPIRE","stars":5}}`,
+		map[string]string{"review": `{"stars":5,"commentary":"none","episode":"EMPIRE"}`}, ""))
+	t.Run("input object default", run(`query Review($review: ReviewInput! = {stars: 3}) { review(review: $review) }`, `{}`,
+		map[string]string{"review": `{"stars":3,"commentary":"none"}`}, ""))
+	t.Run("input object missing field", run(`query Review($review: ReviewInput!) { review(review: $review) }`, `{"review":{}}`, nil, "required field: stars of type: Int! was not provided"))
+	t.Run("input object unknown field", run(`query Review($review: ReviewInput!) { review(review: $review) }`, `{"review":{"stars":5,"foo":1}}`, nil, "field: foo is not defined on input type: ReviewInput"))
+	t.Run("input object invalid field", run(`query Review($review: ReviewInput!) { review(review: $review) }`, `{"review":{"stars":5,"episode":"JEDI"}}`, nil, "expected type: Episode at: episode"))
+}
+
+func TestHandler_Scalars(t *testing.T) {
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+		schema {
+			query: Query
+		}
+		type Query {
+			event(id: UUID, after: DateTime): Event
+		}
+		type Event {
+			id: UUID
+			at: DateTime
+			attendees: BigInt
+			history: [DateTime]
+			payload: JSON
+		}
+		scalar UUID
+		scalar DateTime
+		scalar BigInt
+		scalar JSON`), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "event",
+				Mapping: &datasource.MappingConfiguration{
+					Disabled: true,
+				},
+				DataSource: datasource.SourceConfig{
+					Name: "StaticDataSource",
+					Config: toJSON(datasource.StaticDataSourceConfig{
+						Data: `{"id":"A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11","at":"2020-01-02 10:00:00","attendees":12345678901234567890,"history":["2020-01-01T12:00:00+02:00","yesterday"],"payload":{"a":[1,"b"]}}`,
+					}),
+				},
+			},
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+	handler := NewHandler(base, nil)
+	for _, scalar := range []Scalar{UUIDScalar, DateTimeScalar, BigIntScalar, JSONScalar} {
+		handler.RegisterScalar(scalar)
+	}
+
+	t.Run("serialize", func(t *testing.T) {
+		executor, node, ctx, err := handler.Handle([]byte(`{"query":"{ event { id at attendees history payload } }"}`), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := bytes.Buffer{}
+		if err := executor.Execute(ctx, node, &out); err != nil {
+			t.Fatal(err)
+		}
+		want := `{"data":{"event":{"id":"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11","at":"2020-01-02T10:00:00Z","attendees":"12345678901234567890","history":["2020-01-01T10:00:00Z",null],"payload":{"a":[1,"b"]}}},` +
+			`"errors":[{"message":"DateTime cannot represent value: \"yesterday\"; expected a date time in the format: 2006-01-02T15:04:05Z07:00","locations":[{"line":1,"column":27}],"path":["event","history",1]}]}`
+		if got := out.String(); got != want {
+			t.Fatalf("want: %s\ngot: %s\n", want, got)
+		}
+	})
+	t.Run("parse variables", func(t *testing.T) {
+		_, _, ctx, err := handler.Handle([]byte(`{"query":"query Event($id: UUID, $after: DateTime) { event(id: $id, after: $after) { id } }","variables":{"id":"A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11","after":"2020-01-01T12:00:00+02:00"}}`), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, want := range map[string]string{"id": "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "after": "2020-01-01T10:00:00Z"} {
+			if got := string(ctx.Variables[xxhash.Sum64String(name)]); got != want {
+				t.Fatalf("want variable %s: %s, got: %s", name, want, got)
+			}
+		}
+	})
+	t.Run("invalid variable", func(t *testing.T) {
+		_, _, _, err := handler.Handle([]byte(`{"query":"query Event($id: UUID) { event(id: $id) { id } }","variables":{"id":"foo"}}`), nil)
+		want := `variable: id got invalid value: "foo"; expected a UUID`
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("want error: %s, got: %v", want, err)
+		}
+	})
+}
+
+func TestHandler_ListFilters(t *testing.T) {
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+		schema {
+			query: Query
+		}
+		type Query {
+			tasks(status: String, skip: Int, first: Int): [Task]
+				@ListFilterEquals(path: "status", argument: "status")
+				@ListFilterSortBy(path: "rank", descending: true)
+				@ListFilterOffset(argument: "skip")
+				@ListFilterFirstN(n: 2, argument: "first")
+		}
+		type Task {
+			id: Int
+		}`), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "tasks",
+				Mapping: &datasource.MappingConfiguration{
+					Disabled: true,
+				},
+				DataSource: datasource.SourceConfig{
+					Name: "StaticDataSource",
+					Config: toJSON(datasource.StaticDataSourceConfig{
+						Data: `[{"id":1,"status":"open","rank":3},{"id":2,"status":"closed","rank":1},{"id":3,"status":"open","rank":2},{"id":4,"status":"open","rank":1}]`,
+					}),
+				},
+			},
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+	handler := NewHandler(base, nil)
+
+	run := func(request, want string) func(t *testing.T) {
+		return func(t *testing.T) {
+			executor, node, ctx, err := handler.Handle([]byte(request), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := bytes.Buffer{}
+			if err := executor.Execute(ctx, node, &out); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != want {
+				t.Fatalf("want: %s\ngot: %s\n", want, got)
+			}
+		}
+	}
+
+	t.Run("defaults", run(`{"query":"{ tasks { id } }"}`, `{"data":{"tasks":[{"id":1},{"id":3}]}}`))
+	t.Run("literal arguments", run(`{"query":"{ tasks(status: \"open\", skip: 1, first: 5) { id } }"}`, `{"data":{"tasks":[{"id":3},{"id":4}]}}`))
+	t.Run("variables", run(`{"query":"query Tasks($status: String, $first: Int) { tasks(status: $status, first: $first) { id } }","variables":{"status":"open","first":1}}`, `{"data":{"tasks":[{"id":1}]}}`))
+	t.Run("missing variables", run(`{"query":"query Tasks($status: String, $first: Int) { tasks(status: $status, first: $first) { id } }"}`, `{"data":{"tasks":[{"id":1},{"id":3}]}}`))
+}
+
+func TestHandler_QueryPlanExtension(t *testing.T) {
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+		schema {
+			query: Query
+		}
+		type Query {
+			hello: String
+		}`), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "hello",
+				Mapping: &datasource.MappingConfiguration{
+					Disabled: true,
+				},
+				DataSource: datasource.SourceConfig{
+					Name: "StaticDataSource",
+					Config: toJSON(datasource.StaticDataSourceConfig{
+						Data: "world",
+					}),
+				},
+			},
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+
+	run := func(enabled bool, request, want string) func(t *testing.T) {
+		return func(t *testing.T) {
+			handler := NewHandler(base, nil)
+			handler.EnableQueryPlanExtension(enabled)
+			executor, node, ctx, err := handler.Handle([]byte(request), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := bytes.Buffer{}
+			if err := executor.Execute(ctx, node, &out); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != want {
+				t.Fatalf("want: %s\ngot: %s\n", want, got)
+			}
+		}
+	}
+
+	t.Run("json", run(true, `{"query":"{ hello }","extensions":{"queryPlan":true}}`,
+		`{"data":{"hello":"world"},"extensions":{"queryPlan":{"operationType":"query","root":{"kind":"Object","path":"","fields":[{"name":"data","value":{"kind":"Object","path":"data","fetch":{"kind":"SingleFetch","dataSource":"StaticDataSource","bufferName":"hello"},"fields":[{"name":"hello","value":{"kind":"Value","path":"data.hello","valueType":"StringValueType"}}]}}]}}}}`))
+	t.Run("dot", run(true, `{"query":"{ hello }","extensions":{"queryPlan":"dot"}}`,
+		`{"data":{"hello":"world"},"extensions":{"queryPlan":"digraph plan {\n\tnode [shape=ellipse];\n\t\"query\" [label=\"query\", shape=doubleoctagon];\n\t\"data\" [label=\"data\"];\n\t\"query\" -\u003e \"data\";\n\t\"fetch1\" [label=\"SingleFetch\\nStaticDataSource\\nbuffer: hello\", shape=box];\n\t\"fetch1\" -\u003e \"data\" [style=dotted];\n\t\"data.hello\" [label=\"hello: String\"];\n\t\"data\" -\u003e \"data.hello\";\n}\n"}}`))
+	t.Run("not requested", run(true, `{"query":"{ hello }"}`, `{"data":{"hello":"world"}}`))
+	t.Run("disabled", run(false, `{"query":"{ hello }","extensions":{"queryPlan":true}}`, `{"data":{"hello":"world"}}`))
+}
+
+func TestHandler_TracingExtension(t *testing.T) {
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+		schema {
+			query: Query
+		}
+		type Query {
+			hello: String
+		}`), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "hello",
+				Mapping: &datasource.MappingConfiguration{
+					Disabled: true,
+				},
+				DataSource: datasource.SourceConfig{
+					Name: "StaticDataSource",
+					Config: toJSON(datasource.StaticDataSourceConfig{
+						Data: "world",
+					}),
+					Resilience: &datasource.ResilienceConfiguration{
+						MaxRetries: 1,
+					},
+				},
+				Upstream: "greetings",
+			},
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+	handler := NewHandler(base, nil)
+	handler.EnableTracingExtension(true)
+
+	executor, node, ctx, err := handler.Handle([]byte(`{"query":"{ hello }"}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.Buffer{}
+	if err := executor.Execute(ctx, node, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var response struct {
+		Data       map[string]string `json:"data"`
+		Extensions struct {
+			Tracing struct {
+				Version    int    `json:"version"`
+				StartTime  string `json:"startTime"`
+				Duration   int64  `json:"duration"`
+				Parsing    struct{ Duration int64 }
+				Validation struct{ Duration int64 }
+				Planning   struct{ Duration int64 }
+				Execution  struct {
+					Resolvers []struct {
+						Path       []interface{} `json:"path"`
+						ParentType string        `json:"parentType"`
+						FieldName  string        `json:"fieldName"`
+						ReturnType string        `json:"returnType"`
+						DataSource string        `json:"dataSource"`
+						Upstream   string        `json:"upstream"`
+						Bytes      int           `json:"bytes"`
+					} `json:"resolvers"`
+				} `json:"execution"`
+			} `json:"tracing"`
+		} `json:"extensions"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Data["hello"] != "world" {
+		t.Fatalf("want data, got: %s", out.String())
+	}
+	tracing := response.Extensions.Tracing
+	if tracing.Version != 1 || tracing.StartTime == "" || tracing.Duration <= 0 {
+		t.Fatalf("want tracing version, start time and duration, got: %s", out.String())
+	}
+	if tracing.Parsing.Duration <= 0 || tracing.Validation.Duration <= 0 || tracing.Planning.Duration <= 0 {
+		t.Fatalf("want durations of parsing, validation and planning, got: %s", out.String())
+	}
+	if len(tracing.Execution.Resolvers) != 1 {
+		t.Fatalf("want one resolver, got: %s", out.String())
+	}
+	resolver := tracing.Execution.Resolvers[0]
+	if !reflect.DeepEqual(resolver.Path, []interface{}{"hello"}) || resolver.ParentType != "Query" || resolver.FieldName != "hello" || resolver.ReturnType != "String" || resolver.DataSource != "StaticDataSource" || resolver.Upstream != "greetings" || resolver.Bytes != len("world") {
+		t.Fatalf("unexpected resolver: %+v", resolver)
+	}
+}
+
+func TestHandler_Metrics(t *testing.T) {
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+		schema {
+			query: Query
+		}
+		type Query {
+			hello: String
+		}`), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "hello",
+				Mapping: &datasource.MappingConfiguration{
+					Disabled: true,
+				},
+				DataSource: datasource.SourceConfig{
+					Name: "StaticDataSource",
+					Config: toJSON(datasource.StaticDataSourceConfig{
+						Data: "world",
+					}),
+					Resilience: &datasource.ResilienceConfiguration{
+						MaxRetries: 1,
+					},
+				},
+				Upstream: "greetings",
+			},
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+	collected := metrics.NewInMemory()
+	handler := NewHandler(base, nil)
+	handler.SetMetrics(collected)
+	handler.EnableOperationNameLabel(true)
+	handler.EnableTracingExtension(true)
+
+	for i := 0; i < 2; i++ {
+		executor, node, ctx, err := handler.Handle([]byte(`{"query":"query Hello { hello }","operationName":"Hello"}`), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := bytes.Buffer{}
+		if err := executor.Execute(ctx, node, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(out.Bytes(), []byte(`"tracing"`)) {
+			t.Fatalf("want tracing extension next to the metrics, got: %s", out.String())
+		}
+	}
+	if _, _, _, err := handler.Handle([]byte(`{"query":"query Invalid { goodbye }","operationName":"Invalid"}`), nil); err == nil {
+		t.Fatal("want error for invalid query")
+	}
+
+	hello := metrics.Label{Name: metrics.LabelOperationName, Value: "Hello"}
+	invalid := metrics.Label{Name: metrics.LabelOperationName, Value: "Invalid"}
+	if got := collected.Counter(metrics.RequestsTotal, hello); got != 2 {
+		t.Fatalf("want 2 requests for Hello, got: %v", got)
+	}
+	if got := collected.Counter(metrics.RequestErrorsTotal, hello); got != 0 {
+		t.Fatalf("want 0 request errors for Hello, got: %v", got)
+	}
+	if got := collected.Counter(metrics.RequestsTotal, invalid); got != 1 {
+		t.Fatalf("want 1 request for Invalid, got: %v", got)
+	}
+	if got := collected.Counter(metrics.RequestErrorsTotal, invalid); got != 1 {
+		t.Fatalf("want 1 request error for Invalid, got: %v", got)
+	}
+
+	// the second request uses the cached plan so only validation and execution are observed twice
+	phases := map[string]int{
+		metrics.PhaseParse:    1,
+		metrics.PhaseValidate: 2,
+		metrics.PhasePlan:     1,
+		metrics.PhaseExecute:  2,
+	}
+	for phase, want := range phases {
+		if got := collected.Histogram(metrics.PhaseDurationSeconds, hello, metrics.Label{Name: metrics.LabelPhase, Value: phase}); len(got) != want {
+			t.Fatalf("want %d observations of phase %s, got: %v", want, phase, got)
+		}
+	}
+
+	dataSource := metrics.Label{Name: metrics.LabelDataSource, Value: "greetings"}
+	if got := collected.Histogram(metrics.DataSourceDurationSeconds, dataSource); len(got) != 2 {
+		t.Fatalf("want 2 observations of the data source latency, got: %v", got)
+	}
+	if got := collected.Counter(metrics.DataSourceErrorsTotal, dataSource); got != 0 {
+		t.Fatalf("want 0 data source errors, got: %v", got)
+	}
+
+	handler.EnableOperationNameLabel(false)
+	if _, _, _, err := handler.Handle([]byte(`{"query":"query Hello { hello }","operationName":"Hello"}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := collected.Counter(metrics.RequestsTotal, metrics.Label{Name: metrics.LabelOperationName}); got != 1 {
+		t.Fatalf("want 1 request without operation name label, got: %v", got)
+	}
+}
+
+func TestHandler_Enums(t *testing.T) {
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+		schema {
+			query: Query
+		}
+		type Query {
+			task: Task
+		}
+		type Task {
+			status: Status
+			mappedStatus: Status @enumMapping(values: [{from: "in_progress", to: "IN_PROGRESS"}, {from: "done", to: "DONE"}])
+			history: [Status!]
+		}
+		enum Status {
+			TODO
+			IN_PROGRESS
+			DONE
+		}`), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "task",
+				Mapping: &datasource.MappingConfiguration{
+					Disabled: true,
+				},
+				DataSource: datasource.SourceConfig{
+					Name: "StaticDataSource",
+					Config: toJSON(datasource.StaticDataSourceConfig{
+						Data: `{"status":"in_progress","mappedStatus":"in_progress","history":["TODO","done"]}`,
+					}),
+				},
+			},
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+	handler := NewHandler(base, nil)
+
+	executor, node, ctx, err := handler.Handle([]byte(`{"query":"{ task { status mappedStatus history } }"}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.Buffer{}
+	if err := executor.Execute(ctx, node, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"data":{"task":{"status":null,"mappedStatus":"IN_PROGRESS","history":null}},` +
+		`"errors":[{"message":"Enum Status cannot represent value: in_progress","locations":[{"line":1,"column":10}],"path":["task","status"]},` +
+		`{"message":"Enum Status cannot represent value: done","locations":[{"line":1,"column":30}],"path":["task","history",1]}]}`
+	if got := out.String(); got != want {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+	}
+}
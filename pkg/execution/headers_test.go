@@ -0,0 +1,154 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	log "github.com/jensneuse/abstractlogger"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDataSource_HeaderForwarding(t *testing.T) {
+
+	forwarding := datasource.HeaderForwarding{
+		Request: datasource.HeaderRules{
+			Allow: append([]string{"authorization", "X-Auth", "Cookie", "Accept-Encoding", "X-Static"}, datasource.TracingHeaders...),
+			Deny:  []string{"cookie"},
+			Rename: map[string]string{
+				"x-auth": "X-Upstream-Auth",
+			},
+		},
+		Response: datasource.HeaderRules{
+			Allow: []string{"*"},
+			Deny:  []string{"X-Internal"},
+		},
+	}
+
+	request := []byte(`{"uri":"/","method":"POST","headers":{"Authorization":"Bearer \"token\"","X-Auth":"secret","Cookie":"session=1","Accept-Encoding":"gzip","Traceparent":"00-abc-def-01","X-Other":"other","X-Static":"client"}}`)
+
+	// wantStatic is the X-Static header the upstream receives, headers configured statically take precedence over forwarded ones
+	run := func(source datasource.DataSource, args ResolvedArgs, wantStatic string) func(t *testing.T) {
+		return func(t *testing.T) {
+			var upstreamHeader http.Header
+			var upstreamBody []byte
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstreamHeader = r.Header
+				upstreamBody, _ = ioutil.ReadAll(r.Body)
+				w.Header().Add("Set-Cookie", "a=1")
+				w.Header().Add("Set-Cookie", "b=2")
+				w.Header().Set("Cache-Control", "max-age=60")
+				w.Header().Set("X-Internal", "secret")
+				_, _ = w.Write([]byte(`{"data":{"user":{"name":"Jens"}}}`))
+			}))
+			defer upstream.Close()
+
+			args = append(ResolvedArgs{
+				{
+					Key:   literal.HOST,
+					Value: []byte(upstream.URL),
+				},
+				{
+					Key:   literal.REQUEST,
+					Value: request,
+				},
+			}, args...)
+
+			responseHeaders := &datasource.ResponseHeaders{}
+			out := bytes.Buffer{}
+			if _, err := source.Resolve(datasource.WithResponseHeaders(context.Background(), responseHeaders), args, &out); err != nil {
+				t.Fatal(err)
+			}
+
+			wantForwarded := map[string]string{
+				"Authorization":   `Bearer "token"`,
+				"X-Upstream-Auth": "secret",
+				"Traceparent":     "00-abc-def-01",
+				"X-Static":        wantStatic,
+				"X-Auth":          "",
+				"Cookie":          "",
+				"X-Other":         "",
+			}
+			for name, want := range wantForwarded {
+				if got := upstreamHeader.Get(name); got != want {
+					t.Fatalf("want header %s: %q, got: %q", name, want, got)
+				}
+			}
+			if bytes.Contains(upstreamBody, []byte("session=1")) {
+				t.Fatalf("the client request must not be forwarded as part of the body: %s", string(upstreamBody))
+			}
+
+			header := http.Header{}
+			responseHeaders.CopyTo(header)
+			wantHeader := http.Header{
+				"Set-Cookie":    {"a=1", "b=2"},
+				"Cache-Control": {"max-age=60"},
+			}
+			header.Del("Date")
+			if !reflect.DeepEqual(header, wantHeader) {
+				t.Fatalf("want response headers: %v\ngot: %v", wantHeader, header)
+			}
+		}
+	}
+
+	t.Run("GraphQLDataSource", run(&datasource.GraphQLDataSource{
+		Log:              log.NoopLogger,
+		HeaderForwarding: forwarding,
+	}, ResolvedArgs{
+		{
+			Key:   literal.URL,
+			Value: []byte("/"),
+		},
+		{
+			Key:   literal.QUERY,
+			Value: []byte("query o{user{name}}"),
+		},
+	}, "client"))
+	t.Run("HttpJsonDataSource", run(&datasource.HttpJsonDataSource{
+		Log:              log.NoopLogger,
+		HeaderForwarding: forwarding,
+	}, ResolvedArgs{
+		{
+			Key:   literal.URL,
+			Value: []byte("/"),
+		},
+		{
+			Key:   literal.METHOD,
+			Value: []byte("GET"),
+		},
+		{
+			Key:   literal.HEADERS,
+			Value: toJSON(map[string]string{"X-Static": "static"}),
+		},
+	}, "static"))
+}
+
+func TestGraphQLDataSourcePlanner_HeaderForwarding(t *testing.T) {
+
+	forwarding := datasource.HeaderForwarding{
+		Request: datasource.HeaderRules{
+			Allow: []string{"Authorization"},
+		},
+	}
+	config, err := json.Marshal(datasource.GraphQLDataSourceConfig{
+		Host:             "localhost",
+		URL:              "/",
+		HeaderForwarding: forwarding,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory, err := datasource.GraphQLDataSourcePlannerFactoryFactory{}.Initialize(datasource.BasePlanner{}, bytes.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, _ := factory.DataSourcePlanner().Plan(nil)
+	if got := source.(*datasource.GraphQLDataSource).HeaderForwarding; !reflect.DeepEqual(got, forwarding) {
+		t.Fatalf("want HeaderForwarding: %+v\ngot: %+v", forwarding, got)
+	}
+}
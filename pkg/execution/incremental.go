@@ -0,0 +1,201 @@
+package execution
+
+import (
+	"encoding/json"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
+)
+
+// DeferredFragment is a fragment annotated with @defer
+// Its fields get resolved after the initial payload and are sent as a patch for the path of the enclosing Object
+type DeferredFragment struct {
+	Label string
+	// Disabled is the condition of a variable "if" argument, if it evaluates to true the fragment gets resolved in place
+	Disabled BooleanCondition
+	Object   *Object
+}
+
+// Stream is the configuration of a list annotated with @stream
+// The first InitialCount items are part of the initial payload, each remaining item is sent as a patch of its own
+type Stream struct {
+	Label        string
+	InitialCount int
+	// Disabled is the condition of a variable "if" argument, if it evaluates to true the list gets resolved in place
+	Disabled BooleanCondition
+}
+
+// IncrementalWriter receives the payloads of an incremental execution
+// hasNext is false for the last payload
+type IncrementalWriter interface {
+	WritePayload(payload []byte, hasNext bool) error
+}
+
+// patch is a deferred fragment or an item of a streamed list which gets resolved after the current payload
+type patch struct {
+	label    string
+	path     string
+	data     []byte
+	fragment *DeferredFragment
+	list     *List
+	items    [][]byte
+	index    int
+}
+
+// ExecuteIncremental resolves the RootNode like Execute but sends deferred fragments and streamed list items
+// as separate payloads after the initial payload
+// All payloads, including the initial payload, contain "hasNext" to indicate if more payloads follow
+func (e *Executor) ExecuteIncremental(ctx Context, node RootNode, w IncrementalWriter) error {
+	e.reset(ctx, node)
+	e.executionStart()
+	e.incremental = true
+	defer func() {
+		e.incremental = false
+	}()
+	path := rootPath(node)
+	switch root := node.(type) {
+	case *Object:
+		e.write(literal.LBRACE)
+		e.resolveObjectFields(root, nil, path)
+	default:
+		e.write(literal.LBRACE)
+		e.writeQuoted(literal.DATA)
+		e.write(literal.COLON)
+		e.resolveNode(node, nil, path, nil, true)
+	}
+	e.executionEnd()    // patches aren't part of the instrumented execution as they get resolved while the client reads the response
+	e.writeExtensions() // extensions are only part of the initial payload
+	if err := e.writePayload(w); err != nil {
+		return err
+	}
+	for len(e.patches) != 0 {
+		next := e.patches[0]
+		e.patches = e.patches[1:]
+		e.out.Reset()
+		e.errors = e.errors[:0]
+		e.write(literal.LBRACE)
+		if next.fragment != nil {
+			e.writeQuoted(literal.DATA)
+			e.write(literal.COLON)
+			e.resolveDeferredFragment(next)
+		} else {
+			e.writeQuoted(literal.ITEMS)
+			e.write(literal.COLON)
+			e.resolveListItems(next.list, next.items, next.path, next.index)
+		}
+		e.write(literal.COMMA)
+		e.writeQuoted(literal.PATH)
+		e.write(literal.COLON)
+		e.writePatchPath(next)
+		if next.label != "" {
+			e.write(literal.COMMA)
+			e.writeQuoted(literal.LABEL)
+			e.write(literal.COLON)
+			e.writeJSON(next.label)
+		}
+		if err := e.writePayload(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePayload completes the current payload with the errors and "hasNext" and hands it over to the writer
+func (e *Executor) writePayload(w IncrementalWriter) error {
+	hasNext := len(e.patches) != 0
+	if len(e.errors) != 0 {
+		e.write(literal.COMMA)
+		e.writeErrors()
+	}
+	e.write(literal.COMMA)
+	e.writeQuoted(literal.HAS_NEXT)
+	e.write(literal.COLON)
+	if hasNext {
+		e.write(literal.TRUE)
+	} else {
+		e.write(literal.FALSE)
+	}
+	e.write(literal.RBRACE)
+	if e.err != nil {
+		return e.err
+	}
+	return w.WritePayload(e.out.Bytes(), hasNext)
+}
+
+func (e *Executor) resolveDeferredFragment(next patch) {
+	object := next.fragment.Object
+	if object.hasFetch() {
+		e.fetch(object, next.data, next.path)
+	}
+	start, patches := e.out.Len(), len(e.patches)
+	e.write(literal.LBRACE)
+	if e.resolveObjectFields(object, next.data, next.path) {
+		e.truncate(start, patches) // a non-null field resolved to null, the parent can't be nulled anymore so the patch data becomes null
+		e.write(literal.NULL)
+		return
+	}
+	e.write(literal.RBRACE)
+}
+
+// writePatchPath writes the response path of the patch, for streamed lists the path ends with the index of the item
+func (e *Executor) writePatchPath(next patch) {
+	path := responsePath(next.path)
+	if path == nil {
+		path = []interface{}{}
+	}
+	if next.list != nil {
+		path = append(path, next.index)
+	}
+	e.writeJSON(path)
+}
+
+func (e *Executor) writeJSON(value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.write(data)
+}
+
+// isDeferred reports if the fragment gets sent as a patch instead of being resolved in place
+func (e *Executor) isDeferred(fragment *DeferredFragment, data []byte) bool {
+	return e.incremental && (fragment.Disabled == nil || !fragment.Disabled.Evaluate(e.context, data))
+}
+
+// isStreamed reports if the remaining items of the list get sent as patches instead of being resolved in place
+func (e *Executor) isStreamed(stream *Stream, data []byte) bool {
+	return e.incremental && (stream.Disabled == nil || !stream.Disabled.Evaluate(e.context, data))
+}
+
+// truncate removes the output written after start together with all patches queued meanwhile
+// It's used when a non-null child resolved to null and the output gets replaced by null
+func (e *Executor) truncate(start, patches int) {
+	e.out.Truncate(start)
+	e.patches = e.patches[:patches]
+}
+
+// fetch executes the fetch of the object and the fetches of all fragments of the object which don't get deferred
+// fetch errors are stored next to the buffers and get reported by the fields reading from them
+// A SerialFetch is skipped as it gets executed field by field while resolving the fields, see fetchSerially
+func (e *Executor) fetch(node *Object, data []byte, path string) {
+	if _, serial := node.Fetch.(*SerialFetch); node.Fetch != nil && !serial {
+		_, _ = node.Fetch.Fetch(e.context, data, e, path, &e.buffers)
+	}
+	for _, fragment := range node.Deferred {
+		if !e.isDeferred(fragment, data) {
+			e.fetch(fragment.Object, data, path)
+		}
+	}
+}
+
+// hasFetch reports if the object or one of its deferred fragments has a fetch
+func (o *Object) hasFetch() bool {
+	if o.Fetch != nil {
+		return true
+	}
+	for i := range o.Deferred {
+		if o.Deferred[i].Object.hasFetch() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package execution
+
+import (
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"time"
+)
+
+// Instrumentation receives events about an execution, e.g. to collect traces or metrics
+// FetchStart and FetchEnd get called concurrently for fetches running in parallel
+type Instrumentation interface {
+	ExecutionStart(ctx Context)
+	ExecutionEnd(ctx Context)
+	FetchStart(ctx Context, event FetchEvent)
+	FetchEnd(ctx Context, event FetchEndEvent)
+}
+
+// FetchEvent describes a call to the DataSource of a SingleFetch
+type FetchEvent struct {
+	// Path is the response path of the field the DataSource resolves the data for
+	Path []interface{}
+	// DataSource is the kind of the DataSource without the DataSources wrapping it, e.g. "GraphQLDataSource"
+	DataSource string
+	// Upstream is the name of the upstream the DataSource calls, empty if neither the field nor the DataSource configure one
+	Upstream string
+	// ParentType and ReturnType are the enclosing type and the type of the field, empty if the planner didn't set them
+	ParentType, ReturnType string
+	// ArgsSize is the size of all resolved args in bytes
+	ArgsSize int
+	// Batch is true if the data of the field is resolved together with other list items in a single call
+	Batch bool
+	// Start is the time the DataSource got called
+	Start time.Time
+}
+
+// FetchEndEvent describes a finished call to the DataSource of a SingleFetch
+type FetchEndEvent struct {
+	FetchEvent
+	Duration time.Duration
+	// Bytes is the number of bytes the DataSource returned for the field
+	Bytes int
+	// Err is the error returned by the DataSource
+	Err error
+	// Shared is true if the fetch used the result of an identical fetch of the same execution instead of calling the DataSource
+	Shared bool
+}
+
+// SetInstrumentation sets the Instrumentation receiving the events of the following executions, nil disables instrumentation
+func (e *Executor) SetInstrumentation(instrumentation Instrumentation) {
+	e.instrumentation = instrumentation
+}
+
+// AddInstrumentation adds an Instrumentation which receives the events of the following executions in addition to the existing ones
+func (e *Executor) AddInstrumentation(instrumentation Instrumentation) {
+	switch existing := e.instrumentation.(type) {
+	case nil:
+		e.instrumentation = instrumentation
+	case Instrumentations:
+		e.instrumentation = append(existing, instrumentation)
+	default:
+		e.instrumentation = Instrumentations{existing, instrumentation}
+	}
+}
+
+// Instrumentations passes all events to each of its Instrumentation
+type Instrumentations []Instrumentation
+
+func (i Instrumentations) ExecutionStart(ctx Context) {
+	for j := range i {
+		i[j].ExecutionStart(ctx)
+	}
+}
+
+func (i Instrumentations) ExecutionEnd(ctx Context) {
+	for j := range i {
+		i[j].ExecutionEnd(ctx)
+	}
+}
+
+func (i Instrumentations) FetchStart(ctx Context, event FetchEvent) {
+	for j := range i {
+		i[j].FetchStart(ctx, event)
+	}
+}
+
+func (i Instrumentations) FetchEnd(ctx Context, event FetchEndEvent) {
+	for j := range i {
+		i[j].FetchEnd(ctx, event)
+	}
+}
+
+func (e *Executor) executionStart() {
+	if e.instrumentation != nil {
+		e.instrumentation.ExecutionStart(e.context)
+	}
+}
+
+func (e *Executor) executionEnd() {
+	if e.instrumentation != nil {
+		e.instrumentation.ExecutionEnd(e.context)
+	}
+}
+
+// startFetch emits the FetchStart event and returns the func emitting the matching FetchEnd event
+func (s *SingleFetch) startFetch(ctx Context, path string, args ResolvedArgs, batch bool) (end func(bytes int, shared bool, err error)) {
+	if ctx.instrumentation == nil {
+		return func(int, bool, error) {}
+	}
+	event := FetchEvent{
+		Path:       append(responsePath(path), s.BufferName),
+		DataSource: typeName(datasource.Unwrap(s.Source.DataSource)),
+		Upstream:   s.Source.UpstreamName(),
+		ParentType: s.Source.ParentType,
+		ReturnType: s.Source.ReturnType,
+		ArgsSize:   args.size(),
+		Batch:      batch,
+		Start:      time.Now(),
+	}
+	ctx.instrumentation.FetchStart(ctx, event)
+	return func(bytes int, shared bool, err error) {
+		ctx.instrumentation.FetchEnd(ctx, FetchEndEvent{
+			FetchEvent: event,
+			Duration:   time.Since(event.Start),
+			Bytes:      bytes,
+			Err:        err,
+			Shared:     shared,
+		})
+	}
+}
+
+// size returns the size of all keys and values in bytes
+func (r ResolvedArgs) size() (size int) {
+	for i := range r {
+		size += len(r[i].Key) + len(r[i].Value)
+	}
+	return size
+}
@@ -0,0 +1,149 @@
+package execution
+
+import (
+	"bytes"
+	"github.com/cespare/xxhash"
+	"github.com/jensneuse/graphql-go-tools/internal/pkg/unsafebytes"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
+	"github.com/tidwall/gjson"
+	"sort"
+	"strconv"
+)
+
+// ListFilterLastN limits the list to its last items
+type ListFilterLastN struct {
+	// LastN is the maximum number of items, a negative LastN doesn't limit the list
+	LastN int
+	// Variable is the name of the variable passed to the field argument of the filter
+	// If the variable is provided its value replaces LastN
+	Variable []byte
+}
+
+func (_ ListFilterLastN) Kind() ListFilterKind {
+	return ListFilterKindLastN
+}
+
+// ListFilterOffset skips the first items of the list
+type ListFilterOffset struct {
+	// Offset is the number of skipped items
+	Offset int
+	// Variable is the name of the variable passed to the field argument of the filter
+	// If the variable is provided its value replaces Offset
+	Variable []byte
+}
+
+func (_ ListFilterOffset) Kind() ListFilterKind {
+	return ListFilterKindOffset
+}
+
+// ListFilterEquals keeps the items whose value at Path equals Value
+type ListFilterEquals struct {
+	// Path is the path of the compared value within the item, an empty Path compares the item itself
+	Path string
+	// Value is the value to compare with, the filter isn't applied if there's neither a Value nor a provided Variable
+	Value []byte
+	// Variable is the name of the variable passed to the field argument of the filter
+	// If the variable is provided its value replaces Value
+	Variable []byte
+}
+
+func (_ ListFilterEquals) Kind() ListFilterKind {
+	return ListFilterKindEquals
+}
+
+// ListFilterSortBy sorts the items by their value at Path, items with equal values keep their order
+// Values of different JSON types are ordered: null < false < number < string < true < object/array
+type ListFilterSortBy struct {
+	// Path is the path of the value to sort by within the item, an empty Path sorts by the item itself
+	Path       string
+	Descending bool
+}
+
+func (_ ListFilterSortBy) Kind() ListFilterKind {
+	return ListFilterKindSortBy
+}
+
+// ListFilterChain applies multiple filters one after another
+type ListFilterChain struct {
+	Filters []ListFilter
+}
+
+func (_ ListFilterChain) Kind() ListFilterKind {
+	return ListFilterKindChain
+}
+
+// filterListItems applies the filter to the items of a list
+func (e *Executor) filterListItems(filter ListFilter, items []gjson.Result) []gjson.Result {
+	switch filter := filter.(type) {
+	case *ListFilterFirstN:
+		if n, ok := e.listFilterCount(filter.FirstN, filter.Variable); ok && len(items) > n {
+			items = items[:n]
+		}
+	case *ListFilterLastN:
+		if n, ok := e.listFilterCount(filter.LastN, filter.Variable); ok && len(items) > n {
+			items = items[len(items)-n:]
+		}
+	case *ListFilterOffset:
+		if n, ok := e.listFilterCount(filter.Offset, filter.Variable); ok {
+			if n > len(items) {
+				n = len(items)
+			}
+			items = items[n:]
+		}
+	case *ListFilterEquals:
+		value := filter.Value
+		if variable, ok := e.listFilterVariable(filter.Variable); ok {
+			value = variable
+		}
+		if value == nil {
+			return items
+		}
+		filtered := items[:0]
+		for i := range items {
+			if listItemValue(items[i], filter.Path).String() == unsafebytes.BytesToString(value) {
+				filtered = append(filtered, items[i])
+			}
+		}
+		items = filtered
+	case *ListFilterSortBy:
+		sort.SliceStable(items, func(i, j int) bool {
+			left, right := listItemValue(items[i], filter.Path), listItemValue(items[j], filter.Path)
+			if filter.Descending {
+				return right.Less(left, true)
+			}
+			return left.Less(right, true)
+		})
+	case *ListFilterChain:
+		for i := range filter.Filters {
+			items = e.filterListItems(filter.Filters[i], items)
+		}
+	}
+	return items
+}
+
+// listFilterCount returns the value of the variable if it's a valid count, otherwise the configured count
+// ok is false if the filter shouldn't be applied
+func (e *Executor) listFilterCount(count int, variable []byte) (n int, ok bool) {
+	if value, ok := e.listFilterVariable(variable); ok {
+		if n, err := strconv.Atoi(unsafebytes.BytesToString(value)); err == nil && n >= 0 {
+			return n, true
+		}
+	}
+	return count, count >= 0
+}
+
+// listFilterVariable returns the value of the variable, ok is false if the variable isn't provided or null
+func (e *Executor) listFilterVariable(variable []byte) ([]byte, bool) {
+	if variable == nil {
+		return nil, false
+	}
+	value, ok := e.context.Variables[xxhash.Sum64(variable)]
+	return value, ok && !bytes.Equal(value, literal.NULL)
+}
+
+func listItemValue(item gjson.Result, path string) gjson.Result {
+	if path == "" {
+		return item
+	}
+	return item.Get(path)
+}
@@ -0,0 +1,71 @@
+package execution
+
+import (
+	"github.com/jensneuse/graphql-go-tools/pkg/metrics"
+	"time"
+)
+
+// phaseLabels are the values of the phase label of the phases of the Handler
+var phaseLabels = [tracingPhaseCount]string{
+	tracingPhaseParsing:    metrics.PhaseParse,
+	tracingPhaseValidation: metrics.PhaseValidate,
+	tracingPhasePlanning:   metrics.PhasePlan,
+}
+
+// handlerPhases reports the durations of the phases of a single call to Handle to the metrics and the tracing extension
+type handlerPhases struct {
+	metrics       metrics.Metrics
+	tracing       *TracingCollector
+	operationName string
+}
+
+func (h handlerPhases) start() time.Time {
+	return time.Now()
+}
+
+func (h handlerPhases) end(phase tracingPhaseKind, start time.Time) {
+	h.metrics.ObserveHistogram(metrics.PhaseDurationSeconds, time.Since(start).Seconds(),
+		operationNameLabel(h.operationName),
+		metrics.Label{Name: metrics.LabelPhase, Value: phaseLabels[phase]},
+	)
+	h.tracing.endPhase(phase, start)
+}
+
+// metricsInstrumentation reports the duration of executions and the latency and errors of data source calls
+// Data source calls are labelled with their upstream, calls without a configured upstream with the kind of their data source
+type metricsInstrumentation struct {
+	metrics       metrics.Metrics
+	operationName string
+	start         time.Time
+}
+
+func (m *metricsInstrumentation) ExecutionStart(ctx Context) {
+	m.start = time.Now()
+}
+
+func (m *metricsInstrumentation) ExecutionEnd(ctx Context) {
+	m.metrics.ObserveHistogram(metrics.PhaseDurationSeconds, time.Since(m.start).Seconds(),
+		operationNameLabel(m.operationName),
+		metrics.Label{Name: metrics.LabelPhase, Value: metrics.PhaseExecute},
+	)
+}
+
+func (m *metricsInstrumentation) FetchStart(ctx Context, event FetchEvent) {}
+
+func (m *metricsInstrumentation) FetchEnd(ctx Context, event FetchEndEvent) {
+	if event.Shared { // the data source didn't get called
+		return
+	}
+	dataSource := metrics.Label{Name: metrics.LabelDataSource, Value: event.Upstream}
+	if dataSource.Value == "" {
+		dataSource.Value = event.DataSource
+	}
+	m.metrics.ObserveHistogram(metrics.DataSourceDurationSeconds, event.Duration.Seconds(), dataSource)
+	if event.Err != nil {
+		m.metrics.IncCounter(metrics.DataSourceErrorsTotal, dataSource)
+	}
+}
+
+func operationNameLabel(operationName string) metrics.Label {
+	return metrics.Label{Name: metrics.LabelOperationName, Value: operationName}
+}
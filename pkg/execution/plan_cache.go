@@ -0,0 +1,76 @@
+package execution
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultPlanCacheSize is the number of plans a Handler keeps by default
+const DefaultPlanCacheSize = 1024
+
+// planCache is a bounded LRU cache of query plans
+// It's safe to be used from multiple goroutines
+type planCache struct {
+	mux     sync.Mutex
+	size    int
+	entries map[uint64]*list.Element
+	order   *list.List
+}
+
+type planCacheEntry struct {
+	key  uint64
+	plan cachedPlan
+}
+
+// cachedPlan is the plan of an operation together with the variable definitions of the operation
+type cachedPlan struct {
+	plan                RootNode
+	variableDefinitions []variableDefinition
+}
+
+func newPlanCache(size int) *planCache {
+	return &planCache{
+		size:    size,
+		entries: make(map[uint64]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+func (c *planCache) get(key uint64) (cachedPlan, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return cachedPlan{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*planCacheEntry).plan, true
+}
+
+func (c *planCache) add(key uint64, plan cachedPlan) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*planCacheEntry).plan = plan
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&planCacheEntry{
+		key:  key,
+		plan: plan,
+	})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*planCacheEntry).key)
+	}
+}
+
+func (c *planCache) len() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.order.Len()
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/jensneuse/pipeline/pkg/pipe"
 	"io"
 	"os"
+	"time"
 )
 
 type Planner struct {
@@ -30,11 +31,13 @@ type DataSourceDefinition struct {
 func NewPlanner(base *datasource.BasePlanner) *Planner {
 	walker := astvisitor.NewWalker(48)
 	visitor := planningVisitor{
-		Walker: &walker,
-		base:   base,
+		Walker:  &walker,
+		base:    base,
+		scalars: Scalars{},
 	}
 
 	walker.RegisterEnterDocumentVisitor(&visitor)
+	walker.RegisterEnterOperationVisitor(&visitor)
 	walker.RegisterEnterFieldVisitor(&visitor)
 	walker.RegisterLeaveFieldVisitor(&visitor)
 	walker.RegisterEnterSelectionSetVisitor(&visitor)
@@ -48,7 +51,15 @@ func NewPlanner(base *datasource.BasePlanner) *Planner {
 	}
 }
 
-func (p *Planner) Plan(operation, definition *ast.Document, report *operationreport.Report) RootNode {
+// RegisterScalar registers a custom scalar, Values of the scalar get serialized using the Serialize func of the scalar
+func (p *Planner) RegisterScalar(scalar Scalar) {
+	p.visitor.scalars.Register(scalar)
+}
+
+// Plan plans the operation with the name operationName
+// operationName might be empty if the document contains exactly one operation
+func (p *Planner) Plan(operation, definition *ast.Document, operationName string, report *operationreport.Report) RootNode {
+	p.visitor.operationName = operationName
 	p.walker.Walk(operation, definition, report)
 	return p.visitor.rootNode
 }
@@ -60,19 +71,42 @@ type planningVisitor struct {
 	rootNode              RootNode
 	currentNode           []Node
 	planners              []dataSourcePlannerRef
+	operationName         string
+	operationRef          int
+	scalars               Scalars
+	// cache stores the results of fields with a @cacheControl directive, nil disables caching
+	cache datasource.Cache
+	// deferredFragments reports for each entered inline fragment if it got planned as a DeferredFragment
+	deferredFragments []bool
 }
 
 type dataSourcePlannerRef struct {
 	path     ast.Path
 	fieldRef int
 	planner  datasource.Planner
+	batch    bool
+	// cacheMaxAge is the max age of cached results of the planned DataSource, results don't get cached if it's 0
+	cacheMaxAge time.Duration
+	// cacheIdentity distinguishes the cached results of different fields
+	cacheIdentity string
+	// upstream is the Upstream of the field, fields of the same Upstream get resolved by the planned DataSource
+	upstream string
+	// parentType and returnType are the name of the enclosing type and the printed type of the field, e.g. "Query" and "[Droid!]"
+	parentType, returnType string
 }
 
 func (p *planningVisitor) EnterDocument(operation, definition *ast.Document) {
 	p.operation, p.definition, p.base.Definition = operation, definition, definition
+	p.rootNode = nil
+	var err *operationreport.ExternalError
+	p.operationRef, err = operationDefinitionRef(operation, p.operationName)
+	if err != nil {
+		p.StopWithExternalErr(*err)
+		return
+	}
 	obj := &Object{}
 	p.rootNode = &Object{
-		operationType: operation.OperationDefinitions[0].OperationType,
+		operationType: operation.OperationDefinitions[p.operationRef].OperationType,
 		Fields: []Field{
 			{
 				Name:  literal.DATA,
@@ -82,18 +116,160 @@ func (p *planningVisitor) EnterDocument(operation, definition *ast.Document) {
 	}
 	p.currentNode = p.currentNode[:0]
 	p.currentNode = append(p.currentNode, obj)
+	p.deferredFragments = p.deferredFragments[:0]
+}
+
+func (p *planningVisitor) EnterOperationDefinition(ref int) {
+	if ref != p.operationRef {
+		p.SkipNode() // only the selected operation gets planned
+	}
+}
+
+// operationDefinitionRef returns the ref of the operation definition with the name operationName
+// If operationName is empty the document must contain exactly one operation
+func operationDefinitionRef(operation *ast.Document, operationName string) (int, *operationreport.ExternalError) {
+	ref := -1
+	for i := range operation.RootNodes {
+		if operation.RootNodes[i].Kind != ast.NodeKindOperationDefinition {
+			continue
+		}
+		if operationName == "" {
+			if ref != -1 {
+				err := operationreport.ErrOperationNameRequired()
+				return -1, &err
+			}
+			ref = operation.RootNodes[i].Ref
+			continue
+		}
+		if operation.OperationDefinitionNameString(operation.RootNodes[i].Ref) == operationName {
+			return operation.RootNodes[i].Ref, nil
+		}
+	}
+	if ref == -1 {
+		err := operationreport.ErrOperationWithNameNotFound([]byte(operationName))
+		return -1, &err
+	}
+	return ref, nil
 }
 
 func (p *planningVisitor) EnterInlineFragment(ref int) {
 	if len(p.planners) != 0 {
 		p.planners[len(p.planners)-1].planner.EnterInlineFragment(ref)
 	}
+	fragment := p.deferredFragment(ref)
+	p.deferredFragments = append(p.deferredFragments, fragment != nil)
+	if fragment == nil {
+		return
+	}
+	parent := p.currentNode[len(p.currentNode)-1].(*Object)
+	parent.Deferred = append(parent.Deferred, fragment)
+	p.currentNode = append(p.currentNode, fragment.Object)
+	p.rootNode.(*Object).incremental = true
 }
 
 func (p *planningVisitor) LeaveInlineFragment(ref int) {
 	if len(p.planners) != 0 {
 		p.planners[len(p.planners)-1].planner.LeaveInlineFragment(ref)
 	}
+	if p.deferredFragments[len(p.deferredFragments)-1] {
+		p.currentNode = p.currentNode[:len(p.currentNode)-1]
+	}
+	p.deferredFragments = p.deferredFragments[:len(p.deferredFragments)-1]
+}
+
+// deferredFragment returns the DeferredFragment for an inline fragment annotated with @defer
+// It returns nil if the fragment isn't annotated or the "if" argument is false
+func (p *planningVisitor) deferredFragment(ref int) *DeferredFragment {
+	if _, ok := p.currentNode[len(p.currentNode)-1].(*Object); !ok {
+		return nil
+	}
+	directive, ok := p.directiveByName(p.operation.InlineFragments[ref].Directives.Refs, literal.DEFER)
+	if !ok {
+		return nil
+	}
+	disabled, enabled := p.directiveDisabledCondition(directive)
+	if !enabled {
+		return nil
+	}
+	return &DeferredFragment{
+		Label:    p.directiveLabel(directive),
+		Disabled: disabled,
+		Object:   &Object{},
+	}
+}
+
+// fieldStream returns the Stream for a list field annotated with @stream
+// It returns nil if the field isn't annotated or the "if" argument is false
+func (p *planningVisitor) fieldStream(ref int) *Stream {
+	directive, ok := p.directiveByName(p.operation.FieldDirectives(ref), literal.STREAM)
+	if !ok {
+		return nil
+	}
+	disabled, enabled := p.directiveDisabledCondition(directive)
+	if !enabled {
+		return nil
+	}
+	stream := &Stream{
+		Label:    p.directiveLabel(directive),
+		Disabled: disabled,
+	}
+	value, ok := p.operation.DirectiveArgumentValueByName(directive, literal.INITIAL_COUNT)
+	if ok && value.Kind == ast.ValueKindInteger {
+		stream.InitialCount = int(p.operation.IntValueAsInt(value.Ref))
+	}
+	return stream
+}
+
+// directiveDisabledCondition evaluates the "if" argument of @defer and @stream
+// A variable argument results in a condition which disables the directive at runtime if the variable is false,
+// the argument defaults to true so an omitted variable keeps the directive enabled,
+// a literal false argument disables the directive entirely
+func (p *planningVisitor) directiveDisabledCondition(directive int) (disabled BooleanCondition, enabled bool) {
+	value, ok := p.operation.DirectiveArgumentValueByName(directive, literal.IF)
+	if !ok {
+		return nil, true
+	}
+	switch value.Kind {
+	case ast.ValueKindBoolean:
+		return nil, bool(p.operation.BooleanValue(value.Ref))
+	case ast.ValueKindVariable:
+		return &IfEqual{
+			Left: &datasource.ContextVariableArgument{
+				VariableName: p.operation.VariableValueNameBytes(value.Ref),
+			},
+			Right: &datasource.StaticVariableArgument{
+				Value: literal.FALSE,
+			},
+		}, true
+	default:
+		return nil, true
+	}
+}
+
+func (p *planningVisitor) directiveByName(directives []int, name []byte) (int, bool) {
+	for _, directive := range directives {
+		if bytes.Equal(p.operation.DirectiveNameBytes(directive), name) {
+			return directive, true
+		}
+	}
+	return -1, false
+}
+
+// printType prints the type of the definition, e.g. "[Droid!]"
+func (p *planningVisitor) printType(ref int) string {
+	printed, err := p.definition.PrintTypeBytes(ref, nil)
+	if err != nil {
+		return ""
+	}
+	return string(printed)
+}
+
+func (p *planningVisitor) directiveLabel(directive int) string {
+	value, ok := p.operation.DirectiveArgumentValueByName(directive, literal.LABEL)
+	if !ok || value.Kind != ast.ValueKindString {
+		return ""
+	}
+	return p.operation.StringValueContentString(value.Ref)
 }
 
 func (p *planningVisitor) EnterField(ref int) {
@@ -107,13 +283,30 @@ func (p *planningVisitor) EnterField(ref int) {
 	fieldName := p.operation.FieldNameString(ref)
 
 	plannerFactory := p.base.Config.DataSourcePlannerFactoryForTypeField(typeName, fieldName)
+	upstream := p.base.Config.UpstreamForTypeField(typeName, fieldName)
+	if plannerFactory != nil && p.resolvedByEnclosingPlanner(upstream) {
+		plannerFactory = nil
+	}
+	if plannerFactory == nil && !p.definedByEnclosingUpstream(typeName, fieldName) {
+		p.StopWithExternalErr(operationreport.ErrFieldUndefinedByUpstream([]byte(fieldName), []byte(typeName), []byte(p.planners[len(p.planners)-1].upstream)))
+		return
+	}
 	if plannerFactory != nil {
+		if !p.addRequiredFields(typeName, fieldName) {
+			return
+		}
 		planner := plannerFactory.DataSourcePlanner()
 		planner.Configure(p.operation,p.definition,p.Walker)
 		p.planners = append(p.planners, dataSourcePlannerRef{
-			path:     p.Path,
-			fieldRef: ref,
-			planner:  planner,
+			path:          p.Path,
+			fieldRef:      ref,
+			planner:       planner,
+			batch:         p.base.Config.BatchForTypeField(typeName, fieldName),
+			cacheMaxAge:   p.fieldCacheMaxAge(definition),
+			cacheIdentity: typeName + "." + fieldName,
+			upstream:      upstream,
+			parentType:    p.definition.NodeNameString(p.EnclosingTypeDefinition),
+			returnType:    p.printType(p.definition.FieldDefinitionType(definition)),
 		})
 	}
 
@@ -124,55 +317,46 @@ func (p *planningVisitor) EnterField(ref int) {
 	switch parent := p.currentNode[len(p.currentNode)-1].(type) {
 	case *Object:
 
-		var skipCondition BooleanCondition
-		ancestor := p.Ancestors[len(p.Ancestors)-2]
-		if ancestor.Kind == ast.NodeKindInlineFragment {
-			typeConditionName := p.operation.InlineFragmentTypeConditionName(ancestor.Ref)
-			skipCondition = &IfNotEqual{
-				Left: &datasource.ObjectVariableArgument{
-					PathSelector: datasource.PathSelector{
-						Path: "__typename",
-					},
-				},
-				Right: &datasource.StaticVariableArgument{
-					Value: typeConditionName,
-				},
-			}
-		}
-
+		skipCondition := p.fieldSkipCondition(ref)
 		dataResolvingConfig := p.fieldDataResolvingConfig(ref)
 
 		var value Node
 		fieldDefinitionType := p.definition.FieldDefinitionType(definition)
 		if p.definition.TypeIsList(fieldDefinitionType) {
 
+			itemIsNonNull := p.definition.TypeIsNonNull(p.listItemType(fieldDefinitionType))
 			if !p.operation.FieldHasSelections(ref) {
 				value = &Value{
 					ValueType: p.jsonValueType(fieldDefinitionType),
+					NonNull:   itemIsNonNull,
+					Scalar:    p.scalars[string(p.definition.ResolveTypeName(fieldDefinitionType))],
+					Enum:      p.enum(definition, fieldDefinitionType),
 				}
 			} else {
-				value = &Object{}
+				value = &Object{
+					NonNull:      itemIsNonNull,
+					TypeResolver: p.typeResolver(fieldDefinitionType),
+				}
 			}
 
 			list := &List{
 				DataResolvingConfig: dataResolvingConfig,
 				Value:               value,
+				NonNull:             p.definition.TypeIsNonNull(fieldDefinitionType),
 			}
 
-			firstNValue, ok := p.FieldDefinitionDirectiveArgumentValueByName(ref, []byte("ListFilterFirstN"), []byte("n"))
-			if ok {
-				if firstNValue.Kind == ast.ValueKindInteger {
-					firstN := p.definition.IntValueAsInt(firstNValue.Ref)
-					list.Filter = &ListFilterFirstN{
-						FirstN: int(firstN),
-					}
-				}
+			list.Stream = p.fieldStream(ref)
+			if list.Stream != nil {
+				p.rootNode.(*Object).incremental = true
 			}
 
+			list.Filter = p.listFilter(ref, definition)
+
 			parent.Fields = append(parent.Fields, Field{
-				Name:  p.operation.FieldNameBytes(ref),
-				Value: list,
-				Skip:  skipCondition,
+				Name:     p.operation.FieldNameBytes(ref),
+				Value:    list,
+				Skip:     skipCondition,
+				Position: p.fieldPosition(ref),
 			})
 
 			p.currentNode = append(p.currentNode, value)
@@ -183,27 +367,78 @@ func (p *planningVisitor) EnterField(ref int) {
 			value = &Value{
 				DataResolvingConfig: dataResolvingConfig,
 				ValueType:           p.jsonValueType(fieldDefinitionType),
+				NonNull:             p.definition.TypeIsNonNull(fieldDefinitionType),
+				Scalar:              p.scalars[string(p.definition.ResolveTypeName(fieldDefinitionType))],
+				Enum:                p.enum(definition, fieldDefinitionType),
 			}
 		} else {
 			value = &Object{
 				DataResolvingConfig: dataResolvingConfig,
+				NonNull:             p.definition.TypeIsNonNull(fieldDefinitionType),
+				TypeResolver:        p.typeResolver(fieldDefinitionType),
 			}
 		}
 
 		parent.Fields = append(parent.Fields, Field{
-			Name:  p.operation.FieldObjectNameBytes(ref),
-			Value: value,
-			Skip:  skipCondition,
+			Name:     p.operation.FieldObjectNameBytes(ref),
+			Value:    value,
+			Skip:     skipCondition,
+			Position: p.fieldPosition(ref),
 		})
 
 		p.currentNode = append(p.currentNode, value)
 	}
 }
 
+// resolvedByEnclosingPlanner reports if the field gets resolved by the DataSource of the enclosing field as both share the upstream
+func (p *planningVisitor) resolvedByEnclosingPlanner(upstream string) bool {
+	return upstream != "" && len(p.planners) != 0 && p.planners[len(p.planners)-1].upstream == upstream
+}
+
+// definedByEnclosingUpstream reports if the field can be resolved by the DataSource of the enclosing field
+// Fields configured with Upstreams are only defined by these, all other fields by every upstream
+func (p *planningVisitor) definedByEnclosingUpstream(typeName, fieldName string) bool {
+	if len(p.planners) == 0 || p.planners[len(p.planners)-1].upstream == "" {
+		return true
+	}
+	upstreams := p.base.Config.UpstreamsForTypeField(typeName, fieldName)
+	if len(upstreams) == 0 {
+		return true
+	}
+	for i := range upstreams {
+		if upstreams[i] == p.planners[len(p.planners)-1].upstream {
+			return true
+		}
+	}
+	return false
+}
+
+// addRequiredFields makes the DataSource of the enclosing field resolve the RequiredFields of the field
+// Required fields resolved by another Upstream than the one of the enclosing field can't be added and fail the planning
+func (p *planningVisitor) addRequiredFields(typeName, fieldName string) bool {
+	fieldNames := p.base.Config.RequiredFieldsForTypeField(typeName, fieldName)
+	if len(fieldNames) == 0 || len(p.planners) == 0 {
+		return true
+	}
+	enclosing := p.planners[len(p.planners)-1]
+	for _, required := range fieldNames {
+		if upstream := p.base.Config.UpstreamForTypeField(typeName, required); upstream != "" && upstream != enclosing.upstream {
+			p.StopWithExternalErr(operationreport.ErrRequiredFieldUnresolvable([]byte(required), []byte(typeName), []byte(fieldName)))
+			return false
+		}
+	}
+	if planner, ok := enclosing.planner.(datasource.RequiredFieldsPlanner); ok {
+		planner.AddRequiredFields(fieldNames)
+	}
+	return true
+}
+
 func (p *planningVisitor) LeaveField(ref int) {
 
 	var plannedDataSource datasource.DataSource
 	var plannedArgs []datasource.Argument
+	var plannedUpstream string
+	var plannedParentType, plannedReturnType string
 
 	if len(p.planners) != 0 {
 
@@ -211,6 +446,13 @@ func (p *planningVisitor) LeaveField(ref int) {
 
 		if p.planners[len(p.planners)-1].path.Equals(p.Path) && p.planners[len(p.planners)-1].fieldRef == ref {
 			plannedDataSource, plannedArgs = p.planners[len(p.planners)-1].planner.Plan(p.fieldContextVariableArguments(ref))
+			plannedUpstream = p.planners[len(p.planners)-1].upstream
+			plannedParentType, plannedReturnType = p.planners[len(p.planners)-1].parentType, p.planners[len(p.planners)-1].returnType
+			if maxAge := p.planners[len(p.planners)-1].cacheMaxAge; maxAge > 0 {
+				plannedDataSource = datasource.NewCachingDataSource(plannedDataSource, p.cache, maxAge, p.planners[len(p.planners)-1].cacheIdentity)
+			}
+			_, isBatchDataSource := plannedDataSource.(datasource.BatchDataSource)
+			batch := p.planners[len(p.planners)-1].batch && isBatchDataSource
 			p.planners = p.planners[:len(p.planners)-1]
 
 			if len(p.currentNode) >= 2 {
@@ -226,8 +468,13 @@ func (p *planningVisitor) LeaveField(ref int) {
 								Source: &DataSourceInvocation{
 									Args:       plannedArgs,
 									DataSource: plannedDataSource,
+									Upstream:   plannedUpstream,
+									ParentType: plannedParentType,
+									ReturnType: plannedReturnType,
 								},
 								BufferName: pathName,
+								Batch:      batch,
+								Skip:       parent.Fields[i].Skip,
 							}
 
 							if parent.Fetch == nil {
@@ -240,6 +487,15 @@ func (p *planningVisitor) LeaveField(ref int) {
 									fetch.Fetches = append(fetch.Fetches, singleFetch)
 								case *SingleFetch:
 									first := *fetch
+									if p.isMutationRootObject() {
+										parent.Fetch = &SerialFetch{
+											Fetches: []Fetch{
+												&first,
+												singleFetch,
+											},
+										}
+										break
+									}
 									parent.Fetch = &ParallelFetch{
 										Fetches: []Fetch{
 											&first,
@@ -258,6 +514,91 @@ func (p *planningVisitor) LeaveField(ref int) {
 	p.currentNode = p.currentNode[:len(p.currentNode)-1]
 }
 
+// fieldSkipCondition returns the condition to skip the field at runtime, nil if the field must always be resolved
+// A field gets skipped if it's selected by an inline fragment on a different type
+// or if a @skip/@include directive with a variable argument on the field or an enclosing inline fragment says so
+func (p *planningVisitor) fieldSkipCondition(ref int) BooleanCondition {
+	var conditions []BooleanCondition
+	ancestor := p.Ancestors[len(p.Ancestors)-2]
+	if ancestor.Kind == ast.NodeKindInlineFragment && p.operation.InlineFragmentHasTypeCondition(ancestor.Ref) {
+		typeConditionName := p.operation.InlineFragmentTypeConditionName(ancestor.Ref)
+		conditions = append(conditions, &IfNotEqual{
+			Left: &datasource.ObjectVariableArgument{
+				PathSelector: datasource.PathSelector{
+					Path: "__typename",
+				},
+			},
+			Right: &datasource.StaticVariableArgument{
+				Value: typeConditionName,
+			},
+		})
+	}
+	conditions = p.appendDirectiveSkipConditions(conditions, p.operation.FieldDirectives(ref))
+	for i := len(p.Ancestors) - 1; i >= 0; i-- {
+		if p.Ancestors[i].Kind == ast.NodeKindField || p.Ancestors[i].Kind == ast.NodeKindOperationDefinition {
+			break
+		}
+		if p.Ancestors[i].Kind == ast.NodeKindInlineFragment {
+			conditions = p.appendDirectiveSkipConditions(conditions, p.operation.InlineFragments[p.Ancestors[i].Ref].Directives.Refs)
+		}
+	}
+	switch len(conditions) {
+	case 0:
+		return nil
+	case 1:
+		return conditions[0]
+	default:
+		return &IfAny{
+			Conditions: conditions,
+		}
+	}
+}
+
+// appendDirectiveSkipConditions appends the skip conditions of all @skip/@include directives with a variable argument
+// Directives with a literal argument got already applied during normalization
+func (p *planningVisitor) appendDirectiveSkipConditions(conditions []BooleanCondition, directives []int) []BooleanCondition {
+	for _, directive := range directives {
+		value, ok := p.operation.DirectiveArgumentValueByName(directive, literal.IF)
+		if !ok || value.Kind != ast.ValueKindVariable {
+			continue
+		}
+		variable := &datasource.ContextVariableArgument{
+			VariableName: p.operation.VariableValueNameBytes(value.Ref),
+		}
+		isTrue := &datasource.StaticVariableArgument{
+			Value: literal.TRUE,
+		}
+		switch name := p.operation.DirectiveNameBytes(directive); {
+		case bytes.Equal(name, literal.SKIP):
+			conditions = append(conditions, &IfEqual{
+				Left:  variable,
+				Right: isTrue,
+			})
+		case bytes.Equal(name, literal.INCLUDE):
+			conditions = append(conditions, &IfNotEqual{
+				Left:  variable,
+				Right: isTrue,
+			})
+		}
+	}
+	return conditions
+}
+
+// isMutationRootObject reports if the parent of the current field is the root object of a mutation
+// The spec requires the root fields of a mutation to be executed serially, the SerialFetch makes the Executor
+// resolve each root field including its nested fields before the next root field gets fetched
+func (p *planningVisitor) isMutationRootObject() bool {
+	return len(p.currentNode) == 2 && p.operation.OperationDefinitions[p.operationRef].OperationType == ast.OperationTypeMutation
+}
+
+func (p *planningVisitor) fieldPosition(ref int) Position {
+	position := p.operation.Fields[ref].Position
+	return Position{
+		Line:   position.LineStart,
+		Column: position.CharStart,
+	}
+}
+
 func (p *planningVisitor) fieldContextVariableArguments(ref int) []datasource.Argument {
 	// args
 	if p.operation.FieldHasArguments(ref) {
@@ -295,6 +636,205 @@ func (p *planningVisitor) LeaveSelectionSet(ref int) {
 	}
 }
 
+// listItemType returns the type of the items of a (non-null) list type
+func (p *planningVisitor) listItemType(listType int) int {
+	if p.definition.TypeIsNonNull(listType) {
+		listType = p.definition.Types[listType].OfType
+	}
+	return p.definition.Types[listType].OfType
+}
+
+// listFilter returns the filters of the ListFilter directives of the field definition in the order of the directives
+// Multiple filters get combined into a ListFilterChain
+func (p *planningVisitor) listFilter(ref, definition int) ListFilter {
+	var filters []ListFilter
+	for _, directive := range p.definition.FieldDefinitionDirectives(definition) {
+		var filter ListFilter
+		switch p.definition.DirectiveNameString(directive) {
+		case "ListFilterFirstN":
+			if n, variable, ok := p.listFilterCount(ref, directive); ok {
+				filter = &ListFilterFirstN{
+					FirstN:   n,
+					Variable: variable,
+				}
+			}
+		case "ListFilterLastN":
+			if n, variable, ok := p.listFilterCount(ref, directive); ok {
+				filter = &ListFilterLastN{
+					LastN:    n,
+					Variable: variable,
+				}
+			}
+		case "ListFilterOffset":
+			if n, variable, ok := p.listFilterCount(ref, directive); ok {
+				filter = &ListFilterOffset{
+					Offset:   n,
+					Variable: variable,
+				}
+			}
+		case "ListFilterEquals":
+			filter = p.listFilterEquals(ref, directive)
+		case "ListFilterSortBy":
+			sortBy := &ListFilterSortBy{}
+			if path, ok := p.definition.DirectiveArgumentValueByName(directive, []byte("path")); ok && path.Kind == ast.ValueKindString {
+				sortBy.Path = p.definition.StringValueContentString(path.Ref)
+			}
+			if descending, ok := p.definition.DirectiveArgumentValueByName(directive, []byte("descending")); ok && descending.Kind == ast.ValueKindBoolean {
+				sortBy.Descending = bool(p.definition.BooleanValue(descending.Ref))
+			}
+			filter = sortBy
+		}
+		if filter != nil {
+			filters = append(filters, filter)
+		}
+	}
+	switch len(filters) {
+	case 0:
+		return nil
+	case 1:
+		return filters[0]
+	default:
+		return &ListFilterChain{
+			Filters: filters,
+		}
+	}
+}
+
+// listFilterCount returns the count of a ListFilter directive from its "n" argument
+// or from the field argument named by its "argument" argument, n is negative if there's no count
+// ok is false if neither a count nor a variable is configured
+func (p *planningVisitor) listFilterCount(ref, directive int) (n int, variable []byte, ok bool) {
+	n = -1
+	if value, ok := p.definition.DirectiveArgumentValueByName(directive, []byte("n")); ok && value.Kind == ast.ValueKindInteger {
+		n = int(p.definition.IntValueAsInt(value.Ref))
+	}
+	if value, ok := p.listFilterArgumentValue(ref, directive); ok {
+		switch value.Kind {
+		case ast.ValueKindInteger:
+			n = int(p.operation.IntValueAsInt(value.Ref))
+		case ast.ValueKindVariable:
+			variable = p.operation.VariableValueNameBytes(value.Ref)
+		}
+	}
+	return n, variable, n >= 0 || variable != nil
+}
+
+func (p *planningVisitor) listFilterEquals(ref, directive int) ListFilter {
+	equals := &ListFilterEquals{}
+	if path, ok := p.definition.DirectiveArgumentValueByName(directive, []byte("path")); ok && path.Kind == ast.ValueKindString {
+		equals.Path = p.definition.StringValueContentString(path.Ref)
+	}
+	if value, ok := p.definition.DirectiveArgumentValueByName(directive, literal.VALUE); ok && value.Kind == ast.ValueKindString {
+		equals.Value = p.definition.StringValueContentBytes(value.Ref)
+	}
+	if value, ok := p.listFilterArgumentValue(ref, directive); ok {
+		switch value.Kind {
+		case ast.ValueKindVariable:
+			equals.Variable = p.operation.VariableValueNameBytes(value.Ref)
+		case ast.ValueKindBoolean:
+			if p.operation.BooleanValue(value.Ref) {
+				equals.Value = literal.TRUE
+			} else {
+				equals.Value = literal.FALSE
+			}
+		case ast.ValueKindString, ast.ValueKindInteger, ast.ValueKindFloat, ast.ValueKindEnum:
+			equals.Value = p.operation.ValueContentBytes(value)
+		}
+	}
+	if equals.Value == nil && equals.Variable == nil {
+		return nil
+	}
+	return equals
+}
+
+// listFilterArgumentValue returns the value of the field argument named by the "argument" argument of a ListFilter directive
+func (p *planningVisitor) listFilterArgumentValue(ref, directive int) (ast.Value, bool) {
+	name, ok := p.definition.DirectiveArgumentValueByName(directive, []byte("argument"))
+	if !ok || name.Kind != ast.ValueKindString {
+		return ast.Value{}, false
+	}
+	argument, ok := p.operation.FieldArgument(ref, p.definition.StringValueContentBytes(name.Ref))
+	if !ok {
+		return ast.Value{}, false
+	}
+	return p.operation.ArgumentValue(argument), true
+}
+
+// fieldCacheMaxAge returns the max age of the @cacheControl directive of the field definition
+// Only fields of queries get cached as mutations have side effects and subscriptions need fresh data
+func (p *planningVisitor) fieldCacheMaxAge(definition int) time.Duration {
+	if p.cache == nil || p.operation.OperationDefinitions[p.operationRef].OperationType != ast.OperationTypeQuery {
+		return 0
+	}
+	directive, ok := p.definition.FieldDefinitionDirectiveByName(definition, literal.CACHE_CONTROL)
+	if !ok {
+		return 0
+	}
+	maxAge, ok := p.definition.DirectiveArgumentValueByName(directive, literal.MAX_AGE)
+	if !ok || maxAge.Kind != ast.ValueKindInteger {
+		return 0
+	}
+	return time.Duration(p.definition.IntValueAsInt(maxAge.Ref)) * time.Second
+}
+
+// typeResolver returns the configured TypeResolver if the type is an interface or union
+func (p *planningVisitor) typeResolver(fieldType int) datasource.TypeResolver {
+	typeName := p.definition.ResolveTypeName(fieldType)
+	node, ok := p.definition.NodeByName(typeName)
+	if !ok || (node.Kind != ast.NodeKindInterfaceTypeDefinition && node.Kind != ast.NodeKindUnionTypeDefinition) {
+		return nil
+	}
+	return p.base.Config.TypeResolverForType(unsafebytes.BytesToString(typeName))
+}
+
+// enum returns the Enum of the field type including the mappings of the @enumMapping directive, nil if the type is no enum
+func (p *planningVisitor) enum(fieldDefinition, fieldType int) *Enum {
+	typeName := p.definition.ResolveTypeName(fieldType)
+	node, ok := p.definition.NodeByName(typeName)
+	if !ok || node.Kind != ast.NodeKindEnumTypeDefinition {
+		return nil
+	}
+	enumValueRefs := p.definition.EnumTypeDefinitions[node.Ref].EnumValuesDefinition.Refs
+	enum := &Enum{
+		Name:   string(typeName),
+		Values: make([][]byte, 0, len(enumValueRefs)),
+	}
+	for _, ref := range enumValueRefs {
+		enum.Values = append(enum.Values, p.definition.EnumValueDefinitionNameBytes(ref))
+	}
+	directive, ok := p.definition.FieldDefinitionDirectiveByName(fieldDefinition, literal.ENUM_MAPPING)
+	if !ok {
+		return enum
+	}
+	values, ok := p.definition.DirectiveArgumentValueByName(directive, literal.VALUES)
+	if !ok || values.Kind != ast.ValueKindList {
+		return enum
+	}
+	for _, ref := range p.definition.ListValues[values.Ref].Refs {
+		value := p.definition.Values[ref]
+		if value.Kind != ast.ValueKindObject {
+			continue
+		}
+		var from, to ast.Value
+		for _, field := range p.definition.ObjectValues[value.Ref].Refs {
+			switch {
+			case bytes.Equal(p.definition.ObjectFieldNameBytes(field), literal.FROM):
+				from = p.definition.ObjectFieldValue(field)
+			case bytes.Equal(p.definition.ObjectFieldNameBytes(field), literal.TO):
+				to = p.definition.ObjectFieldValue(field)
+			}
+		}
+		if from.Kind != ast.ValueKindString || to.Kind != ast.ValueKindString {
+			continue
+		}
+		if enum.Mapping == nil {
+			enum.Mapping = map[string]string{}
+		}
+		enum.Mapping[p.definition.StringValueContentString(from.Ref)] = p.definition.StringValueContentString(to.Ref)
+	}
+	return enum
+}
+
 func (p *planningVisitor) jsonValueType(valueType int) JSONValueType {
 	typeName := p.definition.ResolveTypeName(valueType)
 	switch {
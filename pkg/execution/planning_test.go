@@ -1,6 +1,7 @@
 package execution
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/davecgh/go-spew/spew"
@@ -69,7 +70,7 @@ func run(definition string, operation string, configureBase func(base *datasourc
 		configureBase(base)
 
 		planner := NewPlanner(base)
-		got := planner.Plan(&op, &def, &report)
+		got := planner.Plan(&op, &def, "", &report)
 		if report.HasErrors() {
 			t.Error(report)
 		}
@@ -133,7 +134,7 @@ func TestPlanner_Plan(t *testing.T) {
 									},
 									&datasource.StaticVariableArgument{
 										Name:  []byte("query"),
-										Value: []byte("query o($code: String!){country(code: $code){code name native}}"),
+										Value: []byte("query GraphQLQuery($code: String!){country(code: $code){code name native}}"),
 									},
 									&datasource.StaticVariableArgument{
 										Name:  []byte("method"),
@@ -147,6 +148,8 @@ func TestPlanner_Plan(t *testing.T) {
 								DataSource: &datasource.GraphQLDataSource{
 									Log: log.NoopLogger,
 								},
+								ParentType: "Query",
+								ReturnType: "Country",
 							},
 							BufferName: "country",
 						},
@@ -171,6 +174,10 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   4,
+												Column: 7,
+											},
 										},
 										{
 											Name: []byte("name"),
@@ -182,6 +189,10 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   5,
+												Column: 7,
+											},
 										},
 										{
 											Name: []byte("aliased"),
@@ -193,9 +204,17 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   6,
+												Column: 7,
+											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 6,
+								},
 							},
 						},
 					},
@@ -250,7 +269,7 @@ func TestPlanner_Plan(t *testing.T) {
 									},
 									&datasource.StaticVariableArgument{
 										Name:  []byte("query"),
-										Value: []byte("mutation o($id: ID!){likePost(id: $id){id likes}}"),
+										Value: []byte("mutation LikePost($id: ID!){likePost(id: $id){id likes}}"),
 									},
 									&datasource.StaticVariableArgument{
 										Name:  []byte("method"),
@@ -264,6 +283,8 @@ func TestPlanner_Plan(t *testing.T) {
 								DataSource: &datasource.GraphQLDataSource{
 									Log: log.NoopLogger,
 								},
+								ParentType: "Mutation",
+								ReturnType: "Post",
 							},
 							BufferName: "likePost",
 						},
@@ -287,6 +308,11 @@ func TestPlanner_Plan(t *testing.T) {
 													},
 												},
 												ValueType: StringValueType,
+												NonNull:   true,
+											},
+											Position: Position{
+												Line:   4,
+												Column: 7,
 											},
 										},
 										{
@@ -298,10 +324,19 @@ func TestPlanner_Plan(t *testing.T) {
 													},
 												},
 												ValueType: IntegerValueType,
+												NonNull:   true,
+											},
+											Position: Position{
+												Line:   5,
+												Column: 7,
 											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 6,
+								},
 							},
 						},
 					},
@@ -426,6 +461,8 @@ func TestPlanner_Plan(t *testing.T) {
 												Value: []byte(`{"defaultTypeName":"HttpBinGet"}`),
 											},
 										},
+										ParentType: "Query",
+										ReturnType: "HttpBinGet",
 									},
 									BufferName: "httpBinGet",
 								},
@@ -456,6 +493,8 @@ func TestPlanner_Plan(t *testing.T) {
 										DataSource: &datasource.HttpJsonDataSource{
 											Log: log.NoopLogger,
 										},
+										ParentType: "Query",
+										ReturnType: "JSONPlaceholderPost",
 									},
 									BufferName: "post",
 								},
@@ -485,6 +524,11 @@ func TestPlanner_Plan(t *testing.T) {
 																},
 															},
 															ValueType: StringValueType,
+															NonNull:   true,
+														},
+														Position: Position{
+															Line:   5,
+															Column: 9,
 														},
 													},
 													{
@@ -496,6 +540,11 @@ func TestPlanner_Plan(t *testing.T) {
 																},
 															},
 															ValueType: StringValueType,
+															NonNull:   true,
+														},
+														Position: Position{
+															Line:   6,
+															Column: 9,
 														},
 													},
 													{
@@ -508,12 +557,25 @@ func TestPlanner_Plan(t *testing.T) {
 															},
 															ValueType: StringValueType,
 														},
+														Position: Position{
+															Line:   7,
+															Column: 9,
+														},
 													},
 												},
+												NonNull: true,
+											},
+											Position: Position{
+												Line:   4,
+												Column: 8,
 											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 7,
+								},
 							},
 							{
 								Name:            []byte("post"),
@@ -542,6 +604,8 @@ func TestPlanner_Plan(t *testing.T) {
 											DataSource: &datasource.HttpJsonDataSource{
 												Log: log.NoopLogger,
 											},
+											ParentType: "JSONPlaceholderPost",
+											ReturnType: "[JSONPlaceholderComment]",
 										},
 										BufferName: "comments",
 									},
@@ -555,6 +619,11 @@ func TestPlanner_Plan(t *testing.T) {
 													},
 												},
 												ValueType: IntegerValueType,
+												NonNull:   true,
+											},
+											Position: Position{
+												Line:   11,
+												Column: 8,
 											},
 										},
 										{
@@ -572,14 +641,27 @@ func TestPlanner_Plan(t *testing.T) {
 																	},
 																},
 																ValueType: IntegerValueType,
+																NonNull:   true,
+															},
+															Position: Position{
+																Line:   13,
+																Column: 9,
 															},
 														},
 													},
 												},
 											},
+											Position: Position{
+												Line:   12,
+												Column: 8,
+											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   10,
+									Column: 7,
+								},
 							},
 						},
 					},
@@ -649,6 +731,8 @@ func TestPlanner_Plan(t *testing.T) {
 										VariableName: []byte("input"),
 									},
 								},
+								ParentType: "Query",
+								ReturnType: "String!",
 							},
 						},
 						Fields: []Field{
@@ -657,6 +741,11 @@ func TestPlanner_Plan(t *testing.T) {
 								HasResolvedData: true,
 								Value: &Value{
 									ValueType: StringValueType,
+									NonNull:   true,
+								},
+								Position: Position{
+									Line:   3,
+									Column: 7,
 								},
 							},
 						},
@@ -716,6 +805,8 @@ func TestPlanner_Plan(t *testing.T) {
 										Value: []byte("GET"),
 									},
 								},
+								ParentType: "Query",
+								ReturnType: "String!",
 							},
 						},
 						Fields: []Field{
@@ -729,6 +820,11 @@ func TestPlanner_Plan(t *testing.T) {
 										},
 									},
 									ValueType: StringValueType,
+									NonNull:   true,
+								},
+								Position: Position{
+									Line:   3,
+									Column: 7,
 								},
 							},
 						},
@@ -794,6 +890,8 @@ func TestPlanner_Plan(t *testing.T) {
 										Value: []byte(`{"defaultTypeName":"ListItem"}`),
 									},
 								},
+								ParentType: "Query",
+								ReturnType: "[ListItem]",
 							},
 						},
 						Fields: []Field{
@@ -812,11 +910,20 @@ func TestPlanner_Plan(t *testing.T) {
 														},
 													},
 													ValueType: StringValueType,
+													NonNull:   true,
+												},
+												Position: Position{
+													Line:   4,
+													Column: 8,
 												},
 											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 7,
+								},
 							},
 						},
 					},
@@ -881,6 +988,8 @@ func TestPlanner_Plan(t *testing.T) {
 										Value: []byte(`{"defaultTypeName":"ListItem"}`),
 									},
 								},
+								ParentType: "Query",
+								ReturnType: "[ListItem]",
 							},
 						},
 						Fields: []Field{
@@ -904,11 +1013,20 @@ func TestPlanner_Plan(t *testing.T) {
 														},
 													},
 													ValueType: StringValueType,
+													NonNull:   true,
+												},
+												Position: Position{
+													Line:   4,
+													Column: 8,
 												},
 											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 7,
+								},
 							},
 						},
 					},
@@ -990,6 +1108,8 @@ func TestPlanner_Plan(t *testing.T) {
 										},
 									},
 								},
+								ParentType: "Query",
+								ReturnType: "String!",
 							},
 						},
 						Fields: []Field{
@@ -998,6 +1118,11 @@ func TestPlanner_Plan(t *testing.T) {
 								HasResolvedData: true,
 								Value: &Value{
 									ValueType: StringValueType,
+									NonNull:   true,
+								},
+								Position: Position{
+									Line:   3,
+									Column: 7,
 								},
 							},
 						},
@@ -1072,6 +1197,8 @@ func TestPlanner_Plan(t *testing.T) {
 										DataSource: &datasource.StaticDataSource{
 											Data: []byte("World!"),
 										},
+										ParentType: "Query",
+										ReturnType: "String!",
 									},
 									BufferName: "hello",
 								},
@@ -1080,6 +1207,8 @@ func TestPlanner_Plan(t *testing.T) {
 										DataSource: &datasource.StaticDataSource{
 											Data: []byte("null"),
 										},
+										ParentType: "Query",
+										ReturnType: "Int",
 									},
 									BufferName: "nullableInt",
 								},
@@ -1088,6 +1217,8 @@ func TestPlanner_Plan(t *testing.T) {
 										DataSource: &datasource.StaticDataSource{
 											Data: []byte("{\"bar\":\"baz\"}"),
 										},
+										ParentType: "Query",
+										ReturnType: "Foo!",
 									},
 									BufferName: "foo",
 								},
@@ -1099,6 +1230,11 @@ func TestPlanner_Plan(t *testing.T) {
 								HasResolvedData: true,
 								Value: &Value{
 									ValueType: StringValueType,
+									NonNull:   true,
+								},
+								Position: Position{
+									Line:   3,
+									Column: 7,
 								},
 							},
 							{
@@ -1107,6 +1243,10 @@ func TestPlanner_Plan(t *testing.T) {
 								Value: &Value{
 									ValueType: IntegerValueType,
 								},
+								Position: Position{
+									Line:   4,
+									Column: 7,
+								},
 							},
 							{
 								Name:            []byte("foo"),
@@ -1122,9 +1262,19 @@ func TestPlanner_Plan(t *testing.T) {
 													},
 												},
 												ValueType: StringValueType,
+												NonNull:   true,
+											},
+											Position: Position{
+												Line:   6,
+												Column: 8,
 											},
 										},
 									},
+									NonNull: true,
+								},
+								Position: Position{
+									Line:   5,
+									Column: 7,
 								},
 							},
 						},
@@ -1176,6 +1326,8 @@ func TestPlanner_Plan(t *testing.T) {
 									},
 								},
 								DataSource: &datasource.TypeDataSource{},
+								ParentType: "Query",
+								ReturnType: "__Type!",
 							},
 							BufferName: "__type",
 						},
@@ -1200,6 +1352,10 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   4,
+												Column: 7,
+											},
 										},
 										{
 											Name: []byte("fields"),
@@ -1220,6 +1376,11 @@ func TestPlanner_Plan(t *testing.T) {
 																	},
 																},
 																ValueType: StringValueType,
+																NonNull:   true,
+															},
+															Position: Position{
+																Line:   6,
+																Column: 8,
 															},
 														},
 														{
@@ -1241,15 +1402,34 @@ func TestPlanner_Plan(t *testing.T) {
 																			},
 																			ValueType: StringValueType,
 																		},
+																		Position: Position{
+																			Line:   8,
+																			Column: 9,
+																		},
 																	},
 																},
+																NonNull: true,
+															},
+															Position: Position{
+																Line:   7,
+																Column: 8,
 															},
 														},
 													},
+													NonNull: true,
 												},
 											},
+											Position: Position{
+												Line:   5,
+												Column: 7,
+											},
 										},
 									},
+									NonNull: true,
+								},
+								Position: Position{
+									Line:   3,
+									Column: 6,
 								},
 							},
 						},
@@ -1302,7 +1482,7 @@ func TestPlanner_Plan(t *testing.T) {
 									},
 									&datasource.StaticVariableArgument{
 										Name:  literal.QUERY,
-										Value: []byte("query o($id: String!){user(id: $id){id name birthday}}"),
+										Value: []byte("query UserQuery($id: String!){user(id: $id){id name birthday}}"),
 									},
 									&datasource.StaticVariableArgument{
 										Name:  literal.METHOD,
@@ -1316,6 +1496,8 @@ func TestPlanner_Plan(t *testing.T) {
 								DataSource: &datasource.GraphQLDataSource{
 									Log: log.NoopLogger,
 								},
+								ParentType: "Query",
+								ReturnType: "User",
 							},
 							BufferName: "user",
 						},
@@ -1340,6 +1522,10 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   4,
+												Column: 6,
+											},
 										},
 										{
 											Name: []byte("name"),
@@ -1351,6 +1537,10 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   5,
+												Column: 6,
+											},
 										},
 										{
 											Name: []byte("birthday"),
@@ -1362,9 +1552,17 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   6,
+												Column: 6,
+											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 5,
+								},
 							},
 						},
 					},
@@ -1433,6 +1631,8 @@ func TestPlanner_Plan(t *testing.T) {
 								DataSource: &datasource.HttpJsonDataSource{
 									Log: log.NoopLogger,
 								},
+								ParentType: "Query",
+								ReturnType: "User",
 							},
 							BufferName: "restUser",
 						},
@@ -1452,6 +1652,10 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   4,
+												Column: 7,
+											},
 										},
 										{
 											Name: []byte("name"),
@@ -1463,6 +1667,10 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   5,
+												Column: 7,
+											},
 										},
 										{
 											Name: []byte("birthday"),
@@ -1474,9 +1682,17 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   6,
+												Column: 7,
+											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 6,
+								},
 							},
 						},
 					},
@@ -1549,7 +1765,7 @@ func TestPlanner_Plan(t *testing.T) {
 									},
 									&datasource.StaticVariableArgument{
 										Name:  literal.QUERY,
-										Value: []byte("query o($id: String!){user(id: $id){id name birthday}}"),
+										Value: []byte("query UserQuery($id: String!){user(id: $id){id name birthday}}"),
 									},
 									&datasource.StaticVariableArgument{
 										Name:  literal.METHOD,
@@ -1563,6 +1779,8 @@ func TestPlanner_Plan(t *testing.T) {
 								DataSource: &datasource.GraphQLDataSource{
 									Log: log.NoopLogger,
 								},
+								ParentType: "Query",
+								ReturnType: "User",
 							},
 							BufferName: "user",
 						},
@@ -1599,6 +1817,8 @@ func TestPlanner_Plan(t *testing.T) {
 											DataSource: &datasource.HttpJsonDataSource{
 												Log: log.NoopLogger,
 											},
+											ParentType: "User",
+											ReturnType: "[User]",
 										},
 										BufferName: "friends",
 									},
@@ -1613,6 +1833,10 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   4,
+												Column: 6,
+											},
 										},
 										{
 											Name: []byte("name"),
@@ -1624,6 +1848,10 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   5,
+												Column: 6,
+											},
 										},
 										{
 											Name: []byte("birthday"),
@@ -1635,6 +1863,10 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   6,
+												Column: 6,
+											},
 										},
 										{
 											Name:            []byte("friends"),
@@ -1652,6 +1884,10 @@ func TestPlanner_Plan(t *testing.T) {
 																},
 																ValueType: StringValueType,
 															},
+															Position: Position{
+																Line:   8,
+																Column: 7,
+															},
 														},
 														{
 															Name: []byte("name"),
@@ -1663,6 +1899,10 @@ func TestPlanner_Plan(t *testing.T) {
 																},
 																ValueType: StringValueType,
 															},
+															Position: Position{
+																Line:   9,
+																Column: 7,
+															},
 														},
 														{
 															Name: []byte("birthday"),
@@ -1674,13 +1914,25 @@ func TestPlanner_Plan(t *testing.T) {
 																},
 																ValueType: StringValueType,
 															},
+															Position: Position{
+																Line:   10,
+																Column: 7,
+															},
 														},
 													},
 												},
 											},
+											Position: Position{
+												Line:   7,
+												Column: 6,
+											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 5,
+								},
 							},
 						},
 					},
@@ -1833,7 +2085,7 @@ func TestPlanner_Plan(t *testing.T) {
 									},
 									&datasource.StaticVariableArgument{
 										Name:  literal.QUERY,
-										Value: []byte("query o($id: String!){user(id: $id){id name birthday}}"),
+										Value: []byte("query UserQuery($id: String!){user(id: $id){id name birthday}}"),
 									},
 									&datasource.ContextVariableArgument{
 										Name:         []byte("id"),
@@ -1899,7 +2151,7 @@ func TestPlanner_Plan(t *testing.T) {
 														},
 														&datasource.StaticVariableArgument{
 															Name:  literal.QUERY,
-															Value: []byte("query o($userId: String!){userPets(userId: $userId){__typename nickname ... on Dog {name woof} ... on Cat {name meow}}}"),
+															Value: []byte("query UserQuery($userId: String!){userPets(userId: $userId){__typename nickname ... on Dog {name woof} ... on Cat {name meow}}}"),
 														},
 														&datasource.ObjectVariableArgument{
 															Name: []byte("userId"),
@@ -1955,7 +2207,7 @@ func TestPlanner_Plan(t *testing.T) {
 																},
 																&datasource.StaticVariableArgument{
 																	Name:  literal.QUERY,
-																	Value: []byte("query o($userId: String!){userPets(userId: $userId){__typename nickname ... on Dog {name woof} ... on Cat {name meow}}}"),
+																	Value: []byte("query UserQuery($userId: String!){userPets(userId: $userId){__typename nickname ... on Dog {name woof} ... on Cat {name meow}}}"),
 																},
 																&datasource.ObjectVariableArgument{
 																	Name: []byte("userId"),
@@ -2960,6 +3212,8 @@ func TestPlanner_Plan(t *testing.T) {
 									Log:   log.NoopLogger,
 									Delay: time.Second * 5,
 								},
+								ParentType: "Subscription",
+								ReturnType: "Foo",
 							},
 							BufferName: "stream",
 						},
@@ -2979,6 +3233,10 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: StringValueType,
 											},
+											Position: Position{
+												Line:   4,
+												Column: 8,
+											},
 										},
 										{
 											Name: []byte("baz"),
@@ -2990,9 +3248,17 @@ func TestPlanner_Plan(t *testing.T) {
 												},
 												ValueType: IntegerValueType,
 											},
+											Position: Position{
+												Line:   5,
+												Column: 8,
+											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 7,
+								},
 							},
 						},
 					},
@@ -3037,6 +3303,8 @@ func TestPlanner_Plan(t *testing.T) {
 								DataSource: &datasource.StaticDataSource{
 									Data: []byte("[{\"bar\":\"baz\"},{\"bar\":\"bal\"},{\"bar\":\"bat\"}]"),
 								},
+								ParentType: "Query",
+								ReturnType: "[Foo]",
 							},
 							BufferName: "foos",
 						},
@@ -3060,10 +3328,18 @@ func TestPlanner_Plan(t *testing.T) {
 													},
 													ValueType: StringValueType,
 												},
+												Position: Position{
+													Line:   4,
+													Column: 6,
+												},
 											},
 										},
 									},
 								},
+								Position: Position{
+									Line:   3,
+									Column: 5,
+								},
 							},
 						},
 					},
@@ -3142,6 +3418,8 @@ func TestPlanner_Plan(t *testing.T) {
 										return pipeline
 									}(),
 								},
+								ParentType: "Query",
+								ReturnType: "String",
 							},
 							BufferName: "stringPipeline",
 						},
@@ -3152,6 +3430,10 @@ func TestPlanner_Plan(t *testing.T) {
 								Value: &Value{
 									ValueType: StringValueType,
 								},
+								Position: Position{
+									Line:   3,
+									Column: 5,
+								},
 							},
 						},
 					},
@@ -3221,6 +3503,8 @@ func TestPlanner_Plan(t *testing.T) {
 										return pipeline
 									}(),
 								},
+								ParentType: "Query",
+								ReturnType: "String",
 							},
 							BufferName: "filePipeline",
 						},
@@ -3231,6 +3515,10 @@ func TestPlanner_Plan(t *testing.T) {
 								Value: &Value{
 									ValueType: StringValueType,
 								},
+								Position: Position{
+									Line:   3,
+									Column: 5,
+								},
 							},
 						},
 					},
@@ -3307,10 +3595,19 @@ func TestPlanner_Plan(t *testing.T) {
 																	},
 																	ValueType: StringValueType,
 																},
+																Position: Position{
+																	Line:   6,
+																	Column: 8,
+																},
 															},
 														},
+														NonNull: true,
 													},
 												},
+												Position: Position{
+													Line:   5,
+													Column: 9,
+												},
 											},
 											{
 												Name: []byte("status"),
@@ -3332,6 +3629,10 @@ func TestPlanner_Plan(t *testing.T) {
 														},
 													},
 												},
+												Position: Position{
+													Line:   10,
+													Column: 7,
+												},
 											},
 											{
 												Name: []byte("message"),
@@ -3353,9 +3654,17 @@ func TestPlanner_Plan(t *testing.T) {
 														},
 													},
 												},
+												Position: Position{
+													Line:   11,
+													Column: 7,
+												},
 											},
 										},
 									},
+									Position: Position{
+										Line:   3,
+										Column: 5,
+									},
 								},
 							},
 						},
@@ -3366,6 +3675,542 @@ func TestPlanner_Plan(t *testing.T) {
 	})
 }
 
+func TestPlanner_Batch(t *testing.T) {
+
+	schema := withBaseSchema(`
+		schema {
+			query: Query
+		}
+		type Query {
+			posts: [Post]
+		}
+		type Post {
+			id: Int
+			user: User @cacheControl(maxAge: 60)
+		}
+		type User {
+			name: String
+		}`)
+
+	plan := func(t *testing.T, batch bool, dataSourceName string, factory datasource.PlannerFactoryFactory, cache ...datasource.Cache) *SingleFetch {
+		def := unsafeparser.ParseGraphqlDocumentString(schema)
+		op := unsafeparser.ParseGraphqlDocumentString(`query Posts { posts { id user { name } } }`)
+
+		var report operationreport.Report
+		astnormalization.NewNormalizer(true).NormalizeOperation(&op, &def, &report)
+		if report.HasErrors() {
+			t.Fatal(report)
+		}
+
+		base, err := datasource.NewBaseDataSourcePlanner([]byte(schema), datasource.PlannerConfiguration{
+			TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+				{
+					TypeName:  "query",
+					FieldName: "posts",
+					DataSource: datasource.SourceConfig{
+						Name: "StaticDataSource",
+						Config: toJSON(datasource.StaticDataSourceConfig{
+							Data: `[{"id":1},{"id":2}]`,
+						}),
+					},
+				},
+				{
+					TypeName:  "Post",
+					FieldName: "user",
+					DataSource: datasource.SourceConfig{
+						Name: dataSourceName,
+						Config: toJSON(datasource.HttpJsonDataSourceConfig{
+							Host: "example.com",
+							URL:  "/users/{{ .object.id }}",
+							Batch: &datasource.HttpJsonDataSourceConfigBatch{
+								URL:   "/users",
+								Input: `{{ .object.id }}`,
+							},
+						}),
+					},
+					Batch: batch,
+				},
+			},
+		}, log.NoopLogger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+		panicOnErr(base.RegisterDataSourcePlannerFactory(dataSourceName, factory))
+
+		planner := NewPlanner(base)
+		if len(cache) == 1 {
+			planner.visitor.cache = cache[0]
+		}
+		root := planner.Plan(&op, &def, "", &report)
+		if report.HasErrors() {
+			t.Fatal(report)
+		}
+		posts := root.(*Object).Fields[0].Value.(*Object).Fields[0].Value.(*List)
+		return posts.Value.(*Object).Fetch.(*SingleFetch)
+	}
+
+	t.Run("batch enabled", func(t *testing.T) {
+		fetch := plan(t, true, "HttpJsonDataSource", datasource.HttpJsonDataSourcePlannerFactoryFactory{})
+		if !fetch.Batch {
+			t.Fatal("want batch fetch")
+		}
+		hasBatchURL := false
+		for _, arg := range fetch.Source.Args {
+			if bytes.Equal(arg.ArgName(), literal.BATCHURL) {
+				hasBatchURL = true
+			}
+		}
+		if !hasBatchURL {
+			t.Fatal("want batchUrl arg")
+		}
+	})
+	t.Run("batch enabled with cache control", func(t *testing.T) {
+		fetch := plan(t, true, "HttpJsonDataSource", datasource.HttpJsonDataSourcePlannerFactoryFactory{}, datasource.NewInMemoryCache(10))
+		if !fetch.Batch {
+			t.Fatal("want batch fetch")
+		}
+		if _, ok := fetch.Source.DataSource.(*datasource.CachingBatchDataSource); !ok {
+			t.Fatalf("want *datasource.CachingBatchDataSource, got: %T", fetch.Source.DataSource)
+		}
+	})
+	t.Run("batch disabled", func(t *testing.T) {
+		fetch := plan(t, false, "HttpJsonDataSource", datasource.HttpJsonDataSourcePlannerFactoryFactory{})
+		if fetch.Batch {
+			t.Fatal("want no batch fetch")
+		}
+	})
+	t.Run("data source without batch support", func(t *testing.T) {
+		fetch := plan(t, true, "StaticUserDataSource", datasource.StaticDataSourcePlannerFactoryFactory{})
+		if fetch.Batch {
+			t.Fatal("want no batch fetch")
+		}
+	})
+}
+
+func TestPlanner_SkipInclude(t *testing.T) {
+
+	schema := withBaseSchema(`
+		schema {
+			query: Query
+		}
+		type Query {
+			hero: Hero
+		}
+		type Hero {
+			name: String
+			friends: [Hero]
+		}`)
+
+	def := unsafeparser.ParseGraphqlDocumentString(schema)
+	op := unsafeparser.ParseGraphqlDocumentString(`
+		query Hero($withFriends: Boolean!, $withoutName: Boolean!) {
+			hero {
+				name @skip(if: $withoutName) @include(if: true)
+				... @include(if: $withFriends) {
+					friends {
+						name
+					}
+				}
+			}
+		}`)
+
+	var report operationreport.Report
+	astnormalization.NewNormalizer(true).NormalizeOperation(&op, &def, &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(schema), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "hero",
+				DataSource: datasource.SourceConfig{
+					Name: "StaticDataSource",
+					Config: toJSON(datasource.StaticDataSourceConfig{
+						Data: `{"name":"Luke"}`,
+					}),
+				},
+			},
+			{
+				TypeName:  "Hero",
+				FieldName: "friends",
+				DataSource: datasource.SourceConfig{
+					Name: "StaticDataSource",
+					Config: toJSON(datasource.StaticDataSourceConfig{
+						Data: `[{"name":"Leia"}]`,
+					}),
+				},
+			},
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+
+	root := NewPlanner(base).Plan(&op, &def, "", &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	hero := root.(*Object).Fields[0].Value.(*Object).Fields[0].Value.(*Object)
+	name, friends := hero.Fields[0], hero.Fields[1]
+
+	wantSkip := &IfEqual{
+		Left: &datasource.ContextVariableArgument{
+			VariableName: []byte("withoutName"),
+		},
+		Right: &datasource.StaticVariableArgument{
+			Value: literal.TRUE,
+		},
+	}
+	if !reflect.DeepEqual(name.Skip, wantSkip) {
+		t.Fatalf("want skip condition: %+v, got: %+v", wantSkip, name.Skip)
+	}
+
+	wantInclude := &IfNotEqual{
+		Left: &datasource.ContextVariableArgument{
+			VariableName: []byte("withFriends"),
+		},
+		Right: &datasource.StaticVariableArgument{
+			Value: literal.TRUE,
+		},
+	}
+	if !reflect.DeepEqual(friends.Skip, wantInclude) {
+		t.Fatalf("want include condition: %+v, got: %+v", wantInclude, friends.Skip)
+	}
+
+	fetch, ok := hero.Fetch.(*SingleFetch)
+	if !ok {
+		t.Fatalf("want single fetch for friends, got: %T", hero.Fetch)
+	}
+	if fetch.Skip != friends.Skip {
+		t.Fatal("want the fetch of friends to be skipped together with the field")
+	}
+}
+
+func TestPlanner_MutationRootFields(t *testing.T) {
+
+	schema := withBaseSchema(`
+		schema {
+			query: Query
+			mutation: Mutation
+		}
+		type Query {
+			hello: String
+		}
+		type Mutation {
+			first: String
+			second: String
+			third: String
+		}`)
+
+	plan := func(t *testing.T, operation string) Fetch {
+		def := unsafeparser.ParseGraphqlDocumentString(schema)
+		op := unsafeparser.ParseGraphqlDocumentString(operation)
+
+		var report operationreport.Report
+		astnormalization.NewNormalizer(true).NormalizeOperation(&op, &def, &report)
+		if report.HasErrors() {
+			t.Fatal(report)
+		}
+
+		var typeFieldConfigurations []datasource.TypeFieldConfiguration
+		for _, typeField := range [][2]string{{"query", "hello"}, {"mutation", "first"}, {"mutation", "second"}, {"mutation", "third"}} {
+			typeFieldConfigurations = append(typeFieldConfigurations, datasource.TypeFieldConfiguration{
+				TypeName:  typeField[0],
+				FieldName: typeField[1],
+				DataSource: datasource.SourceConfig{
+					Name: "StaticDataSource",
+					Config: toJSON(datasource.StaticDataSourceConfig{
+						Data: typeField[1],
+					}),
+				},
+			})
+		}
+		base, err := datasource.NewBaseDataSourcePlanner([]byte(schema), datasource.PlannerConfiguration{
+			TypeFieldConfigurations: typeFieldConfigurations,
+		}, log.NoopLogger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+
+		root := NewPlanner(base).Plan(&op, &def, "", &report)
+		if report.HasErrors() {
+			t.Fatal(report)
+		}
+		return root.(*Object).Fields[0].Value.(*Object).Fetch
+	}
+
+	t.Run("mutation", func(t *testing.T) {
+		fetch, ok := plan(t, `mutation { first second third }`).(*SerialFetch)
+		if !ok {
+			t.Fatalf("want serial fetch")
+		}
+		if len(fetch.Fetches) != 3 {
+			t.Fatalf("want 3 fetches, got: %d", len(fetch.Fetches))
+		}
+		for i, want := range []string{"first", "second", "third"} {
+			if got := fetch.Fetches[i].(*SingleFetch).BufferName; got != want {
+				t.Fatalf("want fetch %d for field %s, got: %s", i, want, got)
+			}
+		}
+	})
+	t.Run("single mutation field", func(t *testing.T) {
+		if _, ok := plan(t, `mutation { first }`).(*SingleFetch); !ok {
+			t.Fatal("want single fetch")
+		}
+	})
+	t.Run("query", func(t *testing.T) {
+		if _, ok := plan(t, `query { hello alias: hello }`).(*ParallelFetch); !ok {
+			t.Fatal("want parallel fetch")
+		}
+	})
+}
+
+func TestPlanner_DeferStream(t *testing.T) {
+
+	schema := withBaseSchema(`
+		schema {
+			query: Query
+		}
+		type Query {
+			hero: Hero
+		}
+		type Hero {
+			name: String
+			friends: [Hero]
+		}`)
+
+	def := unsafeparser.ParseGraphqlDocumentString(schema)
+	op := unsafeparser.ParseGraphqlDocumentString(`
+		query Hero($deferFriends: Boolean!) {
+			hero {
+				name
+				... @defer(label: "friends", if: $deferFriends) {
+					friends @stream(label: "items", initialCount: 1) {
+						name
+					}
+				}
+				... @defer(if: false) {
+					name
+				}
+			}
+		}`)
+
+	var report operationreport.Report
+	astnormalization.NewNormalizer(true).NormalizeOperation(&op, &def, &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(schema), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "hero",
+				DataSource: datasource.SourceConfig{
+					Name: "StaticDataSource",
+					Config: toJSON(datasource.StaticDataSourceConfig{
+						Data: `{"name":"Luke"}`,
+					}),
+				},
+			},
+			{
+				TypeName:  "Hero",
+				FieldName: "friends",
+				DataSource: datasource.SourceConfig{
+					Name: "StaticDataSource",
+					Config: toJSON(datasource.StaticDataSourceConfig{
+						Data: `[{"name":"Leia"},{"name":"Han"}]`,
+					}),
+				},
+			},
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+
+	root := NewPlanner(base).Plan(&op, &def, "", &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	if !root.Incremental() {
+		t.Fatal("want incremental plan")
+	}
+
+	hero := root.(*Object).Fields[0].Value.(*Object).Fields[0].Value.(*Object)
+	if hero.Fetch != nil {
+		t.Fatalf("want the fetch of friends to be part of the deferred fragment, got: %T", hero.Fetch)
+	}
+	if len(hero.Fields) != 2 {
+		t.Fatalf("want the fragment with if: false to be resolved in place, got fields: %d", len(hero.Fields))
+	}
+	if len(hero.Deferred) != 1 {
+		t.Fatalf("want 1 deferred fragment, got: %d", len(hero.Deferred))
+	}
+
+	deferred := hero.Deferred[0]
+	if deferred.Label != "friends" {
+		t.Fatalf("want label: friends, got: %s", deferred.Label)
+	}
+	wantDisabled := &IfEqual{
+		Left: &datasource.ContextVariableArgument{
+			VariableName: []byte("deferFriends"),
+		},
+		Right: &datasource.StaticVariableArgument{
+			Value: literal.FALSE,
+		},
+	}
+	if !reflect.DeepEqual(deferred.Disabled, wantDisabled) {
+		t.Fatalf("want disabled condition: %+v, got: %+v", wantDisabled, deferred.Disabled)
+	}
+	if _, ok := deferred.Object.Fetch.(*SingleFetch); !ok {
+		t.Fatalf("want single fetch for friends, got: %T", deferred.Object.Fetch)
+	}
+
+	friends := deferred.Object.Fields[0]
+	if string(friends.Name) != "friends" || !friends.HasResolvedData {
+		t.Fatalf("want resolved field friends, got: %s", friends.Name)
+	}
+	wantStream := &Stream{
+		Label:        "items",
+		InitialCount: 1,
+	}
+	if !reflect.DeepEqual(friends.Value.(*List).Stream, wantStream) {
+		t.Fatalf("want stream: %+v, got: %+v", wantStream, friends.Value.(*List).Stream)
+	}
+}
+
+func TestPlanner_TypeResolver(t *testing.T) {
+
+	schema := withBaseSchema(`
+		schema {
+			query: Query
+		}
+		type Query {
+			pets: [Pet]
+			cat: Cat
+		}
+		union Pet = Cat | Dog
+		type Cat {
+			name: String
+		}
+		type Dog {
+			name: String
+		}`)
+
+	def := unsafeparser.ParseGraphqlDocumentString(schema)
+	op := unsafeparser.ParseGraphqlDocumentString(`
+		query Pets {
+			pets {
+				... on Cat {
+					name
+				}
+			}
+			cat {
+				name
+			}
+		}`)
+
+	var report operationreport.Report
+	astnormalization.NewNormalizer(true).NormalizeOperation(&op, &def, &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(schema), datasource.PlannerConfiguration{
+		TypeResolvers: []datasource.TypeResolverConfiguration{
+			{
+				TypeName: "Pet",
+				Discriminator: &datasource.DiscriminatorConfiguration{
+					Path: "kind",
+				},
+			},
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := NewPlanner(base).Plan(&op, &def, "", &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	data := root.(*Object).Fields[0].Value.(*Object)
+	pet := data.Fields[0].Value.(*List).Value.(*Object)
+	want := &datasource.DiscriminatorConfiguration{
+		Path: "kind",
+	}
+	if !reflect.DeepEqual(pet.TypeResolver, want) {
+		t.Fatalf("want type resolver: %+v, got: %+v", want, pet.TypeResolver)
+	}
+	if cat := data.Fields[1].Value.(*Object); cat.TypeResolver != nil {
+		t.Fatalf("want no type resolver for object type, got: %+v", cat.TypeResolver)
+	}
+}
+
+func TestPlanner_Enum(t *testing.T) {
+
+	schema := withBaseSchema(`
+		schema {
+			query: Query
+		}
+		type Query {
+			status: Status @enumMapping(values: [{from: "in_progress", to: "IN_PROGRESS"}])
+			name: String
+		}
+		enum Status {
+			TODO
+			IN_PROGRESS
+		}`)
+
+	def := unsafeparser.ParseGraphqlDocumentString(schema)
+	op := unsafeparser.ParseGraphqlDocumentString(`
+		query Status {
+			status
+			name
+		}`)
+
+	var report operationreport.Report
+	astnormalization.NewNormalizer(true).NormalizeOperation(&op, &def, &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(schema), datasource.PlannerConfiguration{}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := NewPlanner(base).Plan(&op, &def, "", &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	data := root.(*Object).Fields[0].Value.(*Object)
+	want := &Enum{
+		Name:   "Status",
+		Values: [][]byte{[]byte("TODO"), []byte("IN_PROGRESS")},
+		Mapping: map[string]string{
+			"in_progress": "IN_PROGRESS",
+		},
+	}
+	if got := data.Fields[0].Value.(*Value).Enum; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want enum: %+v, got: %+v", want, got)
+	}
+	if got := data.Fields[1].Value.(*Value).Enum; got != nil {
+		t.Fatalf("want no enum for scalar, got: %+v", got)
+	}
+}
+
 func BenchmarkPlanner_Plan(b *testing.B) {
 	schema := withBaseSchema(complexSchema)
 	def := unsafeparser.ParseGraphqlDocumentString(schema)
@@ -3463,7 +4308,7 @@ func BenchmarkPlanner_Plan(b *testing.B) {
 	b.ReportAllocs()
 
 	for i := 0; i < b.N; i++ {
-		planner.Plan(&op, &def, &report)
+		planner.Plan(&op, &def, "", &report)
 		if report.HasErrors() {
 			b.Fatal(report)
 		}
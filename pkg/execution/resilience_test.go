@@ -0,0 +1,372 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	log "github.com/jensneuse/abstractlogger"
+	"github.com/jensneuse/graphql-go-tools/internal/pkg/unsafeparser"
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/astnormalization"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyDataSource fails the first failures calls after writing partial data
+type flakyDataSource struct {
+	mux      sync.Mutex
+	calls    int
+	failures int
+	delay    time.Duration
+}
+
+func (f *flakyDataSource) Resolve(ctx context.Context, args datasource.ResolverArgs, out io.Writer) (n int, err error) {
+	f.mux.Lock()
+	f.calls++
+	call := f.calls
+	f.mux.Unlock()
+	if f.delay != 0 {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(f.delay):
+		}
+	}
+	if call <= f.failures {
+		_, _ = out.Write([]byte(`{"partial":`))
+		return 0, fmt.Errorf("call %d failed", call)
+	}
+	return out.Write([]byte(`{"foo":"bar"}`))
+}
+
+func TestResilientDataSource(t *testing.T) {
+
+	resolve := func(source datasource.DataSource) (string, error) {
+		out := bytes.Buffer{}
+		_, err := source.Resolve(context.Background(), ResolvedArgs{}, &out)
+		return out.String(), err
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		source := datasource.NewResilientDataSource(&flakyDataSource{delay: time.Second}, datasource.ResilienceConfiguration{
+			TimeoutMilliseconds: 10,
+		}, true, nil)
+		start := time.Now()
+		_, err := resolve(source)
+		if err != context.DeadlineExceeded {
+			t.Fatalf("want deadline exceeded, got: %v", err)
+		}
+		if time.Since(start) > time.Millisecond*500 {
+			t.Fatal("want call to be canceled after the timeout")
+		}
+	})
+	t.Run("retries", func(t *testing.T) {
+		flaky := &flakyDataSource{failures: 2}
+		source := datasource.NewResilientDataSource(flaky, datasource.ResilienceConfiguration{
+			MaxRetries:               2,
+			RetryBackoffMilliseconds: 1,
+		}, true, nil)
+		out, err := resolve(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != `{"foo":"bar"}` {
+			t.Fatalf("want output of successful call only, got: %s", out)
+		}
+		if flaky.calls != 3 {
+			t.Fatalf("want 3 calls, got: %d", flaky.calls)
+		}
+	})
+	t.Run("retries exhausted", func(t *testing.T) {
+		flaky := &flakyDataSource{failures: 3}
+		source := datasource.NewResilientDataSource(flaky, datasource.ResilienceConfiguration{
+			MaxRetries: 2,
+		}, true, nil)
+		out, err := resolve(source)
+		if err == nil || err.Error() != "call 3 failed" {
+			t.Fatalf("want error of last call, got: %v", err)
+		}
+		if out != "" {
+			t.Fatalf("want no output, got: %s", out)
+		}
+	})
+	t.Run("no retries for non idempotent operations", func(t *testing.T) {
+		flaky := &flakyDataSource{failures: 1}
+		source := datasource.NewResilientDataSource(flaky, datasource.ResilienceConfiguration{
+			MaxRetries: 2,
+		}, false, nil)
+		_, err := resolve(source)
+		if err == nil {
+			t.Fatal("want err")
+		}
+		if flaky.calls != 1 {
+			t.Fatalf("want 1 call, got: %d", flaky.calls)
+		}
+	})
+	t.Run("circuit breaker", func(t *testing.T) {
+		flaky := &flakyDataSource{failures: 2}
+		source := datasource.NewResilientDataSource(flaky, datasource.ResilienceConfiguration{}, true, datasource.NewCircuitBreaker(2, time.Millisecond*20))
+		for i := 0; i < 2; i++ {
+			if _, err := resolve(source); err == nil || err == datasource.ErrCircuitOpen {
+				t.Fatalf("want upstream error, got: %v", err)
+			}
+		}
+		if _, err := resolve(source); err != datasource.ErrCircuitOpen {
+			t.Fatalf("want open circuit, got: %v", err)
+		}
+		if flaky.calls != 2 {
+			t.Fatalf("want no call while the circuit is open, got: %d calls", flaky.calls)
+		}
+		time.Sleep(time.Millisecond * 30)
+		out, err := resolve(source)
+		if err != nil {
+			t.Fatalf("want trial call after cooldown to succeed, got: %v", err)
+		}
+		if out != `{"foo":"bar"}` {
+			t.Fatalf("unexpected output: %s", out)
+		}
+	})
+	t.Run("circuit opened by a retried call", func(t *testing.T) {
+		flaky := &flakyDataSource{failures: 3}
+		source := datasource.NewResilientDataSource(flaky, datasource.ResilienceConfiguration{
+			MaxRetries: 2,
+		}, true, datasource.NewCircuitBreaker(2, time.Hour))
+		if _, err := resolve(source); err == nil || err.Error() != "call 2 failed" {
+			t.Fatalf("want error of the call opening the circuit, got: %v", err)
+		}
+		if flaky.calls != 2 {
+			t.Fatalf("want no retry after the circuit opened, got: %d calls", flaky.calls)
+		}
+		if _, err := resolve(source); err != datasource.ErrCircuitOpen {
+			t.Fatalf("want open circuit, got: %v", err)
+		}
+	})
+	t.Run("batch", func(t *testing.T) {
+		source := datasource.NewResilientDataSource(&batchDataSource{}, datasource.ResilienceConfiguration{}, true, nil)
+		if _, ok := source.(datasource.BatchDataSource); !ok {
+			t.Fatal("want resilient data source to support batching")
+		}
+		source = datasource.NewResilientDataSource(&flakyDataSource{}, datasource.ResilienceConfiguration{}, true, nil)
+		if _, ok := source.(datasource.BatchDataSource); ok {
+			t.Fatal("want resilient data source not to support batching")
+		}
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+
+	open := func() *datasource.CircuitBreaker {
+		breaker := datasource.NewCircuitBreaker(1, time.Millisecond*10)
+		_, trial := breaker.Allow()
+		breaker.Report(trial, fmt.Errorf("failed"))
+		if allowed, _ := breaker.Allow(); allowed {
+			t.Fatal("want open circuit")
+		}
+		time.Sleep(time.Millisecond * 20)
+		return breaker
+	}
+
+	t.Run("single trial call", func(t *testing.T) {
+		breaker := open()
+		allowed, trial := breaker.Allow()
+		if !allowed || !trial {
+			t.Fatalf("want trial call after cooldown, got allowed: %v trial: %v", allowed, trial)
+		}
+		if allowed, _ := breaker.Allow(); allowed {
+			t.Fatal("want no further call while the trial call is in flight")
+		}
+		breaker.Report(trial, nil)
+		if allowed, trial := breaker.Allow(); !allowed || trial {
+			t.Fatalf("want closed circuit, got allowed: %v trial: %v", allowed, trial)
+		}
+	})
+	t.Run("failed trial call", func(t *testing.T) {
+		breaker := open()
+		_, trial := breaker.Allow()
+		breaker.Report(trial, fmt.Errorf("failed"))
+		if allowed, _ := breaker.Allow(); allowed {
+			t.Fatal("want open circuit until the next cooldown passed")
+		}
+	})
+	t.Run("abandoned trial call", func(t *testing.T) {
+		breaker := open()
+		_, trial := breaker.Allow()
+		breaker.Abandon(trial)
+		if allowed, trial := breaker.Allow(); !allowed || !trial {
+			t.Fatalf("want next trial call, got allowed: %v trial: %v", allowed, trial)
+		}
+	})
+}
+
+func TestResilienceConfiguration_JSON(t *testing.T) {
+	var config datasource.SourceConfig
+	err := json.Unmarshal([]byte(`{"resilience":{"timeoutMilliseconds":100,"maxRetries":2,"retryBackoffMilliseconds":10,"circuitBreakerThreshold":5,"circuitBreakerCooldownMilliseconds":1000}}`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := datasource.ResilienceConfiguration{
+		TimeoutMilliseconds:                100,
+		MaxRetries:                         2,
+		RetryBackoffMilliseconds:           10,
+		CircuitBreakerThreshold:            5,
+		CircuitBreakerCooldownMilliseconds: 1000,
+	}
+	if config.Resilience == nil || *config.Resilience != want {
+		t.Fatalf("want: %+v\ngot: %+v", want, config.Resilience)
+	}
+}
+
+func TestResilientPlannerFactory(t *testing.T) {
+
+	schema := withBaseSchema(`
+		schema {
+			query: Query
+		}
+		type Query {
+			foo: String
+			bar: String
+			baz: String
+		}`)
+
+	def := unsafeparser.ParseGraphqlDocumentString(schema)
+	op := unsafeparser.ParseGraphqlDocumentString(`query Foo { foo bar baz }`)
+
+	var report operationreport.Report
+	astnormalization.NewNormalizer(true).NormalizeOperation(&op, &def, &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	typeField := func(fieldName, upstream string) datasource.TypeFieldConfiguration {
+		return datasource.TypeFieldConfiguration{
+			TypeName:  "query",
+			FieldName: fieldName,
+			Upstream:  upstream,
+			DataSource: datasource.SourceConfig{
+				Name: "StaticDataSource",
+				Config: toJSON(datasource.StaticDataSourceConfig{
+					Data: `"` + fieldName + `"`,
+				}),
+				Resilience: &datasource.ResilienceConfiguration{
+					TimeoutMilliseconds:                100,
+					MaxRetries:                         1,
+					CircuitBreakerThreshold:            2,
+					CircuitBreakerCooldownMilliseconds: 60000,
+				},
+			},
+		}
+	}
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(schema), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			typeField("foo", "static"),
+			typeField("bar", "static"),
+			typeField("baz", "other"),
+		},
+	}, log.NoopLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicOnErr(base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+
+	plan := func() []*datasource.ResilientDataSource {
+		root := NewPlanner(base).Plan(&op, &def, "", &report)
+		if report.HasErrors() {
+			t.Fatal(report)
+		}
+		var sources []*datasource.ResilientDataSource
+		for _, field := range root.(*Object).Fields[0].Value.(*Object).Fetch.(*ParallelFetch).Fetches {
+			fetch := field.(*SingleFetch)
+			source, ok := fetch.Source.DataSource.(*datasource.ResilientDataSource)
+			if !ok {
+				t.Fatalf("want *datasource.ResilientDataSource, got: %T", fetch.Source.DataSource)
+			}
+			sources = append(sources, source)
+		}
+		if len(sources) != 3 {
+			t.Fatalf("want 3 data sources, got: %d", len(sources))
+		}
+		return sources
+	}
+
+	first, second := plan(), plan()
+	foo, bar, baz := first[0], first[1], first[2]
+	if !foo.Idempotent {
+		t.Fatal("want queries to be idempotent")
+	}
+	if foo.Config.TimeoutMilliseconds != 100 || foo.Config.MaxRetries != 1 {
+		t.Fatalf("unexpected config: %+v", foo.Config)
+	}
+	if _, ok := foo.DataSource.(*datasource.StaticDataSource); !ok {
+		t.Fatalf("want *datasource.StaticDataSource to be wrapped, got: %T", foo.DataSource)
+	}
+	if foo.CircuitBreaker == nil || foo.CircuitBreaker != second[0].CircuitBreaker {
+		t.Fatal("want all data sources of the type field to share one circuit breaker")
+	}
+	if baz.CircuitBreaker == nil || baz.CircuitBreaker == foo.CircuitBreaker {
+		t.Fatal("want fields of different upstreams to have their own circuit breaker")
+	}
+
+	for i := 0; i < 2; i++ {
+		_, trial := foo.CircuitBreaker.Allow()
+		foo.CircuitBreaker.Report(trial, fmt.Errorf("failed"))
+	}
+	if allowed, _ := bar.CircuitBreaker.Allow(); allowed {
+		t.Fatal("want failures of foo to open the circuit of bar as both call the same upstream")
+	}
+	if allowed, _ := baz.CircuitBreaker.Allow(); !allowed {
+		t.Fatal("want the circuit of the other upstream to stay closed")
+	}
+}
+
+func TestExecutor_CircuitBreakerFieldError(t *testing.T) {
+
+	plan := &Object{
+		operationType: ast.OperationTypeQuery,
+		Fields: []Field{
+			{
+				Name: []byte("data"),
+				Value: &Object{
+					Fetch: &SingleFetch{
+						Source: &DataSourceInvocation{
+							DataSource: datasource.NewResilientDataSource(&flakyDataSource{failures: 1}, datasource.ResilienceConfiguration{}, true, datasource.NewCircuitBreaker(1, time.Hour)),
+						},
+						BufferName: "foo",
+					},
+					Fields: []Field{
+						{
+							Name:            []byte("foo"),
+							HasResolvedData: true,
+							Value: &Value{
+								ValueType: StringValueType,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	execute := func() string {
+		out := bytes.Buffer{}
+		err := NewExecutor(nil).Execute(Context{Context: context.Background()}, plan, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	want := `{"data":{"foo":null},"errors":[{"message":"call 1 failed","path":["foo"]}]}`
+	if got := execute(); got != want {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+	}
+	want = `{"data":{"foo":null},"errors":[{"message":"circuit breaker is open","path":["foo"]}]}`
+	if got := execute(); got != want {
+		t.Fatalf("want: %s\ngot: %s\n", want, got)
+	}
+}
@@ -0,0 +1,213 @@
+package execution
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/buger/jsonparser"
+	"github.com/jensneuse/graphql-go-tools/internal/pkg/unsafebytes"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
+	"github.com/tidwall/gjson"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Scalar defines how the values of a custom scalar get serialized into the response and parsed from variables
+// Both functions receive and return raw JSON values, e.g. a string value including its quotes
+// A nil function accepts all values as they are
+type Scalar struct {
+	// Name is the name of the scalar in the schema
+	Name string
+	// Serialize turns the value returned by the upstream into the value of the response
+	// An error gets reported as field error and the field resolves to null
+	Serialize func(value []byte) ([]byte, error)
+	// ParseValue turns the value of a variable into the value sent to the upstream
+	// An error gets reported as invalid variable and the operation isn't executed
+	ParseValue func(value []byte) ([]byte, error)
+}
+
+// Scalars is a registry of custom scalars by name
+type Scalars map[string]*Scalar
+
+// Register adds the scalar to the registry, an already registered scalar with the same name gets replaced
+func (s Scalars) Register(scalar Scalar) {
+	s[scalar.Name] = &scalar
+}
+
+// ErrScalarValueInvalid is the field error for values the Serialize func of a Scalar rejected
+type ErrScalarValueInvalid struct {
+	value  []byte
+	scalar string
+	reason error
+}
+
+func (e ErrScalarValueInvalid) Error() string {
+	return fmt.Sprintf("%s cannot represent value: %s; %s", e.scalar, unsafebytes.BytesToString(e.value), e.reason)
+}
+
+// resolveScalar writes the value of a custom scalar using its Serialize func
+func (e *Executor) resolveScalar(node *Value, data []byte, path string) (isNull bool) {
+	value := data
+	if len(data) != 0 && node.DataResolvingConfig.PathSelector.Path != "" {
+		value = unsafebytes.StringToBytes(gjson.GetBytes(data, node.DataResolvingConfig.PathSelector.Path).Raw)
+		if node.DataResolvingConfig.Transformation != nil && len(value) != 0 {
+			var err error
+			value, err = node.DataResolvingConfig.Transformation.Transform(value)
+			if err != nil {
+				e.addError(path, err)
+				e.write(literal.NULL)
+				return true
+			}
+		}
+	}
+	if len(value) == 0 || bytes.Equal(value, literal.NULL) {
+		e.write(literal.NULL)
+		return true
+	}
+	if node.Scalar.Serialize == nil {
+		e.write(value)
+		return false
+	}
+	serialized, err := node.Scalar.Serialize(value)
+	if err != nil {
+		e.addError(path, ErrScalarValueInvalid{
+			value:  value,
+			scalar: node.Scalar.Name,
+			reason: err,
+		})
+		e.write(literal.NULL)
+		return true
+	}
+	e.write(serialized)
+	return len(serialized) == 0 || bytes.Equal(serialized, literal.NULL)
+}
+
+// isScalar reports if the node is the Value of a custom scalar which needs the raw JSON value
+func isScalar(node Node) bool {
+	value, ok := node.(*Value)
+	return ok && value.Scalar != nil
+}
+
+var (
+	errExpectedString          = errors.New("expected a string")
+	errExpectedStringOrInteger = errors.New("expected a string or an integer")
+	errExpectedUUID            = errors.New("expected a UUID")
+)
+
+// dateTimeLayouts are the layouts accepted for DateTime values returned by upstreams
+var dateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// DateTimeScalar is a date time which gets normalized to RFC3339 in UTC
+// Upstream values may use any of the layouts RFC3339, "2006-01-02 15:04:05" or "2006-01-02", values without a zone are UTC
+// Variables must be RFC3339
+var DateTimeScalar = Scalar{
+	Name: "DateTime",
+	Serialize: func(value []byte) ([]byte, error) {
+		text, err := jsonString(value)
+		if err != nil {
+			return nil, err
+		}
+		for _, layout := range dateTimeLayouts {
+			if parsed, err := time.Parse(layout, text); err == nil {
+				return json.Marshal(parsed.UTC().Format(time.RFC3339Nano))
+			}
+		}
+		return nil, fmt.Errorf("expected a date time in the format: %s", time.RFC3339)
+	},
+	ParseValue: func(value []byte) ([]byte, error) {
+		text, err := jsonString(value)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, fmt.Errorf("expected a date time in the format: %s", time.RFC3339)
+		}
+		return json.Marshal(parsed.UTC().Format(time.RFC3339Nano))
+	},
+}
+
+// BigIntScalar is an integer of arbitrary size which is represented as string to not lose precision in JSON clients
+// Upstream values and variables may be integers or strings containing an integer
+var BigIntScalar = Scalar{
+	Name:       "BigInt",
+	Serialize:  bigIntString,
+	ParseValue: bigIntString,
+}
+
+func bigIntString(value []byte) ([]byte, error) {
+	text := unsafebytes.BytesToString(value)
+	if value[0] == '"' {
+		var err error
+		if text, err = jsonString(value); err != nil {
+			return nil, err
+		}
+	}
+	integer, ok := new(big.Int).SetString(text, 10)
+	if !ok {
+		return nil, errExpectedStringOrInteger
+	}
+	return json.Marshal(integer.String())
+}
+
+// JSONScalar is an arbitrary JSON value which is passed through as it is
+var JSONScalar = Scalar{
+	Name: "JSON",
+}
+
+// UUIDScalar is a UUID in the canonical textual representation which gets normalized to lower case
+var UUIDScalar = Scalar{
+	Name:       "UUID",
+	Serialize:  uuidString,
+	ParseValue: uuidString,
+}
+
+func uuidString(value []byte) ([]byte, error) {
+	text, err := jsonString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(text) != 36 {
+		return nil, errExpectedUUID
+	}
+	for i := 0; i < len(text); i++ {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if text[i] != '-' {
+				return nil, errExpectedUUID
+			}
+		case !strings.ContainsRune("0123456789abcdefABCDEF", rune(text[i])):
+			return nil, errExpectedUUID
+		}
+	}
+	return json.Marshal(strings.ToLower(text))
+}
+
+// UploadScalar is a file upload, it's an input only scalar
+// The value of an Upload variable is passed through as it is, e.g. the null placeholder of a multipart request
+var UploadScalar = Scalar{
+	Name: "Upload",
+	Serialize: func(value []byte) ([]byte, error) {
+		return nil, errors.New("input only scalar Upload can't be returned")
+	},
+}
+
+// jsonString returns the content of a JSON string
+func jsonString(value []byte) (string, error) {
+	if len(value) < 2 || value[0] != '"' {
+		return "", errExpectedString
+	}
+	content, err := jsonparser.ParseString(value[1 : len(value)-1])
+	if err != nil {
+		return "", errExpectedString
+	}
+	return content, nil
+}
@@ -0,0 +1,97 @@
+package execution
+
+import (
+	"bytes"
+	log "github.com/jensneuse/abstractlogger"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+	"github.com/jensneuse/graphql-go-tools/pkg/stitching"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStitching(t *testing.T) {
+
+	users := subgraphServer(map[string]string{
+		`query o {user(id: "1"){name}} {}`: `{"data":{"user":{"name":"Jens"}}}`,
+	})
+	defer users.Close()
+	reviews := subgraphServer(map[string]string{
+		`query o {reviews {body author {name}}} {}`: `{"data":{"reviews":[{"body":"great","author":{"name":"Jens"}}]}}`,
+	})
+	defer reviews.Close()
+
+	upstream := func(name string, server *httptest.Server, sdl string) stitching.Upstream {
+		return stitching.Upstream{
+			Name: name,
+			SDL:  []byte(sdl),
+			DataSource: datasource.GraphQLDataSourceConfig{
+				Host: server.URL,
+				URL:  "/",
+			},
+		}
+	}
+
+	report := operationreport.Report{}
+	schema, config := stitching.Stitch([]stitching.Upstream{
+		upstream("users", users, `
+			type Query {
+				user(id: String!): User
+			}
+			type User {
+				id: ID!
+				name: String
+			}`),
+		upstream("reviews", reviews, `
+			type Query {
+				reviews: [Review]
+			}
+			type Review {
+				body: String
+				author: User
+			}
+			type User {
+				name: String
+				reviews: [Review]
+			}`),
+	}, &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	run := func(query, want string) func(t *testing.T) {
+		return func(t *testing.T) {
+			base, err := datasource.NewBaseDataSourcePlanner(schema, config, log.NoopLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			panicOnErr(base.RegisterDataSourcePlannerFactory("GraphQLDataSource", datasource.GraphQLDataSourcePlannerFactoryFactory{}))
+			handler := NewHandler(base, nil)
+
+			executor, node, ctx, err := handler.Handle([]byte(`{"query":"`+query+`"}`), nil)
+			if err != nil {
+				if !strings.Contains(err.Error(), want) {
+					t.Fatalf("want error: %s\ngot: %s\n", want, err)
+				}
+				return
+			}
+			out := bytes.Buffer{}
+			if err := executor.Execute(ctx, node, &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != want {
+				t.Fatalf("want: %s\ngot: %s\n", want, out.String())
+			}
+		}
+	}
+
+	t.Run("root fields of several upstreams", run(
+		`{ user(id: \"1\") { name } reviews { body author { name } } }`,
+		`{"data":{"user":{"name":"Jens"},"reviews":[{"body":"great","author":{"name":"Jens"}}]}}`,
+	))
+	t.Run("field undefined by the upstream of the enclosing field", run(
+		`{ user(id: \"1\") { name reviews { body } } }`,
+		`field: reviews on type: User isn't defined by upstream: users resolving the enclosing field`,
+	))
+}
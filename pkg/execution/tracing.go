@@ -0,0 +1,153 @@
+package execution
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// TracingCollector is an Instrumentation which adds the timings of a request in the Apollo tracing format
+// to the "tracing" field of the response extensions
+// Each fetch is reported as resolver with the response path of the field it resolves the data for
+// A TracingCollector collects the timings of a single request, it must not be shared between requests
+type TracingCollector struct {
+	executor  *Executor
+	mux       sync.Mutex
+	start     time.Time
+	phases    [tracingPhaseCount]tracingPhase
+	execution tracingPhase
+	resolvers []tracingResolver
+}
+
+// tracingPhaseKind is a phase of the Handler
+type tracingPhaseKind int
+
+const (
+	tracingPhaseParsing tracingPhaseKind = iota
+	tracingPhaseValidation
+	tracingPhasePlanning
+	tracingPhaseCount
+)
+
+// NewTracingCollector creates a TracingCollector and adds it to the Instrumentation of the executor
+// The timings are relative to the creation of the TracingCollector which should happen once the request arrives
+func NewTracingCollector(executor *Executor) *TracingCollector {
+	collector := &TracingCollector{
+		executor:  executor,
+		start:     time.Now(),
+		resolvers: []tracingResolver{},
+	}
+	executor.AddInstrumentation(collector)
+	return collector
+}
+
+type tracingPhase struct {
+	StartOffset int64 `json:"startOffset"`
+	Duration    int64 `json:"duration"`
+}
+
+type tracingResolver struct {
+	Path        []interface{} `json:"path"`
+	ParentType  string        `json:"parentType"`
+	FieldName   string        `json:"fieldName"`
+	ReturnType  string        `json:"returnType"`
+	StartOffset int64         `json:"startOffset"`
+	Duration    int64         `json:"duration"`
+	DataSource  string        `json:"dataSource"`
+	Upstream    string        `json:"upstream,omitempty"`
+	ArgsSize    int           `json:"argsSize"`
+	Bytes       int           `json:"bytes"`
+	Batch       bool          `json:"batch,omitempty"`
+	Shared      bool          `json:"shared,omitempty"`
+	Error       string        `json:"error,omitempty"`
+}
+
+type tracing struct {
+	Version    int              `json:"version"`
+	StartTime  string           `json:"startTime"`
+	EndTime    string           `json:"endTime"`
+	Duration   int64            `json:"duration"`
+	Parsing    tracingPhase     `json:"parsing"`
+	Validation tracingPhase     `json:"validation"`
+	Planning   tracingPhase     `json:"planning"`
+	Execution  tracingExecution `json:"execution"`
+}
+
+type tracingExecution struct {
+	tracingPhase
+	Resolvers []tracingResolver `json:"resolvers"`
+}
+
+// endPhase records a phase of the Handler which started at start, it's safe to be called on a nil TracingCollector
+func (t *TracingCollector) endPhase(phase tracingPhaseKind, start time.Time) {
+	if t == nil {
+		return
+	}
+	t.phases[phase] = t.phase(start, time.Since(start))
+}
+
+func (t *TracingCollector) phase(start time.Time, duration time.Duration) tracingPhase {
+	return tracingPhase{
+		StartOffset: start.Sub(t.start).Nanoseconds(),
+		Duration:    duration.Nanoseconds(),
+	}
+}
+
+func (t *TracingCollector) ExecutionStart(ctx Context) {
+	t.mux.Lock()
+	t.execution = t.phase(time.Now(), 0)
+	t.resolvers = t.resolvers[:0]
+	t.mux.Unlock()
+}
+
+// ExecutionEnd sets the "tracing" extension of the executor
+func (t *TracingCollector) ExecutionEnd(ctx Context) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	end := time.Now()
+	t.execution.Duration = end.Sub(t.start).Nanoseconds() - t.execution.StartOffset
+	data, err := json.Marshal(tracing{
+		Version:    1,
+		StartTime:  t.start.UTC().Format(time.RFC3339Nano),
+		EndTime:    end.UTC().Format(time.RFC3339Nano),
+		Duration:   end.Sub(t.start).Nanoseconds(),
+		Parsing:    t.phases[tracingPhaseParsing],
+		Validation: t.phases[tracingPhaseValidation],
+		Planning:   t.phases[tracingPhasePlanning],
+		Execution: tracingExecution{
+			tracingPhase: t.execution,
+			Resolvers:    t.resolvers,
+		},
+	})
+	if err != nil {
+		return
+	}
+	t.executor.SetExtension("tracing", data)
+}
+
+func (t *TracingCollector) FetchStart(ctx Context, event FetchEvent) {}
+
+func (t *TracingCollector) FetchEnd(ctx Context, event FetchEndEvent) {
+	resolver := tracingResolver{
+		Path:        event.Path,
+		ParentType:  event.ParentType,
+		ReturnType:  event.ReturnType,
+		StartOffset: event.Start.Sub(t.start).Nanoseconds(),
+		Duration:    event.Duration.Nanoseconds(),
+		DataSource:  event.DataSource,
+		Upstream:    event.Upstream,
+		ArgsSize:    event.ArgsSize,
+		Bytes:       event.Bytes,
+		Batch:       event.Batch,
+		Shared:      event.Shared,
+	}
+	if len(event.Path) != 0 {
+		resolver.FieldName, _ = event.Path[len(event.Path)-1].(string)
+	}
+	if event.Err != nil {
+		resolver.Error = event.Err.Error()
+	}
+	t.mux.Lock()
+	t.resolvers = append(t.resolvers, resolver)
+	t.mux.Unlock()
+}
@@ -11,11 +11,10 @@ type Transformation interface {
 
 type PipelineTransformation struct {
 	pipeline pipe.Pipeline
-	buf      bytes.Buffer
 }
 
 func (p *PipelineTransformation) Transform(input []byte) ([]byte,error) {
-	p.buf.Reset()
-	err := p.pipeline.Run(bytes.NewReader(input), &p.buf)
-	return p.buf.Bytes(),err
+	buf := bytes.Buffer{} // plans are shared between concurrent executions so each transformation needs its own buffer
+	err := p.pipeline.Run(bytes.NewReader(input), &buf)
+	return buf.Bytes(),err
 }
@@ -123,6 +123,8 @@ func TestPlanner_WithTransformation(t *testing.T) {
 								DataSource: &datasource.StaticDataSource{
 									Data: []byte("{\"bar\":\"baz\"}"),
 								},
+								ParentType: "Query",
+								ReturnType: "String!",
 							},
 							BufferName: "foo",
 						},
@@ -141,6 +143,11 @@ func TestPlanner_WithTransformation(t *testing.T) {
 										},
 									},
 									ValueType: StringValueType,
+									NonNull:   true,
+								},
+								Position: Position{
+									Line:   3,
+									Column: 4,
 								},
 							},
 						},
@@ -184,6 +191,8 @@ func TestPlanner_WithTransformation(t *testing.T) {
 								DataSource: &datasource.StaticDataSource{
 									Data: []byte("{\"bar\":\"baz\"}"),
 								},
+								ParentType: "Query",
+								ReturnType: "String!",
 							},
 							BufferName: "bar",
 						},
@@ -202,6 +211,11 @@ func TestPlanner_WithTransformation(t *testing.T) {
 										},
 									},
 									ValueType: StringValueType,
+									NonNull:   true,
+								},
+								Position: Position{
+									Line:   3,
+									Column: 4,
 								},
 							},
 						},
@@ -0,0 +1,398 @@
+package execution
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/buger/jsonparser"
+	"github.com/cespare/xxhash"
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// variableDefinition is the definition of a variable of an operation, e.g. $id: ID! = "1"
+// It doesn't reference the operation document so that it can be cached alongside the plan of the operation
+type variableDefinition struct {
+	name         []byte
+	variableType *variableType
+	// defaultValue is the JSON encoded default value, nil if the variable has no default value
+	defaultValue []byte
+	location     operationreport.Location
+}
+
+// variableType is an input type, e.g. [Int!]!
+type variableType struct {
+	nonNull bool
+	// name is the name of a named type, empty for list types
+	name string
+	// ofType is the type of the items of a list type, nil for named types
+	ofType *variableType
+}
+
+func newVariableType(document *ast.Document, ref int) *variableType {
+	switch document.Types[ref].TypeKind {
+	case ast.TypeKindNonNull:
+		nonNull := newVariableType(document, document.Types[ref].OfType)
+		nonNull.nonNull = true
+		return nonNull
+	case ast.TypeKindList:
+		return &variableType{
+			ofType: newVariableType(document, document.Types[ref].OfType),
+		}
+	default:
+		return &variableType{
+			name: string(document.TypeNameBytes(ref)),
+		}
+	}
+}
+
+func (v *variableType) String() string {
+	out := v.name
+	if v.ofType != nil {
+		out = "[" + v.ofType.String() + "]"
+	}
+	if v.nonNull {
+		out += "!"
+	}
+	return out
+}
+
+// newVariableDefinitions returns the variable definitions of the operation with the given ref
+func newVariableDefinitions(operation *ast.Document, operationRef int) []variableDefinition {
+	refs := operation.OperationDefinitions[operationRef].VariableDefinitions.Refs
+	definitions := make([]variableDefinition, 0, len(refs))
+	for _, ref := range refs {
+		variable := operation.VariableDefinitions[ref]
+		dollar := operation.VariableValues[variable.VariableValue.Ref].Dollar
+		definition := variableDefinition{
+			name:         append([]byte(nil), operation.VariableValueNameBytes(variable.VariableValue.Ref)...),
+			variableType: newVariableType(operation, variable.Type),
+			location: operationreport.Location{
+				Line:   dollar.LineStart,
+				Column: dollar.CharStart,
+			},
+		}
+		if variable.DefaultValue.IsDefined {
+			defaultValue := bytes.Buffer{}
+			writeValueJSON(operation, variable.DefaultValue.Value, &defaultValue)
+			definition.defaultValue = defaultValue.Bytes()
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions
+}
+
+// writeValueJSON writes the JSON representation of a constant value
+func writeValueJSON(document *ast.Document, value ast.Value, out *bytes.Buffer) {
+	switch value.Kind {
+	case ast.ValueKindString:
+		if document.StringValueIsBlockString(value.Ref) {
+			content, _ := json.Marshal(document.StringValueContentString(value.Ref))
+			out.Write(content)
+			return
+		}
+		out.WriteByte('"')
+		out.Write(document.StringValueContentBytes(value.Ref))
+		out.WriteByte('"')
+	case ast.ValueKindInteger:
+		if document.IntValueIsNegative(value.Ref) {
+			out.WriteByte('-')
+		}
+		out.Write(document.IntValueRaw(value.Ref))
+	case ast.ValueKindFloat:
+		if document.FloatValueIsNegative(value.Ref) {
+			out.WriteByte('-')
+		}
+		out.Write(document.FloatValueRaw(value.Ref))
+	case ast.ValueKindBoolean:
+		out.WriteString(strconv.FormatBool(bool(document.BooleanValue(value.Ref))))
+	case ast.ValueKindEnum:
+		out.WriteByte('"')
+		out.Write(document.EnumValueNameBytes(value.Ref))
+		out.WriteByte('"')
+	case ast.ValueKindList:
+		out.WriteByte('[')
+		for i, ref := range document.ListValues[value.Ref].Refs {
+			if i != 0 {
+				out.WriteByte(',')
+			}
+			writeValueJSON(document, document.Value(ref), out)
+		}
+		out.WriteByte(']')
+	case ast.ValueKindObject:
+		out.WriteByte('{')
+		for i, ref := range document.ObjectValues[value.Ref].Refs {
+			if i != 0 {
+				out.WriteByte(',')
+			}
+			out.WriteByte('"')
+			out.Write(document.ObjectFieldNameBytes(ref))
+			out.WriteString(`":`)
+			writeValueJSON(document, document.ObjectFieldValue(ref), out)
+		}
+		out.WriteByte('}')
+	default:
+		out.WriteString("null")
+	}
+}
+
+// coerceVariables checks the request variables against the variable definitions
+// Missing variables get set to their default value, valid variables get stored in variables in the shape of their definition, e.g. a single value for a list type gets wrapped in a list
+// All invalid variables get reported as external errors
+func coerceVariables(definitions []variableDefinition, schema *ast.Document, scalars Scalars, requestVariables []byte, variables Variables, report *operationreport.Report) {
+	for i := range definitions {
+		definition := &definitions[i]
+		value, dataType, _, err := jsonparser.Get(requestVariables, string(definition.name))
+		if err != nil {
+			dataType = jsonparser.NotExist
+		}
+		if dataType == jsonparser.NotExist && definition.defaultValue != nil {
+			value, dataType, _, _ = jsonparser.Get(definition.defaultValue)
+		}
+		if dataType == jsonparser.NotExist {
+			if definition.variableType.nonNull {
+				externalErr := operationreport.ErrVariableRequired(definition.name, []byte(definition.variableType.String()))
+				externalErr.Locations = []operationreport.Location{definition.location}
+				report.AddExternalError(externalErr)
+			}
+			continue
+		}
+		value = rawJSON(value, dataType)
+		coerced, err := coerceValue(schema, scalars, value, dataType, definition.variableType, nil)
+		if err != nil {
+			externalErr := operationreport.ErrVariableValueInvalid(definition.name, value, err.Error())
+			externalErr.Locations = []operationreport.Location{definition.location}
+			report.AddExternalError(externalErr)
+			continue
+		}
+		variables[xxhash.Sum64(definition.name)] = variableValue(coerced)
+		if isCustomScalar(schema, definition.variableType) {
+			variables[xxhash.Sum64(datasource.JSONVariableName(definition.name))] = coerced
+		}
+	}
+}
+
+// isCustomScalar reports if the variable type is a custom scalar, values of custom scalars can be of any JSON type
+// so the GraphQLDataSource forwards them from their JSON variable
+func isCustomScalar(schema *ast.Document, variableType *variableType) bool {
+	switch variableType.name {
+	case "", "Int", "Float", "String", "Boolean", "ID":
+		return false
+	}
+	node, ok := schema.Index.Nodes[xxhash.Sum64String(variableType.name)]
+	return ok && node.Kind == ast.NodeKindScalarTypeDefinition
+}
+
+// variableValue turns a JSON value into a variable value, strings are stored without quotes, all other values as JSON
+func variableValue(value []byte) []byte {
+	if len(value) >= 2 && value[0] == '"' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// rawJSON restores the quotes jsonparser strips off of string values
+func rawJSON(value []byte, dataType jsonparser.ValueType) []byte {
+	if dataType != jsonparser.String {
+		return value
+	}
+	out := make([]byte, 0, len(value)+2)
+	out = append(out, '"')
+	out = append(out, value...)
+	return append(out, '"')
+}
+
+type variableValueError struct {
+	path   []string
+	reason string
+}
+
+func (v variableValueError) Error() string {
+	if len(v.path) == 0 {
+		return v.reason
+	}
+	return fmt.Sprintf("%s at: %s", v.reason, strings.Join(v.path, "."))
+}
+
+func expectedTypeError(path []string, expected *variableType) error {
+	return variableValueError{
+		path:   path,
+		reason: fmt.Sprintf("expected type: %s", expected),
+	}
+}
+
+func coerceValue(schema *ast.Document, scalars Scalars, value []byte, dataType jsonparser.ValueType, expected *variableType, path []string) ([]byte, error) {
+	if dataType == jsonparser.Null {
+		if expected.nonNull {
+			return nil, expectedTypeError(path, expected)
+		}
+		return value, nil
+	}
+	if expected.ofType != nil {
+		return coerceList(schema, scalars, value, dataType, expected, path)
+	}
+	switch expected.name {
+	case "Int":
+		if dataType != jsonparser.Number {
+			return nil, expectedTypeError(path, expected)
+		}
+		// JSON doesn't distinguish integers and floats, so integral numbers like 1.0 or 1e3 are valid as well
+		number, err := strconv.ParseFloat(string(value), 64)
+		if err != nil || number != math.Trunc(number) || number < math.MinInt32 || number > math.MaxInt32 {
+			return nil, expectedTypeError(path, expected)
+		}
+		return strconv.AppendInt(nil, int64(number), 10), nil
+	case "Float":
+		if dataType != jsonparser.Number {
+			return nil, expectedTypeError(path, expected)
+		}
+		return value, nil
+	case "String":
+		if dataType != jsonparser.String {
+			return nil, expectedTypeError(path, expected)
+		}
+		return value, nil
+	case "Boolean":
+		if dataType != jsonparser.Boolean {
+			return nil, expectedTypeError(path, expected)
+		}
+		return value, nil
+	case "ID":
+		switch dataType {
+		case jsonparser.String:
+			return value, nil
+		case jsonparser.Number:
+			if _, err := strconv.ParseInt(string(value), 10, 64); err != nil {
+				return nil, expectedTypeError(path, expected)
+			}
+			return rawJSON(value, jsonparser.String), nil
+		default:
+			return nil, expectedTypeError(path, expected)
+		}
+	}
+	node, ok := schema.Index.Nodes[xxhash.Sum64String(expected.name)]
+	if !ok {
+		return nil, expectedTypeError(path, expected)
+	}
+	switch node.Kind {
+	case ast.NodeKindEnumTypeDefinition:
+		if dataType == jsonparser.String {
+			name := value[1 : len(value)-1]
+			for _, ref := range schema.EnumTypeDefinitions[node.Ref].EnumValuesDefinition.Refs {
+				if bytes.Equal(name, schema.EnumValueDefinitionNameBytes(ref)) {
+					return value, nil
+				}
+			}
+		}
+		return nil, expectedTypeError(path, expected)
+	case ast.NodeKindInputObjectTypeDefinition:
+		return coerceInputObject(schema, scalars, value, dataType, node.Ref, expected, path)
+	case ast.NodeKindScalarTypeDefinition:
+		scalar, ok := scalars[expected.name]
+		if !ok || scalar.ParseValue == nil { // custom scalars without a registered parser accept any value
+			return value, nil
+		}
+		parsed, err := scalar.ParseValue(value)
+		if err != nil {
+			return nil, variableValueError{
+				path:   path,
+				reason: err.Error(),
+			}
+		}
+		return parsed, nil
+	default:
+		return value, nil
+	}
+}
+
+// coerceList coerces all items of a list, a single value gets coerced into a list containing the value
+func coerceList(schema *ast.Document, scalars Scalars, value []byte, dataType jsonparser.ValueType, expected *variableType, path []string) ([]byte, error) {
+	if dataType != jsonparser.Array {
+		item, err := coerceValue(schema, scalars, value, dataType, expected.ofType, path)
+		if err != nil {
+			return nil, err
+		}
+		return append(append([]byte{'['}, item...), ']'), nil
+	}
+	out := bytes.Buffer{}
+	out.WriteByte('[')
+	var err error
+	i := 0
+	_, _ = jsonparser.ArrayEach(value, func(itemValue []byte, itemDataType jsonparser.ValueType, offset int, _ error) {
+		if err != nil {
+			return
+		}
+		var item []byte
+		item, err = coerceValue(schema, scalars, rawJSON(itemValue, itemDataType), itemDataType, expected.ofType, append(path[:len(path):len(path)], strconv.Itoa(i)))
+		if i != 0 {
+			out.WriteByte(',')
+		}
+		out.Write(item)
+		i++
+	})
+	if err != nil {
+		return nil, err
+	}
+	out.WriteByte(']')
+	return out.Bytes(), nil
+}
+
+// coerceInputObject coerces all fields of an input object and sets missing fields to their default value
+func coerceInputObject(schema *ast.Document, scalars Scalars, value []byte, dataType jsonparser.ValueType, inputObject int, expected *variableType, path []string) ([]byte, error) {
+	if dataType != jsonparser.Object {
+		return nil, expectedTypeError(path, expected)
+	}
+	err := jsonparser.ObjectEach(value, func(key []byte, _ []byte, _ jsonparser.ValueType, _ int) error {
+		if schema.InputObjectTypeDefinitionInputValueDefinitionByName(inputObject, key) == -1 {
+			return variableValueError{
+				path:   path,
+				reason: fmt.Sprintf("field: %s is not defined on input type: %s", key, expected.name),
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := bytes.Buffer{}
+	out.WriteByte('{')
+	for _, ref := range schema.InputObjectTypeDefinitions[inputObject].InputFieldsDefinition.Refs {
+		name := schema.InputValueDefinitionNameString(ref)
+		fieldType := newVariableType(schema, schema.InputValueDefinitionType(ref))
+		fieldValue, fieldDataType, _, err := jsonparser.Get(value, name)
+		if err != nil {
+			fieldDataType = jsonparser.NotExist
+		}
+		var field []byte
+		switch {
+		case fieldDataType != jsonparser.NotExist:
+			field, err = coerceValue(schema, scalars, rawJSON(fieldValue, fieldDataType), fieldDataType, fieldType, append(path[:len(path):len(path)], name))
+			if err != nil {
+				return nil, err
+			}
+		case schema.InputValueDefinitionHasDefaultValue(ref):
+			defaultValue := bytes.Buffer{}
+			writeValueJSON(schema, schema.InputValueDefinitionDefaultValue(ref), &defaultValue)
+			field = defaultValue.Bytes()
+		case fieldType.nonNull:
+			return nil, variableValueError{
+				path:   path,
+				reason: fmt.Sprintf("required field: %s of type: %s was not provided", name, fieldType),
+			}
+		default:
+			continue
+		}
+		if out.Len() != 1 {
+			out.WriteByte(',')
+		}
+		out.WriteString(strconv.Quote(name))
+		out.WriteByte(':')
+		out.Write(field)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
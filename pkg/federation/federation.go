@@ -0,0 +1,318 @@
+// Package federation composes the schemas of the subgraphs of a federated graph into the schema and the PlannerConfiguration of a gateway
+//
+// The subgraphs are GraphQL services implementing the Apollo Federation spec, see https://www.apollographql.com/docs/federation/federation-spec/
+// Root fields get resolved by the subgraph defining them, the fields of entities (types with a @key) by the subgraph owning them
+// using the "_entities" root field of the subgraph. The representations of the entities are built from the key fields and the
+// fields of the @requires directive, which get resolved by the subgraph resolving the enclosing field.
+//
+// Limitations:
+// Only the first @key of an entity is used and it must not contain nested selections.
+// @provides gets accepted but ignored, provided fields get resolved by the subgraph owning them.
+// Subscriptions aren't supported.
+package federation
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/astnormalization"
+	"github.com/jensneuse/graphql-go-tools/pkg/astparser"
+	"github.com/jensneuse/graphql-go-tools/pkg/astprinter"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+	"strings"
+)
+
+// Subgraph is an upstream GraphQL service taking part in a federated graph
+type Subgraph struct {
+	// Name identifies the subgraph, it's the Upstream of the fields the subgraph resolves
+	Name string
+	// SDL is the schema of the subgraph including the federation directives, e.g. as returned by "_service { sdl }"
+	SDL []byte
+	// DataSource configures the GraphQLDataSource resolving the fields of the subgraph, its Name defaults to the Name of the subgraph
+	DataSource datasource.GraphQLDataSourceConfig
+}
+
+const (
+	queryTypeName    = "Query"
+	mutationTypeName = "Mutation"
+)
+
+var (
+	// federationDirectives are the directives of the federation spec, they're removed from the schema of the gateway
+	federationDirectives = []string{"key", "extends", "external", "requires", "provides"}
+	// federationTypes are the types of the federation spec, they're removed from the schema of the gateway
+	federationTypes = []string{"_Any", "_FieldSet", "_Entity", "_Service"}
+	// federationRootFields are the root fields each subgraph adds to its Query type, the gateway doesn't expose them
+	federationRootFields = []string{"_entities", "_service"}
+)
+
+// Compose merges the SDLs of the subgraphs into the schema of the gateway and generates the TypeFieldConfigurations of the fields
+// Fields with the @external directive are owned by another subgraph, all other fields by the first subgraph defining them
+// Fields of types which aren't entities get resolved by the subgraph resolving the enclosing field
+// The TypeFieldConfigurations use the GraphQLDataSource, it must be registered with the name "GraphQLDataSource"
+func Compose(subgraphs []Subgraph, report *operationreport.Report) (schema []byte, config datasource.PlannerConfiguration) {
+	c := composer{
+		subgraphs:    subgraphs,
+		report:       report,
+		definedTypes: map[string]bool{},
+		objectTypes:  map[string]map[string]bool{},
+		keys:         map[string]map[int][]string{},
+	}
+
+	schemas := make([][]byte, 0, len(subgraphs))
+	for i := range subgraphs {
+		schemas = append(schemas, c.addSubgraph(i))
+	}
+	if report.HasErrors() {
+		return nil, config
+	}
+
+	schema = astnormalization.MergeTypeExtensions(bytes.Join(schemas, literal.LINETERMINATOR), report)
+	if report.HasErrors() {
+		return nil, config
+	}
+
+	config = c.plannerConfiguration()
+	if report.HasErrors() {
+		return nil, datasource.PlannerConfiguration{}
+	}
+	return schema, config
+}
+
+type composer struct {
+	subgraphs []Subgraph
+	report    *operationreport.Report
+	// definedTypes are the names of all types and directives but object types which are defined already
+	definedTypes map[string]bool
+	// objectTypes are the names of the object types defined already with the names of their fields
+	objectTypes map[string]map[string]bool
+	// keys are the key fields of the entities for each subgraph
+	keys map[string]map[int][]string
+	// ownedFields are the fields of object types with the subgraph owning them
+	ownedFields []ownedField
+}
+
+// ownedField is a field of an object type which gets resolved by the subgraph owning it
+type ownedField struct {
+	typeName, fieldName string
+	subgraph            int
+	// requires are the fields of the @requires directive of the field
+	requires []string
+}
+
+// addSubgraph returns the schema of the subgraph without the federation directives and types as well as the types and fields defined already
+// Object types get printed as type extensions if they're defined already
+func (c *composer) addSubgraph(subgraph int) []byte {
+	document, report := astparser.ParseGraphqlDocumentBytes(c.subgraphs[subgraph].SDL)
+	if report.HasErrors() {
+		c.report.InternalErrors = append(c.report.InternalErrors, report.InternalErrors...)
+		c.report.ExternalErrors = append(c.report.ExternalErrors, report.ExternalErrors...)
+		return nil
+	}
+
+	rootNodes := make([]ast.Node, 0, len(document.RootNodes))
+	for _, node := range document.RootNodes {
+		switch node.Kind {
+		case ast.NodeKindObjectTypeDefinition, ast.NodeKindObjectTypeExtension:
+			if objectTypeNode, ok := c.addObjectType(&document, subgraph, node); ok {
+				rootNodes = append(rootNodes, objectTypeNode)
+			}
+		case ast.NodeKindSchemaDefinition, ast.NodeKindSchemaExtension:
+			// the schema definition of the gateway gets derived from its root types
+		case ast.NodeKindDirectiveDefinition:
+			name := document.DirectiveDefinitionNameString(node.Ref)
+			if contains(federationDirectives, name) || c.definedTypes["@"+name] {
+				continue
+			}
+			c.definedTypes["@"+name] = true
+			rootNodes = append(rootNodes, node)
+		default:
+			name := document.NodeNameString(node)
+			if contains(federationTypes, name) || c.definedTypes[name] {
+				continue
+			}
+			c.definedTypes[name] = true
+			rootNodes = append(rootNodes, node)
+		}
+	}
+	document.RootNodes = rootNodes
+
+	out := bytes.Buffer{}
+	if err := astprinter.Print(&document, nil, &out); err != nil {
+		c.report.AddInternalError(err)
+		return nil
+	}
+	return out.Bytes()
+}
+
+// addObjectType records the key of the object type and the owner of its fields and removes all federation directives
+// It returns the object type without the fields defined already, ok is false if it has no fields left
+func (c *composer) addObjectType(document *ast.Document, subgraph int, node ast.Node) (objectTypeNode ast.Node, ok bool) {
+	var definition ast.ObjectTypeDefinition
+	if node.Kind == ast.NodeKindObjectTypeExtension {
+		definition = document.ObjectTypeExtensions[node.Ref].ObjectTypeDefinition
+	} else {
+		definition = document.ObjectTypeDefinitions[node.Ref]
+	}
+	name := document.Input.ByteSliceString(definition.Name)
+
+	if keyDirective, isEntity := directiveByName(document, definition.Directives.Refs, "key"); isEntity {
+		if c.keys[name] == nil {
+			c.keys[name] = map[int][]string{}
+		}
+		c.keys[name][subgraph] = c.fieldSet(document, keyDirective, name)
+	}
+
+	definedFields, isDefined := c.objectTypes[name]
+	if !isDefined {
+		definedFields = map[string]bool{}
+	}
+
+	fields := make([]int, 0, len(definition.FieldsDefinition.Refs))
+	for _, field := range definition.FieldsDefinition.Refs {
+		fieldName := document.FieldDefinitionNameString(field)
+		if _, external := directiveByName(document, document.FieldDefinitions[field].Directives.Refs, "external"); external {
+			continue
+		}
+		if name == queryTypeName && contains(federationRootFields, fieldName) || definedFields[fieldName] {
+			continue
+		}
+		definedFields[fieldName] = true
+		owned := ownedField{
+			typeName:  name,
+			fieldName: fieldName,
+			subgraph:  subgraph,
+		}
+		if requiresDirective, requires := directiveByName(document, document.FieldDefinitions[field].Directives.Refs, "requires"); requires {
+			owned.requires = c.fieldSet(document, requiresDirective, name)
+		}
+		c.ownedFields = append(c.ownedFields, owned)
+		document.FieldDefinitions[field].Directives.Refs = withoutFederationDirectives(document, document.FieldDefinitions[field].Directives.Refs)
+		document.FieldDefinitions[field].HasDirectives = len(document.FieldDefinitions[field].Directives.Refs) != 0
+		fields = append(fields, field)
+	}
+
+	if len(fields) == 0 {
+		return node, false
+	}
+	c.objectTypes[name] = definedFields
+
+	definition.FieldsDefinition.Refs = fields
+	definition.HasFieldDefinitions = true
+	definition.Directives.Refs = withoutFederationDirectives(document, definition.Directives.Refs)
+	definition.HasDirectives = len(definition.Directives.Refs) != 0
+
+	if !isDefined {
+		document.ObjectTypeDefinitions = append(document.ObjectTypeDefinitions, definition)
+		return ast.Node{Kind: ast.NodeKindObjectTypeDefinition, Ref: len(document.ObjectTypeDefinitions) - 1}, true
+	}
+	definition.Description = ast.Description{}
+	document.ObjectTypeExtensions = append(document.ObjectTypeExtensions, ast.ObjectTypeExtension{ObjectTypeDefinition: definition})
+	return ast.Node{Kind: ast.NodeKindObjectTypeExtension, Ref: len(document.ObjectTypeExtensions) - 1}, true
+}
+
+// fieldSet returns the field names of the "fields" argument of a @key or @requires directive, e.g. "id name" becomes [id name]
+func (c *composer) fieldSet(document *ast.Document, directive int, typeName string) []string {
+	value, ok := document.DirectiveArgumentValueByName(directive, []byte("fields"))
+	if !ok || value.Kind != ast.ValueKindString {
+		c.report.AddExternalError(operationreport.ErrFieldSetInvalid([]byte(document.DirectiveNameString(directive)), []byte(typeName)))
+		return nil
+	}
+	fieldSet := document.StringValueContentString(value.Ref)
+	if strings.ContainsAny(fieldSet, "{}") {
+		c.report.AddExternalError(operationreport.ErrFieldSetInvalid([]byte(document.DirectiveNameString(directive)), []byte(typeName)))
+		return nil
+	}
+	return strings.Fields(fieldSet)
+}
+
+// plannerConfiguration configures the root fields to be resolved by the subgraph defining them
+// and the fields of entities to be resolved by the subgraph owning them using "_entities"
+// Key fields don't get configured as every subgraph defining the entity resolves them
+func (c *composer) plannerConfiguration() (config datasource.PlannerConfiguration) {
+	for _, field := range c.ownedFields {
+		switch field.typeName {
+		case queryTypeName, mutationTypeName:
+			config.TypeFieldConfigurations = append(config.TypeFieldConfigurations, datasource.TypeFieldConfiguration{
+				TypeName:   strings.ToLower(field.typeName),
+				FieldName:  field.fieldName,
+				DataSource: c.dataSource(field.subgraph, false),
+				Upstream:   c.subgraphs[field.subgraph].Name,
+			})
+			continue
+		}
+		keys, isEntity := c.keys[field.typeName]
+		if !isEntity {
+			continue
+		}
+		key, ok := keys[field.subgraph]
+		if !ok {
+			c.report.AddExternalError(operationreport.ErrEntityKeyUndefined([]byte(field.typeName), []byte(c.subgraphs[field.subgraph].Name)))
+			continue
+		}
+		if contains(key, field.fieldName) {
+			continue
+		}
+		requiredFields := append([]string{}, key...)
+		for _, required := range field.requires {
+			if !contains(requiredFields, required) {
+				requiredFields = append(requiredFields, required)
+			}
+		}
+		config.TypeFieldConfigurations = append(config.TypeFieldConfigurations, datasource.TypeFieldConfiguration{
+			TypeName:       field.typeName,
+			FieldName:      field.fieldName,
+			DataSource:     c.dataSource(field.subgraph, true),
+			Batch:          true,
+			Upstream:       c.subgraphs[field.subgraph].Name,
+			RequiredFields: requiredFields,
+		})
+	}
+	return config
+}
+
+func (c *composer) dataSource(subgraph int, entity bool) datasource.SourceConfig {
+	dataSourceConfig := c.subgraphs[subgraph].DataSource
+	if dataSourceConfig.Name == "" {
+		dataSourceConfig.Name = c.subgraphs[subgraph].Name
+	}
+	dataSourceConfig.Entity = entity
+	rawConfig, err := json.Marshal(dataSourceConfig)
+	if err != nil {
+		c.report.AddInternalError(err)
+	}
+	return datasource.SourceConfig{
+		Name:   "GraphQLDataSource",
+		Config: rawConfig,
+	}
+}
+
+func directiveByName(document *ast.Document, directives []int, name string) (int, bool) {
+	for _, directive := range directives {
+		if document.DirectiveNameString(directive) == name {
+			return directive, true
+		}
+	}
+	return -1, false
+}
+
+func withoutFederationDirectives(document *ast.Document, directives []int) []int {
+	out := make([]int, 0, len(directives))
+	for _, directive := range directives {
+		if !contains(federationDirectives, document.DirectiveNameString(directive)) {
+			out = append(out, directive)
+		}
+	}
+	return out
+}
+
+func contains(values []string, value string) bool {
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+	return false
+}
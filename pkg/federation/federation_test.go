@@ -0,0 +1,250 @@
+package federation
+
+import (
+	"encoding/json"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+	"reflect"
+	"testing"
+)
+
+func TestCompose(t *testing.T) {
+
+	subgraphs := []Subgraph{
+		{
+			Name: "accounts",
+			SDL: []byte(`
+				directive @key(fields: _FieldSet!) on OBJECT | INTERFACE
+				scalar _Any
+				scalar _FieldSet
+				extend type Query {
+					me: User
+					_service: _Service!
+				}
+				"A user of the shop"
+				type User @key(fields: "id") {
+					id: ID!
+					name: String
+				}
+				enum Role {
+					ADMIN
+					CUSTOMER
+				}`),
+			DataSource: datasource.GraphQLDataSourceConfig{
+				Host: "accounts.service",
+				URL:  "/graphql",
+			},
+		},
+		{
+			Name: "reviews",
+			SDL: []byte(`
+				type Review {
+					body: String
+					author: User @provides(fields: "name")
+				}
+				type User @key(fields: "id") @extends {
+					id: ID! @external
+					name: String @external
+					reviews: [Review]
+				}
+				enum Role {
+					ADMIN
+					CUSTOMER
+				}
+				extend type Mutation {
+					addReview(body: String!): Review
+				}`),
+			DataSource: datasource.GraphQLDataSourceConfig{
+				Host: "reviews.service",
+				URL:  "/graphql",
+				Name: "reviews-v2",
+			},
+		},
+	}
+
+	report := operationreport.Report{}
+	schema, config := Compose(subgraphs, &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	wantSchema := `type Query {
+    me: User
+}
+
+"A user of the shop"
+type User {
+    id: ID!
+    name: String
+    reviews: [Review]
+}
+
+enum Role {
+    ADMIN
+    CUSTOMER
+}
+
+type Review {
+    body: String
+    author: User
+}
+
+type Mutation {
+    addReview(body: String!): Review
+}`
+	if string(schema) != wantSchema {
+		t.Fatalf("want schema:\n%s\ngot:\n%s", wantSchema, string(schema))
+	}
+
+	dataSource := func(config datasource.GraphQLDataSourceConfig) datasource.SourceConfig {
+		rawConfig, err := json.Marshal(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return datasource.SourceConfig{
+			Name:   "GraphQLDataSource",
+			Config: rawConfig,
+		}
+	}
+
+	wantConfig := datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:  "query",
+				FieldName: "me",
+				DataSource: dataSource(datasource.GraphQLDataSourceConfig{
+					Host: "accounts.service",
+					URL:  "/graphql",
+					Name: "accounts",
+				}),
+				Upstream: "accounts",
+			},
+			{
+				TypeName:  "User",
+				FieldName: "name",
+				DataSource: dataSource(datasource.GraphQLDataSourceConfig{
+					Host:   "accounts.service",
+					URL:    "/graphql",
+					Name:   "accounts",
+					Entity: true,
+				}),
+				Batch:          true,
+				Upstream:       "accounts",
+				RequiredFields: []string{"id"},
+			},
+			{
+				TypeName:  "User",
+				FieldName: "reviews",
+				DataSource: dataSource(datasource.GraphQLDataSourceConfig{
+					Host:   "reviews.service",
+					URL:    "/graphql",
+					Name:   "reviews-v2",
+					Entity: true,
+				}),
+				Batch:          true,
+				Upstream:       "reviews",
+				RequiredFields: []string{"id"},
+			},
+			{
+				TypeName:  "mutation",
+				FieldName: "addReview",
+				DataSource: dataSource(datasource.GraphQLDataSourceConfig{
+					Host: "reviews.service",
+					URL:  "/graphql",
+					Name: "reviews-v2",
+				}),
+				Upstream: "reviews",
+			},
+		},
+	}
+	if !reflect.DeepEqual(config, wantConfig) {
+		got, _ := json.MarshalIndent(config, "", "  ")
+		want, _ := json.MarshalIndent(wantConfig, "", "  ")
+		t.Fatalf("want config:\n%s\ngot:\n%s", string(want), string(got))
+	}
+}
+
+func TestCompose_TypeExtensions(t *testing.T) {
+
+	report := operationreport.Report{}
+	schema, _ := Compose([]Subgraph{
+		{
+			Name: "accounts",
+			SDL: []byte(`
+				extend type Query { me: User }
+				extend type Query { users: [User] }
+				interface Node { id: ID! }
+				type User implements Node @key(fields: "id") { id: ID! }`),
+		},
+		{
+			Name: "products",
+			SDL: []byte(`
+				extend type Query { topProducts: [Product] }
+				extend interface Node { id: ID! }
+				type Product implements Node @key(fields: "upc") { id: ID! upc: String! }`),
+		},
+	}, &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	wantSchema := `type Query {
+    me: User
+    users: [User]
+    topProducts: [Product]
+}
+
+interface Node {
+    id: ID!
+}
+
+type User implements Node {
+    id: ID!
+}
+
+type Product implements Node {
+    id: ID!
+    upc: String!
+}`
+	if string(schema) != wantSchema {
+		t.Fatalf("want schema:\n%s\ngot:\n%s", wantSchema, string(schema))
+	}
+}
+
+func TestCompose_Errors(t *testing.T) {
+
+	run := func(sdls []string, wantErrors ...string) func(t *testing.T) {
+		return func(t *testing.T) {
+			subgraphs := make([]Subgraph, len(sdls))
+			for i := range sdls {
+				subgraphs[i] = Subgraph{
+					Name: "subgraph" + string(rune('A'+i)),
+					SDL:  []byte(sdls[i]),
+				}
+			}
+			report := operationreport.Report{}
+			schema, _ := Compose(subgraphs, &report)
+			if schema != nil {
+				t.Fatalf("want no schema, got: %s", string(schema))
+			}
+			if len(report.ExternalErrors) != len(wantErrors) {
+				t.Fatalf("want errors: %v\ngot: %s", wantErrors, report.Error())
+			}
+			for i := range wantErrors {
+				if report.ExternalErrors[i].Message != wantErrors[i] {
+					t.Fatalf("want error: %s\ngot: %s", wantErrors[i], report.ExternalErrors[i].Message)
+				}
+			}
+		}
+	}
+
+	t.Run("nested key fields", run([]string{`
+		type Query { user: User }
+		type User @key(fields: "id organization { id }") { id: ID! }`,
+	}, "fields of directive: key on type: User must be a string of field names without selections"))
+	t.Run("entity field without key", run([]string{`
+		type Query { user: User }
+		type User @key(fields: "id") { id: ID! }`, `
+		extend type User { name: String }`,
+	}, "entity: User has no @key in subgraph: subgraphB"))
+}
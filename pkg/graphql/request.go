@@ -19,7 +19,7 @@ var (
 )
 
 type Request struct {
-	OperationName string          `json:"operation_name"`
+	OperationName string          `json:"operationName"`
 	Variables     json.RawMessage `json:"variables"`
 	Query         string          `json:"query"`
 
@@ -28,6 +28,23 @@ type Request struct {
 	isNormalized bool
 }
 
+// UnmarshalJSON accepts the operation name using the standard "operationName" key as well as the legacy "operation_name" key
+func (r *Request) UnmarshalJSON(data []byte) error {
+	type request Request // prevents the recursion into UnmarshalJSON
+	legacyRequest := struct {
+		request
+		LegacyOperationName string `json:"operation_name"`
+	}{}
+	if err := json.Unmarshal(data, &legacyRequest); err != nil {
+		return err
+	}
+	*r = Request(legacyRequest.request)
+	if r.OperationName == "" {
+		r.OperationName = legacyRequest.LegacyOperationName
+	}
+	return nil
+}
+
 func UnmarshalRequest(reader io.Reader, request *Request) error {
 	requestBytes, err := ioutil.ReadAll(reader)
 	if err != nil {
@@ -33,6 +33,18 @@ func TestUnmarshalRequest(t *testing.T) {
 		assert.Equal(t, "Hello", request.OperationName)
 		assert.Equal(t, "query Hello { hello }", request.Query)
 	})
+
+	t.Run("should unmarshal the standard operationName key", func(t *testing.T) {
+		requestBytes := []byte(`{"operationName": "Hello", "query": "query Hello { hello }"}`)
+		requestBuffer := bytes.NewBuffer(requestBytes)
+
+		var request Request
+		err := UnmarshalRequest(requestBuffer, &request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello", request.OperationName)
+		assert.Equal(t, "query Hello { hello }", request.Query)
+	})
 }
 
 func TestRequest_ValidateForSchema(t *testing.T) {
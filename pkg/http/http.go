@@ -3,16 +3,27 @@ package http
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	log "github.com/jensneuse/abstractlogger"
+
+	"github.com/jensneuse/graphql-go-tools/pkg/execution"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
 )
 
 const (
 	httpHeaderContentType string = "Content-Type"
 
 	httpContentTypeApplicationJson string = "application/json"
+
+	httpHeaderAccept string = "Accept"
+
+	httpContentTypeMultipartMixed string = "multipart/mixed"
 )
 
 func (g *GraphQLHTTPRequestHandler) handleHTTP(w http.ResponseWriter, r *http.Request) {
@@ -40,10 +51,21 @@ func (g *GraphQLHTTPRequestHandler) handleHTTP(w http.ResponseWriter, r *http.Re
 		g.log.Error("executionHandler.Handle",
 			log.Error(err),
 		)
+		if report, ok := err.(operationreport.Report); ok && len(report.ExternalErrors) != 0 {
+			writeExternalErrors(w, report.ExternalErrors)
+			return
+		}
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	ctx.Context = r.Context()
+	// headers of upstream responses only get forwarded to non incremental responses as incremental responses write their headers first
+	responseHeaders := &datasource.ResponseHeaders{}
+	ctx.Context = datasource.WithResponseHeaders(r.Context(), responseHeaders)
+	if rootNode.Incremental() && strings.Contains(r.Header.Get(httpHeaderAccept), httpContentTypeMultipartMixed) {
+		g.handleIncremental(w, executor, rootNode, ctx)
+		return
+	}
+
 	buf := bytes.NewBuffer(make([]byte, 0, 4096))
 	err = executor.Execute(ctx, rootNode, buf)
 	if err != nil {
@@ -54,7 +76,60 @@ func (g *GraphQLHTTPRequestHandler) handleHTTP(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	responseHeaders.CopyTo(w.Header())
 	w.Header().Add(httpHeaderContentType, "application/json")
 	w.WriteHeader(http.StatusOK)
 	_, _ = buf.WriteTo(w)
 }
+
+// handleIncremental responds to an operation containing @defer or @stream with a multipart/mixed response
+// The initial payload and all patches are written as separate parts as soon as they are resolved
+func (g *GraphQLHTTPRequestHandler) handleIncremental(w http.ResponseWriter, executor *execution.Executor, rootNode execution.RootNode, ctx execution.Context) {
+	w.Header().Add(httpHeaderContentType, httpContentTypeMultipartMixed+`; boundary="-"`)
+	w.WriteHeader(http.StatusOK)
+	err := executor.ExecuteIncremental(ctx, rootNode, &multipartWriter{w: w})
+	if err != nil {
+		g.log.Error("executor.ExecuteIncremental",
+			log.Error(err),
+		)
+	}
+}
+
+// multipartWriter writes each payload of an incremental execution as a part of a multipart/mixed response
+type multipartWriter struct {
+	w http.ResponseWriter
+}
+
+func (m *multipartWriter) WritePayload(payload []byte, hasNext bool) error {
+	if _, err := io.WriteString(m.w, "\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"); err != nil {
+		return err
+	}
+	if _, err := m.w.Write(payload); err != nil {
+		return err
+	}
+	if !hasNext {
+		if _, err := io.WriteString(m.w, "\r\n-----\r\n"); err != nil {
+			return err
+		}
+	}
+	if flusher, ok := m.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// writeExternalErrors responds to a request which can't be executed with the GraphQL errors explaining why
+func writeExternalErrors(w http.ResponseWriter, externalErrors []operationreport.ExternalError) {
+	response, err := json.Marshal(struct {
+		Errors []operationreport.ExternalError `json:"errors"`
+	}{
+		Errors: externalErrors,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Add(httpHeaderContentType, httpContentTypeApplicationJson)
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write(response)
+}
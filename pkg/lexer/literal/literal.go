@@ -55,6 +55,10 @@ var (
 	INCLUDE                       = []byte("include")
 	IF                            = []byte("if")
 	SKIP                          = []byte("skip")
+	DEFER                         = []byte("defer")
+	STREAM                        = []byte("stream")
+	LABEL                         = []byte("label")
+	INITIAL_COUNT                 = []byte("initialCount")
 	SCHEMA                        = []byte("schema")
 	EXTEND                        = []byte("extend")
 	SCALAR                        = []byte("scalar")
@@ -70,6 +74,12 @@ var (
 	NULL                          = []byte("null")
 	OBJECT                        = []byte("object")
 	DATA                          = []byte("data")
+	ERRORS                        = []byte("errors")
+	EXTENSIONS                    = []byte("extensions")
+	QUERY_PLAN                    = []byte("queryPlan")
+	PATH                          = []byte("path")
+	ITEMS                         = []byte("items")
+	HAS_NEXT                      = []byte("hasNext")
 	URL                           = []byte("url")
 	CONFIG_FILE_PATH              = []byte("configFilePath")
 	CONFIG_STRING                 = []byte("configString")
@@ -78,6 +88,12 @@ var (
 	PIPELINE_CONFIG_STRING        = []byte("pipelineConfigString")
 	PIPELINE_CONFIG_FILE          = []byte("pipelineConfigFile")
 	TRANSFORMATION                = []byte("transformation")
+	ENUM_MAPPING                  = []byte("enumMapping")
+	VALUES                        = []byte("values")
+	FROM                          = []byte("from")
+	TO                            = []byte("to")
+	CACHE_CONTROL                 = []byte("cacheControl")
+	MAX_AGE                       = []byte("maxAge")
 	INPUT_JSON                    = []byte("inputJSON")
 	DEFAULT_TYPENAME              = []byte("defaultTypeName")
 	STATUS_CODE_TYPENAME_MAPPINGS = []byte("statusCodeTypeNameMappings")
@@ -94,6 +110,16 @@ var (
 	METHOD                        = []byte("method")
 	MODE                          = []byte("mode")
 	HEADERS                       = []byte("headers")
+	RAW_VARIABLES                 = []byte("rawVariables")
+	REQUEST                       = []byte("request")
+	REPRESENTATIONS               = []byte("representations")
+	REPRESENTATION_DOT            = []byte("representation.")
+	DOT_JSON                      = []byte(".json")
+	ENTITIES                      = []byte("_entities")
+	ANY                           = []byte("_Any")
+	BATCHURL                      = []byte("batchUrl")
+	BATCHMETHOD                   = []byte("batchMethod")
+	BATCHINPUT                    = []byte("batchInput")
 	KEY                           = []byte("key")
 	VALUE                         = []byte("value")
 	HTTP_METHOD_GET               = []byte("GET")
@@ -0,0 +1,142 @@
+// Package metrics defines a backend agnostic interface to collect counters, gauges and histograms of GraphQL executions.
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Names of the metrics collected by the execution, http and subscription packages
+const (
+	// RequestsTotal counts the requests per operation name
+	RequestsTotal = "graphql_requests_total"
+	// RequestErrorsTotal counts the requests which couldn't be parsed, validated or planned per operation name
+	RequestErrorsTotal = "graphql_request_errors_total"
+	// PhaseDurationSeconds observes the duration of a phase of a request per operation name and phase
+	PhaseDurationSeconds = "graphql_phase_duration_seconds"
+	// DataSourceDurationSeconds observes the latency of the calls to a data source per upstream, or per kind of data source if no upstream is configured
+	DataSourceDurationSeconds = "graphql_datasource_duration_seconds"
+	// DataSourceErrorsTotal counts the calls to a data source which returned an error per upstream, or per kind of data source if no upstream is configured
+	DataSourceErrorsTotal = "graphql_datasource_errors_total"
+	// ActiveSubscriptions is the number of running subscription operations
+	ActiveSubscriptions = "graphql_active_subscriptions"
+)
+
+// Names of the labels
+const (
+	// LabelOperationName is the operation name sent by the client, it's empty unless the operation name label is enabled on the execution Handler
+	LabelOperationName = "operation_name"
+	LabelPhase         = "phase"
+	LabelDataSource    = "datasource"
+)
+
+// Values of the phase label
+const (
+	PhaseParse    = "parse"
+	PhaseValidate = "validate"
+	PhasePlan     = "plan"
+	PhaseExecute  = "execute"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Metrics receives the metrics, implementations bridge them to a metrics backend and must be safe for concurrent use
+type Metrics interface {
+	// IncCounter increments the counter with the given labels by one
+	IncCounter(name string, labels ...Label)
+	// AddGauge adds delta to the gauge with the given labels, delta may be negative
+	AddGauge(name string, delta float64, labels ...Label)
+	// ObserveHistogram adds an observation to the histogram with the given labels, durations are observed in seconds
+	ObserveHistogram(name string, value float64, labels ...Label)
+}
+
+// Noop discards all metrics
+type Noop struct{}
+
+func (Noop) IncCounter(name string, labels ...Label) {}
+
+func (Noop) AddGauge(name string, delta float64, labels ...Label) {}
+
+func (Noop) ObserveHistogram(name string, value float64, labels ...Label) {}
+
+// InMemory keeps all metrics in memory, it's meant to be used in tests
+type InMemory struct {
+	mux        sync.Mutex
+	counters   map[string]float64
+	gauges     map[string]float64
+	histograms map[string][]float64
+}
+
+func NewInMemory() *InMemory {
+	return &InMemory{
+		counters:   map[string]float64{},
+		gauges:     map[string]float64{},
+		histograms: map[string][]float64{},
+	}
+}
+
+func (m *InMemory) IncCounter(name string, labels ...Label) {
+	m.mux.Lock()
+	m.counters[key(name, labels)]++
+	m.mux.Unlock()
+}
+
+func (m *InMemory) AddGauge(name string, delta float64, labels ...Label) {
+	m.mux.Lock()
+	m.gauges[key(name, labels)] += delta
+	m.mux.Unlock()
+}
+
+func (m *InMemory) ObserveHistogram(name string, value float64, labels ...Label) {
+	m.mux.Lock()
+	key := key(name, labels)
+	m.histograms[key] = append(m.histograms[key], value)
+	m.mux.Unlock()
+}
+
+// Counter returns the value of the counter with the given labels, the order of the labels doesn't matter
+func (m *InMemory) Counter(name string, labels ...Label) float64 {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.counters[key(name, labels)]
+}
+
+// Gauge returns the value of the gauge with the given labels, the order of the labels doesn't matter
+func (m *InMemory) Gauge(name string, labels ...Label) float64 {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.gauges[key(name, labels)]
+}
+
+// Histogram returns the observations of the histogram with the given labels, the order of the labels doesn't matter
+func (m *InMemory) Histogram(name string, labels ...Label) []float64 {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return append([]float64(nil), m.histograms[key(name, labels)]...)
+}
+
+// key identifies a metric by its name and its labels sorted by name, e.g. name{a="1",b="2"}
+func key(name string, labels []Label) string {
+	sorted := append([]Label(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	builder := strings.Builder{}
+	builder.WriteString(name)
+	builder.WriteByte('{')
+	for i := range sorted {
+		if i != 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(sorted[i].Name)
+		builder.WriteString(`="`)
+		builder.WriteString(sorted[i].Value)
+		builder.WriteByte('"')
+	}
+	builder.WriteByte('}')
+	return builder.String()
+}
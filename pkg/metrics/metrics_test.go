@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInMemory(t *testing.T) {
+	metrics := NewInMemory()
+
+	metrics.IncCounter(RequestsTotal, Label{LabelOperationName, "Query"})
+	metrics.IncCounter(RequestsTotal, Label{LabelOperationName, "Query"})
+	metrics.IncCounter(RequestsTotal, Label{LabelOperationName, "Other"})
+	metrics.AddGauge(ActiveSubscriptions, 2)
+	metrics.AddGauge(ActiveSubscriptions, -1)
+	metrics.ObserveHistogram(PhaseDurationSeconds, 1, Label{LabelOperationName, "Query"}, Label{LabelPhase, PhaseParse})
+	metrics.ObserveHistogram(PhaseDurationSeconds, 2, Label{LabelPhase, PhaseParse}, Label{LabelOperationName, "Query"})
+
+	if got := metrics.Counter(RequestsTotal, Label{LabelOperationName, "Query"}); got != 2 {
+		t.Fatalf("want 2 requests for Query, got: %v", got)
+	}
+	if got := metrics.Counter(RequestsTotal, Label{LabelOperationName, "Missing"}); got != 0 {
+		t.Fatalf("want 0 requests for Missing, got: %v", got)
+	}
+	if got := metrics.Gauge(ActiveSubscriptions); got != 1 {
+		t.Fatalf("want 1 active subscription, got: %v", got)
+	}
+	want := []float64{1, 2}
+	if got := metrics.Histogram(PhaseDurationSeconds, Label{LabelPhase, PhaseParse}, Label{LabelOperationName, "Query"}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want observations: %v, got: %v", want, got)
+	}
+
+	var _ Metrics = Noop{}
+}
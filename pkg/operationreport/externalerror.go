@@ -36,6 +36,16 @@ func ErrAnonymousOperationMustBeTheOnlyOperationInDocument() (err ExternalError)
 	return err
 }
 
+func ErrOperationWithNameNotFound(operationName ast.ByteSlice) (err ExternalError) {
+	err.Message = fmt.Sprintf("operation with name: %s not found in document", operationName)
+	return err
+}
+
+func ErrOperationNameRequired() (err ExternalError) {
+	err.Message = fmt.Sprintf("operation name is required when the document contains multiple operations")
+	return err
+}
+
 func ErrSubscriptionMustOnlyHaveOneRootSelection(subscriptionName ast.ByteSlice) (err ExternalError) {
 	err.Message = fmt.Sprintf("subscription: %s must only have one root selection", subscriptionName)
 	return err
@@ -112,6 +122,16 @@ func ErrVariableOfTypeIsNoValidInputValue(variableName, ofTypeName ast.ByteSlice
 	return err
 }
 
+func ErrVariableRequired(variableName, typeName ast.ByteSlice) (err ExternalError) {
+	err.Message = fmt.Sprintf("variable: %s of required type: %s was not provided", variableName, typeName)
+	return err
+}
+
+func ErrVariableValueInvalid(variableName, value ast.ByteSlice, reason string) (err ExternalError) {
+	err.Message = fmt.Sprintf("variable: %s got invalid value: %s; %s", variableName, value, reason)
+	return err
+}
+
 func ErrArgumentMustBeUnique(argName ast.ByteSlice) (err ExternalError) {
 	err.Message = fmt.Sprintf("argument: %s must be unique", argName)
 	return err
@@ -176,3 +196,38 @@ func ErrDirectiveMustBeUniquePerLocation(directiveName ast.ByteSlice) (err Exter
 	err.Message = fmt.Sprintf("directive: %s must be unique per location", directiveName)
 	return err
 }
+
+func ErrFieldSetInvalid(directiveName, typeName ast.ByteSlice) (err ExternalError) {
+	err.Message = fmt.Sprintf("fields of directive: %s on type: %s must be a string of field names without selections", directiveName, typeName)
+	return err
+}
+
+func ErrEntityKeyUndefined(typeName, subgraphName ast.ByteSlice) (err ExternalError) {
+	err.Message = fmt.Sprintf("entity: %s has no @key in subgraph: %s", typeName, subgraphName)
+	return err
+}
+
+func ErrRequiredFieldUnresolvable(requiredFieldName, typeName, fieldName ast.ByteSlice) (err ExternalError) {
+	err.Message = fmt.Sprintf("field: %s required by field: %s on type: %s can't be resolved by the upstream of the enclosing field", requiredFieldName, fieldName, typeName)
+	return err
+}
+
+func ErrFieldUndefinedByUpstream(fieldName, typeName, upstreamName ast.ByteSlice) (err ExternalError) {
+	err.Message = fmt.Sprintf("field: %s on type: %s isn't defined by upstream: %s resolving the enclosing field", fieldName, typeName, upstreamName)
+	return err
+}
+
+func ErrTypeKindConflict(typeName, upstreamName ast.ByteSlice) (err ExternalError) {
+	err.Message = fmt.Sprintf("type: %s of upstream: %s conflicts with a type of another kind with the same name", typeName, upstreamName)
+	return err
+}
+
+func ErrFieldTypeConflict(fieldName, typeName, upstreamName, fieldType, definedFieldType ast.ByteSlice) (err ExternalError) {
+	err.Message = fmt.Sprintf("field: %s on type: %s of upstream: %s has type: %s, conflicting with type: %s defined already", fieldName, typeName, upstreamName, fieldType, definedFieldType)
+	return err
+}
+
+func ErrArgumentDefinitionsConflict(fieldName, typeName, upstreamName, argumentDefinitions, definedArgumentDefinitions ast.ByteSlice) (err ExternalError) {
+	err.Message = fmt.Sprintf("field: %s on type: %s of upstream: %s has arguments: (%s), conflicting with arguments: (%s) defined already", fieldName, typeName, upstreamName, argumentDefinitions, definedArgumentDefinitions)
+	return err
+}
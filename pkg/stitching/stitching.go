@@ -0,0 +1,476 @@
+// Package stitching stitches the schemas of several GraphQL upstreams into the schema and the PlannerConfiguration of a gateway
+//
+// Types with the same name get merged, the merged type has the fields, input fields, enum values and union members of all upstreams.
+// Root fields get resolved by the first upstream defining them, all other fields by the upstream resolving the enclosing root field.
+// Unlike with federation, the upstreams can't resolve the fields of each other's types, so fields of a merged type defined
+// by some upstreams only get configured with these upstreams. Selecting them below a root field of another upstream
+// fails the planning of the operation instead of sending an invalid query to the upstream.
+//
+// Limitations:
+// The root types must be named Query and Mutation, subscriptions aren't supported.
+// Descriptions and directives of types get taken from the upstream defining the type first.
+package stitching
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
+	"github.com/jensneuse/graphql-go-tools/pkg/astnormalization"
+	"github.com/jensneuse/graphql-go-tools/pkg/astparser"
+	"github.com/jensneuse/graphql-go-tools/pkg/astprinter"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/lexer/literal"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+	"sort"
+	"strings"
+)
+
+// Upstream is a GraphQL service whose schema gets stitched into the schema of the gateway
+type Upstream struct {
+	// Name identifies the upstream, it's the Upstream of the root fields the upstream resolves
+	Name string
+	// SDL is the schema of the upstream
+	SDL []byte
+	// DataSource configures the GraphQLDataSource resolving the root fields of the upstream, its Name defaults to the Name of the upstream
+	DataSource datasource.GraphQLDataSourceConfig
+}
+
+const (
+	queryTypeName    = "Query"
+	mutationTypeName = "Mutation"
+)
+
+// Stitch merges the SDLs of the upstreams into the schema of the gateway and generates the TypeFieldConfigurations of the root fields
+// as well as of the fields of merged types which aren't defined by all upstreams defining the type
+// Fields, input fields and arguments defined by several upstreams must have the same types, conflicts get reported as external errors
+// The TypeFieldConfigurations use the GraphQLDataSource, it must be registered with the name "GraphQLDataSource"
+func Stitch(upstreams []Upstream, report *operationreport.Report) (schema []byte, config datasource.PlannerConfiguration) {
+	s := stitcher{
+		upstreams:     upstreams,
+		report:        report,
+		types:         map[string]ast.NodeKind{},
+		members:       map[string]map[string]member{},
+		typeUpstreams: map[string][]int{},
+	}
+
+	schemas := make([][]byte, 0, len(upstreams))
+	for i := range upstreams {
+		schemas = append(schemas, s.addUpstream(i))
+	}
+	if report.HasErrors() {
+		return nil, config
+	}
+
+	schema = astnormalization.MergeTypeExtensions(bytes.Join(schemas, literal.LINETERMINATOR), report)
+	if report.HasErrors() {
+		return nil, config
+	}
+	s.addFieldUpstreams()
+	return schema, s.config
+}
+
+type stitcher struct {
+	upstreams []Upstream
+	report    *operationreport.Report
+	// types are the definition kinds of the types and directives defined already, directive names are prefixed with "@"
+	types map[string]ast.NodeKind
+	// members are the fields, input fields, enum values and union members defined already by the name of their type
+	members map[string]map[string]member
+	// typeUpstreams are the upstreams defining the object and interface types by their name
+	typeUpstreams map[string][]int
+	// fields are the fields of object and interface types in the order of their definition
+	fields []typeField
+	config datasource.PlannerConfiguration
+}
+
+type typeField struct {
+	typeName, fieldName string
+}
+
+// member is a field, input field, enum value or union member of a type
+type member struct {
+	// typ is the type of a field or the type and default value of an input field, e.g. "[String]!" or "Int = 10"
+	typ string
+	// argumentDefinitions are the arguments of a field sorted by name, e.g. "after: String, first: Int = 10"
+	argumentDefinitions string
+	// upstreams are the upstreams defining a field
+	upstreams []int
+}
+
+// addUpstream returns the schema of the upstream without the directive definitions, types and members defined already
+// Types get printed as type extensions if they're defined already
+func (s *stitcher) addUpstream(upstream int) []byte {
+	document, report := astparser.ParseGraphqlDocumentBytes(s.upstreams[upstream].SDL)
+	if report.HasErrors() {
+		s.report.InternalErrors = append(s.report.InternalErrors, report.InternalErrors...)
+		s.report.ExternalErrors = append(s.report.ExternalErrors, report.ExternalErrors...)
+		return nil
+	}
+
+	rootNodes := make([]ast.Node, 0, len(document.RootNodes))
+	for _, node := range document.RootNodes {
+		switch node.Kind {
+		case ast.NodeKindSchemaDefinition, ast.NodeKindSchemaExtension:
+			// the schema definition of the gateway gets derived from its root types
+		case ast.NodeKindDirectiveDefinition:
+			name := "@" + document.DirectiveDefinitionNameString(node.Ref)
+			if _, defined := s.types[name]; defined {
+				continue
+			}
+			s.types[name] = node.Kind
+			rootNodes = append(rootNodes, node)
+		default:
+			if typeNode, ok := s.addType(&document, upstream, node); ok {
+				rootNodes = append(rootNodes, typeNode)
+			}
+		}
+	}
+	document.RootNodes = rootNodes
+
+	out := bytes.Buffer{}
+	if err := astprinter.Print(&document, nil, &out); err != nil {
+		s.report.AddInternalError(err)
+		return nil
+	}
+	return out.Bytes()
+}
+
+// addType returns the type definition or extension to add to the schema of the gateway, ok is false if the type adds no members
+func (s *stitcher) addType(document *ast.Document, upstream int, node ast.Node) (typeNode ast.Node, ok bool) {
+	name := string(document.NodeNameBytes(node))
+	kind := definitionKind(node.Kind)
+
+	definedKind, isDefined := s.types[name]
+	if isDefined && definedKind != kind {
+		s.report.AddExternalError(operationreport.ErrTypeKindConflict([]byte(name), []byte(s.upstreams[upstream].Name)))
+		return node, false
+	}
+	s.types[name] = kind
+	if !isDefined {
+		s.members[name] = map[string]member{}
+	}
+
+	switch kind {
+	case ast.NodeKindObjectTypeDefinition:
+		return s.addObjectType(document, upstream, node, name, isDefined)
+	case ast.NodeKindInterfaceTypeDefinition:
+		return s.addInterfaceType(document, upstream, node, name, isDefined)
+	case ast.NodeKindInputObjectTypeDefinition:
+		return s.addInputObjectType(document, upstream, node, name, isDefined)
+	case ast.NodeKindEnumTypeDefinition:
+		return s.addEnumType(document, node, name, isDefined)
+	case ast.NodeKindUnionTypeDefinition:
+		return s.addUnionType(document, node, name, isDefined)
+	case ast.NodeKindScalarTypeDefinition:
+		return s.addScalarType(document, node, isDefined)
+	}
+	return node, false
+}
+
+func (s *stitcher) addObjectType(document *ast.Document, upstream int, node ast.Node, name string, isDefined bool) (ast.Node, bool) {
+	var definition ast.ObjectTypeDefinition
+	if node.Kind == ast.NodeKindObjectTypeExtension {
+		definition = document.ObjectTypeExtensions[node.Ref].ObjectTypeDefinition
+	} else {
+		definition = document.ObjectTypeDefinitions[node.Ref]
+	}
+
+	definition.FieldsDefinition.Refs = s.addFields(document, upstream, name, definition.FieldsDefinition.Refs)
+	definition.HasFieldDefinitions = len(definition.FieldsDefinition.Refs) != 0
+	if name == queryTypeName || name == mutationTypeName {
+		s.addRootFields(document, upstream, name, definition.FieldsDefinition.Refs)
+	}
+
+	if !isDefined {
+		document.ObjectTypeDefinitions = append(document.ObjectTypeDefinitions, definition)
+		return ast.Node{Kind: ast.NodeKindObjectTypeDefinition, Ref: len(document.ObjectTypeDefinitions) - 1}, true
+	}
+	if !definition.HasFieldDefinitions {
+		return node, false
+	}
+	definition.Description = ast.Description{}
+	definition.Directives.Refs, definition.HasDirectives = nil, false
+	document.ObjectTypeExtensions = append(document.ObjectTypeExtensions, ast.ObjectTypeExtension{ObjectTypeDefinition: definition})
+	return ast.Node{Kind: ast.NodeKindObjectTypeExtension, Ref: len(document.ObjectTypeExtensions) - 1}, true
+}
+
+func (s *stitcher) addInterfaceType(document *ast.Document, upstream int, node ast.Node, name string, isDefined bool) (ast.Node, bool) {
+	var definition ast.InterfaceTypeDefinition
+	if node.Kind == ast.NodeKindInterfaceTypeExtension {
+		definition = document.InterfaceTypeExtensions[node.Ref].InterfaceTypeDefinition
+	} else {
+		definition = document.InterfaceTypeDefinitions[node.Ref]
+	}
+
+	definition.FieldsDefinition.Refs = s.addFields(document, upstream, name, definition.FieldsDefinition.Refs)
+	definition.HasFieldDefinitions = len(definition.FieldsDefinition.Refs) != 0
+
+	if !isDefined {
+		document.InterfaceTypeDefinitions = append(document.InterfaceTypeDefinitions, definition)
+		return ast.Node{Kind: ast.NodeKindInterfaceTypeDefinition, Ref: len(document.InterfaceTypeDefinitions) - 1}, true
+	}
+	if !definition.HasFieldDefinitions {
+		return node, false
+	}
+	definition.Description = ast.Description{}
+	definition.Directives.Refs, definition.HasDirectives = nil, false
+	document.InterfaceTypeExtensions = append(document.InterfaceTypeExtensions, ast.InterfaceTypeExtension{InterfaceTypeDefinition: definition})
+	return ast.Node{Kind: ast.NodeKindInterfaceTypeExtension, Ref: len(document.InterfaceTypeExtensions) - 1}, true
+}
+
+func (s *stitcher) addInputObjectType(document *ast.Document, upstream int, node ast.Node, name string, isDefined bool) (ast.Node, bool) {
+	var definition ast.InputObjectTypeDefinition
+	if node.Kind == ast.NodeKindInputObjectTypeExtension {
+		definition = document.InputObjectTypeExtensions[node.Ref].InputObjectTypeDefinition
+	} else {
+		definition = document.InputObjectTypeDefinitions[node.Ref]
+	}
+
+	inputFields := make([]int, 0, len(definition.InputFieldsDefinition.Refs))
+	for _, inputField := range definition.InputFieldsDefinition.Refs {
+		inputFieldName := document.InputValueDefinitionNameString(inputField)
+		added := member{
+			typ: s.inputValueType(document, inputField),
+		}
+		defined, isDefined := s.members[name][inputFieldName]
+		if !isDefined {
+			s.members[name][inputFieldName] = added
+			inputFields = append(inputFields, inputField)
+			continue
+		}
+		if added.typ != defined.typ {
+			s.report.AddExternalError(operationreport.ErrFieldTypeConflict([]byte(inputFieldName), []byte(name), []byte(s.upstreams[upstream].Name), []byte(added.typ), []byte(defined.typ)))
+		}
+	}
+	definition.InputFieldsDefinition.Refs = inputFields
+	definition.HasInputFieldsDefinition = len(inputFields) != 0
+
+	if !isDefined {
+		document.InputObjectTypeDefinitions = append(document.InputObjectTypeDefinitions, definition)
+		return ast.Node{Kind: ast.NodeKindInputObjectTypeDefinition, Ref: len(document.InputObjectTypeDefinitions) - 1}, true
+	}
+	if !definition.HasInputFieldsDefinition {
+		return node, false
+	}
+	definition.Description = ast.Description{}
+	definition.Directives.Refs, definition.HasDirectives = nil, false
+	document.InputObjectTypeExtensions = append(document.InputObjectTypeExtensions, ast.InputObjectTypeExtension{InputObjectTypeDefinition: definition})
+	return ast.Node{Kind: ast.NodeKindInputObjectTypeExtension, Ref: len(document.InputObjectTypeExtensions) - 1}, true
+}
+
+func (s *stitcher) addEnumType(document *ast.Document, node ast.Node, name string, isDefined bool) (ast.Node, bool) {
+	var definition ast.EnumTypeDefinition
+	if node.Kind == ast.NodeKindEnumTypeExtension {
+		definition = document.EnumTypeExtensions[node.Ref].EnumTypeDefinition
+	} else {
+		definition = document.EnumTypeDefinitions[node.Ref]
+	}
+
+	enumValues := make([]int, 0, len(definition.EnumValuesDefinition.Refs))
+	for _, enumValue := range definition.EnumValuesDefinition.Refs {
+		enumValueName := document.EnumValueDefinitionNameString(enumValue)
+		if _, defined := s.members[name][enumValueName]; defined {
+			continue
+		}
+		s.members[name][enumValueName] = member{}
+		enumValues = append(enumValues, enumValue)
+	}
+	definition.EnumValuesDefinition.Refs = enumValues
+	definition.HasEnumValuesDefinition = len(enumValues) != 0
+
+	if !isDefined {
+		document.EnumTypeDefinitions = append(document.EnumTypeDefinitions, definition)
+		return ast.Node{Kind: ast.NodeKindEnumTypeDefinition, Ref: len(document.EnumTypeDefinitions) - 1}, true
+	}
+	if !definition.HasEnumValuesDefinition {
+		return node, false
+	}
+	definition.Description = ast.Description{}
+	definition.Directives.Refs, definition.HasDirectives = nil, false
+	document.EnumTypeExtensions = append(document.EnumTypeExtensions, ast.EnumTypeExtension{EnumTypeDefinition: definition})
+	return ast.Node{Kind: ast.NodeKindEnumTypeExtension, Ref: len(document.EnumTypeExtensions) - 1}, true
+}
+
+func (s *stitcher) addUnionType(document *ast.Document, node ast.Node, name string, isDefined bool) (ast.Node, bool) {
+	var definition ast.UnionTypeDefinition
+	if node.Kind == ast.NodeKindUnionTypeExtension {
+		definition = document.UnionTypeExtensions[node.Ref].UnionTypeDefinition
+	} else {
+		definition = document.UnionTypeDefinitions[node.Ref]
+	}
+
+	memberTypes := make([]int, 0, len(definition.UnionMemberTypes.Refs))
+	for _, memberType := range definition.UnionMemberTypes.Refs {
+		memberTypeName := document.TypeNameString(memberType)
+		if _, defined := s.members[name][memberTypeName]; defined {
+			continue
+		}
+		s.members[name][memberTypeName] = member{}
+		memberTypes = append(memberTypes, memberType)
+	}
+	definition.UnionMemberTypes.Refs = memberTypes
+	definition.HasUnionMemberTypes = len(memberTypes) != 0
+
+	if !isDefined {
+		document.UnionTypeDefinitions = append(document.UnionTypeDefinitions, definition)
+		return ast.Node{Kind: ast.NodeKindUnionTypeDefinition, Ref: len(document.UnionTypeDefinitions) - 1}, true
+	}
+	if !definition.HasUnionMemberTypes {
+		return node, false
+	}
+	definition.Description = ast.Description{}
+	definition.Directives.Refs, definition.HasDirectives = nil, false
+	document.UnionTypeExtensions = append(document.UnionTypeExtensions, ast.UnionTypeExtension{UnionTypeDefinition: definition})
+	return ast.Node{Kind: ast.NodeKindUnionTypeExtension, Ref: len(document.UnionTypeExtensions) - 1}, true
+}
+
+func (s *stitcher) addScalarType(document *ast.Document, node ast.Node, isDefined bool) (ast.Node, bool) {
+	if isDefined {
+		return node, false
+	}
+	if node.Kind == ast.NodeKindScalarTypeExtension {
+		document.ScalarTypeDefinitions = append(document.ScalarTypeDefinitions, document.ScalarTypeExtensions[node.Ref].ScalarTypeDefinition)
+		return ast.Node{Kind: ast.NodeKindScalarTypeDefinition, Ref: len(document.ScalarTypeDefinitions) - 1}, true
+	}
+	return node, true
+}
+
+// addFields returns the fields which aren't defined already
+// Fields defined already must have the same type and arguments as the field defined first
+func (s *stitcher) addFields(document *ast.Document, upstream int, typeName string, fields []int) []int {
+	if !containsUpstream(s.typeUpstreams[typeName], upstream) {
+		s.typeUpstreams[typeName] = append(s.typeUpstreams[typeName], upstream)
+	}
+	added := make([]int, 0, len(fields))
+	for _, field := range fields {
+		fieldName := document.FieldDefinitionNameString(field)
+		addedField := member{
+			typ:                 s.printType(document, document.FieldDefinitionType(field)),
+			argumentDefinitions: s.argumentDefinitions(document, field),
+			upstreams:           []int{upstream},
+		}
+		definedField, isDefined := s.members[typeName][fieldName]
+		if !isDefined {
+			s.members[typeName][fieldName] = addedField
+			s.fields = append(s.fields, typeField{typeName: typeName, fieldName: fieldName})
+			added = append(added, field)
+			continue
+		}
+		if !containsUpstream(definedField.upstreams, upstream) {
+			definedField.upstreams = append(definedField.upstreams, upstream)
+			s.members[typeName][fieldName] = definedField
+		}
+		if addedField.typ != definedField.typ {
+			s.report.AddExternalError(operationreport.ErrFieldTypeConflict([]byte(fieldName), []byte(typeName), []byte(s.upstreams[upstream].Name), []byte(addedField.typ), []byte(definedField.typ)))
+		}
+		if addedField.argumentDefinitions != definedField.argumentDefinitions {
+			s.report.AddExternalError(operationreport.ErrArgumentDefinitionsConflict([]byte(fieldName), []byte(typeName), []byte(s.upstreams[upstream].Name), []byte(addedField.argumentDefinitions), []byte(definedField.argumentDefinitions)))
+		}
+	}
+	return added
+}
+
+// addRootFields configures the root fields to be resolved by the upstream defining them
+func (s *stitcher) addRootFields(document *ast.Document, upstream int, typeName string, fields []int) {
+	dataSourceConfig := s.upstreams[upstream].DataSource
+	if dataSourceConfig.Name == "" {
+		dataSourceConfig.Name = s.upstreams[upstream].Name
+	}
+	rawConfig, err := json.Marshal(dataSourceConfig)
+	if err != nil {
+		s.report.AddInternalError(err)
+		return
+	}
+	for _, field := range fields {
+		s.config.TypeFieldConfigurations = append(s.config.TypeFieldConfigurations, datasource.TypeFieldConfiguration{
+			TypeName:  strings.ToLower(typeName),
+			FieldName: document.FieldDefinitionNameString(field),
+			DataSource: datasource.SourceConfig{
+				Name:   "GraphQLDataSource",
+				Config: rawConfig,
+			},
+			Upstream: s.upstreams[upstream].Name,
+		})
+	}
+}
+
+// addFieldUpstreams configures the fields of merged types which aren't defined by all upstreams defining the type
+// with the upstreams defining them so that the planner doesn't resolve them with the DataSource of another upstream
+func (s *stitcher) addFieldUpstreams() {
+	for _, field := range s.fields {
+		if field.typeName == queryTypeName || field.typeName == mutationTypeName {
+			continue
+		}
+		upstreams := s.members[field.typeName][field.fieldName].upstreams
+		if len(upstreams) == len(s.typeUpstreams[field.typeName]) {
+			continue
+		}
+		for _, upstream := range upstreams {
+			s.config.TypeFieldConfigurations = append(s.config.TypeFieldConfigurations, datasource.TypeFieldConfiguration{
+				TypeName:  field.typeName,
+				FieldName: field.fieldName,
+				Upstream:  s.upstreams[upstream].Name,
+			})
+		}
+	}
+}
+
+// argumentDefinitions prints the arguments of a field sorted by name so that the order of the arguments doesn't matter
+func (s *stitcher) argumentDefinitions(document *ast.Document, field int) string {
+	argumentDefinitions := document.FieldDefinitionArgumentsDefinitions(field)
+	arguments := make([]string, 0, len(argumentDefinitions))
+	for _, argument := range argumentDefinitions {
+		arguments = append(arguments, document.InputValueDefinitionNameString(argument)+": "+s.inputValueType(document, argument))
+	}
+	sort.Strings(arguments)
+	return strings.Join(arguments, ", ")
+}
+
+// inputValueType prints the type and the default value of an argument or input field, e.g. "Int = 10"
+func (s *stitcher) inputValueType(document *ast.Document, inputValue int) string {
+	inputValueType := s.printType(document, document.InputValueDefinitionType(inputValue))
+	if !document.InputValueDefinitionHasDefaultValue(inputValue) {
+		return inputValueType
+	}
+	defaultValue, err := document.PrintValueBytes(document.InputValueDefinitionDefaultValue(inputValue), nil)
+	if err != nil {
+		s.report.AddInternalError(err)
+	}
+	return inputValueType + " = " + string(defaultValue)
+}
+
+func (s *stitcher) printType(document *ast.Document, ref int) string {
+	out, err := document.PrintTypeBytes(ref, nil)
+	if err != nil {
+		s.report.AddInternalError(err)
+	}
+	return string(out)
+}
+
+// definitionKind returns the kind of the type definitions extended by type extensions of the given kind
+func definitionKind(kind ast.NodeKind) ast.NodeKind {
+	switch kind {
+	case ast.NodeKindObjectTypeExtension:
+		return ast.NodeKindObjectTypeDefinition
+	case ast.NodeKindInterfaceTypeExtension:
+		return ast.NodeKindInterfaceTypeDefinition
+	case ast.NodeKindInputObjectTypeExtension:
+		return ast.NodeKindInputObjectTypeDefinition
+	case ast.NodeKindEnumTypeExtension:
+		return ast.NodeKindEnumTypeDefinition
+	case ast.NodeKindUnionTypeExtension:
+		return ast.NodeKindUnionTypeDefinition
+	case ast.NodeKindScalarTypeExtension:
+		return ast.NodeKindScalarTypeDefinition
+	}
+	return kind
+}
+
+func containsUpstream(upstreams []int, upstream int) bool {
+	for i := range upstreams {
+		if upstreams[i] == upstream {
+			return true
+		}
+	}
+	return false
+}
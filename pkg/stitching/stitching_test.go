@@ -0,0 +1,300 @@
+package stitching
+
+import (
+	"encoding/json"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
+	"github.com/jensneuse/graphql-go-tools/pkg/operationreport"
+	"reflect"
+	"testing"
+)
+
+func TestStitch(t *testing.T) {
+
+	upstreams := []Upstream{
+		{
+			Name: "users",
+			SDL: []byte(`
+				schema { query: Query }
+				directive @cacheControl(maxAge: Int) on FIELD_DEFINITION
+				"A user of the shop"
+				type User {
+					id: ID!
+					name: String
+				}
+				type Query {
+					user(id: ID!): User
+					users(first: Int = 10, after: String): [User] @cacheControl(maxAge: 60)
+				}
+				enum Role {
+					ADMIN
+				}
+				input UserFilter {
+					role: Role
+				}
+				union SearchResult = User
+				scalar Date`),
+			DataSource: datasource.GraphQLDataSourceConfig{
+				Host: "users.service",
+				URL:  "/graphql",
+			},
+		},
+		{
+			Name: "reviews",
+			SDL: []byte(`
+				directive @cacheControl(maxAge: Int) on FIELD_DEFINITION
+				type User {
+					id: ID!
+					reviews: [Review]
+				}
+				type Review {
+					body: String
+					createdAt: Date
+				}
+				type Query {
+					users(after: String, first: Int = 10): [User]
+					reviews: [Review]
+				}
+				type Mutation {
+					addReview(body: String!): Review
+				}
+				enum Role {
+					ADMIN
+					CUSTOMER
+				}
+				input UserFilter {
+					role: Role
+					hasReviews: Boolean
+				}
+				union SearchResult = User | Review
+				scalar Date`),
+			DataSource: datasource.GraphQLDataSourceConfig{
+				Host: "reviews.service",
+				URL:  "/graphql",
+				Name: "reviews-v2",
+			},
+		},
+	}
+
+	report := operationreport.Report{}
+	schema, config := Stitch(upstreams, &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	wantSchema := `directive @cacheControl(
+    maxAge: Int
+) on FIELD_DEFINITION
+
+"A user of the shop"
+type User {
+    id: ID!
+    name: String
+    reviews: [Review]
+}
+
+type Query {
+    user(id: ID!): User
+    users(first: Int = 10 after: String): [User] @cacheControl(maxAge: 60)
+    reviews: [Review]
+}
+
+enum Role {
+    ADMIN
+    CUSTOMER
+}
+
+input UserFilter {
+    role: Role 
+    hasReviews: Boolean
+}
+
+union SearchResult = User | Review
+
+scalar Date
+
+type Review {
+    body: String
+    createdAt: Date
+}
+
+type Mutation {
+    addReview(body: String!): Review
+}`
+	if string(schema) != wantSchema {
+		t.Fatalf("want schema:\n%s\ngot:\n%s", wantSchema, string(schema))
+	}
+
+	dataSource := func(config datasource.GraphQLDataSourceConfig) datasource.SourceConfig {
+		rawConfig, err := json.Marshal(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return datasource.SourceConfig{
+			Name:   "GraphQLDataSource",
+			Config: rawConfig,
+		}
+	}
+	users := dataSource(datasource.GraphQLDataSourceConfig{
+		Host: "users.service",
+		URL:  "/graphql",
+		Name: "users",
+	})
+	reviews := dataSource(datasource.GraphQLDataSourceConfig{
+		Host: "reviews.service",
+		URL:  "/graphql",
+		Name: "reviews-v2",
+	})
+
+	wantConfig := datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:   "query",
+				FieldName:  "user",
+				DataSource: users,
+				Upstream:   "users",
+			},
+			{
+				TypeName:   "query",
+				FieldName:  "users",
+				DataSource: users,
+				Upstream:   "users",
+			},
+			{
+				TypeName:   "query",
+				FieldName:  "reviews",
+				DataSource: reviews,
+				Upstream:   "reviews",
+			},
+			{
+				TypeName:   "mutation",
+				FieldName:  "addReview",
+				DataSource: reviews,
+				Upstream:   "reviews",
+			},
+			{
+				TypeName:  "User",
+				FieldName: "name",
+				Upstream:  "users",
+			},
+			{
+				TypeName:  "User",
+				FieldName: "reviews",
+				Upstream:  "reviews",
+			},
+		},
+	}
+	if !reflect.DeepEqual(config, wantConfig) {
+		got, _ := json.MarshalIndent(config, "", "  ")
+		want, _ := json.MarshalIndent(wantConfig, "", "  ")
+		t.Fatalf("want config:\n%s\ngot:\n%s", string(want), string(got))
+	}
+}
+
+func TestStitch_FieldUpstreams(t *testing.T) {
+
+	report := operationreport.Report{}
+	_, config := Stitch([]Upstream{
+		{
+			Name: "users",
+			SDL:  []byte(`type Query { me: User } type User { id: ID! name: String }`),
+		},
+		{
+			Name: "reviews",
+			SDL:  []byte(`type Query { reviews: [Review] } type Review { author: User } type User { id: ID! name: String reviews: [Review] }`),
+		},
+		{
+			Name: "products",
+			SDL:  []byte(`type Query { products: [Product] } type Product { owner: User } type User { id: ID! }`),
+		},
+	}, &report)
+	if report.HasErrors() {
+		t.Fatal(report)
+	}
+
+	var got []datasource.TypeFieldConfiguration
+	for _, field := range config.TypeFieldConfigurations {
+		if field.TypeName == "User" {
+			got = append(got, field)
+		}
+	}
+	want := []datasource.TypeFieldConfiguration{
+		{
+			TypeName:  "User",
+			FieldName: "name",
+			Upstream:  "users",
+		},
+		{
+			TypeName:  "User",
+			FieldName: "name",
+			Upstream:  "reviews",
+		},
+		{
+			TypeName:  "User",
+			FieldName: "reviews",
+			Upstream:  "reviews",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %+v\ngot: %+v", want, got)
+	}
+}
+
+func TestStitch_Errors(t *testing.T) {
+
+	run := func(sdls []string, wantErrors ...string) func(t *testing.T) {
+		return func(t *testing.T) {
+			upstreams := make([]Upstream, len(sdls))
+			for i := range sdls {
+				upstreams[i] = Upstream{
+					Name: "upstream" + string(rune('A'+i)),
+					SDL:  []byte(sdls[i]),
+				}
+			}
+			report := operationreport.Report{}
+			schema, config := Stitch(upstreams, &report)
+			if schema != nil || config.TypeFieldConfigurations != nil {
+				t.Fatalf("want no schema and config, got: %s", string(schema))
+			}
+			if len(report.ExternalErrors) != len(wantErrors) {
+				t.Fatalf("want errors: %v\ngot: %s", wantErrors, report.Error())
+			}
+			for i := range wantErrors {
+				if report.ExternalErrors[i].Message != wantErrors[i] {
+					t.Fatalf("want error: %s\ngot: %s", wantErrors[i], report.ExternalErrors[i].Message)
+				}
+			}
+		}
+	}
+
+	t.Run("type kind", run([]string{`
+		type Query { user: User }
+		type User { id: ID! }`, `
+		type Query { users: [User] }
+		interface User { id: ID! }`,
+	}, "type: User of upstream: upstreamB conflicts with a type of another kind with the same name"))
+	t.Run("field type", run([]string{`
+		type Query { user: User }
+		type User { id: ID! }`, `
+		type Query { users: [User] }
+		type User { id: String }`,
+	}, "field: id on type: User of upstream: upstreamB has type: String, conflicting with type: ID! defined already"))
+	t.Run("input field type", run([]string{`
+		type Query { users(filter: UserFilter): [User] }
+		type User { id: ID! }
+		input UserFilter { first: Int = 10 }`, `
+		input UserFilter { first: Int = 20 }`,
+	}, "field: first on type: UserFilter of upstream: upstreamB has type: Int = 20, conflicting with type: Int = 10 defined already"))
+	t.Run("argument definitions", run([]string{`
+		type Query { user(id: ID!): User }
+		type User { id: ID! }`, `
+		type Query { user(id: ID, name: String): User }`,
+	}, "field: user on type: Query of upstream: upstreamB has arguments: (id: ID, name: String), conflicting with arguments: (id: ID!) defined already"))
+	t.Run("field type and argument definitions", run([]string{`
+		type Query { user(id: ID!): User }
+		type User { id: ID! }`, `
+		type Query { user: [User] }`,
+	},
+		"field: user on type: Query of upstream: upstreamB has type: [User], conflicting with type: User defined already",
+		"field: user on type: Query of upstream: upstreamB has arguments: (), conflicting with arguments: (id: ID!) defined already",
+	))
+}
@@ -8,7 +8,9 @@ import (
 
 	"github.com/jensneuse/abstractlogger"
 
+	"github.com/jensneuse/graphql-go-tools/pkg/ast"
 	"github.com/jensneuse/graphql-go-tools/pkg/execution"
+	"github.com/jensneuse/graphql-go-tools/pkg/metrics"
 )
 
 const (
@@ -169,19 +171,42 @@ func (h *Handler) startSubscription(ctx context.Context, id string, data []byte)
 		)
 
 		h.handleError(id, "error on subscription execution")
+		return
+	}
+
+	// queries and mutations can be started as well but aren't subscriptions
+	if node.OperationType() == ast.OperationTypeSubscription {
+		h.executionHandler.Metrics().AddGauge(metrics.ActiveSubscriptions, 1)
+		defer h.executionHandler.Metrics().AddGauge(metrics.ActiveSubscriptions, -1)
 	}
 
 	executionContext.Context = ctx
+	incremental := node.Incremental()
+	if incremental && node.OperationType() != ast.OperationTypeSubscription {
+		if h.executeIncremental(id, executor, node, executionContext) {
+			h.sendComplete(id)
+		}
+		return
+	}
+
+	// subscriptions get executed on every update, subscriptions with @defer or @stream send all payloads on each update
 	buf := bytes.NewBuffer(make([]byte, 0, 1024))
-	h.executeSubscription(buf, id, executor, node, executionContext)
+	execute := func() {
+		if incremental {
+			h.executeIncremental(id, executor, node, executionContext)
+			return
+		}
+		buf.Reset()
+		h.executeSubscription(buf, id, executor, node, executionContext)
+	}
+	execute()
 
 	for {
-		buf.Reset()
 		select {
 		case <-ctx.Done():
 			return
 		case <-time.After(h.subscriptionUpdateInterval):
-			h.executeSubscription(buf, id, executor, node, executionContext)
+			execute()
 		}
 	}
 
@@ -206,6 +231,35 @@ func (h *Handler) executeSubscription(buf *bytes.Buffer, id string, executor *ex
 	h.sendData(id, buf.Bytes())
 }
 
+// executeIncremental will execute an operation containing @defer or @stream
+// and send the initial payload and all patches as data messages, it reports if the execution succeeded.
+func (h *Handler) executeIncremental(id string, executor *execution.Executor, node execution.RootNode, ctx execution.Context) bool {
+	err := executor.ExecuteIncremental(ctx, node, &dataMessageWriter{handler: h, id: id})
+	if err != nil {
+		h.logger.Error("subscription.Handle.executeIncremental()",
+			abstractlogger.Error(err),
+		)
+
+		h.handleError(id, "error on subscription execution")
+		return false
+	}
+
+	return true
+}
+
+// dataMessageWriter sends each payload of an incremental execution as a data message.
+type dataMessageWriter struct {
+	handler *Handler
+	id      string
+}
+
+func (d *dataMessageWriter) WritePayload(payload []byte, hasNext bool) error {
+	responseData := make([]byte, len(payload)) // the executor reuses the payload buffer
+	copy(responseData, payload)
+	d.handler.sendData(d.id, responseData)
+	return nil
+}
+
 // handleStop will handle a stop message,
 func (h *Handler) handleStop(id string) {
 	h.subCancellations.Cancel(id)
@@ -227,7 +281,6 @@ func (h *Handler) sendData(id string, responseData []byte) {
 	}
 }
 
-//nolint
 // sendComplete will send a complete message to the client.
 func (h *Handler) sendComplete(id string) {
 	completeMessage := Message{
@@ -10,6 +10,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/jensneuse/graphql-go-tools/pkg/execution"
+	"github.com/jensneuse/graphql-go-tools/pkg/execution/datasource"
 	"github.com/jensneuse/graphql-go-tools/pkg/starwars"
 )
 
@@ -134,6 +136,60 @@ func TestHandler_Handle(t *testing.T) {
 		})
 	})
 
+	t.Run("incremental delivery", func(t *testing.T) {
+		client := newMockClient()
+		subscriptionHandler, err := NewHandler(abstractlogger.NoopLogger, client, incrementalExecutionHandler(t))
+		require.NoError(t, err)
+
+		startSubscription := func(query string, timeout time.Duration) []Message {
+			client.resetReceivedMessages()
+			payload := starwars.RequestBody(t, query, nil)
+
+			ctx, cancelFunc := context.WithTimeout(context.Background(), timeout)
+			defer cancelFunc()
+
+			done := make(chan struct{})
+			go func() {
+				subscriptionHandler.startSubscription(ctx, "1", payload)
+				close(done)
+			}()
+			<-done
+
+			return client.readFromServer()
+		}
+
+		t.Run("should keep updating a subscription with @defer", func(t *testing.T) {
+			subscriptionHandler.ChangeSubscriptionUpdateInterval(5 * time.Millisecond)
+
+			messagesFromServer := startSubscription(`subscription { counter { value ... @defer(label: "details") { label } } }`, 50*time.Millisecond)
+
+			initialPayload := Message{Id: "1", Type: MessageTypeData, Payload: []byte(`{"data":{"counter":{"value":1}},"hasNext":true}`)}
+			patch := Message{Id: "1", Type: MessageTypeData, Payload: []byte(`{"data":{"label":"one"},"path":["counter"],"label":"details","hasNext":false}`)}
+
+			updates := 0
+			for i, message := range messagesFromServer {
+				assert.NotEqual(t, MessageTypeComplete, message.Type)
+				if i%2 == 0 {
+					assert.Equal(t, initialPayload, message)
+					updates++
+				} else {
+					assert.Equal(t, patch, message)
+				}
+			}
+			assert.True(t, updates > 1, "want more than one update, got: %d", updates)
+		})
+
+		t.Run("should complete a query with @defer after one execution", func(t *testing.T) {
+			messagesFromServer := startSubscription(`query { counter { value ... @defer(label: "details") { label } } }`, time.Second)
+
+			assert.Equal(t, []Message{
+				{Id: "1", Type: MessageTypeData, Payload: []byte(`{"data":{"counter":{"value":1}},"hasNext":true}`)},
+				{Id: "1", Type: MessageTypeData, Payload: []byte(`{"data":{"label":"one"},"path":["counter"],"label":"details","hasNext":false}`)},
+				{Id: "1", Type: MessageTypeComplete},
+			}, messagesFromServer)
+		})
+	})
+
 	t.Run("connection_terminate", func(t *testing.T) {
 		_, client, handlerRoutine := setupSubscriptionHandlerTest(t)
 
@@ -187,6 +243,48 @@ func setupSubscriptionHandlerTest(t *testing.T) (subscriptionHandler *Handler, c
 	return subscriptionHandler, client, routine
 }
 
+func incrementalExecutionHandler(t *testing.T) *execution.Handler {
+	staticDataSource := datasource.SourceConfig{
+		Name:   "StaticDataSource",
+		Config: json.RawMessage(`{"data":"{\"value\":1,\"label\":\"one\"}"}`),
+	}
+
+	base, err := datasource.NewBaseDataSourcePlanner([]byte(`
+		schema {
+			query: Query
+			subscription: Subscription
+		}
+		type Query {
+			counter: Counter
+		}
+		type Subscription {
+			counter: Counter
+		}
+		type Counter {
+			value: Int
+			label: String
+		}`), datasource.PlannerConfiguration{
+		TypeFieldConfigurations: []datasource.TypeFieldConfiguration{
+			{
+				TypeName:   "query",
+				FieldName:  "counter",
+				Mapping:    &datasource.MappingConfiguration{Disabled: true},
+				DataSource: staticDataSource,
+			},
+			{
+				TypeName:   "subscription",
+				FieldName:  "counter",
+				Mapping:    &datasource.MappingConfiguration{Disabled: true},
+				DataSource: staticDataSource,
+			},
+		},
+	}, abstractlogger.NoopLogger)
+	require.NoError(t, err)
+	require.NoError(t, base.RegisterDataSourcePlannerFactory("StaticDataSource", datasource.StaticDataSourcePlannerFactoryFactory{}))
+
+	return execution.NewHandler(base, nil)
+}
+
 func jsonizePayload(t *testing.T, payload interface{}) json.RawMessage {
 	jsonBytes, err := json.Marshal(payload)
 	require.NoError(t, err)